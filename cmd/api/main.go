@@ -1,8 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -17,12 +21,15 @@ import (
 	"github.com/pavelc4/pixtify/internal/repository"
 	"github.com/pavelc4/pixtify/internal/repository/postgres"
 	"github.com/pavelc4/pixtify/internal/repository/postgres/collection"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/job"
 	"github.com/pavelc4/pixtify/internal/repository/postgres/like"
 	"github.com/pavelc4/pixtify/internal/repository/postgres/tag"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/upload"
 	"github.com/pavelc4/pixtify/internal/repository/postgres/user"
 	"github.com/pavelc4/pixtify/internal/repository/postgres/wallpaper"
 	"github.com/pavelc4/pixtify/internal/service"
 	"github.com/pavelc4/pixtify/internal/storage"
+	"github.com/pavelc4/pixtify/internal/worker"
 )
 
 func main() {
@@ -72,38 +79,168 @@ func main() {
 	log.Println("User handlers initialized")
 
 	// Wallpaper System
-	imageProcessor := processor.NewImageProcessor()
-
-	// MinIO
-	minioStorage, err := storage.NewMinIOStorage(
-		cfg.Storage.Endpoint,
-		cfg.Storage.AccessKey,
-		cfg.Storage.SecretKey,
-		cfg.Storage.CDNURL,
-		cfg.Storage.UseSSL,
-	)
-	if err != nil {
-		log.Printf("Warning: Failed to initialize MinIO storage: %v. Wallpaper uploads will fail.", err)
-	} else {
-		log.Println("R2 Storage connected (buckets managed via Cloudflare Dashboard)")
+	imageProcessor := processor.NewImageProcessor(cfg.Image)
+
+	// Object storage: MinIO/R2, or the local filesystem for single-box deployments
+	var fileStorage storage.Service
+	var fileHandler *handler.FileHandler
+	switch cfg.Storage.Provider {
+	case "local":
+		localStorage, err := storage.NewLocalStorage(cfg.Storage.LocalPath, cfg.Storage.LocalBaseURL, cfg.Storage.SigningSecret)
+		if err != nil {
+			log.Fatal("Failed to initialize local storage:", err)
+		}
+		publicBuckets, privateBuckets := []string{cfg.Storage.BucketOriginals, cfg.Storage.BucketThumbnails}, []string(nil)
+		if cfg.Storage.PrivateOriginals {
+			publicBuckets, privateBuckets = []string{cfg.Storage.BucketThumbnails}, []string{cfg.Storage.BucketOriginals}
+		}
+		if err := localStorage.InitializeBuckets(context.Background(), publicBuckets, privateBuckets); err != nil {
+			log.Fatal("Failed to initialize local storage:", err)
+		}
+		fileStorage = localStorage
+		fileHandler = handler.NewFileHandler(localStorage, imageProcessor.MaxSizeBytes())
+		log.Printf("Local storage at %s, served from %s", cfg.Storage.LocalPath, cfg.Storage.LocalBaseURL)
+	default:
+		minioStorage, err := storage.NewMinIOStorage(
+			cfg.Storage.Endpoint,
+			cfg.Storage.AccessKey,
+			cfg.Storage.SecretKey,
+			cfg.Storage.CDNURL,
+			cfg.Storage.UseSSL,
+		)
+		if err != nil {
+			log.Printf("Warning: Failed to initialize MinIO storage: %v. Wallpaper uploads will fail.", err)
+		} else {
+			fileStorage = minioStorage
+			log.Println("R2 Storage connected (buckets managed via Cloudflare Dashboard)")
+
+			// Originals must not stay publicly readable once they are only linked through signed URLs
+			if cfg.Storage.PrivateOriginals {
+				if err := minioStorage.InitializeBuckets(context.Background(), []string{cfg.Storage.BucketThumbnails}, []string{cfg.Storage.BucketOriginals}); err != nil {
+					log.Fatal("Failed to make the originals bucket private:", err)
+				}
+			}
+		}
+	}
+
+	// Private originals are only linked through expiring signed URLs, thumbnails stay public
+	var originalLinks *service.OriginalLinks
+	if cfg.Storage.PrivateOriginals && fileStorage != nil {
+		var signer storage.URLSigner = storage.NewPresignSigner(fileStorage)
+		if cfg.Storage.CDNSigningSecret != "" {
+			cdnSigner, err := storage.NewCDNSigner(cfg.Storage.OriginalsCDNURL, cfg.Storage.CDNSigningSecret)
+			if err != nil {
+				log.Fatal("Failed to initialize CDN URL signing:", err)
+			}
+			signer = cdnSigner
+		}
+		ttl := mustParseDuration("STORAGE_ORIGINAL_URL_TTL", cfg.Storage.OriginalURLTTL)
+		originalLinks = service.NewOriginalLinks(signer, cfg.Storage.BucketOriginals, ttl)
+		log.Printf("Originals in %s are private, linked with URLs valid for %s", cfg.Storage.BucketOriginals, ttl)
 	}
 
 	wallpaperRepo := wallpaper.NewRepository(db)
+	jobRepo := job.NewRepository(db)
+
+	// Legacy rows keep originals under a UUID that isn't theirs, objects are only ever found through stored keys
+	if misplaced, missing, err := wallpaperRepo.CountMisplacedOriginals(context.Background()); err != nil {
+		log.Printf("Warning: failed to check original keys: %v", err)
+	} else if misplaced > 0 || missing > 0 {
+		log.Printf("Warning: %d wallpapers store their original outside their ID prefix, %d have no original_key (their files can't be processed, purged or protected from the orphan GC)", misplaced, missing)
+	}
+
 	wallpaperService := service.NewWallpaperService(
 		wallpaperRepo,
-		minioStorage,
+		jobRepo,
+		fileStorage,
 		imageProcessor,
 		cfg.Storage.BucketOriginals,
 		cfg.Storage.BucketThumbnails,
+		cfg.Worker.ProcessingMaxAttempts,
+		cfg.Duplicates,
+		service.UploadOptions{
+			MaxConcurrent: cfg.Upload.MaxConcurrent,
+			QueueTimeout:  mustParseDuration("UPLOAD_QUEUE_TIMEOUT", cfg.Upload.QueueTimeout),
+			TempDir:       cfg.Upload.TempDir,
+			PresignExpiry: mustParseDuration("UPLOAD_PRESIGN_EXPIRY", cfg.Upload.PresignExpiry),
+		},
+		originalLinks,
 	)
 
+	// Background image processing (thumbnails, renditions, blurhash)
+	processingWorker := worker.NewProcessingWorker(jobRepo, wallpaperService, worker.ProcessingConfig{
+		Concurrency:  cfg.Worker.ProcessingWorkers,
+		PollInterval: mustParseDuration("PROCESSING_POLL_INTERVAL", cfg.Worker.ProcessingPollInterval),
+		BaseBackoff:  mustParseDuration("PROCESSING_BASE_BACKOFF", cfg.Worker.ProcessingBaseBackoff),
+		MaxBackoff:   mustParseDuration("PROCESSING_MAX_BACKOFF", cfg.Worker.ProcessingMaxBackoff),
+		LockTimeout:  mustParseDuration("PROCESSING_LOCK_TIMEOUT", cfg.Worker.ProcessingLockTimeout),
+	})
+	// Workers stop on shutdown, after the server stopped taking requests (see the end of main)
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var workers []interface{ Wait() }
+	processingWorker.Start(workerCtx)
+	workers = append(workers, processingWorker)
+	log.Printf("Processing workers started (%d)", cfg.Worker.ProcessingWorkers)
+
+	// Resumable uploads (tus), chunks live in the originals bucket until finalized
+	uploadRepo := upload.NewRepository(db)
+	uploadService := service.NewUploadService(
+		uploadRepo,
+		wallpaperService,
+		fileStorage,
+		cfg.Storage.BucketOriginals,
+		imageProcessor.MaxSizeBytes(),
+		mustParseDuration("UPLOAD_RESUMABLE_EXPIRY", cfg.Upload.ResumableExpiry),
+		cfg.Upload.TempDir,
+	)
+	uploadHandler := handler.NewUploadHandler(uploadService)
+	uploadExpiryWorker := worker.NewUploadExpiryWorker(
+		uploadService,
+		mustParseDuration("UPLOAD_EXPIRY_INTERVAL", cfg.Upload.ExpiryInterval),
+	)
+	uploadExpiryWorker.Start(workerCtx)
+	workers = append(workers, uploadExpiryWorker)
+
+	// Orphaned object GC, removes files of failed uploads and long-deleted wallpapers
+	if gcInterval := mustParseDuration("GC_INTERVAL", cfg.GC.Interval); gcInterval > 0 && fileStorage != nil {
+		orphanGCService := service.NewOrphanGCService(
+			wallpaperRepo,
+			uploadRepo,
+			fileStorage,
+			[]string{cfg.Storage.BucketOriginals, cfg.Storage.BucketThumbnails},
+			service.OrphanGCOptions{
+				MinAge:           mustParseDuration("GC_MIN_AGE", cfg.GC.MinAge),
+				DeletedRetention: mustParseDuration("GC_DELETED_RETENTION", cfg.GC.DeletedRetention),
+			},
+		)
+		orphanGCWorker := worker.NewOrphanGCWorker(orphanGCService, gcInterval, cfg.GC.DryRun)
+		orphanGCWorker.Start(workerCtx)
+		workers = append(workers, orphanGCWorker)
+		log.Printf("Orphan GC every %s (dry run: %t)", gcInterval, cfg.GC.DryRun)
+	}
+
+	// Hard purge of soft-deleted wallpapers once their retention is over
+	if purgeInterval := mustParseDuration("PURGE_INTERVAL", cfg.Purge.Interval); purgeInterval > 0 {
+		purgeService := service.NewPurgeService(
+			wallpaperRepo,
+			fileStorage,
+			[]string{cfg.Storage.BucketOriginals, cfg.Storage.BucketThumbnails},
+			mustParseDuration("PURGE_RETENTION", cfg.Purge.Retention),
+		)
+		purgeWorker := worker.NewPurgeWorker(purgeService, purgeInterval)
+		purgeWorker.Start(workerCtx)
+		workers = append(workers, purgeWorker)
+		log.Printf("Purging wallpapers deleted more than %s ago every %s", cfg.Purge.Retention, purgeInterval)
+	}
+
 	// Like system
 	likeRepo := like.NewRepository(db)
-	likeService := service.NewLikeService(likeRepo, wallpaperRepo)
+	likeService := service.NewLikeService(likeRepo, wallpaperRepo, originalLinks)
 
 	// Collection system
 	collectionRepo := collection.NewRepository(db)
-	collectionService := service.NewCollectionService(collectionRepo, wallpaperRepo)
+	collectionService := service.NewCollectionService(collectionRepo, wallpaperRepo, originalLinks)
 
 	// Report system (needs wallpaperService for fetching wallpaper data)
 	reportRepo := repository.NewReportRepository(db)
@@ -111,7 +248,28 @@ func main() {
 	reportHandler := handler.NewReportHandler(reportService, userService, wallpaperService)
 	log.Println("Report handlers initialized")
 
-	wallpaperHandler := handler.NewWallpaperHandler(wallpaperService, likeService)
+	// On-demand resizing at /img/:id/:params, cached next to the thumbnails
+	imageService := service.NewImageService(
+		wallpaperRepo,
+		fileStorage,
+		imageProcessor,
+		cfg.Storage.BucketOriginals,
+		cfg.Storage.BucketThumbnails,
+		cfg.Image.ResizeSecret,
+		cfg.Image.ResizeConcurrency,
+	)
+	imageHandler := handler.NewImageHandler(imageService)
+
+	// Trash, deleted wallpapers can be restored until they are purged
+	trashService := service.NewTrashService(wallpaperRepo, mustParseDuration("PURGE_RETENTION", cfg.Purge.Retention))
+
+	// View and download counts, buffered and flushed in batches
+	counterService := service.NewCounterService(wallpaperRepo, mustParseDuration("COUNTER_DEDUPE_WINDOW", cfg.Counters.DedupeWindow))
+	counterFlushWorker := worker.NewCounterFlushWorker(counterService, mustParseDuration("COUNTER_FLUSH_INTERVAL", cfg.Counters.FlushInterval))
+	counterFlushWorker.Start(workerCtx)
+	workers = append(workers, counterFlushWorker) // flushes what is still buffered when the workers stop
+
+	wallpaperHandler := handler.NewWallpaperHandler(wallpaperService, likeService, trashService, counterService)
 	collectionHandler := handler.NewCollectionHandler(collectionService)
 	log.Println("Wallpaper system initialized")
 
@@ -131,6 +289,7 @@ func main() {
 		ServerHeader:          "Pixtify",
 		ErrorHandler:          customErrorHandler,
 		BodyLimit:             100 * 1024 * 1024,
+		StreamRequestBody:     true, // multipart files over 16MB are spooled to disk instead of buffered
 		DisableStartupMessage: true, // Disable ASCII banner
 	})
 
@@ -143,15 +302,16 @@ func main() {
 	}))
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     "http://localhost:3000,http://localhost:5173",
-		AllowMethods:     "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders:     "Origin,Content-Type,Accept,Authorization",
+		AllowMethods:     "GET,HEAD,POST,PUT,PATCH,DELETE,OPTIONS",
+		AllowHeaders:     "Origin,Content-Type,Accept,Authorization,Tus-Resumable,Upload-Length,Upload-Offset,Upload-Metadata,Upload-Defer-Length",
+		ExposeHeaders:    "Location,Tus-Resumable,Tus-Version,Tus-Extension,Tus-Max-Size,Upload-Offset,Upload-Length,Upload-Expires,Upload-Metadata,Upload-Wallpaper-Id",
 		AllowCredentials: true,
 	}))
 
 	healthHandler := &handler.HealthHandler{
 		StartTime: startTime,
 		DB:        db,
-		Storage:   minioStorage,
+		Storage:   fileStorage,
 	}
 
 	handler.SetupRoutes(
@@ -162,6 +322,9 @@ func main() {
 		wallpaperHandler,
 		collectionHandler,
 		tagHandler,
+		uploadHandler,
+		fileHandler,
+		imageHandler,
 		healthHandler,
 		jwtMiddleware,
 		rateLimiter,
@@ -179,10 +342,42 @@ func main() {
 	log.Printf("  - Register: %d req/%s", rateLimitConfig.RegisterMax, rateLimitConfig.RegisterWindow)
 	log.Printf("  - OAuth: %d req/%s", rateLimitConfig.OAuthMax, rateLimitConfig.OAuthWindow)
 	log.Printf("  - API: %d req/%s", rateLimitConfig.APIMax, rateLimitConfig.APIWindow)
+	log.Printf("  - Image URLs: %d req/%s", rateLimitConfig.ImageSignMax, rateLimitConfig.ImageSignWindow)
 
-	if err := app.Listen(port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	go func() {
+		if err := app.Listen(port); err != nil {
+			log.Fatal("Failed to start server:", err)
+		}
+	}()
+
+	// On SIGINT/SIGTERM stop taking requests, then let the workers finish what they are doing
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+	log.Println("Shutting down...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := app.ShutdownWithContext(shutdownCtx); err != nil {
+		log.Printf("Server shutdown: %v", err)
+	}
+
+	stopWorkers()
+	for _, w := range workers {
+		w.Wait()
+	}
+	log.Println("Server stopped")
+}
+
+// How long in-flight requests get to finish on shutdown
+const shutdownTimeout = 30 * time.Second
+
+func mustParseDuration(name, value string) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", name, err)
 	}
+	return d
 }
 
 func customErrorHandler(c *fiber.Ctx, err error) error {
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/pavelc4/pixtify/internal/config"
+	"github.com/pavelc4/pixtify/internal/processor"
+	"github.com/pavelc4/pixtify/internal/repository/postgres"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/wallpaper"
+)
+
+// Computes blurhash placeholders for wallpapers uploaded before they were generated on upload.
+// Hashes are derived from the stored thumbnails, so originals never have to be downloaded.
+func main() {
+	batchSize := flag.Int("batch", 100, "number of wallpapers fetched per query")
+	dryRun := flag.Bool("dry-run", false, "compute hashes without saving them")
+	flag.Parse()
+
+	cfg := config.Load()
+
+	db, err := postgres.NewPostgresDB(cfg.Database.GetDSN())
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	defer db.Close()
+
+	repo := wallpaper.NewRepository(db)
+	imageProcessor := processor.NewImageProcessor(cfg.Image)
+	client := &http.Client{Timeout: 30 * time.Second}
+	ctx := context.Background()
+
+	var updated, failed int
+	lastID := uuid.Nil
+
+	for {
+		wallpapers, err := repo.ListMissingBlurhash(ctx, lastID, *batchSize)
+		if err != nil {
+			log.Fatal("Failed to list wallpapers:", err)
+		}
+		if len(wallpapers) == 0 {
+			break
+		}
+
+		for _, wp := range wallpapers {
+			lastID = wp.ID
+
+			hash, err := blurhashFromURL(client, imageProcessor, wp.ThumbnailURL)
+			if err != nil {
+				log.Printf("Skipping %s: %v", wp.ID, err)
+				failed++
+				continue
+			}
+
+			if !*dryRun {
+				if err := repo.UpdateBlurhash(ctx, wp.ID, hash); err != nil {
+					log.Printf("Failed to save blurhash for %s: %v", wp.ID, err)
+					failed++
+					continue
+				}
+			}
+
+			log.Printf("%s -> %s", wp.ID, hash)
+			updated++
+		}
+	}
+
+	log.Printf("Backfill finished: %d updated, %d failed (dry run: %t)", updated, failed, *dryRun)
+}
+
+func blurhashFromURL(client *http.Client, p *processor.ImageProcessor, url string) (string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch thumbnail: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch thumbnail: status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read thumbnail: %w", err)
+	}
+
+	img, err := p.Decode(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode thumbnail: %w", err)
+	}
+	return p.GenerateBlurhash(img)
+}
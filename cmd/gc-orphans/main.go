@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/pavelc4/pixtify/internal/config"
+	"github.com/pavelc4/pixtify/internal/repository/postgres"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/upload"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/wallpaper"
+	"github.com/pavelc4/pixtify/internal/service"
+	"github.com/pavelc4/pixtify/internal/storage"
+)
+
+// Finds objects in the buckets that no wallpaper or resumable upload refers to and deletes them,
+// the same job the API runs every GC_INTERVAL. Use -dry-run to only report them.
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report orphaned objects without deleting them")
+	minAge := flag.Duration("min-age", 0, "leave objects younger than this alone (default GC_MIN_AGE)")
+	retention := flag.Duration("retention", 0, "keep objects of soft-deleted wallpapers this long (default GC_DELETED_RETENTION)")
+	asJSON := flag.Bool("json", false, "print the full report as JSON")
+	flag.Parse()
+
+	cfg := config.Load()
+
+	db, err := postgres.NewPostgresDB(cfg.Database.GetDSN())
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	defer db.Close()
+
+	var fileStorage storage.Service
+	switch cfg.Storage.Provider {
+	case "local":
+		fileStorage, err = storage.NewLocalStorage(cfg.Storage.LocalPath, cfg.Storage.LocalBaseURL, cfg.Storage.SigningSecret)
+	default:
+		fileStorage, err = storage.NewMinIOStorage(
+			cfg.Storage.Endpoint,
+			cfg.Storage.AccessKey,
+			cfg.Storage.SecretKey,
+			cfg.Storage.CDNURL,
+			cfg.Storage.UseSSL,
+		)
+	}
+	if err != nil {
+		log.Fatal("Failed to initialize storage:", err)
+	}
+
+	opts := service.OrphanGCOptions{MinAge: *minAge, DeletedRetention: *retention}
+	if opts.MinAge == 0 {
+		opts.MinAge = parseDuration("GC_MIN_AGE", cfg.GC.MinAge)
+	}
+	if opts.DeletedRetention == 0 {
+		opts.DeletedRetention = parseDuration("GC_DELETED_RETENTION", cfg.GC.DeletedRetention)
+	}
+
+	gc := service.NewOrphanGCService(
+		wallpaper.NewRepository(db),
+		upload.NewRepository(db),
+		fileStorage,
+		[]string{cfg.Storage.BucketOriginals, cfg.Storage.BucketThumbnails},
+		opts,
+	)
+
+	report, err := gc.CollectOrphans(context.Background(), *dryRun)
+	if err != nil {
+		log.Printf("Orphan GC stopped: %v", err)
+	}
+
+	if *asJSON && report != nil {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			log.Fatal("Failed to write report:", err)
+		}
+	} else if report != nil {
+		for _, o := range report.Orphans {
+			log.Printf("%s/%s (%d bytes, %s) deleted: %t", o.Bucket, o.Key, o.Size, o.Reason, o.Deleted)
+		}
+		if report.Truncated {
+			log.Printf("... more orphans not listed")
+		}
+		log.Printf("Orphan GC finished: %s", report.Summary())
+	}
+
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+func parseDuration(name, value string) time.Duration {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", name, err)
+	}
+	return d
+}
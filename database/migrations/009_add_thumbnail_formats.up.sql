@@ -0,0 +1 @@
+ALTER TABLE wallpapers ADD COLUMN IF NOT EXISTS thumbnail_formats TEXT[] NOT NULL DEFAULT '{jpeg}';
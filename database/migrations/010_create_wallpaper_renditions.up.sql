@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS wallpaper_renditions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    wallpaper_id UUID NOT NULL REFERENCES wallpapers(id) ON DELETE CASCADE,
+    name VARCHAR(50) NOT NULL,
+    format VARCHAR(10) NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    file_size_bytes BIGINT NOT NULL,
+    storage_key TEXT NOT NULL,
+    url TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    UNIQUE(wallpaper_id, name, format)
+);
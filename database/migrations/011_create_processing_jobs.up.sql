@@ -0,0 +1,30 @@
+BEGIN;
+
+ALTER TABLE wallpapers ADD COLUMN IF NOT EXISTS original_key TEXT;
+
+-- Existing originals were uploaded as <uuid>/<slug>.<ext>. The uuid is NOT the wallpaper id,
+-- the row got its id from the database, so only the path is copied and nothing may assume
+-- original_key starts with id (the API logs how many rows don't at startup).
+UPDATE wallpapers
+SET original_key = substring(original_url from '([0-9a-fA-F-]{36}/[^/]+)$')
+WHERE original_key IS NULL;
+
+CREATE TABLE IF NOT EXISTS processing_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    wallpaper_id UUID NOT NULL REFERENCES wallpapers(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'completed', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 5,
+    last_error TEXT,
+    run_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    locked_at TIMESTAMP WITH TIME ZONE,
+    completed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_processing_jobs_ready ON processing_jobs(run_at) WHERE status = 'pending';
+CREATE INDEX idx_processing_jobs_running ON processing_jobs(locked_at) WHERE status = 'running';
+CREATE INDEX idx_processing_jobs_wallpaper ON processing_jobs(wallpaper_id, created_at DESC);
+
+COMMIT;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS wallpaper_metadata (
+    wallpaper_id UUID PRIMARY KEY REFERENCES wallpapers(id) ON DELETE CASCADE,
+    camera_make VARCHAR(100),
+    camera_model VARCHAR(100),
+    lens_model VARCHAR(100),
+    focal_length_mm NUMERIC(7, 2),
+    aperture NUMERIC(5, 2),
+    iso INTEGER,
+    exposure_time VARCHAR(20),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
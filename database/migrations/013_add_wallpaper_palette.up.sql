@@ -0,0 +1,2 @@
+-- Dominant colours as [{"hex": "#1e3a8a", "weight": 0.42, "l": 27.1, "a": 20.0, "b": -47.6}, ...]
+ALTER TABLE wallpapers ADD COLUMN IF NOT EXISTS palette JSONB NOT NULL DEFAULT '[]';
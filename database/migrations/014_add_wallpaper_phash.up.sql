@@ -0,0 +1,19 @@
+BEGIN;
+
+-- 64-bit DCT perceptual hash, near-duplicates are found by Hamming distance (bit_count of the XOR)
+ALTER TABLE wallpapers ADD COLUMN IF NOT EXISTS phash BIGINT;
+CREATE INDEX IF NOT EXISTS idx_wallpapers_phash ON wallpapers(phash) WHERE deleted_at IS NULL AND phash IS NOT NULL;
+
+-- Uploads flagged as likely reposts of an existing wallpaper, reviewed by moderators
+CREATE TABLE IF NOT EXISTS wallpaper_duplicates (
+    wallpaper_id UUID NOT NULL REFERENCES wallpapers(id) ON DELETE CASCADE,
+    duplicate_of UUID NOT NULL REFERENCES wallpapers(id) ON DELETE CASCADE,
+    distance INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    PRIMARY KEY (wallpaper_id, duplicate_of)
+);
+
+CREATE INDEX idx_wallpaper_duplicates_original ON wallpaper_duplicates(duplicate_of);
+
+COMMIT;
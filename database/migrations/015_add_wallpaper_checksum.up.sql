@@ -0,0 +1,7 @@
+BEGIN;
+
+-- SHA-256 of the stored original, computed while the upload is spooled to disk
+ALTER TABLE wallpapers ADD COLUMN IF NOT EXISTS checksum_sha256 CHAR(64);
+CREATE INDEX IF NOT EXISTS idx_wallpapers_checksum_sha256 ON wallpapers(checksum_sha256) WHERE checksum_sha256 IS NOT NULL;
+
+COMMIT;
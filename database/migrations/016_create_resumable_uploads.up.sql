@@ -0,0 +1,24 @@
+BEGIN;
+
+-- tus resumable uploads, each PATCH is stored as its own object listed in chunk_keys
+CREATE TABLE IF NOT EXISTS resumable_uploads (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    upload_length BIGINT NOT NULL CHECK (upload_length > 0),
+    upload_offset BIGINT NOT NULL DEFAULT 0,
+    metadata JSONB NOT NULL DEFAULT '{}',
+    chunk_keys TEXT[] NOT NULL DEFAULT '{}',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'finalizing', 'completed', 'failed', 'expired')),
+    wallpaper_id UUID REFERENCES wallpapers(id) ON DELETE SET NULL,
+    last_error TEXT,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    CHECK (upload_offset <= upload_length)
+);
+
+CREATE INDEX idx_resumable_uploads_user ON resumable_uploads(user_id, created_at DESC);
+CREATE INDEX idx_resumable_uploads_expiry ON resumable_uploads(expires_at) WHERE status IN ('pending', 'finalizing');
+
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+
+-- Soft-deleted wallpapers are purged oldest first once their retention is over
+CREATE INDEX IF NOT EXISTS idx_wallpapers_purge ON wallpapers(deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- Tags attached on upload never bumped wallpaper_count, recount before the purge starts decrementing it
+UPDATE tags t SET wallpaper_count = (
+    SELECT COUNT(*) FROM wallpaper_tags wt WHERE wt.tag_id = t.id
+);
+
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+
+-- Optional point of interest set by the uploader, relative to the image size.
+-- Device crops keep it in frame, without one they follow the most detailed part of the image.
+ALTER TABLE wallpapers ADD COLUMN IF NOT EXISTS focal_x REAL CHECK (focal_x BETWEEN 0 AND 1);
+ALTER TABLE wallpapers ADD COLUMN IF NOT EXISTS focal_y REAL CHECK (focal_y BETWEEN 0 AND 1);
+
+-- Phone, tablet and ultrawide crops are stored as renditions cut to the device aspect ratio
+ALTER TABLE wallpaper_renditions ADD COLUMN IF NOT EXISTS cropped BOOLEAN NOT NULL DEFAULT FALSE;
+
+COMMIT;
@@ -0,0 +1,24 @@
+BEGIN;
+
+-- Tag names copied onto the wallpaper so they can be part of the generated search vector,
+-- the repositories refresh them whenever tags are attached or deleted
+ALTER TABLE wallpapers ADD COLUMN IF NOT EXISTS search_tags TEXT NOT NULL DEFAULT '';
+
+UPDATE wallpapers w
+SET search_tags = COALESCE((
+    SELECT string_agg(t.name, ' ' ORDER BY t.name)
+    FROM wallpaper_tags wt
+    INNER JOIN tags t ON t.id = wt.tag_id
+    WHERE wt.wallpaper_id = w.id
+), '');
+
+-- Title matches rank above tag matches, which rank above description matches
+ALTER TABLE wallpapers ADD COLUMN IF NOT EXISTS search_vector TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', COALESCE(title, '')), 'A') ||
+    setweight(to_tsvector('english', search_tags), 'B') ||
+    setweight(to_tsvector('english', COALESCE(description, '')), 'C')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_wallpapers_search ON wallpapers USING GIN (search_vector);
+
+COMMIT;
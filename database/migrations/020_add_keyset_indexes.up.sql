@@ -0,0 +1,11 @@
+BEGIN;
+
+-- Keyset pagination walks these in order from the cursor instead of counting and skipping rows
+CREATE INDEX IF NOT EXISTS idx_wallpapers_listing ON wallpapers(created_at DESC, id DESC)
+    WHERE deleted_at IS NULL AND status = 'active';
+CREATE INDEX IF NOT EXISTS idx_wallpapers_popular ON wallpapers(like_count DESC, created_at DESC, id DESC)
+    WHERE deleted_at IS NULL AND status = 'active';
+CREATE INDEX IF NOT EXISTS idx_likes_user_feed ON likes(user_id, created_at DESC, wallpaper_id DESC);
+CREATE INDEX IF NOT EXISTS idx_collection_items_feed ON collection_items(collection_id, added_at DESC, wallpaper_id DESC);
+
+COMMIT;
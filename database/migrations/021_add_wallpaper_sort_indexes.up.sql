@@ -0,0 +1,10 @@
+BEGIN;
+
+-- Most downloaded and most viewed listings, walked from the cursor like idx_wallpapers_popular.
+-- Oldest first scans idx_wallpapers_listing backwards, random orders can't use an index.
+CREATE INDEX IF NOT EXISTS idx_wallpapers_downloads ON wallpapers(download_count DESC, created_at DESC, id DESC)
+    WHERE deleted_at IS NULL AND status = 'active';
+CREATE INDEX IF NOT EXISTS idx_wallpapers_views ON wallpapers(view_count DESC, created_at DESC, id DESC)
+    WHERE deleted_at IS NULL AND status = 'active';
+
+COMMIT;
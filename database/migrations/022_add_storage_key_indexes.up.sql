@@ -0,0 +1,8 @@
+BEGIN;
+
+-- The orphan GC and the purge look wallpapers up by the first segment of their object keys.
+-- Originals uploaded before the API assigned wallpaper IDs don't live under the wallpaper ID.
+CREATE INDEX IF NOT EXISTS idx_wallpapers_original_key_prefix ON wallpapers(split_part(original_key, '/', 1));
+CREATE INDEX IF NOT EXISTS idx_wallpaper_renditions_key_prefix ON wallpaper_renditions(split_part(storage_key, '/', 1));
+
+COMMIT;
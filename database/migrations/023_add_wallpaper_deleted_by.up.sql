@@ -0,0 +1,8 @@
+BEGIN;
+
+-- Who moved a wallpaper to the trash. Wallpapers removed by a moderator stay out of the
+-- owner's trash and can only be restored by a moderator.
+ALTER TABLE wallpapers ADD COLUMN IF NOT EXISTS deleted_by UUID REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE wallpapers ADD COLUMN IF NOT EXISTS removed_by_moderator BOOLEAN NOT NULL DEFAULT FALSE;
+
+COMMIT;
@@ -1,9 +1,14 @@
 package config
 
 import (
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -16,6 +21,13 @@ type Config struct {
 	OAuth        OAuthConfig
 	JWT          JWTConfig
 	Storage      StorageConfig
+	Image        ImageConfig
+	Worker       WorkerConfig
+	Duplicates   DuplicateConfig
+	Upload       UploadConfig
+	GC           GCConfig
+	Purge        PurgeConfig
+	Counters     CounterConfig
 }
 
 type DatabaseConfig struct {
@@ -43,6 +55,12 @@ type JWTConfig struct {
 	RefreshExpiry string
 }
 
+// StorageConfig selects the object store. Provider is "minio" (MinIO or R2) or "local",
+// which keeps objects under LocalPath and serves them at LocalBaseURL.
+// SigningSecret signs URLs the API hands out, it defaults to a key derived from COOKIE_SECRET.
+// With PrivateOriginals the originals bucket is not publicly readable and originals are linked
+// through URLs valid for OriginalURLTTL, presigned by the store or, when CDNSigningSecret is set,
+// HMAC-signed URLs on OriginalsCDNURL (defaults to CDN_BASE_URL).
 type StorageConfig struct {
 	Provider         string
 	Endpoint         string
@@ -52,6 +70,79 @@ type StorageConfig struct {
 	BucketThumbnails string
 	UseSSL           bool
 	CDNURL           string
+	LocalPath        string
+	LocalBaseURL     string
+	SigningSecret    string
+	PrivateOriginals bool
+	OriginalURLTTL   string
+	OriginalsCDNURL  string
+	CDNSigningSecret string
+}
+
+type ImageConfig struct {
+	BlurhashXComponents int
+	BlurhashYComponents int
+	DerivativeFormats   []string
+	Renditions          []string
+	Crops               []string
+	MaxMegapixels       int
+	MinResolution       map[string]string
+	PaletteSize         int
+	ResizeSecret        string
+	ResizeConcurrency   int
+}
+
+// DuplicateConfig controls near-duplicate detection on upload.
+// Mode is "reject", "flag" (upload allowed, queued for moderators) or "off".
+type DuplicateConfig struct {
+	Mode        string
+	MaxDistance int
+}
+
+// UploadConfig bounds how many uploads are spooled and processed at once.
+// Uploads waiting longer than QueueTimeout for a slot are rejected.
+// Resumable uploads not touched for ResumableExpiry are removed every ExpiryInterval.
+// PresignExpiry is how long direct-to-bucket upload URLs stay valid.
+type UploadConfig struct {
+	MaxConcurrent   int
+	QueueTimeout    string
+	TempDir         string
+	ResumableExpiry string
+	ExpiryInterval  string
+	PresignExpiry   string
+}
+
+// GCConfig controls the orphaned object collector. It runs every Interval ("0" disables it)
+// and only reports orphans unless DryRun is turned off. Objects younger than MinAge are left alone,
+// objects of soft-deleted wallpapers are kept for DeletedRetention.
+type GCConfig struct {
+	Interval         string
+	DryRun           bool
+	MinAge           string
+	DeletedRetention string
+}
+
+// PurgeConfig controls the permanent removal of soft-deleted wallpapers. Every Interval ("0" disables it)
+// wallpapers deleted longer than Retention ago are removed together with their stored objects.
+type PurgeConfig struct {
+	Retention string
+	Interval  string
+}
+
+// CounterConfig controls view and download counting. A viewer is counted once per wallpaper
+// per DedupeWindow, buffered counts are written every FlushInterval.
+type CounterConfig struct {
+	DedupeWindow  string
+	FlushInterval string
+}
+
+type WorkerConfig struct {
+	ProcessingWorkers      int
+	ProcessingMaxAttempts  int
+	ProcessingPollInterval string
+	ProcessingBaseBackoff  string
+	ProcessingMaxBackoff   string
+	ProcessingLockTimeout  string
 }
 
 func Load() *Config {
@@ -93,13 +184,98 @@ func Load() *Config {
 			BucketThumbnails: getEnv("STORAGE_BUCKET_THUMBNAILS", "pixtify-thumbnails"),
 			UseSSL:           getEnv("STORAGE_USE_SSL", "false") == "true",
 			CDNURL:           getEnv("CDN_BASE_URL", ""),
+			LocalPath:        getEnv("STORAGE_LOCAL_PATH", "./data/storage"),
+			LocalBaseURL:     getEnv("STORAGE_LOCAL_BASE_URL", "http://localhost:8080/files"),
+			SigningSecret:    getEnv("STORAGE_SIGNING_SECRET", ""),
+			PrivateOriginals: getEnv("STORAGE_PRIVATE_ORIGINALS", "false") == "true",
+			OriginalURLTTL:   getEnv("STORAGE_ORIGINAL_URL_TTL", "1h"),
+			OriginalsCDNURL:  getEnv("ORIGINALS_CDN_URL", ""),
+			CDNSigningSecret: getEnv("CDN_SIGNING_SECRET", ""),
+		},
+		Image: ImageConfig{
+			BlurhashXComponents: getEnvInt("BLURHASH_X_COMPONENTS", 4),
+			BlurhashYComponents: getEnvInt("BLURHASH_Y_COMPONENTS", 3),
+			DerivativeFormats:   getEnvList("IMAGE_DERIVATIVE_FORMATS", "jpeg"),
+			Renditions: getEnvList("IMAGE_RENDITIONS",
+				"720p:1280x720,1080p:1920x1080,1440p:2560x1440,4k:3840x2160,"+
+					"phone-hd:720x1600,phone-fhd:1080x2400,phone-qhd:1440x3200"),
+			Crops:             getEnvList("IMAGE_CROPS", "phone:1080x2340,tablet:2048x2732,ultrawide:3440x1440"),
+			MaxMegapixels:     getEnvInt("IMAGE_MAX_MEGAPIXELS", 100),
+			PaletteSize:       getEnvInt("IMAGE_PALETTE_SIZE", 5),
+			ResizeSecret:      getEnv("IMAGE_SIGNING_SECRET", ""),
+			ResizeConcurrency: getEnvInt("IMAGE_RESIZE_CONCURRENCY", 2),
+			MinResolution: map[string]string{
+				"mobile":  getEnv("IMAGE_MIN_RESOLUTION_MOBILE", "720x1280"),
+				"desktop": getEnv("IMAGE_MIN_RESOLUTION_DESKTOP", "1280x720"),
+			},
+		},
+		Duplicates: DuplicateConfig{
+			Mode:        getEnv("DUPLICATE_MODE", "flag"),
+			MaxDistance: getEnvInt("DUPLICATE_MAX_DISTANCE", 6),
+		},
+		Upload: UploadConfig{
+			MaxConcurrent:   getEnvInt("UPLOAD_MAX_CONCURRENT", 4),
+			QueueTimeout:    getEnv("UPLOAD_QUEUE_TIMEOUT", "30s"),
+			TempDir:         getEnv("UPLOAD_TEMP_DIR", ""),
+			ResumableExpiry: getEnv("UPLOAD_RESUMABLE_EXPIRY", "24h"),
+			ExpiryInterval:  getEnv("UPLOAD_EXPIRY_INTERVAL", "15m"),
+			PresignExpiry:   getEnv("UPLOAD_PRESIGN_EXPIRY", "15m"),
+		},
+		GC: GCConfig{
+			Interval:         getEnv("GC_INTERVAL", "24h"),
+			DryRun:           getEnv("GC_DRY_RUN", "true") != "false",
+			MinAge:           getEnv("GC_MIN_AGE", "24h"),
+			DeletedRetention: getEnv("GC_DELETED_RETENTION", "720h"),
+		},
+		Purge: PurgeConfig{
+			Retention: getEnv("PURGE_RETENTION", "720h"),
+			Interval:  getEnv("PURGE_INTERVAL", "1h"),
+		},
+		Counters: CounterConfig{
+			DedupeWindow:  getEnv("COUNTER_DEDUPE_WINDOW", "30m"),
+			FlushInterval: getEnv("COUNTER_FLUSH_INTERVAL", "10s"),
 		},
+		Worker: WorkerConfig{
+			ProcessingWorkers:      getEnvInt("PROCESSING_WORKERS", 2),
+			ProcessingMaxAttempts:  getEnvInt("PROCESSING_MAX_ATTEMPTS", 5),
+			ProcessingPollInterval: getEnv("PROCESSING_POLL_INTERVAL", "2s"),
+			ProcessingBaseBackoff:  getEnv("PROCESSING_BASE_BACKOFF", "10s"),
+			ProcessingMaxBackoff:   getEnv("PROCESSING_MAX_BACKOFF", "10m"),
+			ProcessingLockTimeout:  getEnv("PROCESSING_LOCK_TIMEOUT", "15m"),
+		},
+	}
+
+	if cfg.Storage.OriginalsCDNURL == "" {
+		cfg.Storage.OriginalsCDNURL = cfg.Storage.CDNURL
 	}
 
 	validate(cfg)
+
+	// Unset signing secrets get their own key derived from COOKIE_SECRET, so a leaked
+	// URL signing key can't be used to forge session cookies or the other kind of URL
+	if cfg.Storage.SigningSecret == "" {
+		cfg.Storage.SigningSecret = deriveSecret(cfg.CookieSecret, "pixtify storage url signing")
+		log.Println("Warning: STORAGE_SIGNING_SECRET not set, using a key derived from COOKIE_SECRET")
+	}
+	if cfg.Image.ResizeSecret == "" {
+		cfg.Image.ResizeSecret = deriveSecret(cfg.CookieSecret, "pixtify image resize signing")
+		log.Println("Warning: IMAGE_SIGNING_SECRET not set, using a key derived from COOKIE_SECRET (set it to build /img URLs outside the API)")
+	}
+	if cfg.Storage.SigningSecret == cfg.CookieSecret || cfg.Image.ResizeSecret == cfg.CookieSecret || cfg.Image.ResizeSecret == cfg.Storage.SigningSecret {
+		log.Println("Warning: COOKIE_SECRET, STORAGE_SIGNING_SECRET and IMAGE_SIGNING_SECRET should all be different")
+	}
 	return cfg
 }
 
+// deriveSecret derives a separate key for one purpose from a master secret with HKDF-SHA256
+func deriveSecret(master, label string) string {
+	key, err := hkdf.Key(sha256.New, []byte(master), nil, label, 32)
+	if err != nil {
+		log.Fatalf("Failed to derive %s key: %v", label, err)
+	}
+	return hex.EncodeToString(key)
+}
+
 func validate(cfg *Config) {
 	required := map[string]string{
 		"DB_PASSWORD":        cfg.Database.Password,
@@ -127,6 +303,30 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvInt(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Warning: invalid %s=%q, using default %d", key, value, defaultValue)
+		return defaultValue
+	}
+	return n
+}
+
+func getEnvList(key, defaultValue string) []string {
+	var values []string
+	for _, v := range strings.Split(getEnv(key, defaultValue), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
 func (d *DatabaseConfig) GetDSN() string {
 	if databaseURL := os.Getenv("DATABASE_URL"); databaseURL != "" {
 		return databaseURL
@@ -15,6 +15,9 @@ type RateLimitConfig struct {
 
 	AdminMax    int
 	AdminWindow time.Duration
+
+	ImageSignMax    int
+	ImageSignWindow time.Duration
 }
 
 func DefaultRateLimitConfig() RateLimitConfig {
@@ -32,5 +35,8 @@ func DefaultRateLimitConfig() RateLimitConfig {
 
 		AdminMax:    200,
 		AdminWindow: 1 * time.Minute,
+
+		ImageSignMax:    30,
+		ImageSignWindow: 1 * time.Minute,
 	}
 }
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
@@ -160,18 +161,19 @@ func (h *CollectionHandler) GetCollectionWallpapers(c *fiber.Ctx) error {
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	limit, _ := strconv.Atoi(c.Query("limit", "20"))
 
-	wallpapers, total, err := h.collectionService.GetCollectionWallpapers(c.Context(), collectionID, userID, page, limit)
+	cursor := c.Query("cursor")
+
+	result, err := h.collectionService.GetCollectionWallpapers(c.Context(), collectionID, userID, cursor, page, limit)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			return badRequestError(c, err.Error())
+		}
 		return internalError(c, err.Error())
 	}
 
 	return c.JSON(fiber.Map{
-		"data": wallpapers,
-		"meta": fiber.Map{
-			"page":  page,
-			"limit": limit,
-			"total": total,
-		},
+		"data": result.Wallpapers,
+		"meta": pageMeta(result, cursor, page, limit),
 	})
 }
 
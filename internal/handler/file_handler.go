@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pavelc4/pixtify/internal/storage"
+)
+
+// FileHandler serves objects of the local filesystem storage backend (STORAGE_PROVIDER=local)
+type FileHandler struct {
+	storage     *storage.LocalStorage
+	maxPutBytes int64
+}
+
+func NewFileHandler(storage *storage.LocalStorage, maxPutBytes int64) *FileHandler {
+	return &FileHandler{storage: storage, maxPutBytes: maxPutBytes}
+}
+
+// GetFile handles GET /files/:bucket/*, objects of private buckets need a presigned URL
+func (h *FileHandler) GetFile(c *fiber.Ctx) error {
+	bucket, key := c.Params("bucket"), c.Params("*")
+	if h.storage.IsPrivate(bucket) && !h.storage.VerifySignature(fiber.MethodGet, bucket, key, c.Query("expires"), c.Query("signature")) {
+		return errorResponse(c, fiber.StatusForbidden, "Invalid or expired file URL")
+	}
+
+	p, err := h.storage.Path(bucket, key)
+	if err != nil {
+		return notFoundError(c, "File not found")
+	}
+	if info, err := os.Stat(p); err != nil || info.IsDir() {
+		return notFoundError(c, "File not found")
+	}
+
+	c.Set(fiber.HeaderContentType, storage.ContentTypeByKey(key))
+	return c.SendFile(p)
+}
+
+// PutFile handles PUT /files/:bucket/*, the target of presigned upload URLs
+func (h *FileHandler) PutFile(c *fiber.Ctx) error {
+	bucket, key := c.Params("bucket"), c.Params("*")
+	if !h.storage.VerifySignature(fiber.MethodPut, bucket, key, c.Query("expires"), c.Query("signature")) {
+		return errorResponse(c, fiber.StatusForbidden, "Invalid or expired upload URL")
+	}
+
+	var body io.Reader
+	if stream := c.Context().RequestBodyStream(); stream != nil {
+		body = stream
+	} else {
+		body = bytes.NewReader(c.Body())
+	}
+	body = http.MaxBytesReader(nil, io.NopCloser(body), h.maxPutBytes)
+
+	size := int64(c.Request().Header.ContentLength())
+	if size > h.maxPutBytes {
+		return errorResponse(c, fiber.StatusRequestEntityTooLarge, "File too large")
+	}
+
+	if _, err := h.storage.Upload(c.Context(), bucket, key, body, size, c.Get(fiber.HeaderContentType)); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return errorResponse(c, fiber.StatusRequestEntityTooLarge, "File too large")
+		}
+		return internalError(c, "Failed to store file")
+	}
+	return c.SendStatus(fiber.StatusOK)
+}
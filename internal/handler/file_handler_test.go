@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pavelc4/pixtify/internal/storage"
+)
+
+func TestFileHandlerSignatures(t *testing.T) {
+	ctx := context.Background()
+	files, err := storage.NewLocalStorage(t.TempDir(), "/files", "test-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := files.InitializeBuckets(ctx, []string{"thumbnails"}, []string{"originals"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, bucket := range []string{"originals", "thumbnails"} {
+		if _, err := files.Upload(ctx, bucket, "a1/image.jpg", strings.NewReader(bucket+" data"), -1, "image/jpeg"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := NewFileHandler(files, 1024)
+	app := fiber.New()
+	app.Get("/files/:bucket/*", h.GetFile)
+	app.Put("/files/:bucket/*", h.PutFile)
+
+	sign := func(method string, bucket, key string, expiry int) string {
+		var signed string
+		var err error
+		if method == fiber.MethodPut {
+			signed, err = files.GetPresignedPutURL(ctx, bucket, key, expiry)
+		} else {
+			signed, err = files.GetPresignedURL(ctx, bucket, key, expiry)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	// withQuery replaces query parameters of a signed URL
+	withQuery := func(signed string, key, value string) string {
+		u, _ := url.Parse(signed)
+		q := u.Query()
+		q.Set(key, value)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+
+	valid := sign(fiber.MethodGet, "originals", "a1/image.jpg", 60)
+	signature := mustQuery(t, valid, "signature")
+	tampered := "0" + signature[1:]
+	if tampered == signature {
+		tampered = "1" + signature[1:]
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "signed private file", method: "GET", target: valid, wantStatus: fiber.StatusOK, wantBody: "originals data"},
+		{name: "public file without a signature", method: "GET", target: "/files/thumbnails/a1/image.jpg", wantStatus: fiber.StatusOK, wantBody: "thumbnails data"},
+		{name: "private file without a signature", method: "GET", target: "/files/originals/a1/image.jpg", wantStatus: fiber.StatusForbidden},
+		{name: "expired", method: "GET", target: sign(fiber.MethodGet, "originals", "a1/image.jpg", -5), wantStatus: fiber.StatusForbidden},
+		{name: "tampered signature", method: "GET", target: withQuery(valid, "signature", tampered), wantStatus: fiber.StatusForbidden},
+		{
+			name: "extended expiry", method: "GET", wantStatus: fiber.StatusForbidden,
+			target: withQuery(valid, "expires", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)),
+		},
+		{name: "signature of another file", method: "GET", target: strings.Replace(valid, "a1/image.jpg", "a2/image.jpg", 1), wantStatus: fiber.StatusForbidden},
+		{name: "public traversal", method: "GET", target: "/files/thumbnails/a1/..%2f..%2foriginals%2fa1%2fimage.jpg", wantStatus: fiber.StatusNotFound},
+		{name: "missing public file", method: "GET", target: "/files/thumbnails/a1/missing.jpg", wantStatus: fiber.StatusNotFound},
+		{name: "signed upload", method: "PUT", target: sign(fiber.MethodPut, "originals", "a3/image.jpg", 60), body: "new data", wantStatus: fiber.StatusOK},
+		{name: "upload with a download signature", method: "PUT", target: valid, body: "overwrite", wantStatus: fiber.StatusForbidden},
+		{name: "expired upload", method: "PUT", target: sign(fiber.MethodPut, "originals", "a4/image.jpg", -5), body: "late", wantStatus: fiber.StatusForbidden},
+		{name: "upload over the limit", method: "PUT", target: sign(fiber.MethodPut, "originals", "a5/image.jpg", 60), body: strings.Repeat("x", 2048), wantStatus: fiber.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, bytes.NewReader([]byte(tt.body)))
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tt.wantStatus, body)
+			}
+			if tt.wantBody != "" && string(body) != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+
+	// The original is untouched by the rejected upload
+	rc, err := files.Download(ctx, "originals", "a1/image.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if data, _ := io.ReadAll(rc); string(data) != "originals data" {
+		t.Errorf("original = %q after a rejected upload", data)
+	}
+}
+
+func mustQuery(t *testing.T, rawURL, key string) string {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Query().Get(key)
+}
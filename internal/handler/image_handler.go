@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pavelc4/pixtify/internal/service"
+)
+
+// ImageHandler serves on-demand resized wallpapers
+type ImageHandler struct {
+	imageService *service.ImageService
+}
+
+func NewImageHandler(imageService *service.ImageService) *ImageHandler {
+	return &ImageHandler{imageService: imageService}
+}
+
+// GetImage handles GET /img/:id/:params?sig=, e.g. /img/{id}/w=2560,h=1080,fit=cover,fmt=png?sig=...
+func (h *ImageHandler) GetImage(c *fiber.Ctx) error {
+	image, err := h.imageService.Render(c.Context(), c.Params("id"), c.Params("params"), c.Query("sig"))
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrInvalidImageSignature):
+		return errorResponse(c, fiber.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrInvalidImageParams):
+		return badRequestError(c, err.Error())
+	case errors.Is(err, service.ErrWallpaperNotFound):
+		return notFoundError(c, "Wallpaper not found")
+	case errors.Is(err, service.ErrImageBusy):
+		return errorResponse(c, fiber.StatusServiceUnavailable, err.Error())
+	default:
+		return internalError(c, "Failed to render image")
+	}
+
+	// The signed URL fully determines the output, so it can be cached for good
+	c.Set(fiber.HeaderCacheControl, "public, max-age=31536000, immutable")
+	c.Set(fiber.HeaderContentType, image.ContentType)
+	return c.Send(image.Data)
+}
+
+// SignImage handles GET /api/wallpapers/:id/img?w=&h=&fit=&fmt=&q=, returning the signed /img URL of the transform
+func (h *ImageHandler) SignImage(c *fiber.Ctx) error {
+	var params []string
+	for _, key := range []string{"w", "h", "fit", "fmt", "q"} {
+		if value := c.Query(key); value != "" {
+			params = append(params, key+"="+value)
+		}
+	}
+
+	url, err := h.imageService.SignURL(c.Context(), c.Params("id"), strings.Join(params, ","))
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrInvalidImageParams):
+		return badRequestError(c, err.Error())
+	case errors.Is(err, service.ErrWallpaperNotFound):
+		return notFoundError(c, "Wallpaper not found")
+	default:
+		return internalError(c, "Failed to sign image URL")
+	}
+
+	return c.JSON(fiber.Map{
+		"data": fiber.Map{"url": url},
+	})
+}
@@ -43,7 +43,7 @@ func (h *ReportHandler) CreateReport(c *fiber.Ctx) error {
 	}
 
 	// Fetch actual wallpaper data from database
-	wallpaper, err := h.wallpaperService.GetWallpaper(c.Context(), req.WallpaperID.String())
+	wallpaper, err := h.wallpaperService.GetWallpaper(c.Context(), req.WallpaperID.String(), reporterID, c.Locals("role").(string))
 	if err != nil {
 		return notFoundError(c, "Wallpaper not found")
 	}
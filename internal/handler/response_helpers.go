@@ -3,6 +3,7 @@ package handler
 import (
 	"github.com/gofiber/fiber/v2"
 	userRepo "github.com/pavelc4/pixtify/internal/repository/postgres/user"
+	"github.com/pavelc4/pixtify/internal/service"
 )
 
 func errorResponse(c *fiber.Ctx, status int, message string) error {
@@ -38,6 +39,30 @@ func successResponse(c *fiber.Ctx, message string, data interface{}) error {
 	})
 }
 
+// pageMeta is the meta block of a wallpaper feed. Pages requested by cursor have no page number
+// or total, next_cursor is null on the last page and facets are only included on the first page.
+// Sorted listings report their sort, and the seed of a random one.
+func pageMeta(result *service.WallpaperPage, cursor string, page, limit int) fiber.Map {
+	meta := fiber.Map{
+		"limit":       limit,
+		"next_cursor": stringPtr(result.NextCursor),
+	}
+	if cursor == "" {
+		meta["page"] = page
+		meta["total"] = result.Total
+	}
+	if result.Sort != "" {
+		meta["sort"] = result.Sort
+	}
+	if result.Seed != nil {
+		meta["seed"] = *result.Seed
+	}
+	if result.Facets != nil {
+		meta["facets"] = result.Facets
+	}
+	return meta
+}
+
 func newUserResponse(user *userRepo.User) UserResponse {
 	fullName := ""
 	if user.FullName != nil {
@@ -13,6 +13,9 @@ func SetupRoutes(
 	wallpaperHandler *WallpaperHandler,
 	collectionHandler *CollectionHandler,
 	tagHandler *TagHandler,
+	uploadHandler *UploadHandler,
+	fileHandler *FileHandler,
+	imageHandler *ImageHandler,
 	healthHandler *HealthHandler,
 	jwtMiddleware *middleware.JWTMiddleware,
 	rateLimiter *middleware.RateLimiterMiddleware,
@@ -44,7 +47,14 @@ func SetupRoutes(
 		public.Get("/wallpapers/featured", wallpaperHandler.ListFeaturedWallpapers)
 		public.Get("/wallpapers/search", wallpaperHandler.SearchWallpapers)
 		public.Get("/wallpapers/trending", wallpaperHandler.GetTrendingWallpapers)
-		public.Get("/wallpapers/:id", wallpaperHandler.GetWallpaper)
+		public.Get("/wallpapers/:id", jwtMiddleware.Optional(), wallpaperHandler.GetWallpaper)
+		public.Get("/wallpapers/:id/download", jwtMiddleware.Optional(), wallpaperHandler.DownloadWallpaper)
+		public.Get("/wallpapers/:id/thumbnail", jwtMiddleware.Optional(), wallpaperHandler.GetThumbnail)
+		public.Get("/wallpapers/:id/renditions/:name", jwtMiddleware.Optional(), wallpaperHandler.GetRendition)
+		public.Get("/wallpapers/:id/img", rateLimiter.ImageSignLimiter(), imageHandler.SignImage)
+
+		// Resumable upload capabilities (tus discovery)
+		public.Options("/uploads", uploadHandler.Options)
 
 		// Public Tag Routes
 		public.Get("/tags", tagHandler.ListTags)
@@ -76,8 +86,20 @@ func SetupRoutes(
 
 		// WALLPAPERS (Protected)
 		protected.Post("/wallpapers", wallpaperHandler.UploadWallpaper)
+		protected.Post("/wallpapers/upload-url", wallpaperHandler.CreateUploadURL)
+		protected.Post("/wallpapers/finalize", wallpaperHandler.FinalizeUpload)
 		protected.Put("/wallpapers/:id", wallpaperHandler.UpdateWallpaper)
 		protected.Delete("/wallpapers/:id", wallpaperHandler.DeleteWallpaper)
+		protected.Get("/wallpapers/:id/processing", wallpaperHandler.GetProcessingStatus)
+		protected.Post("/wallpapers/:id/restore", wallpaperHandler.RestoreWallpaper)
+		protected.Get("/users/me/trash", wallpaperHandler.GetMyTrash)
+
+		// RESUMABLE UPLOADS (tus 1.0), HEAD before GET as Get also registers HEAD
+		protected.Post("/uploads", uploadHandler.RequireTusResumable, uploadHandler.CreateUpload)
+		protected.Head("/uploads/:id", uploadHandler.RequireTusResumable, uploadHandler.HeadUpload)
+		protected.Get("/uploads/:id", uploadHandler.GetUpload)
+		protected.Patch("/uploads/:id", uploadHandler.RequireTusResumable, uploadHandler.PatchUpload)
+		protected.Delete("/uploads/:id", uploadHandler.RequireTusResumable, uploadHandler.DeleteUpload)
 
 		// LIKES
 		protected.Post("/wallpapers/:id/like", wallpaperHandler.LikeWallpaper)
@@ -103,6 +125,9 @@ func SetupRoutes(
 			// Featured wallpapers management
 			moderator.Post("/wallpapers/:id/featured", wallpaperHandler.SetFeaturedStatus)
 
+			// Suspected reposts flagged on upload
+			moderator.Get("/duplicates", wallpaperHandler.ListDuplicates)
+
 			// Report management
 			moderator.Get("/reports", reportHandler.ListReports)
 			moderator.Get("/reports/:id", reportHandler.GetReportByID)
@@ -119,6 +144,15 @@ func SetupRoutes(
 	public.Get("/collections/:id", collectionHandler.GetCollectionById)
 	public.Get("/collections/:id/wallpapers", collectionHandler.GetCollectionWallpapers)
 
+	// Local storage objects (only with STORAGE_PROVIDER=local), PUT needs a presigned URL
+	if fileHandler != nil {
+		app.Get("/files/:bucket/*", fileHandler.GetFile)
+		app.Put("/files/:bucket/*", fileHandler.PutFile)
+	}
+
+	// On-demand resizing, params must carry a signature
+	app.Get("/img/:id/:params", imageHandler.GetImage)
+
 	// Health check
 	app.Get("/health", healthHandler.Check)
 
@@ -0,0 +1,232 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/upload"
+	"github.com/pavelc4/pixtify/internal/service"
+)
+
+// tus 1.0 protocol, see https://tus.io/protocols/resumable-upload
+const (
+	tusVersion         = "1.0.0"
+	tusExtensions      = "creation,expiration,termination"
+	tusContentType     = "application/offset+octet-stream"
+	headerWallpaperID  = "Upload-Wallpaper-Id"
+	maxMetadataEntries = 16
+)
+
+// uploadMetadataKeys are the Upload-Metadata keys kept, matching the multipart upload form fields
+var uploadMetadataKeys = map[string]bool{
+	"title":       true,
+	"description": true,
+	"tags":        true,
+	"device_type": true,
+	"focal_x":     true,
+	"focal_y":     true,
+	"filetype":    true,
+	"filename":    true,
+}
+
+// UploadHandler serves resumable uploads over the tus protocol
+type UploadHandler struct {
+	uploadService *service.UploadService
+}
+
+func NewUploadHandler(uploadService *service.UploadService) *UploadHandler {
+	return &UploadHandler{uploadService: uploadService}
+}
+
+// RequireTusResumable rejects tus requests from clients speaking another protocol version
+func (h *UploadHandler) RequireTusResumable(c *fiber.Ctx) error {
+	if c.Get("Tus-Resumable") != tusVersion {
+		c.Set("Tus-Version", tusVersion)
+		return errorResponse(c, fiber.StatusPreconditionFailed, "Unsupported tus version, expected "+tusVersion)
+	}
+	c.Set("Tus-Resumable", tusVersion)
+	return c.Next()
+}
+
+// Options handles OPTIONS /api/uploads (server capabilities)
+func (h *UploadHandler) Options(c *fiber.Ctx) error {
+	c.Set("Tus-Resumable", tusVersion)
+	c.Set("Tus-Version", tusVersion)
+	c.Set("Tus-Extension", tusExtensions)
+	c.Set("Tus-Max-Size", strconv.FormatInt(h.uploadService.MaxSize(), 10))
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// CreateUpload handles POST /api/uploads
+func (h *UploadHandler) CreateUpload(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	if c.Get("Upload-Defer-Length") != "" {
+		return badRequestError(c, "Upload-Defer-Length is not supported")
+	}
+	length, err := strconv.ParseInt(c.Get("Upload-Length"), 10, 64)
+	if err != nil || length < 1 {
+		return badRequestError(c, "Upload-Length header is required")
+	}
+
+	metadata, err := parseUploadMetadata(c.Get("Upload-Metadata"))
+	if err != nil {
+		return badRequestError(c, err.Error())
+	}
+
+	u, err := h.uploadService.CreateUpload(c.Context(), userID, length, metadata)
+	if err != nil {
+		return uploadError(c, err)
+	}
+
+	c.Set(fiber.HeaderLocation, c.BaseURL()+"/api/uploads/"+u.ID.String())
+	setUploadHeaders(c, u)
+	return c.SendStatus(fiber.StatusCreated)
+}
+
+// HeadUpload handles HEAD /api/uploads/:id, clients use it to find where to resume
+func (h *UploadHandler) HeadUpload(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	u, err := h.uploadService.GetUpload(c.Context(), c.Params("id"), userID)
+	if err != nil {
+		return uploadError(c, err)
+	}
+
+	setUploadHeaders(c, u)
+	c.Set("Upload-Length", strconv.FormatInt(u.Length, 10))
+	c.Set("Upload-Metadata", encodeUploadMetadata(u.Metadata))
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.SendStatus(fiber.StatusOK)
+}
+
+// GetUpload handles GET /api/uploads/:id, the upload status as JSON (including why it failed)
+func (h *UploadHandler) GetUpload(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	u, err := h.uploadService.GetUpload(c.Context(), c.Params("id"), userID)
+	if err != nil {
+		return uploadError(c, err)
+	}
+
+	return c.JSON(fiber.Map{"data": u})
+}
+
+// PatchUpload handles PATCH /api/uploads/:id, appending the request body at Upload-Offset.
+// The request completing the upload also creates the wallpaper.
+func (h *UploadHandler) PatchUpload(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	if !strings.EqualFold(c.Get(fiber.HeaderContentType), tusContentType) {
+		return errorResponse(c, fiber.StatusUnsupportedMediaType, "Content-Type must be "+tusContentType)
+	}
+	offset, err := strconv.ParseInt(c.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return badRequestError(c, "Upload-Offset header is required")
+	}
+
+	// Stream the body instead of buffering chunks that can be as large as the whole upload
+	var body io.Reader
+	if stream := c.Context().RequestBodyStream(); stream != nil {
+		body = stream
+	} else {
+		body = bytes.NewReader(c.Body())
+	}
+
+	u, err := h.uploadService.WriteChunk(c.Context(), c.Params("id"), userID, offset, body)
+	if err != nil {
+		return uploadError(c, err)
+	}
+
+	setUploadHeaders(c, u)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// DeleteUpload handles DELETE /api/uploads/:id (tus termination)
+func (h *UploadHandler) DeleteUpload(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	if err := h.uploadService.Terminate(c.Context(), c.Params("id"), userID); err != nil {
+		return uploadError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func setUploadHeaders(c *fiber.Ctx, u *upload.Upload) {
+	c.Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	if u.Status == upload.StatusPending {
+		c.Set("Upload-Expires", u.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+	if u.WallpaperID != nil {
+		c.Set(headerWallpaperID, u.WallpaperID.String())
+	}
+}
+
+// uploadError maps resumable upload errors to tus status codes
+func uploadError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, service.ErrUploadNotFound):
+		return notFoundError(c, err.Error())
+	case errors.Is(err, service.ErrUploadExpired):
+		return errorResponse(c, fiber.StatusGone, err.Error())
+	case errors.Is(err, service.ErrUploadOffsetMismatch), errors.Is(err, service.ErrUploadNotPending):
+		return conflictError(c, err.Error())
+	case errors.Is(err, service.ErrUploadTooLarge), errors.Is(err, service.ErrUploadLengthExceeded):
+		return errorResponse(c, fiber.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, service.ErrUploadMissingTitle), errors.Is(err, service.ErrInvalidFocalPoint):
+		return badRequestError(c, err.Error())
+	}
+	return createWallpaperError(c, err)
+}
+
+// parseUploadMetadata decodes "key base64value,key2 base64value2", unknown keys are dropped
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	pairs := strings.Split(header, ",")
+	if len(pairs) > maxMetadataEntries {
+		return nil, errors.New("too many Upload-Metadata entries")
+	}
+	for _, pair := range pairs {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("invalid Upload-Metadata header")
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, errors.New("invalid Upload-Metadata value for " + key)
+		}
+		if uploadMetadataKeys[key] {
+			metadata[key] = string(decoded)
+		}
+	}
+	return metadata, nil
+}
+
+func encodeUploadMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if metadata[key] == "" {
+			pairs = append(pairs, key)
+			continue
+		}
+		pairs = append(pairs, key+" "+base64.StdEncoding.EncodeToString([]byte(metadata[key])))
+	}
+	return strings.Join(pairs, ",")
+}
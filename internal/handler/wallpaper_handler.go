@@ -1,25 +1,49 @@
 package handler
 
 import (
+	"errors"
 	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"github.com/pavelc4/pixtify/internal/processor"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/wallpaper"
 	"github.com/pavelc4/pixtify/internal/service"
 )
 
 type WallpaperHandler struct {
 	wallpaperService *service.WallpaperService
 	likeService      *service.LikeService
+	trashService     *service.TrashService
+	counterService   *service.CounterService
 }
 
-func NewWallpaperHandler(wallpaperService *service.WallpaperService, likeService *service.LikeService) *WallpaperHandler {
+func NewWallpaperHandler(wallpaperService *service.WallpaperService, likeService *service.LikeService, trashService *service.TrashService, counterService *service.CounterService) *WallpaperHandler {
 	return &WallpaperHandler{
 		wallpaperService: wallpaperService,
 		likeService:      likeService,
+		trashService:     trashService,
+		counterService:   counterService,
 	}
 }
 
+// viewer identifies who is viewing or downloading for counter deduplication,
+// the user when logged in (routes use the optional JWT middleware), the IP otherwise
+func viewer(c *fiber.Ctx) string {
+	if userID, ok := c.Locals("user_id").(string); ok && userID != "" {
+		return "user:" + userID
+	}
+	return "ip:" + c.IP()
+}
+
+// requester returns the user ID and role set by the optional JWT middleware, empty for anonymous requests
+func requester(c *fiber.Ctx) (string, string) {
+	userID, _ := c.Locals("user_id").(string)
+	role, _ := c.Locals("role").(string)
+	return userID, role
+}
+
 func (h *WallpaperHandler) UploadWallpaper(c *fiber.Ctx) error {
 	// Get User ID (set by middleware)
 	userID := c.Locals("user_id").(string)
@@ -44,62 +68,209 @@ func (h *WallpaperHandler) UploadWallpaper(c *fiber.Ctx) error {
 		tags = service.SplitTags(tagsStr)
 	}
 
-	// Read file content
+	// Large parts are already on disk, the service streams them from there
 	src, err := file.Open()
 	if err != nil {
 		return internalError(c, "Failed to open file")
 	}
 	defer src.Close()
 
-	fileData := make([]byte, file.Size)
-	_, err = src.Read(fileData)
-	if err != nil {
-		return internalError(c, "Failed to read file")
-	}
-
 	contentType := file.Header.Get("Content-Type")
 
 	// Get Device Type (optional, default handled in service)
 	deviceType := c.FormValue("device_type")
 
+	// Focal point (optional), kept in frame by the device crops
+	focal, err := service.ParseFocalPoint(c.FormValue("focal_x"), c.FormValue("focal_y"))
+	if err != nil {
+		return badRequestError(c, err.Error())
+	}
+
 	// Call Service
 	input := service.CreateWallpaperInput{
 		UserID:      userID,
 		Title:       title,
 		Description: description,
 		DeviceType:  deviceType,
-		ImageData:   fileData,
+		Image:       src,
 		ContentType: contentType,
 		Tags:        tags,
+		FocalPoint:  focal,
 	}
 
 	wallpaper, err := h.wallpaperService.CreateWallpaper(c.Context(), input)
 	if err != nil {
-		return internalError(c, err.Error())
+		return createWallpaperError(c, err)
 	}
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"message":   "Wallpaper uploaded successfully",
+	// Derivatives are generated in the background, poll /processing for progress
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message":   "Wallpaper uploaded, processing started",
 		"wallpaper": wallpaper,
 	})
 }
 
+// CreateUploadURL handles POST /api/wallpapers/upload-url, step one of a direct-to-bucket upload
+func (h *WallpaperHandler) CreateUploadURL(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var req struct {
+		ContentType string `json:"content_type"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return badRequestError(c, "Invalid request body")
+		}
+	}
+
+	upload, err := h.wallpaperService.CreateDirectUpload(c.Context(), userID, req.ContentType)
+	if err != nil {
+		return internalError(c, "Failed to create upload URL")
+	}
+
+	return c.JSON(fiber.Map{"data": upload})
+}
+
+// FinalizeUpload handles POST /api/wallpapers/finalize, creating the wallpaper from a direct upload
+func (h *WallpaperHandler) FinalizeUpload(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+
+	var req struct {
+		UploadID    string                `json:"upload_id"`
+		Title       string                `json:"title"`
+		Description string                `json:"description"`
+		DeviceType  string                `json:"device_type"`
+		Tags        []string              `json:"tags"`
+		FocalPoint  *wallpaper.FocalPoint `json:"focal_point"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return badRequestError(c, "Invalid request body")
+	}
+	if req.UploadID == "" {
+		return badRequestError(c, "upload_id is required")
+	}
+	if req.Title == "" {
+		return badRequestError(c, "Title is required")
+	}
+
+	wallpaper, err := h.wallpaperService.FinalizeDirectUpload(c.Context(), service.FinalizeDirectUploadInput{
+		UserID:      userID,
+		UploadID:    req.UploadID,
+		Title:       req.Title,
+		Description: req.Description,
+		DeviceType:  req.DeviceType,
+		Tags:        req.Tags,
+		FocalPoint:  req.FocalPoint,
+	})
+	if err != nil {
+		if err == service.ErrDirectUploadNotFound {
+			return notFoundError(c, err.Error())
+		}
+		return createWallpaperError(c, err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message":   "Wallpaper uploaded, processing started",
+		"wallpaper": wallpaper,
+	})
+}
+
+// createWallpaperError responds to a failed CreateWallpaper, shared by direct and resumable uploads
+func createWallpaperError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, service.ErrInvalidFocalPoint) {
+		return badRequestError(c, err.Error())
+	}
+	if errors.Is(err, service.ErrUploadBusy) {
+		c.Set(fiber.HeaderRetryAfter, "30")
+		return errorResponse(c, fiber.StatusServiceUnavailable, err.Error())
+	}
+	var validationErr *processor.ValidationError
+	if errors.As(err, &validationErr) {
+		return errorResponse(c, validationStatus(validationErr), validationErr.Error())
+	}
+	var duplicateErr *service.DuplicateError
+	if errors.As(err, &duplicateErr) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":        duplicateErr.Error(),
+			"duplicate_of": duplicateErr.DuplicateOf,
+		})
+	}
+	return internalError(c, err.Error())
+}
+
+// validationStatus maps an upload rejection to its HTTP status
+func validationStatus(err *processor.ValidationError) int {
+	switch err.Kind {
+	case processor.ErrImageTooLarge, processor.ErrTooManyPixels:
+		return fiber.StatusRequestEntityTooLarge
+	case processor.ErrUnsupportedType, processor.ErrContentMismatch:
+		return fiber.StatusUnsupportedMediaType
+	case processor.ErrResolutionTooSmall:
+		return fiber.StatusUnprocessableEntity
+	}
+	return fiber.StatusBadRequest
+}
+
+// listQuery reads the filter parameters shared by list and search
+func listQuery(c *fiber.Ctx) service.ListQuery {
+	tags := service.SplitTags(c.Query("tags"))
+	if tag := c.Query("tag"); tag != "" {
+		tags = append(tags, tag)
+	}
+
+	return service.ListQuery{
+		Color:       c.Query("color"),
+		DeviceType:  c.Query("device_type"),
+		MinWidth:    c.Query("min_width"),
+		MinHeight:   c.Query("min_height"),
+		Resolution:  c.Query("resolution"),
+		Screen:      c.Query("screen"),
+		DPR:         c.Query("dpr"),
+		Aspects:     queryList(c, "aspect"),
+		Orientation: c.Query("orientation"),
+		MimeTypes:   queryList(c, "mime_type"),
+		Tags:        tags,
+		TagMode:     c.Query("tag_mode"),
+		Uploader:    c.Query("uploader"),
+		From:        c.Query("from"),
+		To:          c.Query("to"),
+	}
+}
+
+// sortQuery reads the sort parameters of a listing
+func sortQuery(c *fiber.Ctx) service.SortQuery {
+	return service.SortQuery{
+		Sort: c.Query("sort"),
+		Seed: c.Query("seed"),
+	}
+}
+
+// queryList splits a comma separated query parameter
+func queryList(c *fiber.Ctx, key string) []string {
+	if value := c.Query(key); value != "" {
+		return strings.Split(value, ",")
+	}
+	return nil
+}
+
 func (h *WallpaperHandler) ListWallpapers(c *fiber.Ctx) error {
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	limit, _ := strconv.Atoi(c.Query("limit", "20"))
 
-	wallpapers, total, err := h.wallpaperService.ListWallpapers(c.Context(), page, limit)
+	cursor := c.Query("cursor")
+
+	result, err := h.wallpaperService.ListWallpapers(c.Context(), listQuery(c), sortQuery(c), cursor, page, limit)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidColor) || errors.Is(err, service.ErrInvalidFilter) ||
+			errors.Is(err, service.ErrInvalidSort) || errors.Is(err, service.ErrInvalidCursor) {
+			return badRequestError(c, err.Error())
+		}
 		return internalError(c, "Failed to fetch wallpapers")
 	}
 
 	return c.JSON(fiber.Map{
-		"data": wallpapers,
-		"meta": fiber.Map{
-			"page":  page,
-			"limit": limit,
-			"total": total,
-		},
+		"data": result.Wallpapers,
+		"meta": pageMeta(result, cursor, page, limit),
 	})
 }
 
@@ -109,9 +280,18 @@ func (h *WallpaperHandler) GetWallpaper(c *fiber.Ctx) error {
 		return badRequestError(c, "Invalid wallpaper ID")
 	}
 
-	wp, err := h.wallpaperService.GetWallpaper(c.Context(), id)
+	userID, role := requester(c)
+	wp, err := h.wallpaperService.GetWallpaper(c.Context(), id, userID, role)
+	if errors.Is(err, service.ErrWallpaperNotFound) {
+		return notFoundError(c, "Wallpaper not found")
+	}
 	if err != nil {
-		return internalError(c, "Wallpaper not found or error fetching")
+		return internalError(c, "Failed to fetch wallpaper")
+	}
+
+	// Owners and moderators looking at a wallpaper still processing don't count as views
+	if wp.Status == wallpaper.StatusActive {
+		h.counterService.RecordView(wp.ID, viewer(c))
 	}
 
 	return c.JSON(fiber.Map{
@@ -119,6 +299,71 @@ func (h *WallpaperHandler) GetWallpaper(c *fiber.Ctx) error {
 	})
 }
 
+// DownloadWallpaper redirects to a short-lived download URL (?size=original|thumbnail|<rendition>)
+func (h *WallpaperHandler) DownloadWallpaper(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return badRequestError(c, "Invalid wallpaper ID")
+	}
+
+	url, err := h.wallpaperService.GetDownloadURL(c.Context(), id.String(), c.Query("size"))
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrWallpaperNotFound):
+		return notFoundError(c, "Wallpaper not found")
+	case errors.Is(err, service.ErrUnknownSize):
+		return badRequestError(c, err.Error())
+	default:
+		return internalError(c, "Failed to create download URL")
+	}
+
+	h.counterService.RecordDownload(id, viewer(c))
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Redirect(url, fiber.StatusFound)
+}
+
+// GetThumbnail redirects to the thumbnail variant matching the Accept header (public endpoint)
+func (h *WallpaperHandler) GetThumbnail(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if _, err := uuid.Parse(id); err != nil {
+		return badRequestError(c, "Invalid wallpaper ID")
+	}
+
+	userID, role := requester(c)
+	url, err := h.wallpaperService.GetThumbnailURL(c.Context(), id, userID, role, c.Get(fiber.HeaderAccept))
+	if errors.Is(err, service.ErrWallpaperNotFound) {
+		return notFoundError(c, err.Error())
+	}
+	if err != nil {
+		return internalError(c, "Failed to fetch thumbnail")
+	}
+
+	// Caches must key on Accept since the redirect target depends on it
+	c.Vary(fiber.HeaderAccept)
+	return c.Redirect(url, fiber.StatusFound)
+}
+
+// GetRendition redirects to a named rendition in the format matching the Accept header (public endpoint)
+func (h *WallpaperHandler) GetRendition(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if _, err := uuid.Parse(id); err != nil {
+		return badRequestError(c, "Invalid wallpaper ID")
+	}
+
+	userID, role := requester(c)
+	url, err := h.wallpaperService.GetRenditionURL(c.Context(), id, userID, role, c.Params("name"), c.Get(fiber.HeaderAccept))
+	if errors.Is(err, service.ErrWallpaperNotFound) || errors.Is(err, service.ErrRenditionNotFound) {
+		return notFoundError(c, err.Error())
+	}
+	if err != nil {
+		return internalError(c, "Failed to fetch rendition")
+	}
+
+	c.Vary(fiber.HeaderAccept)
+	return c.Redirect(url, fiber.StatusFound)
+}
+
 func (h *WallpaperHandler) LikeWallpaper(c *fiber.Ctx) error {
 	userID := c.Locals("user_id").(string)
 	wallpaperID := c.Params("id")
@@ -148,18 +393,19 @@ func (h *WallpaperHandler) GetMyLikes(c *fiber.Ctx) error {
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	limit, _ := strconv.Atoi(c.Query("limit", "20"))
 
-	wallpapers, total, err := h.likeService.GetUserLikedWallpapers(c.Context(), userID, page, limit)
+	cursor := c.Query("cursor")
+
+	result, err := h.likeService.GetUserLikedWallpapers(c.Context(), userID, cursor, page, limit)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidCursor) {
+			return badRequestError(c, err.Error())
+		}
 		return internalError(c, "Failed to fetch liked wallpapers")
 	}
 
 	return c.JSON(fiber.Map{
-		"data": wallpapers,
-		"meta": fiber.Map{
-			"page":  page,
-			"limit": limit,
-			"total": total,
-		},
+		"data": result.Wallpapers,
+		"meta": pageMeta(result, cursor, page, limit),
 	})
 }
 
@@ -207,6 +453,95 @@ func (h *WallpaperHandler) DeleteWallpaper(c *fiber.Ctx) error {
 	})
 }
 
+// GetMyTrash lists the current user's deleted wallpapers that can still be restored
+func (h *WallpaperHandler) GetMyTrash(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+
+	wallpapers, total, err := h.trashService.ListTrash(c.Context(), userID, page, limit)
+	if err != nil {
+		return internalError(c, "Failed to fetch trash")
+	}
+
+	return c.JSON(fiber.Map{
+		"data": wallpapers,
+		"meta": fiber.Map{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
+// RestoreWallpaper brings a deleted wallpaper back (owner within the retention, moderators any time)
+func (h *WallpaperHandler) RestoreWallpaper(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+	userRole := c.Locals("role").(string)
+	wallpaperID := c.Params("id")
+
+	if _, err := uuid.Parse(wallpaperID); err != nil {
+		return badRequestError(c, "Invalid wallpaper ID")
+	}
+
+	err := h.trashService.RestoreWallpaper(c.Context(), wallpaperID, userID, userRole)
+	switch {
+	case err == nil:
+	case errors.Is(err, service.ErrNotInTrash):
+		return notFoundError(c, err.Error())
+	case errors.Is(err, service.ErrRestoreForbidden), errors.Is(err, service.ErrRemovedByModerator):
+		return errorResponse(c, fiber.StatusForbidden, err.Error())
+	case errors.Is(err, service.ErrRestoreWindowOver):
+		return errorResponse(c, fiber.StatusGone, err.Error())
+	default:
+		return internalError(c, "Failed to restore wallpaper")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Wallpaper restored successfully",
+	})
+}
+
+// GetProcessingStatus returns the processing job status of an upload (owner or moderator)
+func (h *WallpaperHandler) GetProcessingStatus(c *fiber.Ctx) error {
+	userID := c.Locals("user_id").(string)
+	userRole := c.Locals("role").(string)
+	wallpaperID := c.Params("id")
+
+	if _, err := uuid.Parse(wallpaperID); err != nil {
+		return badRequestError(c, "Invalid wallpaper ID")
+	}
+
+	status, err := h.wallpaperService.GetProcessingStatus(c.Context(), wallpaperID, userID, userRole)
+	if err != nil {
+		return internalError(c, err.Error())
+	}
+
+	return c.JSON(fiber.Map{
+		"data": status,
+	})
+}
+
+// ListDuplicates lists suspected repost clusters (moderator only)
+func (h *WallpaperHandler) ListDuplicates(c *fiber.Ctx) error {
+	page, _ := strconv.Atoi(c.Query("page", "1"))
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+
+	clusters, total, err := h.wallpaperService.ListDuplicateClusters(c.Context(), page, limit)
+	if err != nil {
+		return internalError(c, "Failed to fetch duplicates")
+	}
+
+	return c.JSON(fiber.Map{
+		"data": clusters,
+		"meta": fiber.Map{
+			"page":  page,
+			"limit": limit,
+			"total": total,
+		},
+	})
+}
+
 // SetFeaturedStatus toggles featured status (moderator only)
 func (h *WallpaperHandler) SetFeaturedStatus(c *fiber.Ctx) error {
 	wallpaperID := c.Params("id")
@@ -242,18 +577,19 @@ func (h *WallpaperHandler) ListFeaturedWallpapers(c *fiber.Ctx) error {
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	limit, _ := strconv.Atoi(c.Query("limit", "20"))
 
-	wallpapers, total, err := h.wallpaperService.ListFeaturedWallpapers(c.Context(), page, limit)
+	cursor := c.Query("cursor")
+
+	result, err := h.wallpaperService.ListFeaturedWallpapers(c.Context(), sortQuery(c), cursor, page, limit)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidSort) || errors.Is(err, service.ErrInvalidCursor) {
+			return badRequestError(c, err.Error())
+		}
 		return internalError(c, "Failed to fetch featured wallpapers")
 	}
 
 	return c.JSON(fiber.Map{
-		"data": wallpapers,
-		"meta": fiber.Map{
-			"page":  page,
-			"limit": limit,
-			"total": total,
-		},
+		"data": result.Wallpapers,
+		"meta": pageMeta(result, cursor, page, limit),
 	})
 }
 
@@ -267,21 +603,25 @@ func (h *WallpaperHandler) SearchWallpapers(c *fiber.Ctx) error {
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	limit, _ := strconv.Atoi(c.Query("limit", "20"))
 
-	wallpapers, total, err := h.wallpaperService.SearchWallpapers(c.Context(), query, page, limit)
+	cursor := c.Query("cursor")
+
+	result, err := h.wallpaperService.SearchWallpapers(c.Context(), query, listQuery(c), sortQuery(c), cursor, page, limit)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		if errors.Is(err, service.ErrInvalidSearchQuery) || errors.Is(err, service.ErrInvalidColor) ||
+			errors.Is(err, service.ErrInvalidFilter) || errors.Is(err, service.ErrInvalidSort) ||
+			errors.Is(err, service.ErrInvalidCursor) {
+			return badRequestError(c, err.Error())
+		}
+		return internalError(c, "Failed to search wallpapers")
 	}
 
+	meta := pageMeta(result, cursor, page, limit)
+	meta["query"] = query
+	meta["color"] = c.Query("color")
+
 	return c.JSON(fiber.Map{
-		"data": wallpapers,
-		"meta": fiber.Map{
-			"page":  page,
-			"limit": limit,
-			"total": total,
-			"query": query,
-		},
+		"data": result.Wallpapers,
+		"meta": meta,
 	})
 }
 
@@ -295,21 +635,23 @@ func (h *WallpaperHandler) GetWallpapersByTag(c *fiber.Ctx) error {
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	limit, _ := strconv.Atoi(c.Query("limit", "20"))
 
-	wallpapers, total, err := h.wallpaperService.GetWallpapersByTag(c.Context(), tagSlug, page, limit)
+	cursor := c.Query("cursor")
+
+	result, err := h.wallpaperService.GetWallpapersByTag(c.Context(), tagSlug, sortQuery(c), cursor, page, limit)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		if errors.Is(err, service.ErrInvalidFilter) || errors.Is(err, service.ErrInvalidSort) ||
+			errors.Is(err, service.ErrInvalidCursor) {
+			return badRequestError(c, err.Error())
+		}
+		return internalError(c, "Failed to fetch wallpapers")
 	}
 
+	meta := pageMeta(result, cursor, page, limit)
+	meta["tag"] = tagSlug
+
 	return c.JSON(fiber.Map{
-		"data": wallpapers,
-		"meta": fiber.Map{
-			"page":  page,
-			"limit": limit,
-			"total": total,
-			"tag":   tagSlug,
-		},
+		"data": result.Wallpapers,
+		"meta": meta,
 	})
 }
 
@@ -323,19 +665,22 @@ func (h *WallpaperHandler) GetUserWallpapers(c *fiber.Ctx) error {
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	limit, _ := strconv.Atoi(c.Query("limit", "20"))
 
-	wallpapers, total, err := h.wallpaperService.GetUserWallpapers(c.Context(), userID, page, limit)
+	cursor := c.Query("cursor")
+
+	result, err := h.wallpaperService.GetUserWallpapers(c.Context(), userID, sortQuery(c), cursor, page, limit)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidSort) || errors.Is(err, service.ErrInvalidCursor) {
+			return badRequestError(c, err.Error())
+		}
 		return internalError(c, err.Error())
 	}
 
+	meta := pageMeta(result, cursor, page, limit)
+	meta["user_id"] = userID
+
 	return c.JSON(fiber.Map{
-		"data": wallpapers,
-		"meta": fiber.Map{
-			"page":    page,
-			"limit":   limit,
-			"total":   total,
-			"user_id": userID,
-		},
+		"data": result.Wallpapers,
+		"meta": meta,
 	})
 }
 
@@ -344,17 +689,18 @@ func (h *WallpaperHandler) GetTrendingWallpapers(c *fiber.Ctx) error {
 	page, _ := strconv.Atoi(c.Query("page", "1"))
 	limit, _ := strconv.Atoi(c.Query("limit", "20"))
 
-	wallpapers, total, err := h.wallpaperService.GetTrendingWallpapers(c.Context(), page, limit)
+	cursor := c.Query("cursor")
+
+	result, err := h.wallpaperService.GetTrendingWallpapers(c.Context(), sortQuery(c), cursor, page, limit)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidSort) || errors.Is(err, service.ErrInvalidCursor) {
+			return badRequestError(c, err.Error())
+		}
 		return internalError(c, "Failed to fetch trending wallpapers")
 	}
 
 	return c.JSON(fiber.Map{
-		"data": wallpapers,
-		"meta": fiber.Map{
-			"page":  page,
-			"limit": limit,
-			"total": total,
-		},
+		"data": result.Wallpapers,
+		"meta": pageMeta(result, cursor, page, limit),
 	})
 }
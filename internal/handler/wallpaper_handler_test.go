@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/pavelc4/pixtify/internal/config"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/wallpaper"
+	"github.com/pavelc4/pixtify/internal/service"
+)
+
+// stubWallpaperRepo answers listings with a fixed error, every other method is left
+// to the nil embedded interface and panics if a handler reaches it
+type stubWallpaperRepo struct {
+	service.WallpaperRepository
+	err error
+}
+
+func (r *stubWallpaperRepo) List(ctx context.Context, f wallpaper.Filter, o wallpaper.Order, after *wallpaper.Cursor, limit, offset int) ([]*wallpaper.Wallpaper, int, *wallpaper.Cursor, error) {
+	return []*wallpaper.Wallpaper{}, 0, nil, r.err
+}
+
+func (r *stubWallpaperRepo) Facets(ctx context.Context, f wallpaper.Filter) (*wallpaper.Facets, error) {
+	return &wallpaper.Facets{}, r.err
+}
+
+// newListingApp serves the public wallpaper listings on top of repo
+func newListingApp(repo service.WallpaperRepository) *fiber.App {
+	wallpapers := service.NewWallpaperService(repo, nil, nil, nil, "", "", 1, config.DuplicateConfig{}, service.UploadOptions{}, nil)
+	h := NewWallpaperHandler(wallpapers, nil, nil, nil)
+
+	app := fiber.New()
+	app.Get("/search", h.SearchWallpapers)
+	app.Get("/tag", h.GetWallpapersByTag)
+	return app
+}
+
+// cursorFor encodes a listing cursor the way the service does
+func cursorFor(t *testing.T, c wallpaper.Cursor) string {
+	t.Helper()
+	data, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func TestSearchWallpapersErrors(t *testing.T) {
+	newestCursor := cursorFor(t, wallpaper.Cursor{Sort: wallpaper.SortNewest, ID: uuid.New()})
+
+	tests := []struct {
+		name       string
+		query      url.Values
+		repoErr    error
+		wantStatus int
+	}{
+		{name: "found", query: url.Values{"q": {"lake"}}, wantStatus: fiber.StatusOK},
+		{name: "missing query", query: url.Values{}, wantStatus: fiber.StatusBadRequest},
+		{name: "query too short", query: url.Values{"q": {"a"}}, wantStatus: fiber.StatusBadRequest},
+		{name: "invalid color", query: url.Values{"q": {"lake"}, "color": {"blurple"}}, wantStatus: fiber.StatusBadRequest},
+		{name: "invalid filter", query: url.Values{"q": {"lake"}, "orientation": {"diagonal"}}, wantStatus: fiber.StatusBadRequest},
+		{name: "invalid sort", query: url.Values{"q": {"lake"}, "sort": {"loudest"}}, wantStatus: fiber.StatusBadRequest},
+		{name: "malformed cursor", query: url.Values{"q": {"lake"}, "cursor": {"%%%"}}, wantStatus: fiber.StatusBadRequest},
+		{name: "cursor of another sort", query: url.Values{"q": {"lake"}, "cursor": {newestCursor}}, wantStatus: fiber.StatusBadRequest},
+		{name: "database failure", query: url.Values{"q": {"lake"}}, repoErr: errors.New("pq: connection refused"), wantStatus: fiber.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newListingApp(&stubWallpaperRepo{err: tt.repoErr})
+
+			resp, err := app.Test(httptest.NewRequest("GET", "/search?"+tt.query.Encode(), nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tt.wantStatus, body)
+			}
+
+			// Database errors are logged, not shown to clients
+			if tt.repoErr != nil && strings.Contains(string(body), "pq:") {
+				t.Errorf("response leaks the database error: %s", body)
+			}
+		})
+	}
+}
+
+func TestGetWallpapersByTagErrors(t *testing.T) {
+	likedCursor := cursorFor(t, wallpaper.Cursor{Sort: wallpaper.SortMostLiked, ID: uuid.New()})
+
+	tests := []struct {
+		name       string
+		query      url.Values
+		repoErr    error
+		wantStatus int
+	}{
+		{name: "found", query: url.Values{"tag": {"nature"}}, wantStatus: fiber.StatusOK},
+		{name: "missing tag", query: url.Values{}, wantStatus: fiber.StatusBadRequest},
+		{name: "blank tag", query: url.Values{"tag": {"  "}}, wantStatus: fiber.StatusBadRequest},
+		{name: "invalid sort", query: url.Values{"tag": {"nature"}, "sort": {"loudest"}}, wantStatus: fiber.StatusBadRequest},
+		{name: "malformed cursor", query: url.Values{"tag": {"nature"}, "cursor": {"%%%"}}, wantStatus: fiber.StatusBadRequest},
+		{name: "cursor of another sort", query: url.Values{"tag": {"nature"}, "cursor": {likedCursor}}, wantStatus: fiber.StatusBadRequest},
+		{name: "database failure", query: url.Values{"tag": {"nature"}}, repoErr: errors.New("pq: connection refused"), wantStatus: fiber.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newListingApp(&stubWallpaperRepo{err: tt.repoErr})
+
+			resp, err := app.Test(httptest.NewRequest("GET", "/tag?"+tt.query.Encode(), nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tt.wantStatus, body)
+			}
+
+			if tt.repoErr != nil && strings.Contains(string(body), "pq:") {
+				t.Errorf("response leaks the database error: %s", body)
+			}
+		})
+	}
+}
@@ -100,6 +100,23 @@ func (m *RateLimiterMiddleware) AdminLimiter() fiber.Handler {
 	})
 }
 
+// ImageSignLimiter bounds how many /img URLs a client can have signed, each new one may cost a render
+func (m *RateLimiterMiddleware) ImageSignLimiter() fiber.Handler {
+	return limiter.New(limiter.Config{
+		Max:        m.config.ImageSignMax,
+		Expiration: m.config.ImageSignWindow,
+		KeyGenerator: func(c *fiber.Ctx) string {
+			return c.IP()
+		},
+		LimitReached: func(c *fiber.Ctx) error {
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error":       "Too many image URL requests. Please try again later.",
+				"retry_after": m.config.ImageSignWindow.Seconds(),
+			})
+		},
+	})
+}
+
 // Custom limiter - for specific use cases
 func (m *RateLimiterMiddleware) CustomLimiter(max int, window time.Duration, message string) fiber.Handler {
 	return limiter.New(limiter.Config{
@@ -0,0 +1,138 @@
+package processor
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+const base83Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// blurhashSampleWidth is the width images are downscaled to before encoding.
+// BlurHash only keeps a handful of low-frequency components, so sampling the
+// full resolution image would be wasted work.
+const blurhashSampleWidth = 64
+
+// encodeBlurhash encodes img into a BlurHash string (https://blurha.sh)
+func encodeBlurhash(img image.Image, xComponents, yComponents int) (string, error) {
+	if xComponents < 1 || xComponents > 9 || yComponents < 1 || yComponents > 9 {
+		return "", fmt.Errorf("blurhash components must be between 1 and 9")
+	}
+
+	if img.Bounds().Dx() > blurhashSampleWidth {
+		img = imaging.Resize(img, blurhashSampleWidth, 0, imaging.Box)
+	}
+	nrgba := imaging.Clone(img)
+
+	width := nrgba.Bounds().Dx()
+	height := nrgba.Bounds().Dy()
+	if width == 0 || height == 0 {
+		return "", fmt.Errorf("cannot encode empty image")
+	}
+
+	// Convert once to linear RGB so every component reuses the same samples
+	linear := make([][3]float64, width*height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*nrgba.Stride + x*4
+			linear[y*width+x] = [3]float64{
+				sRGBToLinear(nrgba.Pix[i]),
+				sRGBToLinear(nrgba.Pix[i+1]),
+				sRGBToLinear(nrgba.Pix[i+2]),
+			}
+		}
+	}
+
+	factors := make([][3]float64, 0, xComponents*yComponents)
+	for j := 0; j < yComponents; j++ {
+		for i := 0; i < xComponents; i++ {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1.0
+			}
+
+			var r, g, b float64
+			for y := 0; y < height; y++ {
+				basisY := math.Cos(math.Pi * float64(j) * float64(y) / float64(height))
+				for x := 0; x < width; x++ {
+					basis := basisY * math.Cos(math.Pi*float64(i)*float64(x)/float64(width))
+					px := linear[y*width+x]
+					r += basis * px[0]
+					g += basis * px[1]
+					b += basis * px[2]
+				}
+			}
+
+			scale := normalisation / float64(width*height)
+			factors = append(factors, [3]float64{r * scale, g * scale, b * scale})
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString(encodeBase83((xComponents-1)+(yComponents-1)*9, 1))
+
+	dc, ac := factors[0], factors[1:]
+
+	maxValue := 1.0
+	if len(ac) > 0 {
+		actualMax := 0.0
+		for _, f := range ac {
+			actualMax = math.Max(actualMax, math.Max(math.Abs(f[0]), math.Max(math.Abs(f[1]), math.Abs(f[2]))))
+		}
+		quantisedMax := int(math.Max(0, math.Min(82, math.Floor(actualMax*166-0.5))))
+		maxValue = float64(quantisedMax+1) / 166
+		sb.WriteString(encodeBase83(quantisedMax, 1))
+	} else {
+		sb.WriteString(encodeBase83(0, 1))
+	}
+
+	sb.WriteString(encodeBase83(encodeDC(dc), 4))
+	for _, f := range ac {
+		sb.WriteString(encodeBase83(encodeAC(f, maxValue), 2))
+	}
+
+	return sb.String(), nil
+}
+
+func encodeDC(c [3]float64) int {
+	return linearToSRGB(c[0])<<16 + linearToSRGB(c[1])<<8 + linearToSRGB(c[2])
+}
+
+func encodeAC(c [3]float64, maxValue float64) int {
+	quant := func(v float64) int {
+		return int(math.Max(0, math.Min(18, math.Floor(signPow(v/maxValue, 0.5)*9+9.5))))
+	}
+	return quant(c[0])*19*19 + quant(c[1])*19 + quant(c[2])
+}
+
+func encodeBase83(value, length int) string {
+	buf := make([]byte, length)
+	for i := 1; i <= length; i++ {
+		digit := (value / int(math.Pow(83, float64(length-i)))) % 83
+		buf[i-1] = base83Chars[digit]
+	}
+	return string(buf)
+}
+
+func sRGBToLinear(value uint8) float64 {
+	v := float64(value) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+func linearToSRGB(value float64) int {
+	v := math.Max(0, math.Min(1, value))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+func signPow(value, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(value), exp), value)
+}
@@ -0,0 +1,106 @@
+package processor
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// decodeBase83 is the inverse of encodeBase83
+func decodeBase83(s string) int {
+	value := 0
+	for _, c := range s {
+		value = value*83 + strings.IndexRune(base83Chars, c)
+	}
+	return value
+}
+
+func TestBlurhashSolidColors(t *testing.T) {
+	tests := []struct {
+		color color.NRGBA
+		want  string // size flag, maximum AC value and the DC colour
+	}{
+		{color: color.NRGBA{0xff, 0x00, 0x00, 0xff}, want: "L7TI:j"},
+		{color: color.NRGBA{0x00, 0x00, 0x00, 0xff}, want: "L00000"},
+		{color: color.NRGBA{0xff, 0xff, 0xff, 0xff}, want: "L7TSUA"},
+	}
+
+	for _, tt := range tests {
+		got, err := encodeBlurhash(imaging.New(120, 80, tt.color), 4, 3)
+		if err != nil {
+			t.Fatalf("encodeBlurhash: %v", err)
+		}
+		if !strings.HasPrefix(got, tt.want) {
+			t.Errorf("blurhash of %v = %q, want it to start with %q", tt.color, got, tt.want)
+		}
+	}
+}
+
+func TestBlurhashValid(t *testing.T) {
+	img := photoImage(300, 200)
+
+	for _, c := range []struct{ x, y int }{{1, 1}, {4, 3}, {3, 4}, {9, 9}} {
+		hash, err := encodeBlurhash(img, c.x, c.y)
+		if err != nil {
+			t.Fatalf("%dx%d: encodeBlurhash: %v", c.x, c.y, err)
+		}
+
+		if want := 4 + 2*c.x*c.y; len(hash) != want {
+			t.Fatalf("%dx%d: %q has length %d, want %d", c.x, c.y, hash, len(hash), want)
+		}
+		for _, ch := range hash {
+			if !strings.ContainsRune(base83Chars, ch) {
+				t.Fatalf("%dx%d: %q has characters outside base83", c.x, c.y, hash)
+			}
+		}
+
+		size := decodeBase83(hash[:1])
+		if x, y := size%9+1, size/9+1; x != c.x || y != c.y {
+			t.Errorf("%dx%d: size flag decodes to %dx%d", c.x, c.y, x, y)
+		}
+		if quantisedMax := decodeBase83(hash[1:2]); quantisedMax > 82 {
+			t.Errorf("%dx%d: maximum AC value %d out of range", c.x, c.y, quantisedMax)
+		}
+		for i := 6; i < len(hash); i += 2 {
+			if v := decodeBase83(hash[i : i+2]); v >= 19*19*19 {
+				t.Errorf("%dx%d: AC component %d = %d out of range", c.x, c.y, (i-6)/2, v)
+			}
+		}
+
+		// The DC component is the average colour of the image, averaged in linear light
+		dc := decodeBase83(hash[2:6])
+		got := [3]int{dc >> 16, dc >> 8 & 0xff, dc & 0xff}
+		for i, want := range linearAverage(img) {
+			if diff := got[i] - want; diff < -2 || diff > 2 {
+				t.Errorf("%dx%d: average colour %v, want about %v", c.x, c.y, got, linearAverage(img))
+				break
+			}
+		}
+	}
+}
+
+// linearAverage is the average sRGB colour of an image, averaged in linear light
+func linearAverage(img *image.NRGBA) [3]int {
+	var sum [3]float64
+	for i := 0; i < len(img.Pix); i += 4 {
+		for c := range sum {
+			sum[c] += sRGBToLinear(img.Pix[i+c])
+		}
+	}
+	n := float64(len(img.Pix) / 4)
+	return [3]int{linearToSRGB(sum[0] / n), linearToSRGB(sum[1] / n), linearToSRGB(sum[2] / n)}
+}
+
+func TestBlurhashInvalid(t *testing.T) {
+	if _, err := encodeBlurhash(image.NewNRGBA(image.Rect(0, 0, 0, 0)), 4, 3); err == nil {
+		t.Error("empty image encoded")
+	}
+	for _, c := range []struct{ x, y int }{{0, 3}, {4, 10}} {
+		if _, err := encodeBlurhash(photoImage(10, 10), c.x, c.y); err == nil {
+			t.Errorf("%dx%d components accepted", c.x, c.y)
+		}
+	}
+}
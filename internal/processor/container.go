@@ -0,0 +1,124 @@
+package processor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// chunk locates one segment/chunk of an image container without holding its data.
+// offset and size describe the payload, start and end the whole chunk including headers and padding.
+type chunk struct {
+	typ    string
+	start  int64
+	offset int64
+	size   int64
+	end    int64
+}
+
+func readFull(r io.ReaderAt, off int64, n int) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := r.ReadAt(buf, off); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// jpegChunks walks the marker segments preceding the first scan, returning them and the
+// offset of the SOS marker. The chunk type is the marker byte.
+func jpegChunks(r io.ReaderAt, size int64) ([]chunk, int64, error) {
+	soi, err := readFull(r, 0, 2)
+	if err != nil || soi[0] != 0xFF || soi[1] != 0xD8 {
+		return nil, 0, fmt.Errorf("missing jpeg SOI marker")
+	}
+
+	var chunks []chunk
+	pos := int64(2)
+	for {
+		header, err := readFull(r, pos, 4)
+		if err != nil || header[0] != 0xFF {
+			return nil, 0, fmt.Errorf("invalid jpeg marker at offset %d", pos)
+		}
+		marker := header[1]
+		if marker == 0xFF {
+			// Fill byte before a marker
+			pos++
+			continue
+		}
+		if marker == 0xDA {
+			return chunks, pos, nil
+		}
+
+		length := int64(binary.BigEndian.Uint16(header[2:]))
+		if length < 2 || pos+2+length > size {
+			return nil, 0, fmt.Errorf("truncated jpeg segment at offset %d", pos)
+		}
+		chunks = append(chunks, chunk{
+			typ:    string([]byte{marker}),
+			start:  pos,
+			offset: pos + 4,
+			size:   length - 2,
+			end:    pos + 2 + length,
+		})
+		pos += 2 + length
+	}
+}
+
+func pngChunks(r io.ReaderAt, size int64) ([]chunk, error) {
+	var chunks []chunk
+	pos := int64(8)
+	for pos+12 <= size {
+		header, err := readFull(r, pos, 8)
+		if err != nil {
+			return nil, err
+		}
+		length := int64(binary.BigEndian.Uint32(header))
+		if pos+12+length > size {
+			return nil, fmt.Errorf("truncated png chunk at offset %d", pos)
+		}
+		chunks = append(chunks, chunk{
+			typ:    string(header[4:8]),
+			start:  pos,
+			offset: pos + 8,
+			size:   length,
+			end:    pos + 12 + length,
+		})
+		pos += 12 + length
+	}
+	return chunks, nil
+}
+
+func riffChunks(r io.ReaderAt, size int64) ([]chunk, error) {
+	var chunks []chunk
+	pos := int64(12)
+	for pos+8 <= size {
+		header, err := readFull(r, pos, 8)
+		if err != nil {
+			return nil, err
+		}
+		length := int64(binary.LittleEndian.Uint32(header[4:]))
+		if pos+8+length > size {
+			return nil, fmt.Errorf("truncated webp chunk at offset %d", pos)
+		}
+		end := pos + 8 + length + length%2
+		if end > size {
+			end = size
+		}
+		chunks = append(chunks, chunk{
+			typ:    string(header[:4]),
+			start:  pos,
+			offset: pos + 8,
+			size:   length,
+			end:    end,
+		})
+		pos = end
+	}
+	return chunks, nil
+}
+
+// sniffFormatAt reads the magic bytes at the start of r
+func sniffFormatAt(r io.ReaderAt) OutputFormat {
+	header := make([]byte, 12)
+	n, _ := r.ReadAt(header, 0)
+	return sniffFormat(header[:n])
+}
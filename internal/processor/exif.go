@@ -0,0 +1,315 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"io"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// EXIF/TIFF tags we read, everything else is ignored
+const (
+	tagMake         = 0x010F
+	tagModel        = 0x0110
+	tagOrientation  = 0x0112
+	tagExifIFD      = 0x8769
+	tagExposureTime = 0x829A
+	tagFNumber      = 0x829D
+	tagISO          = 0x8827
+	tagFocalLength  = 0x920A
+	tagLensMake     = 0xA433
+	tagLensModel    = 0xA434
+)
+
+// TIFF field types and their sizes in bytes
+var tiffTypeSize = map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 7: 1, 9: 4, 10: 8}
+
+// ImageMetadata is the camera metadata we consider safe to publish.
+// Location, serial numbers, owner names and maker notes are never read.
+type ImageMetadata struct {
+	CameraMake   string
+	CameraModel  string
+	LensModel    string
+	FocalLength  float64 // millimetres
+	Aperture     float64 // f-number
+	ISO          int
+	ExposureTime string // e.g. "1/250" or "2.5"
+}
+
+// IsEmpty reports whether no field was found
+func (m *ImageMetadata) IsEmpty() bool {
+	return *m == ImageMetadata{}
+}
+
+type exifData struct {
+	orientation int
+	metadata    ImageMetadata
+}
+
+type tiffReader struct {
+	data  []byte
+	order binary.ByteOrder
+}
+
+type ifdEntry struct {
+	tag   uint16
+	typ   uint16
+	count uint32
+	value []byte
+}
+
+// parseExif reads the fields we care about from a TIFF-structured EXIF payload
+func parseExif(tiff []byte) (*exifData, error) {
+	if len(tiff) < 8 {
+		return nil, fmt.Errorf("exif payload too short")
+	}
+
+	r := &tiffReader{data: tiff}
+	switch string(tiff[:2]) {
+	case "II":
+		r.order = binary.LittleEndian
+	case "MM":
+		r.order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("invalid exif byte order")
+	}
+	if r.order.Uint16(tiff[2:4]) != 42 {
+		return nil, fmt.Errorf("invalid tiff header")
+	}
+
+	exif := &exifData{orientation: 1}
+
+	ifd0, err := r.readIFD(r.order.Uint32(tiff[4:8]))
+	if err != nil {
+		return nil, err
+	}
+
+	var exifIFD []ifdEntry
+	for _, e := range ifd0 {
+		switch e.tag {
+		case tagOrientation:
+			if o := r.int(e); o >= 1 && o <= 8 {
+				exif.orientation = o
+			}
+		case tagMake:
+			exif.metadata.CameraMake = r.string(e)
+		case tagModel:
+			exif.metadata.CameraModel = r.string(e)
+		case tagExifIFD:
+			// A broken sub-IFD only loses camera details, orientation is still usable
+			exifIFD, _ = r.readIFD(uint32(r.int(e)))
+		}
+	}
+
+	for _, e := range exifIFD {
+		switch e.tag {
+		case tagExposureTime:
+			exif.metadata.ExposureTime = r.exposure(e)
+		case tagFNumber:
+			exif.metadata.Aperture = r.rational(e)
+		case tagISO:
+			exif.metadata.ISO = r.int(e)
+		case tagFocalLength:
+			exif.metadata.FocalLength = r.rational(e)
+		case tagLensModel:
+			exif.metadata.LensModel = r.string(e)
+		case tagLensMake:
+			if exif.metadata.LensModel == "" {
+				exif.metadata.LensModel = r.string(e)
+			}
+		}
+	}
+
+	return exif, nil
+}
+
+func (r *tiffReader) readIFD(offset uint32) ([]ifdEntry, error) {
+	if offset < 8 || int(offset)+2 > len(r.data) {
+		return nil, fmt.Errorf("invalid ifd offset")
+	}
+
+	count := int(r.order.Uint16(r.data[offset:]))
+	start := int(offset) + 2
+	if start+count*12 > len(r.data) {
+		return nil, fmt.Errorf("truncated ifd")
+	}
+
+	entries := make([]ifdEntry, 0, count)
+	for i := 0; i < count; i++ {
+		raw := r.data[start+i*12 : start+i*12+12]
+		e := ifdEntry{
+			tag:   r.order.Uint16(raw[0:2]),
+			typ:   r.order.Uint16(raw[2:4]),
+			count: r.order.Uint32(raw[4:8]),
+		}
+
+		size, ok := tiffTypeSize[e.typ]
+		if !ok || e.count > uint32(len(r.data)) {
+			continue
+		}
+		length := size * int(e.count)
+
+		if length <= 4 {
+			e.value = raw[8 : 8+length]
+		} else {
+			valueOffset := int(r.order.Uint32(raw[8:12]))
+			if valueOffset+length > len(r.data) {
+				continue
+			}
+			e.value = r.data[valueOffset : valueOffset+length]
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+func (r *tiffReader) int(e ifdEntry) int {
+	switch {
+	case e.typ == 3 && len(e.value) >= 2:
+		return int(r.order.Uint16(e.value))
+	case (e.typ == 4 || e.typ == 9) && len(e.value) >= 4:
+		return int(r.order.Uint32(e.value))
+	}
+	return 0
+}
+
+func (r *tiffReader) string(e ifdEntry) string {
+	if e.typ != 2 {
+		return ""
+	}
+	s, _, _ := strings.Cut(string(e.value), "\x00")
+	return strings.TrimSpace(s)
+}
+
+func (r *tiffReader) fraction(e ifdEntry) (uint32, uint32) {
+	if (e.typ != 5 && e.typ != 10) || len(e.value) < 8 {
+		return 0, 0
+	}
+	return r.order.Uint32(e.value[0:4]), r.order.Uint32(e.value[4:8])
+}
+
+func (r *tiffReader) rational(e ifdEntry) float64 {
+	num, den := r.fraction(e)
+	if den == 0 {
+		return 0
+	}
+	return float64(num) / float64(den)
+}
+
+// exposure formats shutter speeds the way cameras display them
+func (r *tiffReader) exposure(e ifdEntry) string {
+	num, den := r.fraction(e)
+	if num == 0 || den == 0 {
+		return ""
+	}
+	if num < den {
+		return fmt.Sprintf("1/%d", (den+num/2)/num)
+	}
+	return fmt.Sprintf("%g", float64(num)/float64(den))
+}
+
+// maxExifSize bounds how much of an EXIF block is read into memory, real ones are a few KB
+const maxExifSize = 1 << 20
+
+// findExif returns the raw TIFF payload embedded in a JPEG, PNG or WebP file, or nil
+func findExif(r io.ReaderAt, size int64, format OutputFormat) []byte {
+	var chunks []chunk
+	var typ string
+	switch format {
+	case FormatJPEG:
+		chunks, _, _ = jpegChunks(r, size)
+		typ = "\xE1"
+	case FormatPNG:
+		chunks, _ = pngChunks(r, size)
+		typ = "eXIf"
+	case FormatWebP:
+		chunks, _ = riffChunks(r, size)
+		typ = "EXIF"
+	}
+
+	for _, c := range chunks {
+		if c.typ != typ || c.size > maxExifSize {
+			continue
+		}
+		payload, err := readFull(r, c.offset, int(c.size))
+		if err != nil {
+			return nil
+		}
+		if format == FormatJPEG && !bytes.HasPrefix(payload, exifHeader) {
+			continue // XMP also lives in APP1
+		}
+		// Some WebP writers keep the JPEG style "Exif\0\0" prefix too
+		return bytes.TrimPrefix(payload, exifHeader)
+	}
+	return nil
+}
+
+// readExif parses the embedded EXIF block, returning nil when there is none or it is unreadable
+func readExif(r io.ReaderAt, size int64) *exifData {
+	tiff := findExif(r, size, sniffFormatAt(r))
+	if tiff == nil {
+		return nil
+	}
+	exif, err := parseExif(tiff)
+	if err != nil {
+		return nil
+	}
+	return exif
+}
+
+// readOrientation returns the EXIF orientation (1-8), 1 when absent
+func readOrientation(r io.ReaderAt, size int64) int {
+	if exif := readExif(r, size); exif != nil {
+		return exif.orientation
+	}
+	return 1
+}
+
+// ExtractMetadata returns the safe camera metadata of an upload, nil when it carries none
+func (p *ImageProcessor) ExtractMetadata(r io.ReaderAt, size int64) *ImageMetadata {
+	exif := readExif(r, size)
+	if exif == nil || exif.metadata.IsEmpty() {
+		return nil
+	}
+	return &exif.metadata
+}
+
+// applyOrientation rotates/flips img so it displays upright for the given EXIF orientation
+func applyOrientation(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return imaging.FlipH(img)
+	case 3:
+		return imaging.Rotate180(img)
+	case 4:
+		return imaging.FlipV(img)
+	case 5:
+		return imaging.Transpose(img)
+	case 6:
+		return imaging.Rotate270(img)
+	case 7:
+		return imaging.Transverse(img)
+	case 8:
+		return imaging.Rotate90(img)
+	}
+	return img
+}
+
+// decodeOriented decodes an image and applies its EXIF orientation
+func decodeOriented(data []byte) (image.Image, error) {
+	return decodeOrientedAt(bytes.NewReader(data), int64(len(data)))
+}
+
+// decodeOrientedAt is decodeOriented for images that are not held in memory, e.g. spooled uploads
+func decodeOrientedAt(r io.ReaderAt, size int64) (image.Image, error) {
+	img, _, err := image.Decode(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return nil, err
+	}
+	return applyOrientation(img, readOrientation(r, size)), nil
+}
@@ -0,0 +1,124 @@
+package processor
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// OutputFormat is an encoding used for generated derivatives (thumbnails, mobile variants)
+type OutputFormat string
+
+const (
+	FormatJPEG OutputFormat = "jpeg"
+	FormatPNG  OutputFormat = "png"
+	FormatWebP OutputFormat = "webp"
+	FormatAVIF OutputFormat = "avif"
+)
+
+// negotiationOrder lists formats from most to least preferred when the client accepts several
+var negotiationOrder = []OutputFormat{FormatAVIF, FormatWebP, FormatJPEG, FormatPNG}
+
+// ParseOutputFormat parses a format name such as "webp" or "jpg"
+func ParseOutputFormat(name string) (OutputFormat, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "jpeg", "jpg":
+		return FormatJPEG, nil
+	case "png":
+		return FormatPNG, nil
+	case "webp":
+		return FormatWebP, nil
+	case "avif":
+		return FormatAVIF, nil
+	}
+	return "", fmt.Errorf("unknown image format: %s", name)
+}
+
+// Extension returns the file extension including the leading dot
+func (f OutputFormat) Extension() string {
+	if f == FormatJPEG {
+		return ".jpg"
+	}
+	return "." + string(f)
+}
+
+// ContentType returns the MIME type of the format
+func (f OutputFormat) ContentType() string {
+	return "image/" + string(f)
+}
+
+// Lossless reports whether the format is encoded without loss, so that
+// derivatives of photos come out several times larger than the JPEG.
+func (f OutputFormat) Lossless() bool {
+	return f == FormatPNG
+}
+
+// Supported reports whether an encoder for the format is compiled in.
+// WebP and AVIF uploads are decoded, but there is no pure-Go encoder for
+// either that we can ship with CGO disabled.
+func (f OutputFormat) Supported() bool {
+	switch f {
+	case FormatJPEG, FormatPNG:
+		return true
+	}
+	return false
+}
+
+// KeepDerivative reports whether an encoding in format is worth storing next to the JPEG of the
+// same image. Lossless encodings only beat JPEG on flat artwork, for photos they're dropped.
+func KeepDerivative(format OutputFormat, size, jpegSize int) bool {
+	return format == FormatJPEG || !format.Lossless() || size < jpegSize
+}
+
+func encodeImage(w io.Writer, img image.Image, format OutputFormat, jpegQuality int) error {
+	switch format {
+	case FormatJPEG:
+		return imaging.Encode(w, img, imaging.JPEG, imaging.JPEGQuality(jpegQuality))
+	case FormatPNG:
+		return imaging.Encode(w, img, imaging.PNG)
+	}
+	return fmt.Errorf("no encoder available for %s", format)
+}
+
+// NegotiateFormat picks the best available format for an HTTP Accept header.
+// Modern formats are only chosen when listed explicitly, since image/* and */*
+// are sent by clients that cannot necessarily decode them. Falls back to JPEG.
+// Lossless derivatives are only generated when smaller than the JPEG (see KeepDerivative),
+// so preferring whatever is available never serves a heavier file.
+func NegotiateFormat(accept string, available []OutputFormat) OutputFormat {
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(fields[0]))
+		if mediaType == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.TrimSpace(key) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		accepted[mediaType] = q
+	}
+
+	has := make(map[OutputFormat]bool, len(available))
+	for _, f := range available {
+		has[f] = true
+	}
+
+	for _, f := range negotiationOrder {
+		if has[f] && accepted[f.ContentType()] > 0 {
+			return f
+		}
+	}
+	return FormatJPEG
+}
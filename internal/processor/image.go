@@ -1,117 +1,202 @@
 package processor
 
 import (
-	"bytes"
-	"fmt"
 	"image"
 	_ "image/jpeg"
 	_ "image/png"
+	"io"
+	"log"
+	"slices"
+	"strings"
 
 	"github.com/disintegration/imaging"
+	"github.com/pavelc4/pixtify/internal/config"
+	_ "golang.org/x/image/webp"
 )
 
 type ImageProcessor struct {
-	maxSizeBytes int64
-	allowedTypes []string
+	maxSizeBytes        int64
+	allowedTypes        []string
+	blurhashXComponents int
+	blurhashYComponents int
+	derivativeFormats   []OutputFormat
+	renditions          []RenditionSpec
+	crops               []RenditionSpec
+	maxPixels           int64
+	minResolution       map[string]Resolution
+	paletteSize         int
 }
 
-func NewImageProcessor() *ImageProcessor {
-	return &ImageProcessor{
-		maxSizeBytes: 100 * 1024 * 1024, // 100MB
-		allowedTypes: []string{"image/jpeg", "image/png", "image/webp"},
+func NewImageProcessor(cfg config.ImageConfig) *ImageProcessor {
+	p := &ImageProcessor{
+		maxSizeBytes:        100 * 1024 * 1024, // 100MB
+		allowedTypes:        []string{"image/jpeg", "image/png", "image/webp"},
+		blurhashXComponents: cfg.BlurhashXComponents,
+		blurhashYComponents: cfg.BlurhashYComponents,
+		maxPixels:           int64(cfg.MaxMegapixels) * 1000 * 1000,
+		minResolution:       make(map[string]Resolution),
+		paletteSize:         cfg.PaletteSize,
 	}
-}
 
-type ImageInfo struct {
-	Width  int
-	Height int
-	Format string
-}
+	if p.paletteSize < 1 || p.paletteSize > 16 {
+		p.paletteSize = 5
+	}
 
-// ValidateImage checks file type, size, and returns dimensions
-func (p *ImageProcessor) ValidateImage(data []byte, contentType string) (*ImageInfo, error) {
-	// Check size
-	if int64(len(data)) > p.maxSizeBytes {
-		return nil, fmt.Errorf("image too large (max 100MB)")
+	// Caps memory used by a full decode, a 100MP RGBA image is ~400MB
+	if p.maxPixels <= 0 {
+		p.maxPixels = 100 * 1000 * 1000
 	}
 
-	// Check type
-	allowed := false
-	for _, t := range p.allowedTypes {
-		if t == contentType {
-			allowed = true
-			break
+	for deviceType, size := range cfg.MinResolution {
+		res, err := ParseResolution(size)
+		if err != nil {
+			log.Printf("Warning: ignoring minimum resolution for %s: %v", deviceType, err)
+			continue
 		}
+		p.minResolution[deviceType] = res
+	}
+
+	// BlurHash supports 1-9 components per axis, fall back to the common 4x3
+	if p.blurhashXComponents < 1 || p.blurhashXComponents > 9 {
+		p.blurhashXComponents = 4
 	}
-	if !allowed {
-		return nil, fmt.Errorf("unsupported image type: %s", contentType)
+	if p.blurhashYComponents < 1 || p.blurhashYComponents > 9 {
+		p.blurhashYComponents = 3
 	}
 
-	// Decode config to check dimensions without decoding whole image
-	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
-	if err != nil {
-		return nil, fmt.Errorf("invalid image format: %w", err)
+	// JPEG is always generated as the universal fallback, extra formats are opt-in
+	p.derivativeFormats = []OutputFormat{FormatJPEG}
+	for _, name := range cfg.DerivativeFormats {
+		format, err := ParseOutputFormat(name)
+		if err != nil {
+			log.Printf("Warning: ignoring derivative format: %v", err)
+			continue
+		}
+		if !format.Supported() {
+			log.Printf("Warning: ignoring derivative format %s: no encoder available", format)
+			continue
+		}
+		if format != FormatJPEG {
+			p.derivativeFormats = append(p.derivativeFormats, format)
+		}
 	}
 
-	return &ImageInfo{
-		Width:  cfg.Width,
-		Height: cfg.Height,
-		Format: format,
-	}, nil
-}
+	renditions, err := ParseRenditionSpecs(cfg.Renditions)
+	if err != nil {
+		log.Printf("Warning: invalid rendition ladder, renditions disabled: %v", err)
+	}
+	p.renditions = renditions
 
-// GenerateThumbnail creates resized version
-func (p *ImageProcessor) GenerateThumbnail(data []byte, width, height int) ([]byte, error) {
-	img, _, err := image.Decode(bytes.NewReader(data))
+	// Crops are stored next to the renditions, so their names must not overlap
+	crops, err := ParseRenditionSpecs(cfg.Crops)
 	if err != nil {
-		return nil, err
+		log.Printf("Warning: invalid device crops, crops disabled: %v", err)
+	}
+	for _, crop := range crops {
+		if slices.ContainsFunc(renditions, func(r RenditionSpec) bool { return r.Name == crop.Name }) {
+			log.Printf("Warning: ignoring device crop %s: a rendition has the same name", crop.Name)
+			continue
+		}
+		p.crops = append(p.crops, crop)
 	}
 
-	// Resize keeping aspect ratio
-	thumbnail := imaging.Fit(img, width, height, imaging.Lanczos)
+	return p
+}
 
-	buf := new(bytes.Buffer)
-	// Always encode thumbnails as JPEG for efficiency
-	if err := imaging.Encode(buf, thumbnail, imaging.JPEG, imaging.JPEGQuality(85)); err != nil {
-		return nil, err
-	}
+// DerivativeFormats returns the formats thumbnails and variants are generated in, JPEG first
+func (p *ImageProcessor) DerivativeFormats() []OutputFormat {
+	return p.derivativeFormats
+}
 
-	return buf.Bytes(), nil
+type ImageInfo struct {
+	Width       int
+	Height      int
+	Format      string
+	ContentType string
 }
 
-func (p *ImageProcessor) ResizeForMobile(data []byte, maxWidth int, format string) ([]byte, error) {
-	img, _, err := image.Decode(bytes.NewReader(data))
-	if err != nil {
-		return nil, err
+// MaxSizeBytes is the largest upload ValidateImage accepts
+func (p *ImageProcessor) MaxSizeBytes() int64 {
+	return p.maxSizeBytes
+}
+
+// ValidateImage checks size, sniffs the real format from the file content and checks
+// dimensions before anything is fully decoded. The declared content type is only trusted
+// when it agrees with the sniffed one. Rejections are returned as *ValidationError.
+func (p *ImageProcessor) ValidateImage(r io.ReaderAt, size int64, contentType, deviceType string) (*ImageInfo, error) {
+	// Check size
+	if size > p.maxSizeBytes {
+		return nil, validationError(ErrImageTooLarge, "max %dMB", p.maxSizeBytes/(1024*1024))
 	}
 
-	bounds := img.Bounds()
-	origWidth := bounds.Dx()
+	// Sniff type from magic bytes
+	sniffed := sniffFormatAt(r)
+	if sniffed == "" {
+		return nil, validationError(ErrUnsupportedType, "allowed types are %s", strings.Join(p.allowedTypes, ", "))
+	}
+	if !slices.Contains(p.allowedTypes, sniffed.ContentType()) {
+		return nil, validationError(ErrUnsupportedType, "%s", sniffed.ContentType())
+	}
 
-	// If image is already smaller, return nil to indicate no resize needed
-	if origWidth <= maxWidth {
-		return nil, nil
+	// Clients that don't know the type send a generic one, anything else must match
+	declared := strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	if declared == "image/jpg" {
+		declared = "image/jpeg"
+	}
+	if declared != "" && declared != "application/octet-stream" && declared != sniffed.ContentType() {
+		return nil, validationError(ErrContentMismatch, "declared %s but content is %s", declared, sniffed.ContentType())
 	}
 
-	resized := imaging.Resize(img, maxWidth, 0, imaging.Lanczos)
+	// Decode config to check dimensions without decoding whole image
+	cfg, format, err := image.DecodeConfig(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return nil, validationError(ErrInvalidImage, "%v", err)
+	}
+	if format != string(sniffed) {
+		return nil, validationError(ErrContentMismatch, "content is %s but decodes as %s", sniffed, format)
+	}
 
-	buf := new(bytes.Buffer)
+	// Reject decompression bombs: small files declaring huge dimensions
+	if cfg.Width < 1 || cfg.Height < 1 {
+		return nil, validationError(ErrInvalidImage, "image has no pixels")
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > p.maxPixels {
+		return nil, validationError(ErrTooManyPixels, "%dx%d exceeds %d megapixels", cfg.Width, cfg.Height, p.maxPixels/(1000*1000))
+	}
 
-	switch format {
-	case "jpeg":
-		if err := imaging.Encode(buf, resized, imaging.JPEG, imaging.JPEGQuality(100)); err != nil {
-			return nil, err
-		}
-	case "png":
-		if err := imaging.Encode(buf, resized, imaging.PNG); err != nil {
-			return nil, err
-		}
-	default:
-		// Fallback to JPEG with max quality
-		if err := imaging.Encode(buf, resized, imaging.JPEG, imaging.JPEGQuality(100)); err != nil {
-			return nil, err
-		}
+	// Phone photos are often stored sideways with an orientation tag, check the upright size
+	width, height := cfg.Width, cfg.Height
+	if readOrientation(r, size) >= 5 {
+		width, height = height, width
+	}
+
+	if minRes, ok := p.minResolution[deviceType]; ok && (width < minRes.Width || height < minRes.Height) {
+		return nil, validationError(ErrResolutionTooSmall, "%s wallpapers must be at least %dx%d, got %dx%d",
+			deviceType, minRes.Width, minRes.Height, width, height)
 	}
 
-	return buf.Bytes(), nil
+	return &ImageInfo{
+		Width:       width,
+		Height:      height,
+		Format:      format,
+		ContentType: sniffed.ContentType(),
+	}, nil
+}
+
+// Decode decodes an image and applies its EXIF orientation. Derivatives are all generated
+// from the decoded image, so an original is only decoded once however many are made of it.
+func (p *ImageProcessor) Decode(r io.ReaderAt, size int64) (image.Image, error) {
+	return decodeOrientedAt(r, size)
+}
+
+// GenerateThumbnails resizes the image to fit within width x height, keeping the aspect ratio,
+// in every derivative format (see encodeDerivatives)
+func (p *ImageProcessor) GenerateThumbnails(img image.Image, width, height int) ([]Rendition, error) {
+	return p.encodeDerivatives(imaging.Fit(img, width, height, imaging.Lanczos), 85)
+}
+
+// GenerateBlurhash computes a BlurHash placeholder string for the image.
+// The image is sampled down to a few dozen pixels first, its size hardly matters.
+func (p *ImageProcessor) GenerateBlurhash(img image.Image) (string, error) {
+	return encodeBlurhash(img, p.blurhashXComponents, p.blurhashYComponents)
 }
@@ -0,0 +1,111 @@
+package processor
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/pavelc4/pixtify/internal/config"
+)
+
+// photoImage looks like a photo to an encoder: smooth gradients with sensor-like noise
+func photoImage(width, height int) *image.NRGBA {
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			noise := func() int { return rng.Intn(24) - 12 }
+			img.SetNRGBA(x, y, color.NRGBA{
+				R: clampByte(x*255/width + noise()),
+				G: clampByte(y*255/height + noise()),
+				B: clampByte((x+y)*128/(width+height) + 64 + noise()),
+				A: 0xff,
+			})
+		}
+	}
+	return img
+}
+
+// flatImage looks like flat artwork: a few solid color blocks
+func flatImage(width, height int) *image.NRGBA {
+	palette := []color.NRGBA{{0x1e, 0x3a, 0x8a, 0xff}, {0xf5, 0x9e, 0x0b, 0xff}, {0xff, 0xff, 0xff, 0xff}}
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA(x, y, palette[(x/50+y/50)%len(palette)])
+		}
+	}
+	return img
+}
+
+func clampByte(v int) uint8 {
+	return uint8(max(0, min(255, v)))
+}
+
+// photoWebP is the lossless encoding of photoImage(40, 20)
+func photoWebP(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile("testdata/photo.webp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDecodeWebP(t *testing.T) {
+	p := NewImageProcessor(config.ImageConfig{})
+	data := photoWebP(t)
+
+	img, err := p.Decode(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	want := photoImage(40, 20)
+	if img.Bounds() != want.Bounds() {
+		t.Fatalf("bounds = %v, want %v", img.Bounds(), want.Bounds())
+	}
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 40; x++ {
+			if got := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA); got != want.NRGBAAt(x, y) {
+				t.Fatalf("pixel (%d,%d) = %v, want %v", x, y, got, want.NRGBAAt(x, y))
+			}
+		}
+	}
+}
+
+func TestEncodeDerivatives(t *testing.T) {
+	p := NewImageProcessor(config.ImageConfig{DerivativeFormats: []string{"jpeg", "png", "webp"}})
+
+	tests := []struct {
+		name string
+		img  image.Image
+		want []OutputFormat
+	}{
+		{name: "photo", img: photoImage(400, 300), want: []OutputFormat{FormatJPEG}},
+		{name: "flat artwork", img: flatImage(400, 300), want: []OutputFormat{FormatJPEG, FormatPNG}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := p.encodeDerivatives(tt.img, 90)
+			if err != nil {
+				t.Fatalf("encodeDerivatives: %v", err)
+			}
+
+			var got []OutputFormat
+			for _, rd := range encoded {
+				got = append(got, rd.Format)
+				if rd.Width != 400 || rd.Height != 300 {
+					t.Errorf("%s is %dx%d, want 400x300", rd.Format, rd.Width, rd.Height)
+				}
+			}
+			if len(got) != len(tt.want) || got[0] != tt.want[0] || got[len(got)-1] != tt.want[len(tt.want)-1] {
+				t.Errorf("formats = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package processor
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// paletteSampleWidth is the width images are downscaled to before clustering
+const paletteSampleWidth = 64
+
+const paletteIterations = 12
+
+// Lab is a colour in CIE L*a*b* (D65), where euclidean distance approximates perceived difference
+type Lab struct {
+	L float64
+	A float64
+	B float64
+}
+
+// Distance returns the CIE76 delta E between two colours
+func (c Lab) Distance(o Lab) float64 {
+	return math.Sqrt((c.L-o.L)*(c.L-o.L) + (c.A-o.A)*(c.A-o.A) + (c.B-o.B)*(c.B-o.B))
+}
+
+// PaletteColor is one dominant colour of an image and the share of pixels it covers
+type PaletteColor struct {
+	Hex    string
+	Weight float64
+	Lab    Lab
+}
+
+// ParseHexColor parses "#1e3a8a", "1e3a8a" or "#13a" into Lab
+func ParseHexColor(hex string) (Lab, error) {
+	hex = strings.TrimPrefix(strings.TrimSpace(hex), "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return Lab{}, fmt.Errorf("invalid hex color %q", hex)
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return Lab{}, fmt.Errorf("invalid hex color %q", hex)
+	}
+	return rgbToLab(uint8(v>>16), uint8(v>>8), uint8(v)), nil
+}
+
+func rgbToLab(r, g, b uint8) Lab {
+	lr, lg, lb := sRGBToLinear(r), sRGBToLinear(g), sRGBToLinear(b)
+
+	// Linear sRGB to XYZ, normalised by the D65 white point
+	x := (0.4124564*lr + 0.3575761*lg + 0.1804375*lb) / 0.95047
+	y := 0.2126729*lr + 0.7151522*lg + 0.0721750*lb
+	z := (0.0193339*lr + 0.1191920*lg + 0.9503041*lb) / 1.08883
+
+	f := func(t float64) float64 {
+		if t > 216.0/24389.0 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27.0*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+
+	return Lab{L: 116*fy - 16, A: 500 * (fx - fy), B: 200 * (fy - fz)}
+}
+
+type paletteSample struct {
+	lab     Lab
+	r, g, b float64
+}
+
+type paletteCluster struct {
+	center  Lab
+	count   int
+	r, g, b float64
+}
+
+// ExtractPalette finds the dominant colours of an image with k-means in Lab space.
+// The image is sampled down first, clustering only needs a few thousand pixels.
+func (p *ImageProcessor) ExtractPalette(img image.Image) ([]PaletteColor, error) {
+	if img.Bounds().Dx() > paletteSampleWidth {
+		img = imaging.Resize(img, paletteSampleWidth, 0, imaging.Box)
+	}
+	nrgba := imaging.Clone(img)
+
+	var samples []paletteSample
+	for i := 0; i+3 < len(nrgba.Pix); i += 4 {
+		// Transparent areas are not part of what users see as the wallpaper colour
+		if nrgba.Pix[i+3] < 128 {
+			continue
+		}
+		r, g, b := nrgba.Pix[i], nrgba.Pix[i+1], nrgba.Pix[i+2]
+		samples = append(samples, paletteSample{lab: rgbToLab(r, g, b), r: float64(r), g: float64(g), b: float64(b)})
+	}
+	if len(samples) == 0 {
+		return nil, nil
+	}
+
+	k := p.paletteSize
+	if k > len(samples) {
+		k = len(samples)
+	}
+
+	// Deterministic seeding: spread initial centers across the lightness range
+	sorted := make([]paletteSample, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].lab.L < sorted[j].lab.L })
+
+	clusters := make([]paletteCluster, k)
+	for i := range clusters {
+		clusters[i].center = sorted[(2*i+1)*len(sorted)/(2*k)].lab
+	}
+
+	assignment := make([]int, len(samples))
+	for iter := 0; iter < paletteIterations; iter++ {
+		changed := false
+		for i, s := range samples {
+			best, bestDist := 0, math.MaxFloat64
+			for j, c := range clusters {
+				if d := s.lab.Distance(c.center); d < bestDist {
+					best, bestDist = j, d
+				}
+			}
+			if iter == 0 || assignment[i] != best {
+				assignment[i] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+
+		sums := make([]Lab, k)
+		counts := make([]int, k)
+		for i, s := range samples {
+			j := assignment[i]
+			sums[j].L += s.lab.L
+			sums[j].A += s.lab.A
+			sums[j].B += s.lab.B
+			counts[j]++
+		}
+		for j := range clusters {
+			if counts[j] > 0 {
+				n := float64(counts[j])
+				clusters[j].center = Lab{L: sums[j].L / n, A: sums[j].A / n, B: sums[j].B / n}
+			}
+		}
+	}
+
+	for i, s := range samples {
+		c := &clusters[assignment[i]]
+		c.count++
+		c.r += s.r
+		c.g += s.g
+		c.b += s.b
+	}
+
+	palette := make([]PaletteColor, 0, k)
+	for _, c := range clusters {
+		if c.count == 0 {
+			continue
+		}
+		n := float64(c.count)
+		palette = append(palette, PaletteColor{
+			Hex:    fmt.Sprintf("#%02x%02x%02x", uint8(c.r/n+0.5), uint8(c.g/n+0.5), uint8(c.b/n+0.5)),
+			Weight: roundTo(n/float64(len(samples)), 4),
+			Lab:    Lab{L: roundTo(c.center.L, 2), A: roundTo(c.center.A, 2), B: roundTo(c.center.B, 2)},
+		})
+	}
+
+	sort.SliceStable(palette, func(i, j int) bool { return palette[i].Weight > palette[j].Weight })
+	return palette, nil
+}
+
+func roundTo(v float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	rounded := math.Round(v*scale) / scale
+	if rounded == 0 {
+		return 0 // avoid "-0" in JSON
+	}
+	return rounded
+}
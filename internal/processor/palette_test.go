@@ -0,0 +1,166 @@
+package processor
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/pavelc4/pixtify/internal/config"
+)
+
+func TestParseHexColor(t *testing.T) {
+	tests := []struct {
+		hex     string
+		want    Lab
+		wantErr bool
+	}{
+		{hex: "#ffffff", want: Lab{L: 100}},
+		{hex: "#000000", want: Lab{}},
+		{hex: "#808080", want: Lab{L: 53.59}},
+		{hex: "#ff0000", want: Lab{L: 53.24, A: 80.09, B: 67.20}},
+		{hex: "#00ff00", want: Lab{L: 87.73, A: -86.18, B: 83.18}},
+		{hex: "#0000ff", want: Lab{L: 32.30, A: 79.19, B: -107.86}},
+		{hex: "1e3a8a", want: Lab{L: 27.12, A: 20.01, B: -47.60}},
+		{hex: " #F00 ", want: Lab{L: 53.24, A: 80.09, B: 67.20}},
+		{hex: "#ff00", wantErr: true},
+		{hex: "#gggggg", wantErr: true},
+		{hex: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.hex, func(t *testing.T) {
+			got, err := ParseHexColor(tt.hex)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseHexColor(%q) = %+v, want an error", tt.hex, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseHexColor(%q): %v", tt.hex, err)
+			}
+			if got.Distance(tt.want) > 0.01 {
+				t.Errorf("ParseHexColor(%q) = %+v, want %+v", tt.hex, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabDistance(t *testing.T) {
+	lab := func(hex string) Lab {
+		c, err := ParseHexColor(hex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	// Color search matches within a delta E of 20
+	const match = 20.0
+
+	tests := []struct {
+		name      string
+		a, b      string
+		want      float64
+		wantMatch bool
+	}{
+		{name: "same colour", a: "#1e3a8a", b: "#1e3a8a", want: 0, wantMatch: true},
+		{name: "black and white", a: "#000000", b: "#ffffff", want: 100},
+		{name: "close shade of navy", a: "#1e3a8a", b: "#223f90", want: 2.17, wantMatch: true},
+		{name: "navy and royal blue", a: "#1e3a8a", b: "#1d4ed8", want: 33.91},
+		{name: "navy and sky blue", a: "#1e3a8a", b: "#3b82f6", want: 33.19},
+		{name: "navy and amber", a: "#1e3a8a", b: "#f59e0b", want: 130.87},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := lab(tt.a).Distance(lab(tt.b))
+			if math.Abs(got-tt.want) > 0.01 {
+				t.Errorf("delta E = %.2f, want %.2f", got, tt.want)
+			}
+			if reverse := lab(tt.b).Distance(lab(tt.a)); reverse != got {
+				t.Errorf("delta E is not symmetric: %.4f and %.4f", got, reverse)
+			}
+			if (got <= match) != tt.wantMatch {
+				t.Errorf("delta E %.2f matches = %v, want %v", got, got <= match, tt.wantMatch)
+			}
+		})
+	}
+}
+
+func TestExtractPalette(t *testing.T) {
+	navy := color.NRGBA{0x1e, 0x3a, 0x8a, 0xff}
+	amber := color.NRGBA{0xf5, 0x9e, 0x0b, 0xff}
+	hidden := color.NRGBA{0xff, 0x00, 0x00, 0x40}
+
+	// 70% navy and 30% amber, under the sample width so no pixels are blended,
+	// with a mostly transparent red band that must not count
+	img := image.NewNRGBA(image.Rect(0, 0, 60, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 60; x++ {
+			switch {
+			case y >= 10:
+				img.SetNRGBA(x, y, hidden)
+			case x < 42:
+				img.SetNRGBA(x, y, navy)
+			default:
+				img.SetNRGBA(x, y, amber)
+			}
+		}
+	}
+
+	p := NewImageProcessor(config.ImageConfig{})
+	palette, err := p.ExtractPalette(img)
+	if err != nil {
+		t.Fatalf("ExtractPalette: %v", err)
+	}
+
+	want := []struct {
+		hex    string
+		weight float64
+	}{
+		{"#1e3a8a", 0.7},
+		{"#f59e0b", 0.3},
+	}
+	if len(palette) != len(want) {
+		t.Fatalf("palette = %+v, want %d colours", palette, len(want))
+	}
+	for i, w := range want {
+		c := palette[i]
+		if c.Hex != w.hex || c.Weight != w.weight {
+			t.Errorf("colour %d = %s at %.4f, want %s at %.1f", i, c.Hex, c.Weight, w.hex, w.weight)
+		}
+		if target, _ := ParseHexColor(w.hex); c.Lab.Distance(target) > 0.01 {
+			t.Errorf("colour %d Lab = %+v, want %+v", i, c.Lab, target)
+		}
+	}
+
+	t.Run("palette size", func(t *testing.T) {
+		small := NewImageProcessor(config.ImageConfig{PaletteSize: 3})
+		palette, err := small.ExtractPalette(photoImage(200, 120))
+		if err != nil {
+			t.Fatalf("ExtractPalette: %v", err)
+		}
+		if len(palette) != 3 {
+			t.Fatalf("got %d colours, want 3", len(palette))
+		}
+		total := 0.0
+		for i, c := range palette {
+			total += c.Weight
+			if i > 0 && c.Weight > palette[i-1].Weight {
+				t.Errorf("colours not sorted by weight: %+v", palette)
+			}
+		}
+		if math.Abs(total-1) > 0.001 {
+			t.Errorf("weights add up to %.4f, want 1", total)
+		}
+	})
+
+	t.Run("fully transparent", func(t *testing.T) {
+		palette, err := p.ExtractPalette(image.NewNRGBA(image.Rect(0, 0, 10, 10)))
+		if err != nil || palette != nil {
+			t.Errorf("palette = %+v, %v, want none", palette, err)
+		}
+	})
+}
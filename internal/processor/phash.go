@@ -0,0 +1,96 @@
+package processor
+
+import (
+	"io"
+	"math"
+	"math/bits"
+	"sort"
+
+	"github.com/disintegration/imaging"
+)
+
+// pHash works on a 32x32 grayscale copy and keeps the 8x8 lowest DCT frequencies,
+// which survive re-encoding, resizing and small edits
+const (
+	phashSampleSize = 32
+	phashHashSize   = 8
+)
+
+// PerceptualHash computes a 64-bit DCT perceptual hash (pHash) of an image.
+// Visually similar images have hashes with a small Hamming distance.
+func (p *ImageProcessor) PerceptualHash(r io.ReaderAt, size int64) (uint64, error) {
+	img, err := decodeOrientedAt(r, size)
+	if err != nil {
+		return 0, err
+	}
+
+	small := imaging.Grayscale(imaging.Resize(img, phashSampleSize, phashSampleSize, imaging.Lanczos))
+
+	var pixels [phashSampleSize][phashSampleSize]float64
+	for y := 0; y < phashSampleSize; y++ {
+		for x := 0; x < phashSampleSize; x++ {
+			pixels[y][x] = float64(small.Pix[y*small.Stride+x*4])
+		}
+	}
+
+	coeffs := dct2D(pixels)
+
+	// Low frequencies only, the DC term is skipped for the median as it is just average brightness
+	values := make([]float64, 0, phashHashSize*phashHashSize)
+	for y := 0; y < phashHashSize; y++ {
+		for x := 0; x < phashHashSize; x++ {
+			values = append(values, coeffs[y][x])
+		}
+	}
+
+	sorted := append([]float64{}, values[1:]...)
+	sort.Float64s(sorted)
+	median := (sorted[len(sorted)/2-1] + sorted[len(sorted)/2]) / 2
+
+	var hash uint64
+	for i, v := range values {
+		if v > median {
+			hash |= 1 << uint(63-i)
+		}
+	}
+	return hash, nil
+}
+
+// HammingDistance returns the number of differing bits between two hashes
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// dct2D computes the low-frequency part of a 2D DCT-II, only the first phashHashSize rows/cols are needed
+func dct2D(pixels [phashSampleSize][phashSampleSize]float64) [phashHashSize][phashHashSize]float64 {
+	var cos [phashHashSize][phashSampleSize]float64
+	for u := 0; u < phashHashSize; u++ {
+		for x := 0; x < phashSampleSize; x++ {
+			cos[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * phashSampleSize))
+		}
+	}
+
+	// Rows first, then columns
+	var rows [phashSampleSize][phashHashSize]float64
+	for y := 0; y < phashSampleSize; y++ {
+		for u := 0; u < phashHashSize; u++ {
+			var sum float64
+			for x := 0; x < phashSampleSize; x++ {
+				sum += pixels[y][x] * cos[u][x]
+			}
+			rows[y][u] = sum
+		}
+	}
+
+	var out [phashHashSize][phashHashSize]float64
+	for v := 0; v < phashHashSize; v++ {
+		for u := 0; u < phashHashSize; u++ {
+			var sum float64
+			for y := 0; y < phashSampleSize; y++ {
+				sum += rows[y][u] * cos[v][y]
+			}
+			out[v][u] = sum
+		}
+	}
+	return out
+}
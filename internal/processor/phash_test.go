@@ -0,0 +1,120 @@
+package processor
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/pavelc4/pixtify/internal/config"
+)
+
+// duplicateDistance is the default DUPLICATE_MAX_DISTANCE
+const duplicateDistance = 6
+
+func TestPerceptualHash(t *testing.T) {
+	p := NewImageProcessor(config.ImageConfig{})
+
+	hash := func(t *testing.T, img image.Image, encode func(*bytes.Buffer, image.Image) error) uint64 {
+		t.Helper()
+		buf := new(bytes.Buffer)
+		if err := encode(buf, img); err != nil {
+			t.Fatal(err)
+		}
+		h, err := p.PerceptualHash(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatalf("PerceptualHash: %v", err)
+		}
+		return h
+	}
+	jpegQuality := func(q int) func(*bytes.Buffer, image.Image) error {
+		return func(buf *bytes.Buffer, img image.Image) error {
+			return jpeg.Encode(buf, img, &jpeg.Options{Quality: q})
+		}
+	}
+	asPNG := func(buf *bytes.Buffer, img image.Image) error { return png.Encode(buf, img) }
+
+	scene := sceneImage(640, 400)
+	original := hash(t, scene, jpegQuality(90))
+
+	near := []struct {
+		name string
+		img  image.Image
+		enc  func(*bytes.Buffer, image.Image) error
+	}{
+		{name: "same image as png", img: scene, enc: asPNG},
+		{name: "recompressed", img: scene, enc: jpegQuality(40)},
+		{name: "downscaled", img: imaging.Resize(scene, 320, 200, imaging.Lanczos), enc: jpegQuality(85)},
+		{name: "upscaled", img: imaging.Resize(scene, 1280, 800, imaging.Linear), enc: jpegQuality(85)},
+		{name: "brightened", img: imaging.AdjustBrightness(scene, 8), enc: jpegQuality(90)},
+		{name: "slightly cropped", img: imaging.Crop(scene, image.Rect(6, 4, 634, 396)), enc: jpegQuality(90)},
+	}
+	for _, tt := range near {
+		t.Run(tt.name, func(t *testing.T) {
+			if d := HammingDistance(original, hash(t, tt.img, tt.enc)); d > duplicateDistance {
+				t.Errorf("distance = %d, want at most %d", d, duplicateDistance)
+			}
+		})
+	}
+
+	different := []struct {
+		name string
+		img  image.Image
+	}{
+		{name: "mirrored", img: imaging.FlipH(scene)},
+		{name: "upside down", img: imaging.FlipV(scene)},
+		{name: "other scene", img: flatImage(640, 400)},
+		{name: "gradient", img: photoImage(640, 400)},
+	}
+	for _, tt := range different {
+		t.Run(tt.name, func(t *testing.T) {
+			if d := HammingDistance(original, hash(t, tt.img, jpegQuality(90))); d <= duplicateDistance {
+				t.Errorf("distance = %d, want more than %d", d, duplicateDistance)
+			}
+		})
+	}
+}
+
+// sceneImage draws a few large shapes, like the composition of a photo
+func sceneImage(width, height int) *image.NRGBA {
+	img := imaging.New(width, height, color.NRGBA{0x87, 0xce, 0xeb, 0xff}) // sky
+	sun := image.Rect(width*3/4-width/12, height/6, width*3/4+width/12, height/6+width/6)
+	hill := func(x, y int) bool {
+		dx, dy := x-width/4, y-height
+		return dx*dx+dy*dy*4 < width*width/4
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			switch {
+			case hill(x, y):
+				img.SetNRGBA(x, y, color.NRGBA{0x2e, 0x7d, 0x32, 0xff})
+			case y > height*3/4:
+				img.SetNRGBA(x, y, color.NRGBA{0x1e, 0x3a, 0x8a, 0xff}) // lake
+			case image.Pt(x, y).In(sun):
+				img.SetNRGBA(x, y, color.NRGBA{0xf5, 0x9e, 0x0b, 0xff})
+			}
+		}
+	}
+	return img
+}
+
+func TestHammingDistance(t *testing.T) {
+	tests := []struct {
+		a, b uint64
+		want int
+	}{
+		{0, 0, 0},
+		{0, 1, 1},
+		{0xFFFFFFFFFFFFFFFF, 0, 64},
+		{0xF0F0F0F0F0F0F0F0, 0x0F0F0F0F0F0F0F0F, 64},
+		{0b1011, 0b0110, 3},
+	}
+	for _, tt := range tests {
+		if got := HammingDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("HammingDistance(%#x, %#x) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
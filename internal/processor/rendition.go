@@ -0,0 +1,138 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// RenditionSpec is one step of the rendition ladder, e.g. "1080p" fitting within 1920x1080
+type RenditionSpec struct {
+	Name   string
+	Width  int
+	Height int
+}
+
+// Rendition is an encoded, resized copy of an original image
+type Rendition struct {
+	Name   string
+	Width  int
+	Height int
+	Format OutputFormat
+	Data   []byte
+}
+
+// ParseRenditionSpecs parses ladder entries in the form "name:WIDTHxHEIGHT"
+func ParseRenditionSpecs(entries []string) ([]RenditionSpec, error) {
+	specs := make([]RenditionSpec, 0, len(entries))
+	seen := make(map[string]bool, len(entries))
+
+	for _, entry := range entries {
+		name, size, ok := strings.Cut(entry, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid rendition %q: expected name:WIDTHxHEIGHT", entry)
+		}
+
+		width, height, err := parseSize(size)
+		if err != nil {
+			return nil, err
+		}
+
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate rendition name %q", name)
+		}
+		seen[name] = true
+
+		specs = append(specs, RenditionSpec{Name: name, Width: width, Height: height})
+	}
+
+	return specs, nil
+}
+
+// parseSize parses "WIDTHxHEIGHT" into positive dimensions
+func parseSize(size string) (int, int, error) {
+	w, h, ok := strings.Cut(strings.ToLower(strings.TrimSpace(size)), "x")
+	width, errW := strconv.Atoi(w)
+	height, errH := strconv.Atoi(h)
+	if !ok || errW != nil || errH != nil || width < 1 || height < 1 {
+		return 0, 0, fmt.Errorf("invalid size %q: expected WIDTHxHEIGHT", size)
+	}
+	return width, height, nil
+}
+
+// encodeDerivatives encodes img in every derivative format, JPEG first. Lossless encodings
+// are dropped when they aren't smaller than the JPEG (see KeepDerivative).
+func (p *ImageProcessor) encodeDerivatives(img image.Image, jpegQuality int) ([]Rendition, error) {
+	size := img.Bounds().Size()
+	var encoded []Rendition
+	jpegSize := 0
+	for _, format := range p.derivativeFormats {
+		buf := new(bytes.Buffer)
+		if err := encodeImage(buf, img, format, jpegQuality); err != nil {
+			return nil, fmt.Errorf("%s: %w", format, err)
+		}
+		if format == FormatJPEG {
+			jpegSize = buf.Len()
+		} else if !KeepDerivative(format, buf.Len(), jpegSize) {
+			continue
+		}
+		encoded = append(encoded, Rendition{Width: size.X, Height: size.Y, Format: format, Data: buf.Bytes()})
+	}
+	return encoded, nil
+}
+
+// RenditionSpecs returns the configured rendition ladder
+func (p *ImageProcessor) RenditionSpecs() []RenditionSpec {
+	return p.renditions
+}
+
+// GenerateRenditions resizes the image for every step of the ladder, in every derivative format (see encodeDerivatives).
+// Landscape boxes are resolution steps and are rotated for portrait images (a portrait 1080p fits 1080x1920).
+// Portrait boxes are device-shaped (phone-fhd:1080x2400) and only apply to portrait images, landscape
+// ones get phone-shaped versions from the device crops instead. Steps that would upscale the original
+// are skipped, and steps resolving to an already generated size are only produced once.
+func (p *ImageProcessor) GenerateRenditions(img image.Image) ([]Rendition, error) {
+	bounds := img.Bounds()
+	origWidth, origHeight := bounds.Dx(), bounds.Dy()
+	portrait := origHeight > origWidth
+
+	var renditions []Rendition
+	generated := make(map[[2]int]bool)
+
+	for _, spec := range p.renditions {
+		boxW, boxH := spec.Width, spec.Height
+		if boxH > boxW && !portrait {
+			continue
+		}
+		if portrait && boxW > boxH {
+			boxW, boxH = boxH, boxW
+		}
+
+		if origWidth <= boxW && origHeight <= boxH {
+			continue
+		}
+
+		resized := imaging.Fit(img, boxW, boxH, imaging.Lanczos)
+		size := [2]int{resized.Bounds().Dx(), resized.Bounds().Dy()}
+		if generated[size] {
+			continue
+		}
+		generated[size] = true
+
+		encoded, err := p.encodeDerivatives(resized, 90)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode rendition %s: %w", spec.Name, err)
+		}
+		for _, rd := range encoded {
+			rd.Name = spec.Name
+			renditions = append(renditions, rd)
+		}
+	}
+
+	return renditions, nil
+}
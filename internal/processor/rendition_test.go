@@ -0,0 +1,38 @@
+package processor
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pavelc4/pixtify/internal/config"
+)
+
+func TestGenerateRenditionsOrientation(t *testing.T) {
+	p := NewImageProcessor(config.ImageConfig{Renditions: []string{"720p:1280x720", "phone-hd:720x1600"}})
+
+	tests := []struct {
+		name          string
+		width, height int
+		want          []string
+	}{
+		{name: "landscape skips portrait device steps", width: 2000, height: 1000, want: []string{"720p:1280x640"}},
+		{name: "portrait rotates resolution steps", width: 1000, height: 2000, want: []string{"720p:640x1280", "phone-hd:720x1440"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			renditions, err := p.GenerateRenditions(photoImage(tt.width, tt.height))
+			if err != nil {
+				t.Fatalf("GenerateRenditions: %v", err)
+			}
+
+			var got []string
+			for _, rd := range renditions {
+				got = append(got, fmt.Sprintf("%s:%dx%d", rd.Name, rd.Width, rd.Height))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("renditions = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package processor
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// Saliency is measured on a downscaled copy, the crop window only needs to be roughly right
+const saliencySize = 256
+
+// Originals within this share of a crop's aspect ratio already fit it, the regular renditions cover them
+const cropAspectTolerance = 0.05
+
+// FocalPoint is a point of interest in relative coordinates, 0,0 is the top left corner and 1,1 the bottom right
+type FocalPoint struct {
+	X float64
+	Y float64
+}
+
+// Valid reports whether the point lies within the image
+func (f FocalPoint) Valid() bool {
+	return f.X >= 0 && f.X <= 1 && f.Y >= 0 && f.Y <= 1
+}
+
+// CropSpecs returns the configured device crops
+func (p *ImageProcessor) CropSpecs() []RenditionSpec {
+	return p.crops
+}
+
+// GenerateCrops cuts the image to the aspect ratio of every device crop (e.g. a 9:19.5 phone
+// from a 16:9 desktop wallpaper) in every derivative format (see encodeDerivatives). The window keeps the focal point
+// in frame when one is given, otherwise it follows the most detailed part of the image.
+// Crops are never upscaled and crops the image already fits are skipped.
+func (p *ImageProcessor) GenerateCrops(img image.Image, focal *FocalPoint) ([]Rendition, error) {
+	bounds := img.Bounds()
+	origAspect := float64(bounds.Dx()) / float64(bounds.Dy())
+
+	var saliency *saliencyMap
+	var crops []Rendition
+
+	for _, spec := range p.crops {
+		aspect := float64(spec.Width) / float64(spec.Height)
+		if math.Abs(origAspect-aspect)/aspect <= cropAspectTolerance {
+			continue
+		}
+
+		// Computed once, only when a crop needs it
+		if saliency == nil && focal == nil {
+			saliency = newSaliencyMap(img)
+		}
+
+		cropped := fillSmart(img, spec.Width, spec.Height, focal, saliency)
+
+		encoded, err := p.encodeDerivatives(cropped, 90)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode crop %s: %w", spec.Name, err)
+		}
+		for _, rd := range encoded {
+			rd.Name = spec.Name
+			crops = append(crops, rd)
+		}
+	}
+
+	return crops, nil
+}
+
+// fillSmart crops img to the aspect ratio of width x height and scales it down to that size.
+// A box larger than the image shrinks to the largest window of the same aspect ratio.
+// saliency may be nil, it is computed when needed.
+func fillSmart(img image.Image, width, height int, focal *FocalPoint, saliency *saliencyMap) image.Image {
+	rect := cropWindow(img, float64(width)/float64(height), focal, saliency)
+	cropped := imaging.Crop(img, rect)
+
+	// The window already has the right aspect ratio, a smaller one is used as is
+	if rect.Dx() <= width || rect.Dy() <= height {
+		return cropped
+	}
+	return imaging.Resize(cropped, width, height, imaging.Lanczos)
+}
+
+// cropWindow returns the largest window of the given aspect ratio, centred on the focal point
+// as far as the image edges allow, or placed over the most salient part of the image
+func cropWindow(img image.Image, aspect float64, focal *FocalPoint, saliency *saliencyMap) image.Rectangle {
+	bounds := img.Bounds()
+	imgW, imgH := bounds.Dx(), bounds.Dy()
+
+	// Only one axis is cropped, the window spans the other one
+	cropW, cropH := imgW, int(math.Round(float64(imgW)/aspect))
+	if cropH > imgH {
+		cropW, cropH = int(math.Round(float64(imgH)*aspect)), imgH
+	}
+	cropW, cropH = max(1, min(cropW, imgW)), max(1, min(cropH, imgH))
+
+	var x, y int
+	switch {
+	case focal != nil:
+		x = clamp(int(focal.X*float64(imgW))-cropW/2, 0, imgW-cropW)
+		y = clamp(int(focal.Y*float64(imgH))-cropH/2, 0, imgH-cropH)
+	case cropW < imgW:
+		if saliency == nil {
+			saliency = newSaliencyMap(img)
+		}
+		x = saliency.bestOffset(saliency.columns, float64(cropW)/float64(imgW), imgW-cropW)
+	case cropH < imgH:
+		if saliency == nil {
+			saliency = newSaliencyMap(img)
+		}
+		y = saliency.bestOffset(saliency.rows, float64(cropH)/float64(imgH), imgH-cropH)
+	}
+
+	return image.Rect(x, y, x+cropW, y+cropH).Add(bounds.Min)
+}
+
+// saliencyMap sums how much each column and row of a downscaled copy of the image draws the eye:
+// edges and fine detail plus strongly saturated colour, which flat skies and backgrounds lack
+type saliencyMap struct {
+	columns []float64
+	rows    []float64
+}
+
+func newSaliencyMap(img image.Image) *saliencyMap {
+	small := imaging.Fit(img, saliencySize, saliencySize, imaging.Box)
+	w, h := small.Bounds().Dx(), small.Bounds().Dy()
+
+	luma := make([]float64, w*h)
+	saturation := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := small.PixOffset(x, y)
+			r, g, b := float64(small.Pix[i]), float64(small.Pix[i+1]), float64(small.Pix[i+2])
+			luma[y*w+x] = 0.299*r + 0.587*g + 0.114*b
+
+			hi, lo := max(r, g, b), min(r, g, b)
+			if hi > 0 {
+				saturation[y*w+x] = (hi - lo) / hi * 255
+			}
+		}
+	}
+
+	m := &saliencyMap{columns: make([]float64, w), rows: make([]float64, h)}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			at := func(dx, dy int) float64 {
+				return luma[clamp(y+dy, 0, h-1)*w+clamp(x+dx, 0, w-1)]
+			}
+			// Laplacian, strong on edges and texture and zero on smooth gradients
+			edge := math.Abs(4*at(0, 0) - at(-1, 0) - at(1, 0) - at(0, -1) - at(0, 1))
+			score := edge + 0.2*saturation[y*w+x]
+
+			m.columns[x] += score
+			m.rows[y] += score
+		}
+	}
+	return m
+}
+
+// bestOffset slides a window covering share of the scores and returns the pixel offset
+// (0 to maxOffset) of the window with the highest total. Ties go to the most central window.
+func (m *saliencyMap) bestOffset(scores []float64, share float64, maxOffset int) int {
+	n := len(scores)
+	size := clamp(int(math.Round(share*float64(n))), 1, n)
+	if size == n {
+		return maxOffset / 2
+	}
+
+	prefix := make([]float64, n+1)
+	for i, s := range scores {
+		prefix[i+1] = prefix[i] + s
+	}
+
+	// A small pull towards the centre settles near ties and keeps flat images centred
+	center := float64(n-size) / 2
+	windowScore := func(start int) float64 {
+		sum := prefix[start+size] - prefix[start]
+		return sum * (1 - 0.1*math.Abs(float64(start)-center)/float64(n))
+	}
+
+	best := (n - size) / 2
+	bestScore := windowScore(best)
+	for start := 0; start <= n-size; start++ {
+		if score := windowScore(start); score > bestScore {
+			best, bestScore = start, score
+		}
+	}
+
+	return clamp(int(math.Round(float64(best)/float64(n-size)*float64(maxOffset))), 0, maxOffset)
+}
+
+func clamp(v, lo, hi int) int {
+	return max(lo, min(v, hi))
+}
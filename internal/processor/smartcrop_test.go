@@ -0,0 +1,84 @@
+package processor
+
+import (
+	"image"
+	"testing"
+)
+
+func TestCropWindowFocalPoint(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 1600, 900))
+
+	phone := 9 / 19.5    // 415x900 window, cropped horizontally
+	ultrawide := 32 / 9. // 1600x450 window, cropped vertically
+
+	tests := []struct {
+		name   string
+		aspect float64
+		focal  FocalPoint
+		want   image.Rectangle
+	}{
+		{name: "centre", aspect: phone, focal: FocalPoint{X: 0.5, Y: 0.5}, want: image.Rect(593, 0, 1008, 900)},
+		{name: "left edge", aspect: phone, focal: FocalPoint{X: 0, Y: 0.5}, want: image.Rect(0, 0, 415, 900)},
+		{name: "near the left edge", aspect: phone, focal: FocalPoint{X: 0.1, Y: 0.5}, want: image.Rect(0, 0, 415, 900)},
+		{name: "right edge", aspect: phone, focal: FocalPoint{X: 1, Y: 0.5}, want: image.Rect(1185, 0, 1600, 900)},
+		{name: "near the right edge", aspect: phone, focal: FocalPoint{X: 0.95, Y: 0.5}, want: image.Rect(1185, 0, 1600, 900)},
+		{name: "top left corner", aspect: phone, focal: FocalPoint{X: 0, Y: 0}, want: image.Rect(0, 0, 415, 900)},
+		{name: "top edge", aspect: ultrawide, focal: FocalPoint{X: 0.5, Y: 0}, want: image.Rect(0, 0, 1600, 450)},
+		{name: "bottom edge", aspect: ultrawide, focal: FocalPoint{X: 0.5, Y: 1}, want: image.Rect(0, 450, 1600, 900)},
+		{name: "bottom right corner", aspect: ultrawide, focal: FocalPoint{X: 1, Y: 1}, want: image.Rect(0, 450, 1600, 900)},
+		{name: "same aspect ratio", aspect: 16 / 9., focal: FocalPoint{X: 1, Y: 1}, want: image.Rect(0, 0, 1600, 900)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cropWindow(img, tt.aspect, &tt.focal, nil)
+			if got != tt.want {
+				t.Errorf("window = %v, want %v", got, tt.want)
+			}
+			if !got.In(img.Bounds()) {
+				t.Errorf("window %v outside the image %v", got, img.Bounds())
+			}
+		})
+	}
+}
+
+func TestCropWindowOffsetBounds(t *testing.T) {
+	// A sub-image doesn't start at 0,0, the window has to be placed within its bounds
+	parent := image.NewNRGBA(image.Rect(0, 0, 2000, 1000))
+	img := parent.SubImage(image.Rect(200, 50, 1800, 950))
+
+	for _, focal := range []FocalPoint{{X: 0, Y: 0}, {X: 0.5, Y: 0.5}, {X: 1, Y: 1}} {
+		got := cropWindow(img, 9/19.5, &focal, nil)
+		if !got.In(img.Bounds()) || got.Dx() != 415 || got.Dy() != 900 {
+			t.Errorf("focal %+v: window = %v, want a 415x900 window inside %v", focal, got, img.Bounds())
+		}
+	}
+}
+
+func TestFillSmartFocalPoint(t *testing.T) {
+	// A focal point on the edge still yields a full crop of the requested size
+	for _, focal := range []FocalPoint{{X: 0, Y: 0}, {X: 1, Y: 1}} {
+		out := fillSmart(photoImage(1600, 900), 207, 450, &focal, nil)
+		if size := out.Bounds().Size(); size != image.Pt(207, 450) {
+			t.Errorf("focal %+v: size = %v, want 207x450", focal, size)
+		}
+	}
+}
+
+func TestFocalPointValid(t *testing.T) {
+	tests := []struct {
+		focal FocalPoint
+		want  bool
+	}{
+		{FocalPoint{X: 0, Y: 0}, true},
+		{FocalPoint{X: 1, Y: 1}, true},
+		{FocalPoint{X: 0.3, Y: 0.7}, true},
+		{FocalPoint{X: -0.01, Y: 0.5}, false},
+		{FocalPoint{X: 0.5, Y: 1.01}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.focal.Valid(); got != tt.want {
+			t.Errorf("%+v.Valid() = %v, want %v", tt.focal, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+var exifHeader = []byte("Exif\x00\x00")
+
+// orientationExif builds a minimal TIFF block holding nothing but the orientation tag
+func orientationExif(orientation int) []byte {
+	buf := make([]byte, 26)
+	copy(buf, "MM\x00\x2A")
+	binary.BigEndian.PutUint32(buf[4:], 8)
+	binary.BigEndian.PutUint16(buf[8:], 1)
+	binary.BigEndian.PutUint16(buf[10:], tagOrientation)
+	binary.BigEndian.PutUint16(buf[12:], 3)
+	binary.BigEndian.PutUint32(buf[14:], 1)
+	binary.BigEndian.PutUint16(buf[18:], uint16(orientation))
+	return buf
+}
+
+// copyRange copies bytes [start, end) of r to w
+func copyRange(w io.Writer, r io.ReaderAt, start, end int64) error {
+	_, err := io.Copy(w, io.NewSectionReader(r, start, end-start))
+	return err
+}
+
+// StripMetadata streams the image in r to w without EXIF, XMP, IPTC and comments, without
+// re-encoding it. Colour profiles are kept, and the orientation tag is written back on its
+// own so the stored original still displays upright.
+func (p *ImageProcessor) StripMetadata(w io.Writer, r io.ReaderAt, size int64) error {
+	orientation := readOrientation(r, size)
+
+	bw := bufio.NewWriter(w)
+
+	var err error
+	switch sniffFormatAt(r) {
+	case FormatJPEG:
+		err = stripJPEG(bw, r, size, orientation)
+	case FormatPNG:
+		err = stripPNG(bw, r, size, orientation)
+	case FormatWebP:
+		err = stripWebP(bw, r, size, orientation)
+	default:
+		return validationError(ErrUnsupportedType, "cannot strip metadata")
+	}
+	if err != nil {
+		return validationError(ErrInvalidImage, "%v", err)
+	}
+	return bw.Flush()
+}
+
+func stripJPEG(w io.Writer, r io.ReaderAt, size int64, orientation int) error {
+	segments, scanStart, err := jpegChunks(r, size)
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write([]byte{0xFF, 0xD8}); err != nil {
+		return err
+	}
+
+	wroteOrientation := orientation == 1
+	for i, seg := range segments {
+		marker := seg.typ[0]
+		switch {
+		case marker == 0xE0, marker == 0xEE:
+			// JFIF and Adobe segments affect decoding
+		case marker == 0xE2:
+			// Only ICC profiles are kept from APP2 (MPF carries secondary images with their own EXIF)
+			prefix, err := readFull(r, seg.offset, min(int(seg.size), 12))
+			if err != nil || !bytes.Equal(prefix, []byte("ICC_PROFILE\x00")) {
+				continue
+			}
+		case marker >= 0xE0 && marker <= 0xEF, marker == 0xFE:
+			continue
+		}
+
+		if !wroteOrientation && (i > 0 || marker != 0xE0) {
+			payload := append(append([]byte{}, exifHeader...), orientationExif(orientation)...)
+			header := []byte{0xFF, 0xE1, 0, 0}
+			binary.BigEndian.PutUint16(header[2:], uint16(len(payload)+2))
+			if _, err := w.Write(append(header, payload...)); err != nil {
+				return err
+			}
+			wroteOrientation = true
+		}
+		if err := copyRange(w, r, seg.start, seg.end); err != nil {
+			return err
+		}
+	}
+
+	return copyJPEGScans(w, io.NewSectionReader(r, scanStart, size-scanStart))
+}
+
+// copyJPEGScans copies image data from the first SOS marker through EOI.
+// Anything trailing EOI (e.g. MPF secondary images) is dropped.
+func copyJPEGScans(w io.Writer, src io.Reader) error {
+	br := bufio.NewReaderSize(src, 64*1024)
+	for {
+		// Entropy-coded data up to the next 0xFF
+		data, err := br.ReadSlice(0xFF)
+		if _, werr := w.Write(data); werr != nil {
+			return werr
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF {
+			return nil // truncated file without EOI, keep what there is
+		}
+		if err != nil {
+			return err
+		}
+
+		next, err := br.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case next == 0x00, next >= 0xD0 && next <= 0xD7:
+			// Stuffed byte or restart marker inside the scan
+			if _, err := w.Write([]byte{next}); err != nil {
+				return err
+			}
+		case next == 0xFF:
+			// Fill byte, the next 0xFF starts the marker
+			br.UnreadByte()
+		case next == 0xD9:
+			_, err := w.Write([]byte{next})
+			return err
+		default:
+			// Marker segment between scans (SOS, DHT, DRI...), copied whole
+			lengthBytes := make([]byte, 2)
+			if _, err := io.ReadFull(br, lengthBytes); err != nil {
+				return err
+			}
+			length := int64(binary.BigEndian.Uint16(lengthBytes))
+			if length < 2 {
+				return fmt.Errorf("invalid jpeg segment length")
+			}
+			if _, err := w.Write([]byte{next, lengthBytes[0], lengthBytes[1]}); err != nil {
+				return err
+			}
+			if _, err := io.CopyN(w, br, length-2); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func stripPNG(w io.Writer, r io.ReaderAt, size int64, orientation int) error {
+	chunks, err := pngChunks(r, size)
+	if err != nil {
+		return err
+	}
+
+	if err := copyRange(w, r, 0, 8); err != nil {
+		return err
+	}
+
+	for _, c := range chunks {
+		switch c.typ {
+		case "eXIf", "tEXt", "zTXt", "iTXt", "tIME":
+			continue
+		}
+		if err := copyRange(w, r, c.start, c.end); err != nil {
+			return err
+		}
+
+		if c.typ == "IHDR" && orientation != 1 {
+			if err := writePNGChunk(w, "eXIf", orientationExif(orientation)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func writePNGChunk(w io.Writer, typ string, payload []byte) error {
+	buf := binary.BigEndian.AppendUint32(nil, uint32(len(payload)))
+	buf = append(buf, typ...)
+	buf = append(buf, payload...)
+	buf = binary.BigEndian.AppendUint32(buf, crc32.ChecksumIEEE(buf[4:]))
+	_, err := w.Write(buf)
+	return err
+}
+
+func stripWebP(w io.Writer, r io.ReaderAt, size int64, orientation int) error {
+	chunks, err := riffChunks(r, size)
+	if err != nil {
+		return err
+	}
+	if len(chunks) == 0 {
+		return fmt.Errorf("invalid webp container")
+	}
+
+	// Simple (VP8/VP8L only) files cannot carry metadata
+	if chunks[0].typ != "VP8X" {
+		return copyRange(w, r, 0, size)
+	}
+
+	var exif []byte
+	if orientation != 1 {
+		exif = orientationExif(orientation)
+	}
+
+	// The RIFF size goes first, so work it out before writing anything
+	riffSize := int64(4)
+	for _, c := range chunks {
+		if c.typ != "EXIF" && c.typ != "XMP " {
+			riffSize += c.end - c.start
+		}
+	}
+	if exif != nil {
+		riffSize += 8 + int64(len(exif))
+	}
+
+	header := []byte("RIFF\x00\x00\x00\x00WEBP")
+	binary.LittleEndian.PutUint32(header[4:], uint32(riffSize))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	for _, c := range chunks {
+		switch c.typ {
+		case "EXIF", "XMP ":
+			continue
+		case "VP8X":
+			vp8x, err := readFull(r, c.start, int(c.end-c.start))
+			if err != nil {
+				return err
+			}
+			if len(vp8x) > 8 {
+				vp8x[8] &^= 0x08 | 0x04 // EXIF and XMP flags
+				if exif != nil {
+					vp8x[8] |= 0x08
+				}
+			}
+			if _, err := w.Write(vp8x); err != nil {
+				return err
+			}
+		default:
+			if err := copyRange(w, r, c.start, c.end); err != nil {
+				return err
+			}
+		}
+	}
+
+	if exif != nil {
+		chunk := append([]byte("EXIF"), binary.LittleEndian.AppendUint32(nil, uint32(len(exif)))...)
+		if _, err := w.Write(append(chunk, exif...)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,242 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/pavelc4/pixtify/internal/config"
+)
+
+// Everything private in the fixtures contains this marker, none of it may survive stripping
+const privateMarker = "PRIVATE"
+
+// testExif builds a big-endian EXIF block with a camera make, an aperture, the orientation
+// and a GPS IFD whose processing method spells out the private marker
+func testExif(orientation int) []byte {
+	const (
+		ifd0    = 8
+		exifIFD = ifd0 + 2 + 4*12 + 4
+		gpsIFD  = exifIFD + 2 + 12 + 4
+		data    = gpsIFD + 2 + 12 + 4
+	)
+	cameraMake := []byte("Canon\x00")
+	gps := []byte("GPS " + privateMarker)
+
+	buf := make([]byte, data+len(cameraMake)+8+len(gps))
+	copy(buf, "MM\x00\x2A")
+	binary.BigEndian.PutUint32(buf[4:], ifd0)
+
+	entry := func(at int, tag, typ uint16, count, value uint32) {
+		binary.BigEndian.PutUint16(buf[at:], tag)
+		binary.BigEndian.PutUint16(buf[at+2:], typ)
+		binary.BigEndian.PutUint32(buf[at+4:], count)
+		binary.BigEndian.PutUint32(buf[at+8:], value)
+	}
+
+	binary.BigEndian.PutUint16(buf[ifd0:], 4)
+	entry(ifd0+2, tagMake, 2, uint32(len(cameraMake)), data)
+	entry(ifd0+14, tagOrientation, 3, 1, uint32(orientation)<<16)
+	entry(ifd0+26, tagExifIFD, 4, 1, exifIFD)
+	entry(ifd0+38, 0x8825, 4, 1, gpsIFD) // GPS IFD pointer
+
+	binary.BigEndian.PutUint16(buf[exifIFD:], 1)
+	entry(exifIFD+2, tagFNumber, 5, 1, data+uint32(len(cameraMake)))
+
+	binary.BigEndian.PutUint16(buf[gpsIFD:], 1)
+	entry(gpsIFD+2, 0x001B, 7, uint32(len(gps)), data+uint32(len(cameraMake))+8) // GPSProcessingMethod
+
+	at := copy(buf[data:], cameraMake) + data
+	binary.BigEndian.PutUint32(buf[at:], 28)
+	binary.BigEndian.PutUint32(buf[at+4:], 10)
+	copy(buf[at+8:], gps)
+	return buf
+}
+
+var (
+	testXMP = []byte(`<x:xmpmeta xmlns:x="adobe:ns:meta/"><exif:GPSLatitude>` + privateMarker + `</exif:GPSLatitude></x:xmpmeta>`)
+	testICC = []byte("ICC profile kept")
+)
+
+// jpegSegment encodes a marker segment with its length
+func jpegSegment(marker byte, payload []byte) []byte {
+	seg := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+// metadataJPEG is a 40x20 JPEG with EXIF, XMP, an ICC profile and a comment
+func metadataJPEG(t *testing.T, orientation int) []byte {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, photoImage(40, 20), nil); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+
+	out := append([]byte{}, encoded[:2]...)
+	out = append(out, jpegSegment(0xE1, append(append([]byte{}, exifHeader...), testExif(orientation)...))...)
+	out = append(out, jpegSegment(0xE1, append([]byte("http://ns.adobe.com/xap/1.0/\x00"), testXMP...))...)
+	out = append(out, jpegSegment(0xE2, append([]byte("ICC_PROFILE\x00\x01\x01"), testICC...))...)
+	out = append(out, jpegSegment(0xFE, []byte("comment "+privateMarker))...)
+	return append(out, encoded[2:]...)
+}
+
+// metadataPNG is a 40x20 PNG with an eXIf chunk, XMP in iTXt, an ICC profile and a text chunk
+func metadataPNG(t *testing.T, orientation int) []byte {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, photoImage(40, 20)); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+	ihdrEnd := 8 + 12 + 13
+
+	chunks := new(bytes.Buffer)
+	for _, c := range []struct {
+		typ     string
+		payload []byte
+	}{
+		{"iCCP", append([]byte("icc\x00\x00"), testICC...)},
+		{"eXIf", testExif(orientation)},
+		{"iTXt", append([]byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00"), testXMP...)},
+		{"tEXt", []byte("Comment\x00" + privateMarker)},
+	} {
+		if err := writePNGChunk(chunks, c.typ, c.payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := append([]byte{}, encoded[:ihdrEnd]...)
+	out = append(out, chunks.Bytes()...)
+	return append(out, encoded[ihdrEnd:]...)
+}
+
+// metadataWebP is a 40x20 extended WebP with ICCP, EXIF and XMP chunks
+func metadataWebP(t *testing.T, orientation int) []byte {
+	t.Helper()
+
+	vp8l := photoWebP(t)[12:] // the VP8L chunk of a simple file
+
+	riffChunk := func(typ string, payload []byte) []byte {
+		c := append([]byte(typ), binary.LittleEndian.AppendUint32(nil, uint32(len(payload)))...)
+		c = append(c, payload...)
+		if len(payload)%2 == 1 {
+			c = append(c, 0)
+		}
+		return c
+	}
+
+	vp8x := make([]byte, 10)
+	vp8x[0] = 0x20 | 0x08 | 0x04 // ICC, EXIF and XMP flags
+	vp8x[4], vp8x[7] = 40-1, 20-1
+
+	body := []byte("WEBP")
+	body = append(body, riffChunk("VP8X", vp8x)...)
+	body = append(body, riffChunk("ICCP", testICC)...)
+	body = append(body, vp8l...)
+	body = append(body, riffChunk("EXIF", testExif(orientation))...)
+	body = append(body, riffChunk("XMP ", testXMP)...)
+
+	out := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	return append(out, body...)
+}
+
+func TestStripMetadata(t *testing.T) {
+	p := NewImageProcessor(config.ImageConfig{})
+
+	tests := []struct {
+		name        string
+		fixture     func(t *testing.T, orientation int) []byte
+		orientation int
+	}{
+		{name: "jpeg", fixture: metadataJPEG, orientation: 6},
+		{name: "jpeg upright", fixture: metadataJPEG, orientation: 1},
+		{name: "png", fixture: metadataPNG, orientation: 6},
+		{name: "png upright", fixture: metadataPNG, orientation: 1},
+		{name: "webp", fixture: metadataWebP, orientation: 6},
+		{name: "webp upright", fixture: metadataWebP, orientation: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := tt.fixture(t, tt.orientation)
+
+			// The fixture carries what is supposed to be removed
+			if !bytes.Contains(in, []byte(privateMarker)) {
+				t.Fatal("fixture has no private data")
+			}
+			if m := p.ExtractMetadata(bytes.NewReader(in), int64(len(in))); m == nil || m.CameraMake != "Canon" || m.Aperture != 2.8 {
+				t.Fatalf("metadata of the fixture = %+v, want Canon at f/2.8", m)
+			}
+
+			out := new(bytes.Buffer)
+			if err := p.StripMetadata(out, bytes.NewReader(in), int64(len(in))); err != nil {
+				t.Fatalf("StripMetadata: %v", err)
+			}
+			stripped := out.Bytes()
+
+			if bytes.Contains(stripped, []byte(privateMarker)) {
+				t.Error("GPS, XMP or comments left in the output")
+			}
+			if bytes.Contains(stripped, []byte("Canon")) {
+				t.Error("camera make left in the output")
+			}
+			if !bytes.Contains(stripped, testICC) {
+				t.Error("ICC profile dropped")
+			}
+			if got := readOrientation(bytes.NewReader(stripped), int64(len(stripped))); got != tt.orientation {
+				t.Errorf("orientation = %d, want %d", got, tt.orientation)
+			}
+
+			img, err := p.Decode(bytes.NewReader(stripped), int64(len(stripped)))
+			if err != nil {
+				t.Fatalf("stripped image does not decode: %v", err)
+			}
+			wantW, wantH := 40, 20
+			if tt.orientation == 6 {
+				wantW, wantH = 20, 40
+			}
+			if size := img.Bounds().Size(); size != image.Pt(wantW, wantH) {
+				t.Errorf("decoded size = %v, want %dx%d", size, wantW, wantH)
+			}
+		})
+	}
+}
+
+func TestStripMetadataTruncated(t *testing.T) {
+	p := NewImageProcessor(config.ImageConfig{})
+
+	// A segment claiming more bytes than the file has
+	jpegData := metadataJPEG(t, 1)
+	truncatedJPEG := append(append([]byte{}, jpegData[:2]...), jpegSegment(0xE1, []byte("Exif\x00\x00MM"))...)
+	binary.BigEndian.PutUint16(truncatedJPEG[4:], 0x4000)
+
+	pngData := metadataPNG(t, 1)
+	webpData := metadataWebP(t, 1)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "jpeg segment", data: truncatedJPEG},
+		{name: "png chunk", data: pngData[:8+12+13+20]},
+		{name: "webp chunk", data: webpData[:40]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.StripMetadata(new(bytes.Buffer), bytes.NewReader(tt.data), int64(len(tt.data)))
+			var validation *ValidationError
+			if !errors.As(err, &validation) || !errors.Is(err, ErrInvalidImage) {
+				t.Errorf("err = %v, want an ErrInvalidImage validation error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+// How a transform fits the image into the requested box
+const (
+	FitContain = "contain" // scale to fit within the box, keeping the aspect ratio
+	FitCover   = "cover"   // scale and crop to fill the box exactly
+)
+
+// MaxTransformDimension caps the width and height of on-demand transforms
+const MaxTransformDimension = 7680
+
+// TransformOptions describes an on-demand resize, parsed from a "w=1080,h=2400,fit=cover,fmt=png,q=80" string.
+// Width or Height may be 0 to follow the aspect ratio, cover needs both.
+type TransformOptions struct {
+	Width   int
+	Height  int
+	Fit     string
+	Format  OutputFormat
+	Quality int
+}
+
+// ParseTransformOptions parses and validates comma separated key=value transform parameters:
+// w and h (pixels), fit (contain or cover), fmt (jpeg or png) and q (JPEG quality 1-100)
+func ParseTransformOptions(params string) (TransformOptions, error) {
+	opts := TransformOptions{Fit: FitContain, Format: FormatJPEG, Quality: 85}
+
+	for _, part := range strings.Split(params, ",") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return opts, fmt.Errorf("invalid parameter %q: expected key=value", part)
+		}
+
+		var err error
+		switch key {
+		case "w":
+			opts.Width, err = parseDimension(value)
+		case "h":
+			opts.Height, err = parseDimension(value)
+		case "fit":
+			if value != FitContain && value != FitCover {
+				err = fmt.Errorf("fit must be %s or %s", FitContain, FitCover)
+			}
+			opts.Fit = value
+		case "fmt":
+			opts.Format, err = ParseOutputFormat(value)
+			if err == nil && !opts.Format.Supported() {
+				err = fmt.Errorf("no encoder available for %s", opts.Format)
+			}
+		case "q":
+			opts.Quality, err = strconv.Atoi(value)
+			if err != nil || opts.Quality < 1 || opts.Quality > 100 {
+				err = fmt.Errorf("quality must be between 1 and 100")
+			}
+		default:
+			err = fmt.Errorf("unknown parameter %q", key)
+		}
+		if err != nil {
+			return opts, fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+
+	if opts.Width == 0 && opts.Height == 0 {
+		return opts, fmt.Errorf("w or h is required")
+	}
+	if opts.Fit == FitCover && (opts.Width == 0 || opts.Height == 0) {
+		return opts, fmt.Errorf("fit=cover needs both w and h")
+	}
+	return opts, nil
+}
+
+func parseDimension(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > MaxTransformDimension {
+		return 0, fmt.Errorf("must be between 1 and %d", MaxTransformDimension)
+	}
+	return n, nil
+}
+
+// String returns the canonical form of the options, equal options give equal strings.
+// A side following the aspect ratio is left out, it parses back to the same options.
+func (o TransformOptions) String() string {
+	var parts []string
+	if o.Width > 0 {
+		parts = append(parts, fmt.Sprintf("w=%d", o.Width))
+	}
+	if o.Height > 0 {
+		parts = append(parts, fmt.Sprintf("h=%d", o.Height))
+	}
+	parts = append(parts, "fit="+o.Fit, fmt.Sprintf("fmt=%s", o.Format), fmt.Sprintf("q=%d", o.Quality))
+	return strings.Join(parts, ",")
+}
+
+// Transform resizes the image as described by opts. Images are never upscaled,
+// a cover crop larger than the image shrinks to the largest box of the same aspect ratio.
+// Cover crops keep the focal point in frame, or the most detailed part of the image without one.
+func (p *ImageProcessor) Transform(data []byte, opts TransformOptions, focal *FocalPoint) ([]byte, error) {
+	img, err := decodeOriented(data)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := img.Bounds()
+	origWidth, origHeight := bounds.Dx(), bounds.Dy()
+	width, height := opts.Width, opts.Height
+
+	switch {
+	case opts.Fit == FitCover:
+		img = fillSmart(img, width, height, focal, nil)
+	case width == 0:
+		img = imaging.Resize(img, 0, min(height, origHeight), imaging.Lanczos)
+	case height == 0:
+		img = imaging.Resize(img, min(width, origWidth), 0, imaging.Lanczos)
+	default:
+		img = imaging.Fit(img, width, height, imaging.Lanczos)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := encodeImage(buf, img, opts.Format, opts.Quality); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
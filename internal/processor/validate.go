@@ -0,0 +1,67 @@
+package processor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrImageTooLarge      = errors.New("image too large")
+	ErrUnsupportedType    = errors.New("unsupported image type")
+	ErrContentMismatch    = errors.New("image content does not match its declared type")
+	ErrInvalidImage       = errors.New("invalid image")
+	ErrTooManyPixels      = errors.New("image dimensions too large")
+	ErrResolutionTooSmall = errors.New("image resolution too small")
+)
+
+// ValidationError is returned by ValidateImage when an upload is rejected.
+// Kind is one of the Err* sentinels above so callers can map it with errors.Is.
+type ValidationError struct {
+	Kind   error
+	Detail string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Detail == "" {
+		return e.Kind.Error()
+	}
+	return fmt.Sprintf("%s: %s", e.Kind, e.Detail)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Kind
+}
+
+func validationError(kind error, format string, args ...any) error {
+	return &ValidationError{Kind: kind, Detail: fmt.Sprintf(format, args...)}
+}
+
+// Resolution is a minimum width x height an upload must reach
+type Resolution struct {
+	Width  int
+	Height int
+}
+
+// ParseResolution parses a size in the form "WIDTHxHEIGHT"
+func ParseResolution(s string) (Resolution, error) {
+	width, height, err := parseSize(s)
+	if err != nil {
+		return Resolution{}, err
+	}
+	return Resolution{Width: width, Height: height}, nil
+}
+
+// sniffFormat detects the image format from its leading magic bytes.
+// Returns "" for anything that is not a supported upload format.
+func sniffFormat(data []byte) OutputFormat {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8, 0xFF}):
+		return FormatJPEG
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return FormatPNG
+	case len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return FormatWebP
+	}
+	return ""
+}
@@ -0,0 +1,201 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/pavelc4/pixtify/internal/config"
+)
+
+// bombPNG is a PNG of a few dozen bytes whose header claims width x height pixels
+func bombPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	ihdr := binary.BigEndian.AppendUint32(nil, uint32(width))
+	ihdr = binary.BigEndian.AppendUint32(ihdr, uint32(height))
+	ihdr = append(ihdr, 8, 6, 0, 0, 0) // 8-bit RGBA, no interlace
+
+	buf := bytes.NewBufferString("\x89PNG\r\n\x1a\n")
+	if err := writePNGChunk(buf, "IHDR", ihdr); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePNGChunk(buf, "IEND", nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// jpegBytes and pngBytes encode a plain test photo of the given size
+func jpegBytes(t *testing.T, w, h int) []byte {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, photoImage(w, h), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func pngBytes(t *testing.T, w, h int) []byte {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, photoImage(w, h)); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestValidateImage(t *testing.T) {
+	p := NewImageProcessor(config.ImageConfig{
+		MaxMegapixels: 100,
+		MinResolution: map[string]string{"mobile": "20x40", "desktop": "40x20"},
+	})
+
+	tests := []struct {
+		name        string
+		data        func(t *testing.T) []byte
+		size        int64 // overrides len(data) when set
+		contentType string
+		deviceType  string
+		wantErr     error
+		wantW       int
+		wantH       int
+	}{
+		{
+			name:        "jpeg as declared",
+			data:        func(t *testing.T) []byte { return jpegBytes(t, 40, 20) },
+			contentType: "image/jpeg",
+			wantW:       40, wantH: 20,
+		},
+		{
+			name:        "jpg alias and parameters",
+			data:        func(t *testing.T) []byte { return jpegBytes(t, 40, 20) },
+			contentType: "Image/JPG; charset=binary",
+			wantW:       40, wantH: 20,
+		},
+		{
+			name:        "octet-stream uses the sniffed type",
+			data:        func(t *testing.T) []byte { return pngBytes(t, 40, 20) },
+			contentType: "application/octet-stream",
+			wantW:       40, wantH: 20,
+		},
+		{
+			name:  "no declared type",
+			data:  func(t *testing.T) []byte { return pngBytes(t, 40, 20) },
+			wantW: 40, wantH: 20,
+		},
+		{
+			name:        "png declared as jpeg",
+			data:        func(t *testing.T) []byte { return pngBytes(t, 40, 20) },
+			contentType: "image/jpeg",
+			wantErr:     ErrContentMismatch,
+		},
+		{
+			name:        "jpeg declared as webp",
+			data:        func(t *testing.T) []byte { return jpegBytes(t, 40, 20) },
+			contentType: "image/webp",
+			wantErr:     ErrContentMismatch,
+		},
+		{
+			name:        "not an image",
+			data:        func(t *testing.T) []byte { return []byte("GIF89a not really") },
+			contentType: "image/gif",
+			wantErr:     ErrUnsupportedType,
+		},
+		{
+			name:        "over the size limit",
+			data:        func(t *testing.T) []byte { return jpegBytes(t, 40, 20) },
+			size:        101 * 1024 * 1024,
+			contentType: "image/jpeg",
+			wantErr:     ErrImageTooLarge,
+		},
+		{
+			name:        "truncated after the signature",
+			data:        func(t *testing.T) []byte { return pngBytes(t, 40, 20)[:12] },
+			contentType: "image/png",
+			wantErr:     ErrInvalidImage,
+		},
+		{
+			name:        "small png claiming too many pixels",
+			data:        func(t *testing.T) []byte { return bombPNG(t, 20000, 20000) },
+			contentType: "image/png",
+			wantErr:     ErrTooManyPixels,
+		},
+		{
+			name:        "png just under the pixel limit",
+			data:        func(t *testing.T) []byte { return bombPNG(t, 10000, 10000) },
+			contentType: "image/png",
+			wantW:       10000, wantH: 10000,
+		},
+		{
+			name:        "desktop landscape",
+			data:        func(t *testing.T) []byte { return metadataJPEG(t, 1) },
+			contentType: "image/jpeg",
+			deviceType:  "desktop",
+			wantW:       40, wantH: 20,
+		},
+		{
+			name:        "desktop stored sideways",
+			data:        func(t *testing.T) []byte { return metadataJPEG(t, 6) },
+			contentType: "image/jpeg",
+			deviceType:  "desktop",
+			wantErr:     ErrResolutionTooSmall,
+		},
+		{
+			name:        "mobile landscape",
+			data:        func(t *testing.T) []byte { return metadataJPEG(t, 1) },
+			contentType: "image/jpeg",
+			deviceType:  "mobile",
+			wantErr:     ErrResolutionTooSmall,
+		},
+		{
+			name:        "mobile stored sideways",
+			data:        func(t *testing.T) []byte { return metadataJPEG(t, 6) },
+			contentType: "image/jpeg",
+			deviceType:  "mobile",
+			wantW:       20, wantH: 40,
+		},
+		{
+			name:        "mobile portrait below the minimum",
+			data:        func(t *testing.T) []byte { return jpegBytes(t, 19, 40) },
+			contentType: "image/jpeg",
+			deviceType:  "mobile",
+			wantErr:     ErrResolutionTooSmall,
+		},
+		{
+			name:        "device without a minimum",
+			data:        func(t *testing.T) []byte { return jpegBytes(t, 4, 4) },
+			contentType: "image/jpeg",
+			deviceType:  "tablet",
+			wantW:       4, wantH: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.data(t)
+			size := int64(len(data))
+			if tt.size != 0 {
+				size = tt.size
+			}
+
+			info, err := p.ValidateImage(bytes.NewReader(data), size, tt.contentType, tt.deviceType)
+			if tt.wantErr != nil {
+				var validation *ValidationError
+				if !errors.As(err, &validation) || !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateImage: %v", err)
+			}
+			if info.Width != tt.wantW || info.Height != tt.wantH {
+				t.Errorf("size = %dx%d, want %dx%d", info.Width, info.Height, tt.wantW, tt.wantH)
+			}
+		})
+	}
+}
@@ -19,6 +19,12 @@ type Collection struct {
 	UpdatedAt      time.Time `json:"updated_at"`
 }
 
+// Cursor is the keyset position of the last wallpaper of a collection page
+type Cursor struct {
+	AddedAt     time.Time `json:"t"`
+	WallpaperID uuid.UUID `json:"i"`
+}
+
 type Repository struct {
 	db *sql.DB
 }
@@ -209,38 +215,68 @@ func (r *Repository) RemoveWallpaper(ctx context.Context, collectionID, wallpape
 	return tx.Commit()
 }
 
-// GetCollectionWallpapers retrieves all wallpapers in a collection
-func (r *Repository) GetCollectionWallpapers(ctx context.Context, collectionID uuid.UUID, limit, offset int) ([]uuid.UUID, int, error) {
-	// Get total count
+// GetCollectionWallpapers retrieves the wallpapers in a collection, last added first. Pages start after
+// the cursor when one is given and skip counting the total, otherwise at offset. The returned cursor
+// is nil on the last page.
+func (r *Repository) GetCollectionWallpapers(ctx context.Context, collectionID uuid.UUID, after *Cursor, limit, offset int) ([]uuid.UUID, int, *Cursor, error) {
 	var total int
-	countQuery := `SELECT COUNT(*) FROM collection_items WHERE collection_id = $1`
-	err := r.db.QueryRowContext(ctx, countQuery, collectionID).Scan(&total)
-	if err != nil {
-		return nil, 0, err
-	}
+	var rows *sql.Rows
+	var err error
 
-	// Get wallpaper IDs
-	query := `
-		SELECT wallpaper_id
-		FROM collection_items
-		WHERE collection_id = $1
-		ORDER BY added_at DESC
-		LIMIT $2 OFFSET $3
-	`
-	rows, err := r.db.QueryContext(ctx, query, collectionID, limit, offset)
+	if after == nil {
+		// Get total count
+		countQuery := `SELECT COUNT(*) FROM collection_items WHERE collection_id = $1`
+		err = r.db.QueryRowContext(ctx, countQuery, collectionID).Scan(&total)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+
+		query := `
+			SELECT wallpaper_id, added_at
+			FROM collection_items
+			WHERE collection_id = $1
+			ORDER BY added_at DESC, wallpaper_id DESC
+			LIMIT $2 OFFSET $3
+		`
+		rows, err = r.db.QueryContext(ctx, query, collectionID, limit+1, offset)
+	} else {
+		query := `
+			SELECT wallpaper_id, added_at
+			FROM collection_items
+			WHERE collection_id = $1 AND (added_at, wallpaper_id) < ($2, $3)
+			ORDER BY added_at DESC, wallpaper_id DESC
+			LIMIT $4
+		`
+		rows, err = r.db.QueryContext(ctx, query, collectionID, after.AddedAt, after.WallpaperID, limit+1)
+	}
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
 	defer rows.Close()
 
-	var wallpaperIDs []uuid.UUID
+	var items []Cursor
 	for rows.Next() {
-		var id uuid.UUID
-		if err := rows.Scan(&id); err != nil {
-			return nil, 0, err
+		var c Cursor
+		if err := rows.Scan(&c.WallpaperID, &c.AddedAt); err != nil {
+			return nil, 0, nil, err
 		}
-		wallpaperIDs = append(wallpaperIDs, id)
+		items = append(items, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, nil, err
+	}
+
+	// One item more than the limit was fetched to tell whether there is a next page
+	var next *Cursor
+	if len(items) > limit {
+		items = items[:limit]
+		next = &items[limit-1]
+	}
+
+	wallpaperIDs := make([]uuid.UUID, len(items))
+	for i, c := range items {
+		wallpaperIDs[i] = c.WallpaperID
 	}
 
-	return wallpaperIDs, total, rows.Err()
+	return wallpaperIDs, total, next, nil
 }
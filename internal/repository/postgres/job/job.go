@@ -0,0 +1,185 @@
+package job
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+// Job is a unit of background image processing for a wallpaper
+type Job struct {
+	ID          uuid.UUID  `json:"id"`
+	WallpaperID uuid.UUID  `json:"wallpaper_id"`
+	Status      string     `json:"status"`
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"max_attempts"`
+	LastError   *string    `json:"last_error,omitempty"`
+	RunAt       time.Time  `json:"run_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Repository is a Postgres-backed job queue, workers claim jobs with FOR UPDATE SKIP LOCKED
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Enqueue schedules a processing job for a wallpaper
+func (r *Repository) Enqueue(ctx context.Context, wallpaperID uuid.UUID, maxAttempts int) (*Job, error) {
+	query := `
+		INSERT INTO processing_jobs (wallpaper_id, max_attempts)
+		VALUES ($1, $2)
+		RETURNING id, wallpaper_id, status, attempts, max_attempts, run_at, created_at, updated_at
+	`
+
+	j := &Job{}
+	err := r.db.QueryRowContext(ctx, query, wallpaperID, maxAttempts).Scan(
+		&j.ID, &j.WallpaperID, &j.Status, &j.Attempts, &j.MaxAttempts, &j.RunAt, &j.CreatedAt, &j.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// ClaimNext locks the next runnable job and marks it running.
+// Jobs stuck in running for longer than lockTimeout (crashed worker) are reclaimed while they have
+// attempts left. Stuck jobs out of attempts, e.g. an image that kills the worker every time, are failed
+// in the same statement along with their wallpaper, since the worker never got to handle the failure.
+// Returns nil when the queue is empty.
+func (r *Repository) ClaimNext(ctx context.Context, lockTimeout time.Duration) (*Job, error) {
+	query := `
+		WITH exhausted AS (
+			UPDATE processing_jobs
+			SET status = 'failed',
+			    last_error = 'worker stopped during the last attempt',
+			    locked_at = NULL,
+			    updated_at = NOW()
+			WHERE status = 'running'
+			  AND locked_at < NOW() - make_interval(secs => $1)
+			  AND attempts >= max_attempts
+			RETURNING wallpaper_id
+		), failed_wallpapers AS (
+			UPDATE wallpapers
+			SET status = 'failed', updated_at = NOW()
+			WHERE id IN (SELECT wallpaper_id FROM exhausted)
+		)
+		UPDATE processing_jobs
+		SET status = 'running',
+		    attempts = attempts + 1,
+		    locked_at = NOW(),
+		    updated_at = NOW()
+		WHERE id = (
+			SELECT id
+			FROM processing_jobs
+			WHERE (status = 'pending' AND run_at <= NOW())
+			   OR (status = 'running' AND locked_at < NOW() - make_interval(secs => $1) AND attempts < max_attempts)
+			ORDER BY run_at
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING id, wallpaper_id, status, attempts, max_attempts, run_at, created_at, updated_at
+	`
+
+	j := &Job{}
+	err := r.db.QueryRowContext(ctx, query, lockTimeout.Seconds()).Scan(
+		&j.ID, &j.WallpaperID, &j.Status, &j.Attempts, &j.MaxAttempts, &j.RunAt, &j.CreatedAt, &j.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// Complete marks a job as successfully finished
+func (r *Repository) Complete(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE processing_jobs
+		SET status = 'completed',
+		    last_error = NULL,
+		    locked_at = NULL,
+		    completed_at = NOW(),
+		    updated_at = NOW()
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// Retry puts a failed attempt back in the queue to run again after delay
+func (r *Repository) Retry(ctx context.Context, id uuid.UUID, lastError string, delay time.Duration) error {
+	query := `
+		UPDATE processing_jobs
+		SET status = 'pending',
+		    last_error = $1,
+		    locked_at = NULL,
+		    run_at = NOW() + make_interval(secs => $2),
+		    updated_at = NOW()
+		WHERE id = $3
+	`
+	_, err := r.db.ExecContext(ctx, query, lastError, delay.Seconds(), id)
+	return err
+}
+
+// Fail marks a job as permanently failed
+func (r *Repository) Fail(ctx context.Context, id uuid.UUID, lastError string) error {
+	query := `
+		UPDATE processing_jobs
+		SET status = 'failed',
+		    last_error = $1,
+		    locked_at = NULL,
+		    updated_at = NOW()
+		WHERE id = $2
+	`
+	_, err := r.db.ExecContext(ctx, query, lastError, id)
+	return err
+}
+
+// GetLatestByWallpaper returns the most recent job for a wallpaper
+func (r *Repository) GetLatestByWallpaper(ctx context.Context, wallpaperID uuid.UUID) (*Job, error) {
+	query := `
+		SELECT id, wallpaper_id, status, attempts, max_attempts, last_error,
+		       run_at, completed_at, created_at, updated_at
+		FROM processing_jobs
+		WHERE wallpaper_id = $1
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+
+	j := &Job{}
+	var lastError sql.NullString
+	var completedAt sql.NullTime
+
+	err := r.db.QueryRowContext(ctx, query, wallpaperID).Scan(
+		&j.ID, &j.WallpaperID, &j.Status, &j.Attempts, &j.MaxAttempts, &lastError,
+		&j.RunAt, &completedAt, &j.CreatedAt, &j.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if lastError.Valid {
+		j.LastError = &lastError.String
+	}
+	if completedAt.Valid {
+		j.CompletedAt = &completedAt.Time
+	}
+	return j, nil
+}
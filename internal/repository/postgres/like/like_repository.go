@@ -15,6 +15,12 @@ type Like struct {
 	CreatedAt   time.Time `json:"created_at"`
 }
 
+// Cursor is the keyset position of the last like of a page
+type Cursor struct {
+	LikedAt     time.Time `json:"t"`
+	WallpaperID uuid.UUID `json:"i"`
+}
+
 type Repository struct {
 	db *sql.DB
 }
@@ -110,39 +116,70 @@ func (r *Repository) GetWallpaperLikeCount(ctx context.Context, wallpaperID uuid
 	return count, err
 }
 
-func (r *Repository) GetUserLikes(ctx context.Context, userID uuid.UUID, limit, offset int) ([]uuid.UUID, int, error) {
-	// Get total count
+// GetUserLikes returns the IDs of the wallpapers a user liked, most recent like first. Pages start after
+// the cursor when one is given and skip counting the total, otherwise at offset. The returned cursor
+// is nil on the last page.
+func (r *Repository) GetUserLikes(ctx context.Context, userID uuid.UUID, after *Cursor, limit, offset int) ([]uuid.UUID, int, *Cursor, error) {
 	var total int
-	countQuery := `SELECT COUNT(*) FROM likes WHERE user_id = $1`
-	err := r.db.QueryRowContext(ctx, countQuery, userID).Scan(&total)
-	if err != nil {
-		return nil, 0, err
-	}
+	var rows *sql.Rows
+	var err error
+
+	if after == nil {
+		// Get total count
+		countQuery := `SELECT COUNT(*) FROM likes WHERE user_id = $1`
+		err = r.db.QueryRowContext(ctx, countQuery, userID).Scan(&total)
+		if err != nil {
+			return nil, 0, nil, err
+		}
 
-	// Get wallpaper IDs
-	query := `
-		SELECT wallpaper_id 
-		FROM likes 
-		WHERE user_id = $1 
-		ORDER BY created_at DESC 
-		LIMIT $2 OFFSET $3
-	`
-	rows, err := r.db.QueryContext(ctx, query, userID, limit, offset)
+		query := `
+			SELECT wallpaper_id, created_at
+			FROM likes
+			WHERE user_id = $1
+			ORDER BY created_at DESC, wallpaper_id DESC
+			LIMIT $2 OFFSET $3
+		`
+		rows, err = r.db.QueryContext(ctx, query, userID, limit+1, offset)
+	} else {
+		query := `
+			SELECT wallpaper_id, created_at
+			FROM likes
+			WHERE user_id = $1 AND (created_at, wallpaper_id) < ($2, $3)
+			ORDER BY created_at DESC, wallpaper_id DESC
+			LIMIT $4
+		`
+		rows, err = r.db.QueryContext(ctx, query, userID, after.LikedAt, after.WallpaperID, limit+1)
+	}
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, nil, err
 	}
 	defer rows.Close()
 
-	var wallpaperIDs []uuid.UUID
+	var likes []Cursor
 	for rows.Next() {
-		var id uuid.UUID
-		if err := rows.Scan(&id); err != nil {
-			return nil, 0, err
+		var c Cursor
+		if err := rows.Scan(&c.WallpaperID, &c.LikedAt); err != nil {
+			return nil, 0, nil, err
 		}
-		wallpaperIDs = append(wallpaperIDs, id)
+		likes = append(likes, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, nil, err
+	}
+
+	// One like more than the limit was fetched to tell whether there is a next page
+	var next *Cursor
+	if len(likes) > limit {
+		likes = likes[:limit]
+		next = &likes[limit-1]
+	}
+
+	wallpaperIDs := make([]uuid.UUID, len(likes))
+	for i, c := range likes {
+		wallpaperIDs[i] = c.WallpaperID
 	}
 
-	return wallpaperIDs, total, rows.Err()
+	return wallpaperIDs, total, next, nil
 }
 
 func (r *Repository) IncrementWallpaperLikeCount(ctx context.Context, wallpaperID uuid.UUID, delta int) error {
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 // Tag represents a wallpaper tag
@@ -156,24 +157,67 @@ func (r *Repository) GetBySlug(ctx context.Context, slug string) (*Tag, error) {
 }
 
 // Delete deletes a tag by ID (cascade deletes wallpaper_tags via DB constraint)
-func (r *Repository) Delete(ctx context.Context, id uuid.UUID) error {
-	query := `DELETE FROM tags WHERE id = $1`
+// and removes its name from the search text of the wallpapers it was on
+func (r *Repository) Delete(ctx context.Context, id uuid.UUID) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	rows, err := tx.QueryContext(ctx, `SELECT wallpaper_id FROM wallpaper_tags WHERE tag_id = $1`, id)
+	if err != nil {
+		return err
+	}
+	var wallpaperIDs []uuid.UUID
+	for rows.Next() {
+		var wallpaperID uuid.UUID
+		if err = rows.Scan(&wallpaperID); err != nil {
+			rows.Close()
+			return err
+		}
+		wallpaperIDs = append(wallpaperIDs, wallpaperID)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
 
-	result, err := r.db.ExecContext(ctx, query, id)
+	result, err := tx.ExecContext(ctx, `DELETE FROM tags WHERE id = $1`, id)
 	if err != nil {
 		return err
 	}
 
-	rows, err := result.RowsAffected()
+	deleted, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
+	if deleted == 0 {
+		err = sql.ErrNoRows
+		return err
+	}
 
-	if rows == 0 {
-		return sql.ErrNoRows
+	query := `
+		UPDATE wallpapers w
+		SET search_tags = COALESCE((
+			SELECT string_agg(t.name, ' ' ORDER BY t.name)
+			FROM wallpaper_tags wt
+			INNER JOIN tags t ON t.id = wt.tag_id
+			WHERE wt.wallpaper_id = w.id
+		), '')
+		WHERE w.id = ANY($1)
+	`
+	if len(wallpaperIDs) > 0 {
+		if _, err = tx.ExecContext(ctx, query, pq.Array(wallpaperIDs)); err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return tx.Commit()
 }
 
 // IncrementCount atomically increments the wallpaper_count for a tag
@@ -0,0 +1,208 @@
+package upload
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	StatusPending    = "pending"
+	StatusFinalizing = "finalizing"
+	StatusCompleted  = "completed"
+	StatusFailed     = "failed"
+	StatusExpired    = "expired"
+)
+
+// Upload is a resumable (tus) upload. Received bytes are stored as chunk objects,
+// once Offset reaches Length they are joined into a wallpaper.
+type Upload struct {
+	ID          uuid.UUID         `json:"id"`
+	UserID      uuid.UUID         `json:"user_id"`
+	Length      int64             `json:"upload_length"`
+	Offset      int64             `json:"upload_offset"`
+	Metadata    map[string]string `json:"metadata"`
+	ChunkKeys   []string          `json:"-"`
+	Status      string            `json:"status"`
+	WallpaperID *uuid.UUID        `json:"wallpaper_id,omitempty"`
+	LastError   *string           `json:"last_error,omitempty"`
+	ExpiresAt   time.Time         `json:"expires_at"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+type Repository struct {
+	db *sql.DB
+}
+
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{db: db}
+}
+
+const uploadColumns = `id, user_id, upload_length, upload_offset, metadata, chunk_keys, status,
+		       wallpaper_id, last_error, expires_at, created_at, updated_at`
+
+func (r *Repository) Create(ctx context.Context, u *Upload) error {
+	metadata, err := json.Marshal(u.Metadata)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO resumable_uploads (user_id, upload_length, metadata, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, upload_offset, status, created_at, updated_at
+	`
+	return r.db.QueryRowContext(ctx, query, u.UserID, u.Length, metadata, u.ExpiresAt).Scan(
+		&u.ID, &u.Offset, &u.Status, &u.CreatedAt, &u.UpdatedAt,
+	)
+}
+
+func (r *Repository) GetByID(ctx context.Context, id uuid.UUID) (*Upload, error) {
+	query := `SELECT ` + uploadColumns + ` FROM resumable_uploads WHERE id = $1`
+	return scanUpload(r.db.QueryRowContext(ctx, query, id))
+}
+
+// AppendChunk records a stored chunk and moves the offset forward, extending the expiry.
+// It only succeeds when the offset is still the one the chunk was written at, so of two
+// concurrent PATCHes for the same offset exactly one wins. Returns false for the loser.
+func (r *Repository) AppendChunk(ctx context.Context, id uuid.UUID, offset, size int64, key string, expiresAt time.Time) (bool, error) {
+	query := `
+		UPDATE resumable_uploads
+		SET upload_offset = upload_offset + $3,
+		    chunk_keys = array_append(chunk_keys, $4),
+		    expires_at = $5,
+		    updated_at = NOW()
+		WHERE id = $1 AND upload_offset = $2 AND status = 'pending'
+	`
+	result, err := r.db.ExecContext(ctx, query, id, offset, size, key, expiresAt)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows == 1, err
+}
+
+// BeginFinalize moves a fully received upload to finalizing, false if another request got there first
+func (r *Repository) BeginFinalize(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `
+		UPDATE resumable_uploads
+		SET status = 'finalizing', updated_at = NOW()
+		WHERE id = $1 AND status = 'pending' AND upload_offset = upload_length
+	`
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows == 1, err
+}
+
+// CancelFinalize puts an upload back to pending when finalizing could not start
+func (r *Repository) CancelFinalize(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE resumable_uploads
+		SET status = 'pending', updated_at = NOW()
+		WHERE id = $1 AND status = 'finalizing'
+	`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// Complete links the created wallpaper, chunk objects are deleted by the caller
+func (r *Repository) Complete(ctx context.Context, id, wallpaperID uuid.UUID) error {
+	query := `
+		UPDATE resumable_uploads
+		SET status = 'completed', wallpaper_id = $2, chunk_keys = '{}', last_error = NULL, updated_at = NOW()
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, id, wallpaperID)
+	return err
+}
+
+// Fail records why an upload could not become a wallpaper
+func (r *Repository) Fail(ctx context.Context, id uuid.UUID, lastError string) error {
+	query := `
+		UPDATE resumable_uploads
+		SET status = 'failed', chunk_keys = '{}', last_error = $2, updated_at = NOW()
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, id, lastError)
+	return err
+}
+
+// Expire marks an abandoned upload expired
+func (r *Repository) Expire(ctx context.Context, id uuid.UUID) error {
+	query := `
+		UPDATE resumable_uploads
+		SET status = 'expired', chunk_keys = '{}', updated_at = NOW()
+		WHERE id = $1
+	`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+func (r *Repository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM resumable_uploads WHERE id = $1`, id)
+	return err
+}
+
+// ListExpired returns unfinished uploads whose expiry has passed
+func (r *Repository) ListExpired(ctx context.Context, limit int) ([]*Upload, error) {
+	query := `
+		SELECT ` + uploadColumns + `
+		FROM resumable_uploads
+		WHERE status IN ('pending', 'finalizing') AND expires_at < NOW()
+		ORDER BY expires_at
+		LIMIT $1
+	`
+	rows, err := r.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var uploads []*Upload
+	for rows.Next() {
+		u, err := scanUpload(rows)
+		if err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, u)
+	}
+	return uploads, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUpload(row scanner) (*Upload, error) {
+	u := &Upload{}
+	var metadata []byte
+	var wallpaperID uuid.NullUUID
+	var lastError sql.NullString
+
+	err := row.Scan(
+		&u.ID, &u.UserID, &u.Length, &u.Offset, &metadata, pq.Array(&u.ChunkKeys), &u.Status,
+		&wallpaperID, &lastError, &u.ExpiresAt, &u.CreatedAt, &u.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(metadata, &u.Metadata); err != nil {
+		return nil, err
+	}
+	if wallpaperID.Valid {
+		u.WallpaperID = &wallpaperID.UUID
+	}
+	if lastError.Valid {
+		u.LastError = &lastError.String
+	}
+	return u, nil
+}
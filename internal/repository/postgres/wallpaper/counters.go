@@ -0,0 +1,43 @@
+package wallpaper
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// CounterDelta is how many views and downloads to add to a wallpaper
+type CounterDelta struct {
+	WallpaperID uuid.UUID
+	Views       int
+	Downloads   int
+}
+
+// IncrementCounters adds a batch of view and download counts in one statement
+func (r *Repository) IncrementCounters(ctx context.Context, deltas []CounterDelta) error {
+	if len(deltas) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(deltas))
+	views := make([]int64, len(deltas))
+	downloads := make([]int64, len(deltas))
+	for i, d := range deltas {
+		ids[i] = d.WallpaperID
+		views[i] = int64(d.Views)
+		downloads[i] = int64(d.Downloads)
+	}
+
+	query := `
+		UPDATE wallpapers w
+		SET view_count = w.view_count + d.views,
+		    download_count = w.download_count + d.downloads
+		FROM (
+			SELECT * FROM unnest($1::uuid[], $2::int[], $3::int[]) AS t(id, views, downloads)
+		) d
+		WHERE w.id = d.id
+	`
+	_, err := r.db.ExecContext(ctx, query, pq.Array(ids), pq.Array(views), pq.Array(downloads))
+	return err
+}
@@ -0,0 +1,147 @@
+package wallpaper
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// SimilarWallpaper is an existing wallpaper whose perceptual hash is close to an upload's
+type SimilarWallpaper struct {
+	ID       uuid.UUID `json:"id"`
+	Distance int       `json:"distance"`
+}
+
+// DuplicateMatch is a flagged upload together with its Hamming distance to the original
+type DuplicateMatch struct {
+	Wallpaper *Wallpaper `json:"wallpaper"`
+	Distance  int        `json:"distance"`
+}
+
+// DuplicateCluster groups every upload flagged as a repost of the same original
+type DuplicateCluster struct {
+	Original   *Wallpaper       `json:"original"`
+	Duplicates []DuplicateMatch `json:"duplicates"`
+}
+
+// FindSimilar returns wallpapers whose perceptual hash is within maxDistance bits of hash, closest first
+func (r *Repository) FindSimilar(ctx context.Context, hash int64, maxDistance, limit int) ([]SimilarWallpaper, error) {
+	query := `
+		SELECT id, bit_count((phash # $1)::bit(64)) AS distance
+		FROM wallpapers
+		WHERE deleted_at IS NULL AND phash IS NOT NULL AND status <> 'failed'
+		  AND bit_count((phash # $1)::bit(64)) <= $2
+		ORDER BY distance, created_at
+		LIMIT $3
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, hash, maxDistance, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var similar []SimilarWallpaper
+	for rows.Next() {
+		var s SimilarWallpaper
+		if err := rows.Scan(&s.ID, &s.Distance); err != nil {
+			return nil, err
+		}
+		similar = append(similar, s)
+	}
+
+	return similar, rows.Err()
+}
+
+// AddDuplicates flags a wallpaper as a suspected repost of existing ones
+func (r *Repository) AddDuplicates(ctx context.Context, wallpaperID uuid.UUID, matches []SimilarWallpaper) error {
+	query := `
+		INSERT INTO wallpaper_duplicates (wallpaper_id, duplicate_of, distance)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (wallpaper_id, duplicate_of) DO NOTHING
+	`
+	for _, m := range matches {
+		if _, err := r.db.ExecContext(ctx, query, wallpaperID, m.ID, m.Distance); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListDuplicateClusters returns suspected repost clusters, most recently flagged first
+func (r *Repository) ListDuplicateClusters(ctx context.Context, limit, offset int) ([]*DuplicateCluster, int, error) {
+	var total int
+	countQuery := `
+		SELECT COUNT(DISTINCT d.duplicate_of)
+		FROM wallpaper_duplicates d
+		INNER JOIN wallpapers w ON w.id = d.wallpaper_id
+		WHERE w.deleted_at IS NULL
+	`
+	if err := r.db.QueryRowContext(ctx, countQuery).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT d.duplicate_of,
+		       array_agg(d.wallpaper_id::text ORDER BY d.distance, d.created_at),
+		       array_agg(d.distance ORDER BY d.distance, d.created_at)
+		FROM wallpaper_duplicates d
+		INNER JOIN wallpapers w ON w.id = d.wallpaper_id
+		WHERE w.deleted_at IS NULL
+		GROUP BY d.duplicate_of
+		ORDER BY MAX(d.created_at) DESC
+		LIMIT $1 OFFSET $2
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	type clusterRow struct {
+		original  uuid.UUID
+		ids       []string
+		distances []int64
+	}
+
+	var clusterRows []clusterRow
+	var ids []uuid.UUID
+	for rows.Next() {
+		var c clusterRow
+		if err := rows.Scan(&c.original, pq.Array(&c.ids), pq.Array(&c.distances)); err != nil {
+			return nil, 0, err
+		}
+		clusterRows = append(clusterRows, c)
+		ids = append(ids, c.original)
+		for _, id := range c.ids {
+			ids = append(ids, uuid.MustParse(id))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	wallpapers, err := r.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, 0, err
+	}
+	byID := make(map[uuid.UUID]*Wallpaper, len(wallpapers))
+	for _, w := range wallpapers {
+		byID[w.ID] = w
+	}
+
+	clusters := make([]*DuplicateCluster, 0, len(clusterRows))
+	for _, c := range clusterRows {
+		cluster := &DuplicateCluster{Original: byID[c.original]}
+		for i, id := range c.ids {
+			if w, ok := byID[uuid.MustParse(id)]; ok {
+				cluster.Duplicates = append(cluster.Duplicates, DuplicateMatch{Wallpaper: w, Distance: int(c.distances[i])})
+			}
+		}
+		clusters = append(clusters, cluster)
+	}
+
+	return clusters, total, nil
+}
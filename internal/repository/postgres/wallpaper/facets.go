@@ -0,0 +1,101 @@
+package wallpaper
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// How many of the most used tags are counted
+const tagFacetLimit = 20
+
+// Facets counts the wallpapers matching a filter by device type, orientation, aspect ratio,
+// mime type and most used tags, so clients can show how many results each refinement leaves
+type Facets struct {
+	DeviceType  map[string]int `json:"device_type"`
+	Orientation map[string]int `json:"orientation"`
+	AspectRatio map[string]int `json:"aspect_ratio"`
+	MimeType    map[string]int `json:"mime_type"`
+	Tags        []TagFacet     `json:"tags"`
+}
+
+// TagFacet is the number of matching wallpapers having a tag
+type TagFacet struct {
+	Slug  string `json:"slug"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Facets counts the wallpapers matching the filter, all facets but tags in a single pass
+func (r *Repository) Facets(ctx context.Context, f Filter) (*Facets, error) {
+	q := newListQuery(f)
+	facets := &Facets{
+		DeviceType:  map[string]int{},
+		Orientation: map[string]int{},
+		AspectRatio: map[string]int{},
+		MimeType:    map[string]int{},
+		Tags:        []TagFacet{},
+	}
+
+	query := fmt.Sprintf(`
+		SELECT w.device_type, %[1]s, %[2]s, w.mime_type, COUNT(*)
+		FROM wallpapers w
+		%[3]s
+		GROUP BY GROUPING SETS ((w.device_type), (%[1]s), (%[2]s), (w.mime_type))
+	`, orientationExpression, aspectExpression, q.whereClause())
+
+	rows, err := r.db.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		// Each row belongs to one grouping set, the other columns are NULL
+		var deviceType, orientation, aspect, mimeType sql.NullString
+		var count int
+		if err := rows.Scan(&deviceType, &orientation, &aspect, &mimeType, &count); err != nil {
+			return nil, err
+		}
+		switch {
+		case deviceType.Valid:
+			facets.DeviceType[deviceType.String] = count
+		case orientation.Valid:
+			facets.Orientation[orientation.String] = count
+		case aspect.Valid:
+			facets.AspectRatio[aspect.String] = count
+		case mimeType.Valid:
+			facets.MimeType[mimeType.String] = count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tagQuery := fmt.Sprintf(`
+		SELECT t.slug, t.name, COUNT(*)
+		FROM wallpapers w
+		INNER JOIN wallpaper_tags wt ON wt.wallpaper_id = w.id
+		INNER JOIN tags t ON t.id = wt.tag_id
+		%s
+		GROUP BY t.id
+		ORDER BY COUNT(*) DESC, t.slug
+		LIMIT %s
+	`, q.whereClause(), q.arg(tagFacetLimit))
+
+	tagRows, err := r.db.QueryContext(ctx, tagQuery, q.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer tagRows.Close()
+
+	for tagRows.Next() {
+		var t TagFacet
+		if err := tagRows.Scan(&t.Slug, &t.Name, &t.Count); err != nil {
+			return nil, err
+		}
+		facets.Tags = append(facets.Tags, t)
+	}
+
+	return facets, tagRows.Err()
+}
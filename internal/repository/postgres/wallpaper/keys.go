@@ -0,0 +1,94 @@
+package wallpaper
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// StoredKeys are the object keys a wallpaper row refers to. Thumbnail keys aren't stored,
+// each thumbnail format sits next to the original as {original without extension}_thumb.{ext}.
+type StoredKeys struct {
+	WallpaperID      uuid.UUID
+	DeletedAt        *time.Time
+	OriginalKey      string
+	ThumbnailFormats []string
+	RenditionKeys    []string
+}
+
+// ListStoredKeys returns the keys of every wallpaper, soft-deleted ones included, whose ID is one of
+// prefixes (the first segment of a key) or with an original or a rendition stored under one of them.
+// Originals uploaded before wallpapers got their ID from the API live under a prefix that isn't the wallpaper ID.
+func (r *Repository) ListStoredKeys(ctx context.Context, prefixes []string) ([]*StoredKeys, error) {
+	if len(prefixes) == 0 {
+		return []*StoredKeys{}, nil
+	}
+
+	query := `
+		SELECT w.id, w.deleted_at, COALESCE(w.original_key, ''), w.thumbnail_formats,
+		       ARRAY(SELECT storage_key FROM wallpaper_renditions WHERE wallpaper_id = w.id)
+		FROM wallpapers w
+		WHERE w.id = ANY($1::uuid[])
+		   OR split_part(w.original_key, '/', 1) = ANY($1)
+		   OR w.id IN (
+		       SELECT wallpaper_id FROM wallpaper_renditions WHERE split_part(storage_key, '/', 1) = ANY($1)
+		   )
+	`
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(prefixes))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*StoredKeys
+	for rows.Next() {
+		k, err := scanStoredKeys(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, k)
+	}
+	return result, rows.Err()
+}
+
+// GetStoredKeys returns the keys of a wallpaper, soft-deleted or not
+func (r *Repository) GetStoredKeys(ctx context.Context, id uuid.UUID) (*StoredKeys, error) {
+	query := `
+		SELECT w.id, w.deleted_at, COALESCE(w.original_key, ''), w.thumbnail_formats,
+		       ARRAY(SELECT storage_key FROM wallpaper_renditions WHERE wallpaper_id = w.id)
+		FROM wallpapers w
+		WHERE w.id = $1
+	`
+	return scanStoredKeys(r.db.QueryRowContext(ctx, query, id))
+}
+
+// CountMisplacedOriginals counts wallpapers whose original is stored outside their ID prefix,
+// originals uploaded before the API assigned wallpaper IDs, and wallpapers with no original_key at all
+func (r *Repository) CountMisplacedOriginals(ctx context.Context) (misplaced, missing int, err error) {
+	query := `
+		SELECT COUNT(*) FILTER (WHERE original_key <> '' AND split_part(original_key, '/', 1) <> id::text),
+		       COUNT(*) FILTER (WHERE COALESCE(original_key, '') = '')
+		FROM wallpapers
+	`
+	err = r.db.QueryRowContext(ctx, query).Scan(&misplaced, &missing)
+	return misplaced, missing, err
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanStoredKeys(row scanner) (*StoredKeys, error) {
+	k := &StoredKeys{}
+	var deletedAt sql.NullTime
+	if err := row.Scan(&k.WallpaperID, &deletedAt, &k.OriginalKey, pq.Array(&k.ThumbnailFormats), pq.Array(&k.RenditionKeys)); err != nil {
+		return nil, err
+	}
+	if deletedAt.Valid {
+		k.DeletedAt = &deletedAt.Time
+	}
+	return k, nil
+}
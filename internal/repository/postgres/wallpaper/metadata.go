@@ -0,0 +1,88 @@
+package wallpaper
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+// Metadata is the camera information extracted from an upload's EXIF block
+type Metadata struct {
+	CameraMake   *string  `json:"camera_make,omitempty"`
+	CameraModel  *string  `json:"camera_model,omitempty"`
+	LensModel    *string  `json:"lens_model,omitempty"`
+	FocalLength  *float64 `json:"focal_length_mm,omitempty"`
+	Aperture     *float64 `json:"aperture,omitempty"`
+	ISO          *int     `json:"iso,omitempty"`
+	ExposureTime *string  `json:"exposure_time,omitempty"`
+}
+
+// SaveMetadata stores (or replaces) the camera metadata of a wallpaper
+func (r *Repository) SaveMetadata(ctx context.Context, wallpaperID uuid.UUID, m *Metadata) error {
+	query := `
+		INSERT INTO wallpaper_metadata (
+			wallpaper_id, camera_make, camera_model, lens_model, focal_length_mm, aperture, iso, exposure_time
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (wallpaper_id) DO UPDATE
+		SET camera_make = EXCLUDED.camera_make,
+		    camera_model = EXCLUDED.camera_model,
+		    lens_model = EXCLUDED.lens_model,
+		    focal_length_mm = EXCLUDED.focal_length_mm,
+		    aperture = EXCLUDED.aperture,
+		    iso = EXCLUDED.iso,
+		    exposure_time = EXCLUDED.exposure_time
+	`
+	_, err := r.db.ExecContext(ctx, query,
+		wallpaperID, m.CameraMake, m.CameraModel, m.LensModel, m.FocalLength, m.Aperture, m.ISO, m.ExposureTime,
+	)
+	return err
+}
+
+// GetMetadata returns the camera metadata of a wallpaper, nil when none was extracted
+func (r *Repository) GetMetadata(ctx context.Context, wallpaperID uuid.UUID) (*Metadata, error) {
+	query := `
+		SELECT camera_make, camera_model, lens_model, focal_length_mm::float8, aperture::float8, iso, exposure_time
+		FROM wallpaper_metadata
+		WHERE wallpaper_id = $1
+	`
+
+	var cameraMake, cameraModel, lensModel, exposureTime sql.NullString
+	var focalLength, aperture sql.NullFloat64
+	var iso sql.NullInt64
+
+	err := r.db.QueryRowContext(ctx, query, wallpaperID).Scan(
+		&cameraMake, &cameraModel, &lensModel, &focalLength, &aperture, &iso, &exposureTime,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Metadata{}
+	if cameraMake.Valid {
+		m.CameraMake = &cameraMake.String
+	}
+	if cameraModel.Valid {
+		m.CameraModel = &cameraModel.String
+	}
+	if lensModel.Valid {
+		m.LensModel = &lensModel.String
+	}
+	if focalLength.Valid {
+		m.FocalLength = &focalLength.Float64
+	}
+	if aperture.Valid {
+		m.Aperture = &aperture.Float64
+	}
+	if iso.Valid {
+		v := int(iso.Int64)
+		m.ISO = &v
+	}
+	if exposureTime.Valid {
+		m.ExposureTime = &exposureTime.String
+	}
+	return m, nil
+}
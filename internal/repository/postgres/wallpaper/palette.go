@@ -0,0 +1,48 @@
+package wallpaper
+
+import (
+	"encoding/json"
+)
+
+// PaletteColor is a dominant colour of a wallpaper, Lab values are kept for colour search
+type PaletteColor struct {
+	Hex    string  `json:"hex"`
+	Weight float64 `json:"weight"`
+	L      float64 `json:"l"`
+	A      float64 `json:"a"`
+	B      float64 `json:"b"`
+}
+
+// ColorFilter matches wallpapers having a palette colour within MaxDistance (CIE76 delta E)
+// of the target that covers at least MinWeight of the image
+type ColorFilter struct {
+	L           float64
+	A           float64
+	B           float64
+	MaxDistance float64
+	MinWeight   float64
+}
+
+// whereColor adds a condition matching the filter, nothing when f is nil
+func (q *listQuery) whereColor(f *ColorFilter) {
+	if f == nil {
+		return
+	}
+
+	q.where(`EXISTS (
+			SELECT 1 FROM jsonb_array_elements(w.palette) p
+			WHERE (p->>'weight')::float8 >= %s
+			  AND sqrt(power((p->>'l')::float8 - %s, 2) + power((p->>'a')::float8 - %s, 2) + power((p->>'b')::float8 - %s, 2)) <= %s
+		  )`, q.arg(f.MinWeight), q.arg(f.L), q.arg(f.A), q.arg(f.B), q.arg(f.MaxDistance))
+}
+
+func scanPalette(raw []byte) ([]PaletteColor, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var palette []PaletteColor
+	if err := json.Unmarshal(raw, &palette); err != nil {
+		return nil, err
+	}
+	return palette, nil
+}
@@ -0,0 +1,88 @@
+package wallpaper
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ListPurgeable returns wallpapers soft-deleted before cutoff, oldest first
+func (r *Repository) ListPurgeable(ctx context.Context, cutoff time.Time, limit int) ([]uuid.UUID, error) {
+	query := `
+		SELECT id FROM wallpapers
+		WHERE deleted_at IS NOT NULL AND deleted_at < $1
+		ORDER BY deleted_at
+		LIMIT $2
+	`
+	rows, err := r.db.QueryContext(ctx, query, cutoff, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Purge permanently deletes a wallpaper soft-deleted before cutoff. Likes, collection items, tags,
+// renditions, metadata and jobs go with it (ON DELETE CASCADE), reports keep a NULL wallpaper.
+// Tag and collection wallpaper_count are decremented in the same transaction.
+// Returns false when the wallpaper no longer qualifies, e.g. it was restored in the meantime.
+func (r *Repository) Purge(ctx context.Context, id uuid.UUID, cutoff time.Time) (purged bool, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Lock the row so a concurrent restore either happens first or waits for the purge
+	var locked uuid.UUID
+	err = tx.QueryRowContext(ctx,
+		`SELECT id FROM wallpapers WHERE id = $1 AND deleted_at IS NOT NULL AND deleted_at < $2 FOR UPDATE`,
+		id, cutoff,
+	).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return false, tx.Rollback()
+	}
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE tags SET wallpaper_count = GREATEST(wallpaper_count - 1, 0)
+		WHERE id IN (SELECT tag_id FROM wallpaper_tags WHERE wallpaper_id = $1)
+	`, id)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE collections SET wallpaper_count = GREATEST(wallpaper_count - 1, 0), updated_at = NOW()
+		WHERE id IN (SELECT collection_id FROM collection_items WHERE wallpaper_id = $1)
+	`, id)
+	if err != nil {
+		return false, err
+	}
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM wallpapers WHERE id = $1`, id); err != nil {
+		return false, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,403 @@
+package wallpaper
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// List orders, relevance only applies to searches and fit to screen filters, both fall back to newest
+const (
+	SortNewest         = "newest"
+	SortOldest         = "oldest"
+	SortMostLiked      = "most_liked"
+	SortMostDownloaded = "most_downloaded"
+	SortMostViewed     = "most_viewed"
+	SortRandom         = "random" // shuffled by Order.Seed, the same seed gives the same order
+	SortRelevance      = "relevance"
+	SortFit            = "fit" // least cropped to the screen's aspect ratio first
+)
+
+// Order is how a listing is sorted, one of the Sort constants
+type Order struct {
+	Sort string
+	Seed int64 // SortRandom only
+}
+
+// Orientations of a wallpaper, from its width and height
+const (
+	OrientationLandscape = "landscape"
+	OrientationPortrait  = "portrait"
+	OrientationSquare    = "square"
+)
+
+// AspectRatio is a named aspect ratio bucket, wallpapers within aspectTolerance of Ratio fall in it
+type AspectRatio struct {
+	Name  string
+	Ratio float64
+}
+
+// AspectRatios are the buckets wallpapers are filtered and counted by, anything else is "other"
+var AspectRatios = []AspectRatio{
+	{"32:9", 32.0 / 9},
+	{"21:9", 21.0 / 9},
+	{"16:9", 16.0 / 9},
+	{"16:10", 16.0 / 10},
+	{"3:2", 3.0 / 2},
+	{"4:3", 4.0 / 3},
+	{"5:4", 5.0 / 4},
+	{"1:1", 1},
+	{"3:4", 3.0 / 4},
+	{"2:3", 2.0 / 3},
+	{"9:16", 9.0 / 16},
+	{"9:19.5", 9.0 / 19.5},
+	{"9:21", 9.0 / 21},
+}
+
+// AspectOther is the bucket of wallpapers matching none of AspectRatios
+const AspectOther = "other"
+
+// Relative difference still counted as the same aspect ratio, e.g. 3440x1440 is 21:9
+const aspectTolerance = 0.03
+
+// Filter narrows wallpaper listings, zero values don't filter.
+// Only active, non-deleted wallpapers are ever listed.
+type Filter struct {
+	Search        string // web search style query over titles, tags and descriptions
+	Color         *ColorFilter
+	DeviceType    string
+	MinWidth      int
+	MinHeight     int
+	Width         int // exact resolution, together with Height
+	Height        int
+	ScreenWidth   int // physical pixels a wallpaper has to cover, together with ScreenHeight
+	ScreenHeight  int
+	AspectRatios  []string // any of the buckets, see AspectRatios
+	Orientation   string
+	MimeTypes     []string
+	Tags          []string // tag slugs
+	MatchAllTags  bool     // wallpapers need every tag instead of any
+	UserID        *uuid.UUID
+	Uploader      string // username
+	Featured      bool
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+}
+
+// Cursor is the keyset position of the last wallpaper of a page: the key of the order it was listed in
+// with its creation time and ID as tiebreakers. The next page continues right after it, no matter how
+// many wallpapers were uploaded in the meantime.
+type Cursor struct {
+	Sort      string     `json:"s"`
+	Key       float64    `json:"k,omitempty"` // like count, search rank or screen fit, unused when newest
+	CreatedAt time.Time  `json:"t"`
+	ID        uuid.UUID  `json:"i"`
+	RankedAt  *time.Time `json:"r,omitempty"` // the relevance recency boost is frozen at the first page
+	Seed      int64      `json:"d,omitempty"` // seed of a random order
+	Since     *time.Time `json:"w,omitempty"` // start of the created_at window, trending's moving window is frozen at the first page
+}
+
+// listQuery builds the WHERE clause of a listing from a Filter, numbering placeholders as arguments are added
+type listQuery struct {
+	conditions []string
+	args       []interface{}
+	search     string // placeholder of the search query, empty when not searching
+	screen     string // placeholder of the screen's aspect ratio, empty without a screen filter
+}
+
+func newListQuery(f Filter) *listQuery {
+	q := &listQuery{conditions: []string{"w.deleted_at IS NULL", "w.status = 'active'"}}
+
+	if f.Search != "" {
+		q.search = q.arg(f.Search)
+		q.where("w.search_vector @@ websearch_to_tsquery('english', %s)", q.search)
+	}
+	q.whereColor(f.Color)
+	if f.DeviceType != "" {
+		q.where("w.device_type = %s", q.arg(f.DeviceType))
+	}
+	if f.MinWidth > 0 {
+		q.where("w.width >= %s", q.arg(f.MinWidth))
+	}
+	if f.MinHeight > 0 {
+		q.where("w.height >= %s", q.arg(f.MinHeight))
+	}
+	if f.Width > 0 && f.Height > 0 {
+		q.where("w.width = %s AND w.height = %s", q.arg(f.Width), q.arg(f.Height))
+	}
+	if f.ScreenWidth > 0 && f.ScreenHeight > 0 {
+		// Covering the screen in both dimensions is enough, the largest window of the screen's
+		// aspect ratio is then at least as large as the screen too
+		q.where("w.width >= %s AND w.height >= %s", q.arg(f.ScreenWidth), q.arg(f.ScreenHeight))
+		q.screen = q.arg(float64(f.ScreenWidth) / float64(f.ScreenHeight))
+	}
+	if len(f.AspectRatios) > 0 {
+		q.where("%s = ANY(%s)", aspectExpression, q.arg(pq.Array(f.AspectRatios)))
+	}
+	if f.Orientation != "" {
+		q.where("%s = %s", orientationExpression, q.arg(f.Orientation))
+	}
+	if len(f.MimeTypes) > 0 {
+		q.where("w.mime_type = ANY(%s)", q.arg(pq.Array(f.MimeTypes)))
+	}
+	if len(f.Tags) > 0 {
+		tagged := `
+			SELECT %s FROM wallpaper_tags wt
+			INNER JOIN tags t ON t.id = wt.tag_id
+			WHERE wt.wallpaper_id = w.id AND t.slug = ANY(%s)`
+		if f.MatchAllTags {
+			q.where("("+tagged+") = %s", "COUNT(DISTINCT t.slug)", q.arg(pq.Array(f.Tags)), q.arg(len(uniqueStrings(f.Tags))))
+		} else {
+			q.where("EXISTS ("+tagged+")", "1", q.arg(pq.Array(f.Tags)))
+		}
+	}
+	if f.UserID != nil {
+		q.where("w.user_id = %s", q.arg(*f.UserID))
+	}
+	if f.Uploader != "" {
+		q.where("w.user_id = (SELECT id FROM users WHERE username = %s)", q.arg(f.Uploader))
+	}
+	if f.Featured {
+		q.where("w.is_featured = TRUE")
+	}
+	if f.CreatedAfter != nil {
+		q.where("w.created_at >= %s", q.arg(*f.CreatedAfter))
+	}
+	if f.CreatedBefore != nil {
+		q.where("w.created_at < %s", q.arg(*f.CreatedBefore))
+	}
+
+	return q
+}
+
+// arg adds a query argument and returns its placeholder
+func (q *listQuery) arg(value interface{}) string {
+	q.args = append(q.args, value)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+// where adds a condition, format is filled with placeholders returned by arg
+func (q *listQuery) where(format string, a ...interface{}) {
+	q.conditions = append(q.conditions, fmt.Sprintf(format, a...))
+}
+
+func (q *listQuery) whereClause() string {
+	return "WHERE " + strings.Join(q.conditions, "\n\t\t  AND ")
+}
+
+// listOrder is a listing's order: by the sort key, then creation time and ID, all in the same direction.
+// Newest and oldest have no key of their own.
+type listOrder struct {
+	sort      string // the sort actually applied
+	key       string // SQL expression
+	ascending bool
+}
+
+// order resolves an Order for this query, unknown sorts and sorts the filter doesn't allow are newest.
+// Search relevance gets a boost for wallpapers recent as of rankedAt that halves after about a month,
+// so an old exact match still beats a new partial one.
+func (q *listQuery) order(o Order, rankedAt time.Time) listOrder {
+	switch {
+	case o.Sort == SortOldest:
+		return listOrder{sort: o.Sort, ascending: true}
+	case o.Sort == SortMostLiked:
+		return listOrder{sort: o.Sort, key: "w.like_count"}
+	case o.Sort == SortMostDownloaded:
+		return listOrder{sort: o.Sort, key: "w.download_count"}
+	case o.Sort == SortMostViewed:
+		return listOrder{sort: o.Sort, key: "w.view_count"}
+	case o.Sort == SortRandom:
+		// A seeded hash of the ID, shifted to fit a float64 cursor key exactly
+		return listOrder{sort: o.Sort, key: fmt.Sprintf("(hashtextextended(w.id::text, %s) >> 11)", q.arg(o.Seed))}
+	case o.Sort == SortRelevance && q.search != "":
+		return listOrder{sort: o.Sort, key: fmt.Sprintf(`(ts_rank(w.search_vector, websearch_to_tsquery('english', %s))
+			* (1 + 1 / (1 + EXTRACT(EPOCH FROM %s::timestamptz - w.created_at) / 2592000)))::float8`, q.search, q.arg(rankedAt))}
+	case o.Sort == SortFit && q.screen != "":
+		// Share of the image left after cropping it to the screen's aspect ratio
+		return listOrder{sort: o.Sort, key: fmt.Sprintf(`(LEAST(w.width::float8 / w.height, %[1]s) / GREATEST(w.width::float8 / w.height, %[1]s))`, q.screen)}
+	}
+	return listOrder{sort: SortNewest}
+}
+
+func (o listOrder) orderBy() string {
+	direction := " DESC"
+	if o.ascending {
+		direction = " ASC"
+	}
+	columns := []string{"w.created_at", "w.id"}
+	if o.key != "" {
+		columns = append([]string{o.key}, columns...)
+	}
+	return strings.Join(columns, direction+", ") + direction
+}
+
+// whereAfter continues a listing after the cursor
+func (q *listQuery) whereAfter(o listOrder, after *Cursor) {
+	op := "<"
+	if o.ascending {
+		op = ">"
+	}
+	if o.key == "" {
+		q.where("(w.created_at, w.id) %s (%s, %s)", op, q.arg(after.CreatedAt), q.arg(after.ID))
+		return
+	}
+	q.where("(%s, w.created_at, w.id) %s (%s, %s, %s)", o.key, op, q.arg(after.Key), q.arg(after.CreatedAt), q.arg(after.ID))
+}
+
+// orientationExpression classifies a wallpaper by OrientationLandscape, OrientationPortrait or OrientationSquare
+const orientationExpression = `(CASE WHEN w.width > w.height THEN 'landscape' WHEN w.width < w.height THEN 'portrait' ELSE 'square' END)`
+
+// aspectExpression puts a wallpaper in the first matching AspectRatios bucket, or AspectOther
+var aspectExpression = func() string {
+	var b strings.Builder
+	b.WriteString("(CASE")
+	for _, a := range AspectRatios {
+		fmt.Fprintf(&b, " WHEN abs(w.width::float8 / w.height - %.6f) <= %.6f THEN '%s'", a.Ratio, a.Ratio*aspectTolerance, a.Name)
+	}
+	fmt.Fprintf(&b, " ELSE '%s' END)", AspectOther)
+	return b.String()
+}()
+
+// List returns the wallpapers matching the filter in the given order. Searches carry
+// highlighted snippets of the matches.
+//
+// Pages start after the cursor when one is given, otherwise at offset. The total is only counted
+// for offset pages, keyset pages skip it. The returned cursor points past the last wallpaper and is
+// nil on the last page.
+func (r *Repository) List(ctx context.Context, f Filter, o Order, after *Cursor, limit, offset int) ([]*Wallpaper, int, *Cursor, error) {
+	q := newListQuery(f)
+
+	// Get total count
+	var total int
+	if after == nil {
+		countQuery := `SELECT COUNT(*) FROM wallpapers w ` + q.whereClause()
+		if err := r.db.QueryRowContext(ctx, countQuery, q.args...).Scan(&total); err != nil {
+			return nil, 0, nil, err
+		}
+	}
+
+	rankedAt := time.Now()
+	if after != nil && after.RankedAt != nil {
+		rankedAt = *after.RankedAt
+	}
+	order := q.order(o, rankedAt)
+	if after != nil {
+		q.whereAfter(order, after)
+		offset = 0
+	}
+
+	// Get paginated results
+	columns := `
+			w.id, w.user_id, w.title, w.thumbnail_url, w.blurhash, w.width, w.height,
+			w.view_count, w.like_count, w.created_at,
+			u.username, u.avatar_url`
+	if q.search != "" {
+		columns += fmt.Sprintf(`,
+			ts_headline('english', w.title, websearch_to_tsquery('english', %[1]s), %[2]s),
+			ts_headline('english', COALESCE(w.description, ''), websearch_to_tsquery('english', %[1]s), %[3]s)`,
+			q.search, q.arg(titleHighlightOptions), q.arg(descriptionHighlightOptions))
+	}
+	if order.key != "" {
+		columns += ",\n\t\t\t" + order.key
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM wallpapers w
+		INNER JOIN users u ON w.user_id = u.id
+		%s
+		ORDER BY %s
+		LIMIT %s OFFSET %s
+	`, columns, q.whereClause(), order.orderBy(), q.arg(limit+1), q.arg(offset))
+
+	rows, err := r.db.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	defer rows.Close()
+
+	var wallpapers []*Wallpaper
+	var keys []float64
+
+	for rows.Next() {
+		var w Wallpaper
+		var u User
+		var blurhash sql.NullString
+		var avatarURL sql.NullString
+		var titleSnippet, descriptionSnippet string
+		var sortKey float64
+
+		dest := []interface{}{
+			&w.ID, &w.UserID, &w.Title, &w.ThumbnailURL, &blurhash, &w.Width, &w.Height,
+			&w.ViewCount, &w.LikeCount, &w.CreatedAt,
+			&u.Username, &avatarURL,
+		}
+		if q.search != "" {
+			dest = append(dest, &titleSnippet, &descriptionSnippet)
+		}
+		if order.key != "" {
+			dest = append(dest, &sortKey)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, 0, nil, err
+		}
+
+		if blurhash.Valid {
+			w.Blurhash = &blurhash.String
+		}
+		if avatarURL.Valid {
+			u.AvatarURL = &avatarURL.String
+		}
+		if q.search != "" {
+			w.Highlight = &Highlight{
+				Title:       highlightHTML(titleSnippet),
+				Description: highlightHTML(descriptionSnippet),
+			}
+		}
+		u.ID = w.UserID
+		w.User = &u
+		wallpapers = append(wallpapers, &w)
+		keys = append(keys, sortKey)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, nil, err
+	}
+
+	// One wallpaper more than the limit was fetched to tell whether there is a next page
+	var next *Cursor
+	if len(wallpapers) > limit {
+		wallpapers = wallpapers[:limit]
+		last := wallpapers[limit-1]
+		next = &Cursor{Sort: order.sort, Key: keys[limit-1], CreatedAt: last.CreatedAt, ID: last.ID, Since: f.CreatedAfter}
+		switch order.sort {
+		case SortRelevance:
+			next.RankedAt = &rankedAt
+		case SortRandom:
+			next.Seed = o.Seed
+		}
+	}
+
+	if err := r.attachRenditions(ctx, wallpapers); err != nil {
+		return nil, 0, nil, err
+	}
+
+	return wallpapers, total, next, nil
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var unique []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,193 @@
+package wallpaper
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestListQueryOrder(t *testing.T) {
+	rankedAt := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	search := Filter{Search: "lake"}
+	screen := Filter{ScreenWidth: 1920, ScreenHeight: 1080}
+
+	tests := []struct {
+		name        string
+		filter      Filter
+		order       Order
+		wantSort    string
+		wantKey     string // prefix of the key expression, empty for none
+		wantOrderBy string
+		wantArgs    []interface{} // arguments after the filter's own
+	}{
+		{
+			name: "newest", order: Order{Sort: SortNewest},
+			wantSort: SortNewest, wantOrderBy: "w.created_at DESC, w.id DESC",
+		},
+		{
+			name: "unknown falls back to newest", order: Order{Sort: "loudest"},
+			wantSort: SortNewest, wantOrderBy: "w.created_at DESC, w.id DESC",
+		},
+		{
+			name: "oldest", order: Order{Sort: SortOldest},
+			wantSort: SortOldest, wantOrderBy: "w.created_at ASC, w.id ASC",
+		},
+		{
+			name: "most liked", order: Order{Sort: SortMostLiked},
+			wantSort: SortMostLiked, wantKey: "w.like_count",
+			wantOrderBy: "w.like_count DESC, w.created_at DESC, w.id DESC",
+		},
+		{
+			name: "most downloaded", order: Order{Sort: SortMostDownloaded},
+			wantSort: SortMostDownloaded, wantKey: "w.download_count",
+			wantOrderBy: "w.download_count DESC, w.created_at DESC, w.id DESC",
+		},
+		{
+			name: "most viewed", order: Order{Sort: SortMostViewed},
+			wantSort: SortMostViewed, wantKey: "w.view_count",
+			wantOrderBy: "w.view_count DESC, w.created_at DESC, w.id DESC",
+		},
+		{
+			name: "relevance without a search is newest", order: Order{Sort: SortRelevance},
+			wantSort: SortNewest, wantOrderBy: "w.created_at DESC, w.id DESC",
+		},
+		{
+			name: "relevance", filter: search, order: Order{Sort: SortRelevance},
+			wantSort: SortRelevance, wantKey: "(ts_rank(w.search_vector, websearch_to_tsquery('english', $1))",
+			wantArgs: []interface{}{rankedAt},
+		},
+		{
+			name: "fit without a screen is newest", order: Order{Sort: SortFit},
+			wantSort: SortNewest, wantOrderBy: "w.created_at DESC, w.id DESC",
+		},
+		{
+			name: "fit", filter: screen, order: Order{Sort: SortFit},
+			wantSort: SortFit, wantKey: "(LEAST(w.width::float8 / w.height, $3) / GREATEST(w.width::float8 / w.height, $3))",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newListQuery(tt.filter)
+			filterArgs := len(q.args)
+
+			o := q.order(tt.order, rankedAt)
+			if o.sort != tt.wantSort {
+				t.Errorf("sort = %q, want %q", o.sort, tt.wantSort)
+			}
+			if !strings.HasPrefix(o.key, tt.wantKey) || (tt.wantKey == "") != (o.key == "") {
+				t.Errorf("key = %q, want it to start with %q", o.key, tt.wantKey)
+			}
+			if tt.wantOrderBy != "" && o.orderBy() != tt.wantOrderBy {
+				t.Errorf("orderBy = %q, want %q", o.orderBy(), tt.wantOrderBy)
+			}
+			if tt.wantKey != "" && !strings.HasPrefix(o.orderBy(), o.key+" DESC, ") {
+				t.Errorf("orderBy = %q, want the key first", o.orderBy())
+			}
+			if got := q.args[filterArgs:]; len(got)+len(tt.wantArgs) > 0 && !reflect.DeepEqual(got, tt.wantArgs) {
+				t.Errorf("order args = %v, want %v", got, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestListQueryWhereAfter(t *testing.T) {
+	createdAt := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	id := uuid.New()
+	after := &Cursor{Key: 42, CreatedAt: createdAt, ID: id}
+
+	tests := []struct {
+		sort     string
+		wantCond string
+		wantArgs []interface{}
+	}{
+		{sort: SortNewest, wantCond: "(w.created_at, w.id) < ($2, $3)", wantArgs: []interface{}{createdAt, id}},
+		{sort: SortOldest, wantCond: "(w.created_at, w.id) > ($2, $3)", wantArgs: []interface{}{createdAt, id}},
+		{sort: SortMostLiked, wantCond: "(w.like_count, w.created_at, w.id) < ($2, $3, $4)", wantArgs: []interface{}{42.0, createdAt, id}},
+		{sort: SortMostDownloaded, wantCond: "(w.download_count, w.created_at, w.id) < ($2, $3, $4)", wantArgs: []interface{}{42.0, createdAt, id}},
+		{sort: SortMostViewed, wantCond: "(w.view_count, w.created_at, w.id) < ($2, $3, $4)", wantArgs: []interface{}{42.0, createdAt, id}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			// One filter argument first, so placeholders have to continue from it
+			q := newListQuery(Filter{DeviceType: "desktop"})
+			q.whereAfter(q.order(Order{Sort: tt.sort}, createdAt), after)
+
+			if got := q.conditions[len(q.conditions)-1]; got != tt.wantCond {
+				t.Errorf("condition = %q, want %q", got, tt.wantCond)
+			}
+			if got := q.args[1:]; !reflect.DeepEqual(got, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", got, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestListQueryWhere(t *testing.T) {
+	userID := uuid.New()
+	q := newListQuery(Filter{
+		Search:     "lake",
+		DeviceType: "mobile",
+		MinWidth:   1080,
+		UserID:     &userID,
+		Featured:   true,
+	})
+
+	want := strings.Join([]string{
+		"WHERE w.deleted_at IS NULL",
+		"w.status = 'active'",
+		"w.search_vector @@ websearch_to_tsquery('english', $1)",
+		"w.device_type = $2",
+		"w.width >= $3",
+		"w.user_id = $4",
+		"w.is_featured = TRUE",
+	}, "\n\t\t  AND ")
+	if got := q.whereClause(); got != want {
+		t.Errorf("where clause =\n%s\nwant\n%s", got, want)
+	}
+	if want := []interface{}{"lake", "mobile", 1080, userID}; !reflect.DeepEqual(q.args, want) {
+		t.Errorf("args = %v, want %v", q.args, want)
+	}
+	if q.search != "$1" {
+		t.Errorf("search placeholder = %q, want $1", q.search)
+	}
+}
+
+func TestListQueryRandomOrder(t *testing.T) {
+	createdAt := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	id := uuid.New()
+
+	q := newListQuery(Filter{DeviceType: "desktop"})
+	o := q.order(Order{Sort: SortRandom, Seed: -7}, createdAt)
+
+	const wantKey = "(hashtextextended(w.id::text, $2) >> 11)"
+	if o.sort != SortRandom || o.key != wantKey {
+		t.Fatalf("order = %+v, want key %q", o, wantKey)
+	}
+	if want := wantKey + " DESC, w.created_at DESC, w.id DESC"; o.orderBy() != want {
+		t.Errorf("orderBy = %q, want %q", o.orderBy(), want)
+	}
+
+	// The next page hashes with the same seed argument and compares against the cursor's key
+	q.whereAfter(o, &Cursor{Sort: SortRandom, Key: 12345, CreatedAt: createdAt, ID: id, Seed: -7})
+	if want := "(" + wantKey + ", w.created_at, w.id) < ($3, $4, $5)"; q.conditions[len(q.conditions)-1] != want {
+		t.Errorf("condition = %q, want %q", q.conditions[len(q.conditions)-1], want)
+	}
+	if want := []interface{}{"desktop", int64(-7), 12345.0, createdAt, id}; !reflect.DeepEqual(q.args, want) {
+		t.Errorf("args = %v, want %v", q.args, want)
+	}
+
+	// Shifted by 11 bits every hash fits a float64 cursor key without rounding
+	for _, hash := range []int64{math.MaxInt64, math.MinInt64, 1<<62 + 1, -(1<<62 + 1)} {
+		key := hash >> 11
+		if int64(float64(key)) != key {
+			t.Errorf("key %d does not survive a float64 round trip", key)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package wallpaper
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Rendition is a resized copy of a wallpaper stored next to the original.
+// Cropped renditions are cut to a device aspect ratio instead of fitting the whole image.
+type Rendition struct {
+	Name          string `json:"name"`
+	Format        string `json:"format"`
+	Width         int    `json:"width"`
+	Height        int    `json:"height"`
+	FileSizeBytes int64  `json:"file_size_bytes"`
+	URL           string `json:"url"`
+	StorageKey    string `json:"-"`
+	Cropped       bool   `json:"cropped,omitempty"`
+}
+
+// AddRenditions stores the renditions generated for a wallpaper
+func (r *Repository) AddRenditions(ctx context.Context, wallpaperID uuid.UUID, renditions []Rendition) (err error) {
+	if len(renditions) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	query := `
+		INSERT INTO wallpaper_renditions (
+			wallpaper_id, name, format, width, height, file_size_bytes, storage_key, url, cropped
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (wallpaper_id, name, format) DO UPDATE
+		SET width = EXCLUDED.width,
+		    height = EXCLUDED.height,
+		    file_size_bytes = EXCLUDED.file_size_bytes,
+		    storage_key = EXCLUDED.storage_key,
+		    url = EXCLUDED.url,
+		    cropped = EXCLUDED.cropped
+	`
+	for _, rd := range renditions {
+		_, err = tx.ExecContext(ctx, query,
+			wallpaperID, rd.Name, rd.Format, rd.Width, rd.Height, rd.FileSizeBytes, rd.StorageKey, rd.URL, rd.Cropped,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetRenditions returns the renditions of several wallpapers keyed by wallpaper ID, largest first
+func (r *Repository) GetRenditions(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID][]Rendition, error) {
+	result := make(map[uuid.UUID][]Rendition, len(ids))
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	query := `
+		SELECT wallpaper_id, name, format, width, height, file_size_bytes, storage_key, url, cropped
+		FROM wallpaper_renditions
+		WHERE wallpaper_id = ANY($1)
+		ORDER BY width * height DESC, format
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var wallpaperID uuid.UUID
+		var rd Rendition
+		if err := rows.Scan(
+			&wallpaperID, &rd.Name, &rd.Format, &rd.Width, &rd.Height, &rd.FileSizeBytes, &rd.StorageKey, &rd.URL, &rd.Cropped,
+		); err != nil {
+			return nil, err
+		}
+		result[wallpaperID] = append(result[wallpaperID], rd)
+	}
+
+	return result, rows.Err()
+}
+
+// attachRenditions loads renditions for wallpapers in a single query
+func (r *Repository) attachRenditions(ctx context.Context, wallpapers []*Wallpaper) error {
+	if len(wallpapers) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(wallpapers))
+	for i, w := range wallpapers {
+		ids[i] = w.ID
+	}
+
+	renditions, err := r.GetRenditions(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, w := range wallpapers {
+		w.Renditions = renditions[w.ID]
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package wallpaper
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Search matches are marked with private use characters so the snippet can be HTML-escaped
+// before the markers become <mark> tags.
+const (
+	highlightStart = "\uE000"
+	highlightStop  = "\uE001"
+)
+
+// ts_headline options, titles are highlighted whole and descriptions cut to the best fragments
+var (
+	titleHighlightOptions       = fmt.Sprintf("StartSel=%s, StopSel=%s, HighlightAll=true", highlightStart, highlightStop)
+	descriptionHighlightOptions = fmt.Sprintf("StartSel=%s, StopSel=%s, MaxWords=35, MinWords=15, MaxFragments=2", highlightStart, highlightStop)
+)
+
+// Highlight holds HTML-escaped snippets of a search result with the matched words in <mark> tags
+type Highlight struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+}
+
+// searchTagsQuery refreshes the tag names copied onto a wallpaper for the search vector
+const searchTagsQuery = `
+	UPDATE wallpapers w
+	SET search_tags = COALESCE((
+		SELECT string_agg(t.name, ' ' ORDER BY t.name)
+		FROM wallpaper_tags wt
+		INNER JOIN tags t ON t.id = wt.tag_id
+		WHERE wt.wallpaper_id = w.id
+	), '')
+	WHERE w.id = $1
+`
+
+// highlightHTML escapes a ts_headline snippet and turns the match markers into <mark> tags.
+// Markers that don't pair up, which can only come from the text itself, are dropped.
+func highlightHTML(snippet string) string {
+	var b strings.Builder
+	open := false
+	for {
+		i := strings.IndexAny(snippet, highlightStart+highlightStop)
+		if i < 0 {
+			break
+		}
+		b.WriteString(html.EscapeString(snippet[:i]))
+		marker := snippet[i : i+len(highlightStart)]
+		snippet = snippet[i+len(marker):]
+
+		switch {
+		case marker == highlightStart && !open:
+			b.WriteString("<mark>")
+			open = true
+		case marker == highlightStop && open:
+			b.WriteString("</mark>")
+			open = false
+		}
+	}
+	b.WriteString(html.EscapeString(snippet))
+	if open {
+		b.WriteString("</mark>")
+	}
+	return b.String()
+}
@@ -0,0 +1,36 @@
+package wallpaper
+
+import "testing"
+
+func TestHighlightHTML(t *testing.T) {
+	const start, stop = highlightStart, highlightStop
+
+	tests := []struct {
+		name    string
+		snippet string
+		want    string
+	}{
+		{name: "plain", snippet: "Mountain lake", want: "Mountain lake"},
+		{name: "match", snippet: start + "Mountain" + stop + " lake", want: "<mark>Mountain</mark> lake"},
+		{name: "several matches", snippet: start + "a" + stop + " b " + start + "c" + stop, want: "<mark>a</mark> b <mark>c</mark>"},
+		{
+			name:    "html in the title is escaped",
+			snippet: `<script>alert("x")</script> ` + start + "lake" + stop + " & <b>",
+			want:    "&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; <mark>lake</mark> &amp; &lt;b&gt;",
+		},
+		{name: "html inside a match", snippet: start + "<i>" + stop, want: "<mark>&lt;i&gt;</mark>"},
+		{name: "stray stop", snippet: "lake" + stop + " " + start + "dawn" + stop, want: "lake <mark>dawn</mark>"},
+		{name: "stray start inside a match", snippet: start + "la" + start + "ke" + stop, want: "<mark>lake</mark>"},
+		{name: "unclosed start", snippet: "lake " + start + "dawn", want: "lake <mark>dawn</mark>"},
+		{name: "only markers", snippet: stop + start + stop + stop, want: "<mark></mark>"},
+		{name: "empty", snippet: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlightHTML(tt.snippet); got != tt.want {
+				t.Errorf("highlightHTML(%q) = %q, want %q", tt.snippet, got, tt.want)
+			}
+		})
+	}
+}
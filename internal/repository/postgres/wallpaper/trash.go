@@ -0,0 +1,110 @@
+package wallpaper
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Soft-deleted wallpapers are invisible to every other query in this package,
+// the methods below are the only ones that read them.
+
+// GetDeleted retrieves a soft-deleted wallpaper, sql.ErrNoRows when it is not in the trash
+func (r *Repository) GetDeleted(ctx context.Context, id uuid.UUID) (*Wallpaper, error) {
+	query := `
+		SELECT id, user_id, title, thumbnail_url, blurhash, width, height, status, created_at, updated_at, deleted_at,
+			deleted_by, removed_by_moderator
+		FROM wallpapers
+		WHERE id = $1 AND deleted_at IS NOT NULL
+	`
+
+	w := &Wallpaper{}
+	var blurhash sql.NullString
+	var deletedAt time.Time
+	var deletedBy uuid.NullUUID
+
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&w.ID, &w.UserID, &w.Title, &w.ThumbnailURL, &blurhash, &w.Width, &w.Height, &w.Status,
+		&w.CreatedAt, &w.UpdatedAt, &deletedAt, &deletedBy, &w.ModeratorRemoved,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if blurhash.Valid {
+		w.Blurhash = &blurhash.String
+	}
+	if deletedBy.Valid {
+		w.DeletedBy = &deletedBy.UUID
+	}
+	w.DeletedAt = &deletedAt
+	return w, nil
+}
+
+// ListDeletedByUser retrieves a user's soft-deleted wallpapers, most recently deleted first.
+// Wallpapers removed by a moderator are not part of the owner's trash.
+func (r *Repository) ListDeletedByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*Wallpaper, int, error) {
+	var total int
+	countQuery := `SELECT COUNT(*) FROM wallpapers WHERE user_id = $1 AND deleted_at IS NOT NULL AND NOT removed_by_moderator`
+	if err := r.db.QueryRowContext(ctx, countQuery, userID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT id, user_id, title, thumbnail_url, blurhash, width, height, status, created_at, updated_at, deleted_at
+		FROM wallpapers
+		WHERE user_id = $1 AND deleted_at IS NOT NULL AND NOT removed_by_moderator
+		ORDER BY deleted_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var wallpapers []*Wallpaper
+	for rows.Next() {
+		w := &Wallpaper{}
+		var blurhash sql.NullString
+		var deletedAt time.Time
+
+		if err := rows.Scan(
+			&w.ID, &w.UserID, &w.Title, &w.ThumbnailURL, &blurhash, &w.Width, &w.Height, &w.Status,
+			&w.CreatedAt, &w.UpdatedAt, &deletedAt,
+		); err != nil {
+			return nil, 0, err
+		}
+
+		if blurhash.Valid {
+			w.Blurhash = &blurhash.String
+		}
+		w.DeletedAt = &deletedAt
+		wallpapers = append(wallpapers, w)
+	}
+
+	return wallpapers, total, rows.Err()
+}
+
+// Restore clears deleted_at of a wallpaper deleted at or after deletedSince (zero restores it
+// regardless of age). Returns false when it isn't in the trash, or was deleted too long ago.
+// The UPDATE takes the same row lock as Purge, so a restore never races a purge.
+func (r *Repository) Restore(ctx context.Context, id uuid.UUID, deletedSince time.Time) (bool, error) {
+	query := `
+		UPDATE wallpapers SET deleted_at = NULL, deleted_by = NULL, removed_by_moderator = FALSE, updated_at = NOW()
+		WHERE id = $1 AND deleted_at IS NOT NULL AND deleted_at >= $2
+	`
+	result, err := r.db.ExecContext(ctx, query, id, deletedSince)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
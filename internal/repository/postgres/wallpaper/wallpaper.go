@@ -3,37 +3,63 @@ package wallpaper
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/lib/pq"
 )
 
+// Wallpaper lifecycle statuses, only active wallpapers are listed publicly
+const (
+	StatusProcessing = "processing"
+	StatusActive     = "active"
+	StatusFailed     = "failed"
+)
+
 type Wallpaper struct {
-	ID            uuid.UUID `json:"id"`
-	UserID        uuid.UUID `json:"user_id"`
-	Title         string    `json:"title"`
-	Description   *string   `json:"description,omitempty"`
-	OriginalURL   string    `json:"original_url"`
-	ImageURL      string    `json:"image_url"`
-	ThumbnailURL  string    `json:"thumbnail_url"`
-	Blurhash      *string   `json:"blurhash,omitempty"`
-	DeviceType    string    `json:"device_type"`
-	Width         int       `json:"width"`
-	Height        int       `json:"height"`
-	FileSizeBytes int64     `json:"file_size_bytes"`
-	MimeType      string    `json:"mime_type"`
-	ViewCount     int       `json:"view_count"`
-	DownloadCount int       `json:"download_count"`
-	LikeCount     int       `json:"like_count"`
-	Status        string    `json:"status"`
-	IsFeatured    bool      `json:"is_featured"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID               uuid.UUID      `json:"id"`
+	UserID           uuid.UUID      `json:"user_id"`
+	Title            string         `json:"title"`
+	Description      *string        `json:"description,omitempty"`
+	OriginalURL      string         `json:"original_url"`
+	OriginalKey      string         `json:"-"`
+	ImageURL         string         `json:"image_url"`
+	ThumbnailURL     string         `json:"thumbnail_url"`
+	ThumbnailFormats []string       `json:"thumbnail_formats,omitempty"`
+	Blurhash         *string        `json:"blurhash,omitempty"`
+	Palette          []PaletteColor `json:"palette,omitempty"`
+	PHash            *int64         `json:"-"`
+	ChecksumSHA256   *string        `json:"checksum_sha256,omitempty"`
+	DeviceType       string         `json:"device_type"`
+	Width            int            `json:"width"`
+	Height           int            `json:"height"`
+	FileSizeBytes    int64          `json:"file_size_bytes"`
+	MimeType         string         `json:"mime_type"`
+	ViewCount        int            `json:"view_count"`
+	DownloadCount    int            `json:"download_count"`
+	LikeCount        int            `json:"like_count"`
+	Status           string         `json:"status"`
+	IsFeatured       bool           `json:"is_featured"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        *time.Time     `json:"deleted_at,omitempty"`
+	DeletedBy        *uuid.UUID     `json:"-"`
+	ModeratorRemoved bool           `json:"-"` // deleted by someone other than the owner
+	FocalPoint       *FocalPoint    `json:"focal_point,omitempty"`
+	Highlight        *Highlight     `json:"highlight,omitempty"`
 
 	// Relations (fetched separately or joined)
-	User *User    `json:"user,omitempty"`
-	Tags []string `json:"tags,omitempty"`
+	User       *User       `json:"user,omitempty"`
+	Tags       []string    `json:"tags,omitempty"`
+	Renditions []Rendition `json:"renditions,omitempty"`
+	Metadata   *Metadata   `json:"metadata,omitempty"`
+}
+
+// FocalPoint is the point of interest set by the uploader, relative to the image size (0-1)
+type FocalPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
 }
 
 type User struct {
@@ -53,27 +79,33 @@ func NewRepository(db *sql.DB) *Repository {
 func (r *Repository) Create(ctx context.Context, w *Wallpaper) error {
 	query := `
 		INSERT INTO wallpapers (
-			user_id, title, description, original_url, image_url,
-			thumbnail_url, blurhash, device_type, width, height, file_size_bytes, mime_type,
-			status, is_featured
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+			id, user_id, title, description, original_url, original_key, image_url,
+			thumbnail_url, thumbnail_formats, blurhash, device_type, width, height, file_size_bytes, mime_type,
+			status, is_featured, phash, checksum_sha256, focal_x, focal_y
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21)
 		RETURNING id, view_count, download_count, like_count, created_at, updated_at
 	`
 
+	var focalX, focalY sql.NullFloat64
+	if w.FocalPoint != nil {
+		focalX = sql.NullFloat64{Float64: w.FocalPoint.X, Valid: true}
+		focalY = sql.NullFloat64{Float64: w.FocalPoint.Y, Valid: true}
+	}
+
 	return r.db.QueryRowContext(
 		ctx, query,
-		w.UserID, w.Title, w.Description, w.OriginalURL, w.ImageURL,
-		w.ThumbnailURL, w.Blurhash, w.DeviceType, w.Width, w.Height, w.FileSizeBytes, w.MimeType,
-		w.Status, w.IsFeatured,
+		w.ID, w.UserID, w.Title, w.Description, w.OriginalURL, w.OriginalKey, w.ImageURL,
+		w.ThumbnailURL, pq.Array(w.ThumbnailFormats), w.Blurhash, w.DeviceType, w.Width, w.Height, w.FileSizeBytes, w.MimeType,
+		w.Status, w.IsFeatured, w.PHash, w.ChecksumSHA256, focalX, focalY,
 	).Scan(&w.ID, &w.ViewCount, &w.DownloadCount, &w.LikeCount, &w.CreatedAt, &w.UpdatedAt)
 }
 
 func (r *Repository) GetByID(ctx context.Context, id uuid.UUID) (*Wallpaper, error) {
 	query := `
-		SELECT id, user_id, title, description, original_url, image_url,
-		       thumbnail_url, blurhash, device_type, width, height, file_size_bytes, mime_type,
-		       view_count, download_count, like_count, status, is_featured,
-		       created_at, updated_at
+		SELECT id, user_id, title, description, original_url, COALESCE(original_key, ''), image_url,
+		       thumbnail_url, thumbnail_formats, blurhash, palette, device_type, width, height, file_size_bytes, mime_type,
+		       checksum_sha256, view_count, download_count, like_count, status, is_featured,
+		       focal_x, focal_y, created_at, updated_at
 		FROM wallpapers
 		WHERE id = $1 AND deleted_at IS NULL
 	`
@@ -81,12 +113,15 @@ func (r *Repository) GetByID(ctx context.Context, id uuid.UUID) (*Wallpaper, err
 	w := &Wallpaper{}
 	var description sql.NullString
 	var blurhash sql.NullString
+	var palette []byte
+	var checksum sql.NullString
+	var focalX, focalY sql.NullFloat64
 
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&w.ID, &w.UserID, &w.Title, &description, &w.OriginalURL, &w.ImageURL,
-		&w.ThumbnailURL, &blurhash, &w.DeviceType, &w.Width, &w.Height, &w.FileSizeBytes, &w.MimeType,
-		&w.ViewCount, &w.DownloadCount, &w.LikeCount, &w.Status, &w.IsFeatured,
-		&w.CreatedAt, &w.UpdatedAt,
+		&w.ID, &w.UserID, &w.Title, &description, &w.OriginalURL, &w.OriginalKey, &w.ImageURL,
+		&w.ThumbnailURL, pq.Array(&w.ThumbnailFormats), &blurhash, &palette, &w.DeviceType, &w.Width, &w.Height, &w.FileSizeBytes, &w.MimeType,
+		&checksum, &w.ViewCount, &w.DownloadCount, &w.LikeCount, &w.Status, &w.IsFeatured,
+		&focalX, &focalY, &w.CreatedAt, &w.UpdatedAt,
 	)
 
 	if err != nil {
@@ -99,82 +134,81 @@ func (r *Repository) GetByID(ctx context.Context, id uuid.UUID) (*Wallpaper, err
 	if blurhash.Valid {
 		w.Blurhash = &blurhash.String
 	}
+	if checksum.Valid {
+		w.ChecksumSHA256 = &checksum.String
+	}
+	if focalX.Valid && focalY.Valid {
+		w.FocalPoint = &FocalPoint{X: focalX.Float64, Y: focalY.Float64}
+	}
+	if w.Palette, err = scanPalette(palette); err != nil {
+		return nil, err
+	}
+
+	if err := r.attachRenditions(ctx, []*Wallpaper{w}); err != nil {
+		return nil, err
+	}
 
 	return w, nil
 }
 
-func (r *Repository) AddTags(ctx context.Context, wallpaperID uuid.UUID, tags []string) error {
-	for _, tagName := range tags {
-		var tagID int64
-		err := r.db.QueryRowContext(ctx,
-			`INSERT INTO tags (name) VALUES ($1) ON CONFLICT (name) DO UPDATE SET name = $1 RETURNING id`,
-			tagName,
-		).Scan(&tagID)
+// TagRef is a tag attached to a wallpaper by name, slug identifies the tag
+type TagRef struct {
+	Name string
+	Slug string
+}
 
+// AddTags attaches tags to a wallpaper, creating the ones that don't exist yet.
+// A tag's wallpaper_count is incremented for each wallpaper newly attached to it.
+func (r *Repository) AddTags(ctx context.Context, wallpaperID uuid.UUID, tags []TagRef) (err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for _, t := range tags {
+		var tagID uuid.UUID
+		err = tx.QueryRowContext(ctx,
+			`INSERT INTO tags (name, slug) VALUES ($1, $2)
+			 ON CONFLICT (slug) DO UPDATE SET slug = EXCLUDED.slug
+			 RETURNING id`,
+			t.Name, t.Slug,
+		).Scan(&tagID)
 		if err != nil {
 			return err
 		}
 
-		_, err = r.db.ExecContext(ctx,
+		var result sql.Result
+		result, err = tx.ExecContext(ctx,
 			`INSERT INTO wallpaper_tags (wallpaper_id, tag_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
 			wallpaperID, tagID,
 		)
 		if err != nil {
 			return err
 		}
-	}
-	return nil
-}
-
-func (r *Repository) List(ctx context.Context, limit, offset int) ([]*Wallpaper, int, error) {
-	var total int
-	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM wallpapers WHERE deleted_at IS NULL`).Scan(&total)
-	if err != nil {
-		return nil, 0, err
-	}
 
-	query := `
-		SELECT 
-			w.id, w.user_id, w.title, w.thumbnail_url, w.width, w.height,
-			w.view_count, w.like_count, w.created_at,
-			u.username, u.avatar_url
-		FROM wallpapers w
-		INNER JOIN users u ON w.user_id = u.id
-		WHERE w.deleted_at IS NULL
-		ORDER BY w.created_at DESC
-		LIMIT $1 OFFSET $2
-	`
-
-	rows, err := r.db.QueryContext(ctx, query, limit, offset)
-	if err != nil {
-		return nil, 0, err
-	}
-	defer rows.Close()
-
-	var wallpapers []*Wallpaper
-
-	for rows.Next() {
-		var w Wallpaper
-		var u User
-		var avatarURL sql.NullString
-
-		if err := rows.Scan(
-			&w.ID, &w.UserID, &w.Title, &w.ThumbnailURL, &w.Width, &w.Height,
-			&w.ViewCount, &w.LikeCount, &w.CreatedAt,
-			&u.Username, &avatarURL,
-		); err != nil {
-			return nil, 0, err
+		var rows int64
+		if rows, err = result.RowsAffected(); err != nil {
+			return err
 		}
-
-		if avatarURL.Valid {
-			u.AvatarURL = &avatarURL.String
+		if rows > 0 {
+			_, err = tx.ExecContext(ctx, `UPDATE tags SET wallpaper_count = wallpaper_count + 1 WHERE id = $1`, tagID)
+			if err != nil {
+				return err
+			}
 		}
-		u.ID = w.UserID
-		w.User = &u
-		wallpapers = append(wallpapers, &w)
 	}
 
-	return wallpapers, total, nil
+	// Make the tags searchable
+	if _, err = tx.ExecContext(ctx, searchTagsQuery, wallpaperID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // Update updates wallpaper metadata
@@ -190,12 +224,18 @@ func (r *Repository) Update(ctx context.Context, id uuid.UUID, title, descriptio
 	return err
 }
 
-// SoftDelete marks wallpaper as deleted
-func (r *Repository) SoftDelete(ctx context.Context, id uuid.UUID) error {
-	query := `UPDATE wallpapers SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL`
-	_, err := r.db.ExecContext(ctx, query, id)
+// SoftDelete marks wallpaper as deleted by deletedBy, a removal by anyone but the owner
+// is recorded as a moderator removal
+func (r *Repository) SoftDelete(ctx context.Context, id, deletedBy uuid.UUID) error {
+	query := `
+		UPDATE wallpapers
+		SET deleted_at = NOW(), deleted_by = $2, removed_by_moderator = (user_id <> $2)
+		WHERE id = $1 AND deleted_at IS NULL
+	`
+	_, err := r.db.ExecContext(ctx, query, id, deletedBy)
 	return err
 }
+
 func (r *Repository) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*Wallpaper, error) {
 	if len(ids) == 0 {
 		return []*Wallpaper{}, nil
@@ -204,7 +244,7 @@ func (r *Repository) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*Wallpape
 	// Build query with ANY for efficient bulk fetch
 	query := `
 		SELECT 
-			w.id, w.user_id, w.title, w.description, w.original_url, w.image_url,
+			w.id, w.user_id, w.title, w.description, w.original_url, COALESCE(w.original_key, ''), w.image_url,
 			w.thumbnail_url, w.blurhash, w.device_type, w.width, w.height, w.file_size_bytes, w.mime_type,
 			w.view_count, w.download_count, w.like_count, w.status, w.is_featured,
 			w.created_at, w.updated_at,
@@ -231,7 +271,7 @@ func (r *Repository) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*Wallpape
 		var avatarURL sql.NullString
 
 		err := rows.Scan(
-			&w.ID, &w.UserID, &w.Title, &description, &w.OriginalURL, &w.ImageURL,
+			&w.ID, &w.UserID, &w.Title, &description, &w.OriginalURL, &w.OriginalKey, &w.ImageURL,
 			&w.ThumbnailURL, &blurhash, &w.DeviceType, &w.Width, &w.Height, &w.FileSizeBytes, &w.MimeType,
 			&w.ViewCount, &w.DownloadCount, &w.LikeCount, &w.Status, &w.IsFeatured,
 			&w.CreatedAt, &w.UpdatedAt,
@@ -268,6 +308,10 @@ func (r *Repository) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*Wallpape
 		}
 	}
 
+	if err := r.attachRenditions(ctx, result); err != nil {
+		return nil, err
+	}
+
 	return result, nil
 }
 
@@ -296,320 +340,76 @@ func (r *Repository) SetFeaturedStatus(ctx context.Context, id uuid.UUID, isFeat
 	return nil
 }
 
-// ListFeatured retrieves all featured wallpapers
-func (r *Repository) ListFeatured(ctx context.Context, limit, offset int) ([]*Wallpaper, int, error) {
-	var total int
-	err := r.db.QueryRowContext(ctx,
-		`SELECT COUNT(*) FROM wallpapers WHERE is_featured = true AND deleted_at IS NULL`,
-	).Scan(&total)
-	if err != nil {
-		return nil, 0, err
-	}
-
+// ListMissingBlurhash returns wallpapers without a blurhash, ordered by ID for keyset iteration
+func (r *Repository) ListMissingBlurhash(ctx context.Context, afterID uuid.UUID, limit int) ([]*Wallpaper, error) {
 	query := `
-		SELECT 
-			w.id, w.user_id, w.title, w.thumbnail_url, w.width, w.height,
-			w.view_count, w.like_count, w.created_at,
-			u.username, u.avatar_url
-		FROM wallpapers w
-		INNER JOIN users u ON w.user_id = u.id
-		WHERE w.is_featured = true AND w.deleted_at IS NULL
-		ORDER BY w.created_at DESC
-		LIMIT $1 OFFSET $2
+		SELECT id, thumbnail_url
+		FROM wallpapers
+		WHERE blurhash IS NULL AND deleted_at IS NULL AND id > $1
+		ORDER BY id
+		LIMIT $2
 	`
 
-	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+	rows, err := r.db.QueryContext(ctx, query, afterID, limit)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 	defer rows.Close()
 
 	var wallpapers []*Wallpaper
-
 	for rows.Next() {
 		var w Wallpaper
-		var u User
-		var avatarURL sql.NullString
-
-		if err := rows.Scan(
-			&w.ID, &w.UserID, &w.Title, &w.ThumbnailURL, &w.Width, &w.Height,
-			&w.ViewCount, &w.LikeCount, &w.CreatedAt,
-			&u.Username, &avatarURL,
-		); err != nil {
-			return nil, 0, err
-		}
-
-		if avatarURL.Valid {
-			u.AvatarURL = &avatarURL.String
+		if err := rows.Scan(&w.ID, &w.ThumbnailURL); err != nil {
+			return nil, err
 		}
-		u.ID = w.UserID
-		w.User = &u
 		wallpapers = append(wallpapers, &w)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, 0, err
-	}
-
-	return wallpapers, total, nil
+	return wallpapers, rows.Err()
 }
-func (r *Repository) Search(ctx context.Context, query string, limit, offset int) ([]*Wallpaper, int, error) {
-	searchPattern := "%" + query + "%"
-
-	// Get total count
-	var total int
-	countQuery := `
-		SELECT COUNT(*) 
-		FROM wallpapers 
-		WHERE deleted_at IS NULL 
-		  AND (title ILIKE $1 OR description ILIKE $1)
-	`
-	err := r.db.QueryRowContext(ctx, countQuery, searchPattern).Scan(&total)
-	if err != nil {
-		return nil, 0, err
-	}
-
-	// Get paginated results
-	query = `
-		SELECT 
-			w.id, w.user_id, w.title, w.thumbnail_url, w.width, w.height,
-			w.view_count, w.like_count, w.created_at,
-			u.username, u.avatar_url
-		FROM wallpapers w
-		INNER JOIN users u ON w.user_id = u.id
-		WHERE w.deleted_at IS NULL
-		  AND (w.title ILIKE $1 OR w.description ILIKE $1)
-		ORDER BY w.created_at DESC
-		LIMIT $2 OFFSET $3
-	`
-
-	rows, err := r.db.QueryContext(ctx, query, searchPattern, limit, offset)
-	if err != nil {
-		return nil, 0, err
-	}
-	defer rows.Close()
-
-	var wallpapers []*Wallpaper
 
-	for rows.Next() {
-		var w Wallpaper
-		var u User
-		var avatarURL sql.NullString
-
-		if err := rows.Scan(
-			&w.ID, &w.UserID, &w.Title, &w.ThumbnailURL, &w.Width, &w.Height,
-			&w.ViewCount, &w.LikeCount, &w.CreatedAt,
-			&u.Username, &avatarURL,
-		); err != nil {
-			return nil, 0, err
-		}
-
-		if avatarURL.Valid {
-			u.AvatarURL = &avatarURL.String
-		}
-		u.ID = w.UserID
-		w.User = &u
-		wallpapers = append(wallpapers, &w)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, 0, err
-	}
-
-	return wallpapers, total, nil
+// UpdateBlurhash sets the blurhash placeholder for a wallpaper
+func (r *Repository) UpdateBlurhash(ctx context.Context, id uuid.UUID, blurhash string) error {
+	query := `UPDATE wallpapers SET blurhash = $1, updated_at = NOW() WHERE id = $2`
+	_, err := r.db.ExecContext(ctx, query, blurhash, id)
+	return err
 }
 
-// ListByTag retrieves wallpapers filtered by tag slug
-func (r *Repository) ListByTag(ctx context.Context, tagSlug string, limit, offset int) ([]*Wallpaper, int, error) {
-	// Get total count
-	var total int
-	countQuery := `
-		SELECT COUNT(*) 
-		FROM wallpapers w
-		INNER JOIN wallpaper_tags wt ON w.id = wt.wallpaper_id
-		INNER JOIN tags t ON wt.tag_id = t.id
-		WHERE w.deleted_at IS NULL AND t.slug = $1
-	`
-	err := r.db.QueryRowContext(ctx, countQuery, tagSlug).Scan(&total)
-	if err != nil {
-		return nil, 0, err
-	}
-
-	// Get paginated results
-	query := `
-		SELECT 
-			w.id, w.user_id, w.title, w.thumbnail_url, w.width, w.height,
-			w.view_count, w.like_count, w.created_at,
-			u.username, u.avatar_url
-		FROM wallpapers w
-		INNER JOIN users u ON w.user_id = u.id
-		INNER JOIN wallpaper_tags wt ON w.id = wt.wallpaper_id
-		INNER JOIN tags t ON wt.tag_id = t.id
-		WHERE w.deleted_at IS NULL AND t.slug = $1
-		ORDER BY w.created_at DESC
-		LIMIT $2 OFFSET $3
-	`
-
-	rows, err := r.db.QueryContext(ctx, query, tagSlug, limit, offset)
-	if err != nil {
-		return nil, 0, err
-	}
-	defer rows.Close()
-
-	var wallpapers []*Wallpaper
-
-	for rows.Next() {
-		var w Wallpaper
-		var u User
-		var avatarURL sql.NullString
-
-		if err := rows.Scan(
-			&w.ID, &w.UserID, &w.Title, &w.ThumbnailURL, &w.Width, &w.Height,
-			&w.ViewCount, &w.LikeCount, &w.CreatedAt,
-			&u.Username, &avatarURL,
-		); err != nil {
-			return nil, 0, err
-		}
-
-		if avatarURL.Valid {
-			u.AvatarURL = &avatarURL.String
-		}
-		u.ID = w.UserID
-		w.User = &u
-		wallpapers = append(wallpapers, &w)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, 0, err
-	}
-
-	return wallpapers, total, nil
+// ProcessingResult holds the derivatives generated by the processing worker
+type ProcessingResult struct {
+	ThumbnailURL     string
+	ThumbnailFormats []string
+	Blurhash         string
+	Palette          []PaletteColor
 }
 
-// ListByUser retrieves all wallpapers uploaded by a specific user
-func (r *Repository) ListByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*Wallpaper, int, error) {
-	// Get total count
-	var total int
-	countQuery := `
-		SELECT COUNT(*) 
-		FROM wallpapers 
-		WHERE user_id = $1 AND deleted_at IS NULL
-	`
-	err := r.db.QueryRowContext(ctx, countQuery, userID).Scan(&total)
+// CompleteProcessing stores generated derivatives and makes the wallpaper visible in listings
+func (r *Repository) CompleteProcessing(ctx context.Context, id uuid.UUID, result ProcessingResult) error {
+	palette, err := json.Marshal(result.Palette)
 	if err != nil {
-		return nil, 0, err
-	}
-
-	// Get paginated results
-	query := `
-		SELECT 
-			w.id, w.user_id, w.title, w.thumbnail_url, w.width, w.height,
-			w.view_count, w.like_count, w.created_at,
-			u.username, u.avatar_url
-		FROM wallpapers w
-		INNER JOIN users u ON w.user_id = u.id
-		WHERE w.user_id = $1 AND w.deleted_at IS NULL
-		ORDER BY w.created_at DESC
-		LIMIT $2 OFFSET $3
-	`
-
-	rows, err := r.db.QueryContext(ctx, query, userID, limit, offset)
-	if err != nil {
-		return nil, 0, err
-	}
-	defer rows.Close()
-
-	var wallpapers []*Wallpaper
-
-	for rows.Next() {
-		var w Wallpaper
-		var u User
-		var avatarURL sql.NullString
-
-		if err := rows.Scan(
-			&w.ID, &w.UserID, &w.Title, &w.ThumbnailURL, &w.Width, &w.Height,
-			&w.ViewCount, &w.LikeCount, &w.CreatedAt,
-			&u.Username, &avatarURL,
-		); err != nil {
-			return nil, 0, err
-		}
-
-		if avatarURL.Valid {
-			u.AvatarURL = &avatarURL.String
-		}
-		u.ID = w.UserID
-		w.User = &u
-		wallpapers = append(wallpapers, &w)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, 0, err
+		return err
 	}
-
-	return wallpapers, total, nil
-}
-
-// ListTrending retrieves trending wallpapers (by like count and recency)
-func (r *Repository) ListTrending(ctx context.Context, limit, offset int) ([]*Wallpaper, int, error) {
-	// Get total count (last 30 days)
-	var total int
-	countQuery := `
-		SELECT COUNT(*) 
-		FROM wallpapers 
-		WHERE deleted_at IS NULL
-		  AND created_at >= NOW() - INTERVAL '30 days'
-	`
-	err := r.db.QueryRowContext(ctx, countQuery).Scan(&total)
-	if err != nil {
-		return nil, 0, err
+	if result.Palette == nil {
+		palette = []byte("[]")
 	}
 
-	// Get paginated results sorted by engagement
 	query := `
-		SELECT 
-			w.id, w.user_id, w.title, w.thumbnail_url, w.width, w.height,
-			w.view_count, w.like_count, w.created_at,
-			u.username, u.avatar_url
-		FROM wallpapers w
-		INNER JOIN users u ON w.user_id = u.id
-		WHERE w.deleted_at IS NULL
-		  AND w.created_at >= NOW() - INTERVAL '30 days'
-		ORDER BY w.like_count DESC, w.created_at DESC
-		LIMIT $1 OFFSET $2
+		UPDATE wallpapers
+		SET thumbnail_url = $1,
+		    thumbnail_formats = $2,
+		    blurhash = $3,
+		    palette = $4,
+		    status = 'active',
+		    updated_at = NOW()
+		WHERE id = $5
 	`
+	_, err = r.db.ExecContext(ctx, query, result.ThumbnailURL, pq.Array(result.ThumbnailFormats), result.Blurhash, palette, id)
+	return err
+}
 
-	rows, err := r.db.QueryContext(ctx, query, limit, offset)
-	if err != nil {
-		return nil, 0, err
-	}
-	defer rows.Close()
-
-	var wallpapers []*Wallpaper
-
-	for rows.Next() {
-		var w Wallpaper
-		var u User
-		var avatarURL sql.NullString
-
-		if err := rows.Scan(
-			&w.ID, &w.UserID, &w.Title, &w.ThumbnailURL, &w.Width, &w.Height,
-			&w.ViewCount, &w.LikeCount, &w.CreatedAt,
-			&u.Username, &avatarURL,
-		); err != nil {
-			return nil, 0, err
-		}
-
-		if avatarURL.Valid {
-			u.AvatarURL = &avatarURL.String
-		}
-		u.ID = w.UserID
-		w.User = &u
-		wallpapers = append(wallpapers, &w)
-	}
-
-	if err := rows.Err(); err != nil {
-		return nil, 0, err
-	}
-
-	return wallpapers, total, nil
+// SetStatus updates the lifecycle status of a wallpaper (processing, active, failed)
+func (r *Repository) SetStatus(ctx context.Context, id uuid.UUID, status string) error {
+	query := `UPDATE wallpapers SET status = $1, updated_at = NOW() WHERE id = $2`
+	_, err := r.db.ExecContext(ctx, query, status, id)
+	return err
 }
@@ -10,14 +10,16 @@ import (
 )
 
 type CollectionService struct {
-	collectionRepo *collection.Repository
-	wallpaperRepo  *wallpaper.Repository
+	collectionRepo CollectionRepository
+	wallpaperRepo  WallpaperRepository
+	originals      *OriginalLinks
 }
 
-func NewCollectionService(collectionRepo *collection.Repository, wallpaperRepo *wallpaper.Repository) *CollectionService {
+func NewCollectionService(collectionRepo CollectionRepository, wallpaperRepo WallpaperRepository, originals *OriginalLinks) *CollectionService {
 	return &CollectionService{
 		collectionRepo: collectionRepo,
 		wallpaperRepo:  wallpaperRepo,
+		originals:      originals,
 	}
 }
 
@@ -153,24 +155,30 @@ func (s *CollectionService) RemoveWallpaperFromCollection(ctx context.Context, u
 	return s.collectionRepo.RemoveWallpaper(ctx, collectionID, wallpaperID)
 }
 
-// GetCollectionWallpapers retrieves wallpapers in a collection with privacy check
-func (s *CollectionService) GetCollectionWallpapers(ctx context.Context, collectionIDStr, requestUserIDStr string, page, limit int) ([]*wallpaper.Wallpaper, int, error) {
+// GetCollectionWallpapers retrieves wallpapers in a collection with privacy check, last added first.
+// Pages continue after cursor when one is given, page is only used without it.
+func (s *CollectionService) GetCollectionWallpapers(ctx context.Context, collectionIDStr, requestUserIDStr, cursor string, page, limit int) (*WallpaperPage, error) {
 	collectionID, err := uuid.Parse(collectionIDStr)
 	if err != nil {
-		return nil, 0, fmt.Errorf("invalid collection ID")
+		return nil, fmt.Errorf("invalid collection ID")
+	}
+
+	after, err := decodeCursor[collection.Cursor](cursor)
+	if err != nil {
+		return nil, err
 	}
 
 	// Verify collection access
 	c, err := s.collectionRepo.GetByID(ctx, collectionID)
 	if err != nil {
-		return nil, 0, fmt.Errorf("collection not found")
+		return nil, fmt.Errorf("collection not found")
 	}
 
 	// Check privacy
 	if !c.IsPublic {
 		requestUserID, err := uuid.Parse(requestUserIDStr)
 		if err != nil || requestUserID != c.UserID {
-			return nil, 0, fmt.Errorf("collection is private")
+			return nil, fmt.Errorf("collection is private")
 		}
 	}
 
@@ -183,22 +191,26 @@ func (s *CollectionService) GetCollectionWallpapers(ctx context.Context, collect
 	offset := (page - 1) * limit
 
 	// Get wallpaper IDs
-	wallpaperIDs, total, err := s.collectionRepo.GetCollectionWallpapers(ctx, collectionID, limit, offset)
+	wallpaperIDs, total, next, err := s.collectionRepo.GetCollectionWallpapers(ctx, collectionID, after, limit, offset)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 
+	result := &WallpaperPage{Wallpapers: []*wallpaper.Wallpaper{}, Total: total, NextCursor: encodeCursor(next)}
 	if len(wallpaperIDs) == 0 {
-		return []*wallpaper.Wallpaper{}, total, nil
+		return result, nil
 	}
 
 	// Bulk fetch wallpapers in a single query (avoids N+1 problem)
-	wallpapers, err := s.wallpaperRepo.GetByIDs(ctx, wallpaperIDs)
+	result.Wallpapers, err = s.wallpaperRepo.GetByIDs(ctx, wallpaperIDs)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
+	}
+	if err := s.originals.Sign(ctx, result.Wallpapers...); err != nil {
+		return nil, err
 	}
 
-	return wallpapers, total, nil
+	return result, nil
 }
 
 // DeleteCollection deletes a collection with ownership verification
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+type collectionFixture struct {
+	service     *CollectionService
+	collections *fakeCollectionRepo
+	wallpapers  *fakeWallpaperRepo
+}
+
+func newCollectionFixture() *collectionFixture {
+	f := &collectionFixture{
+		collections: newFakeCollectionRepo(),
+		wallpapers:  newFakeWallpaperRepo(),
+	}
+	f.service = NewCollectionService(f.collections, f.wallpapers, nil)
+	return f
+}
+
+func TestCreateCollection(t *testing.T) {
+	tests := []struct {
+		name        string
+		userID      string
+		colName     string
+		description string
+		wantErr     string
+	}{
+		{name: "valid", userID: uuid.New().String(), colName: "Space", description: "Nebulas"},
+		{name: "no description", userID: uuid.New().String(), colName: "Space"},
+		{name: "missing name", userID: uuid.New().String(), wantErr: "collection name is required"},
+		{name: "invalid user", userID: "nope", colName: "Space", wantErr: "invalid user ID"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newCollectionFixture()
+
+			c, err := f.service.CreateCollection(context.Background(), tt.userID, tt.colName, tt.description, true)
+			checkErr(t, "CreateCollection", err, tt.wantErr)
+			if err != nil {
+				return
+			}
+			if (c.Description == nil) != (tt.description == "") {
+				t.Errorf("description = %v, want %q", c.Description, tt.description)
+			}
+			if _, err := f.collections.GetByID(context.Background(), c.ID); err != nil {
+				t.Errorf("collection not stored: %v", err)
+			}
+		})
+	}
+}
+
+func TestCollectionPrivacy(t *testing.T) {
+	ownerID := uuid.New()
+
+	tests := []struct {
+		name        string
+		isPublic    bool
+		requestUser string
+		wantErr     string
+	}{
+		{name: "public, anonymous", isPublic: true, requestUser: ""},
+		{name: "public, other user", isPublic: true, requestUser: uuid.New().String()},
+		{name: "private, owner", isPublic: false, requestUser: ownerID.String()},
+		{name: "private, other user", isPublic: false, requestUser: uuid.New().String(), wantErr: "collection is private"},
+		{name: "private, anonymous", isPublic: false, requestUser: "", wantErr: "collection is private"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newCollectionFixture()
+			ctx := context.Background()
+			c := f.collections.add(ownerID, tt.isPublic)
+			wp := f.wallpapers.add(ownerID, "In a collection")
+			if err := f.collections.AddWallpaper(ctx, c.ID, wp.ID); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := f.service.GetCollectionDetails(ctx, c.ID.String(), tt.requestUser)
+			checkErr(t, "GetCollectionDetails", err, tt.wantErr)
+
+			result, err := f.service.GetCollectionWallpapers(ctx, c.ID.String(), tt.requestUser, "", 1, 20)
+			checkErr(t, "GetCollectionWallpapers", err, tt.wantErr)
+			if err == nil && (result.Total != 1 || len(result.Wallpapers) != 1) {
+				t.Errorf("got %d of %d wallpapers, want 1 of 1", len(result.Wallpapers), result.Total)
+			}
+		})
+	}
+
+	t.Run("missing collection", func(t *testing.T) {
+		f := newCollectionFixture()
+		_, err := f.service.GetCollectionDetails(context.Background(), uuid.New().String(), ownerID.String())
+		checkErr(t, "GetCollectionDetails", err, "collection not found")
+	})
+}
+
+func TestCollectionOwnership(t *testing.T) {
+	ownerID := uuid.New()
+
+	tests := []struct {
+		name          string
+		userID        string
+		missingWallID bool
+		wantErr       string
+	}{
+		{name: "owner", userID: ownerID.String()},
+		{name: "other user", userID: uuid.New().String(), wantErr: "you don't own this collection"},
+		{name: "invalid user", userID: "nope", wantErr: "invalid user ID"},
+		{name: "missing wallpaper", userID: ownerID.String(), missingWallID: true, wantErr: "wallpaper not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newCollectionFixture()
+			ctx := context.Background()
+			c := f.collections.add(ownerID, false)
+			wallpaperID := f.wallpapers.add(uuid.New(), "Someone's wallpaper").ID
+			if tt.missingWallID {
+				wallpaperID = uuid.New()
+			}
+
+			err := f.service.AddWallpaperToCollection(ctx, tt.userID, c.ID.String(), wallpaperID.String())
+			checkErr(t, "AddWallpaperToCollection", err, tt.wantErr)
+
+			added := len(f.collections.wallpapers[c.ID]) == 1
+			if added != (tt.wantErr == "") {
+				t.Fatalf("added = %v, want %v", added, tt.wantErr == "")
+			}
+
+			if tt.wantErr != "" && !tt.missingWallID {
+				// Others can neither remove from nor delete the collection either
+				f.collections.wallpapers[c.ID] = []uuid.UUID{wallpaperID}
+
+				err = f.service.RemoveWallpaperFromCollection(ctx, tt.userID, c.ID.String(), wallpaperID.String())
+				checkErr(t, "RemoveWallpaperFromCollection", err, tt.wantErr)
+				err = f.service.DeleteCollection(ctx, tt.userID, c.ID.String())
+				checkErr(t, "DeleteCollection", err, tt.wantErr)
+
+				if _, err := f.collections.GetByID(ctx, c.ID); err != nil || len(f.collections.wallpapers[c.ID]) != 1 {
+					t.Errorf("collection was modified by a non-owner")
+				}
+				return
+			}
+			if tt.wantErr != "" {
+				return
+			}
+
+			err = f.service.RemoveWallpaperFromCollection(ctx, tt.userID, c.ID.String(), wallpaperID.String())
+			checkErr(t, "RemoveWallpaperFromCollection", err, "")
+			err = f.service.DeleteCollection(ctx, tt.userID, c.ID.String())
+			checkErr(t, "DeleteCollection", err, "")
+			if _, err := f.collections.GetByID(ctx, c.ID); err == nil {
+				t.Error("collection still exists after delete")
+			}
+		})
+	}
+}
+
+func TestCollectionPagination(t *testing.T) {
+	f := newCollectionFixture()
+	ctx := context.Background()
+	ownerID := uuid.New()
+
+	c := f.collections.add(ownerID, true)
+	for i := 0; i < 23; i++ {
+		wp := f.wallpapers.add(ownerID, "Wallpaper")
+		if err := f.collections.AddWallpaper(ctx, c.ID, wp.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		f.collections.add(ownerID, i%2 == 0)
+	}
+
+	tests := []struct {
+		name      string
+		page      int
+		limit     int
+		wantCount int
+	}{
+		{name: "defaults", page: 0, limit: 0, wantCount: 20},
+		{name: "second page", page: 2, limit: 20, wantCount: 3},
+		{name: "custom limit", page: 2, limit: 5, wantCount: 5},
+		{name: "limit above max falls back to default", page: 1, limit: 51, wantCount: 20},
+		{name: "past the end", page: 4, limit: 10, wantCount: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := f.service.GetCollectionWallpapers(ctx, c.ID.String(), "", "", tt.page, tt.limit)
+			if err != nil {
+				t.Fatalf("GetCollectionWallpapers: %v", err)
+			}
+			if result.Total != 23 || len(result.Wallpapers) != tt.wantCount {
+				t.Errorf("got %d of %d, want %d of 23", len(result.Wallpapers), result.Total, tt.wantCount)
+			}
+		})
+	}
+
+	t.Run("cursor", func(t *testing.T) {
+		seen := map[uuid.UUID]bool{}
+		cursor, pages := "", 0
+		for {
+			result, err := f.service.GetCollectionWallpapers(ctx, c.ID.String(), "", cursor, 1, 10)
+			if err != nil {
+				t.Fatalf("GetCollectionWallpapers: %v", err)
+			}
+			for _, w := range result.Wallpapers {
+				if seen[w.ID] {
+					t.Fatalf("wallpaper %s listed twice", w.ID)
+				}
+				seen[w.ID] = true
+			}
+			pages++
+			if cursor = result.NextCursor; cursor == "" {
+				break
+			}
+		}
+		if len(seen) != 23 || pages != 3 {
+			t.Errorf("got %d wallpapers in %d pages, want 23 in 3", len(seen), pages)
+		}
+
+		if _, err := f.service.GetCollectionWallpapers(ctx, c.ID.String(), "", "not a cursor", 1, 10); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("err = %v, want ErrInvalidCursor", err)
+		}
+	})
+
+	t.Run("user collections", func(t *testing.T) {
+		collections, total, err := f.service.GetUserCollections(ctx, ownerID.String(), 2, 3)
+		if err != nil {
+			t.Fatalf("GetUserCollections: %v", err)
+		}
+		if total != 5 || len(collections) != 2 {
+			t.Errorf("got %d of %d, want 2 of 5", len(collections), total)
+		}
+	})
+}
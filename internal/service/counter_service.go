@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/wallpaper"
+)
+
+const (
+	counterView     = "view"
+	counterDownload = "download"
+)
+
+// CounterService counts wallpaper views and downloads. A viewer (user ID or IP) is counted once
+// per wallpaper per dedupe window, and counts are buffered in memory and written in batches by
+// FlushCounters so popular wallpapers don't turn into hot rows.
+type CounterService struct {
+	repo   WallpaperRepository
+	window time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	seen    map[string]time.Time // kind/wallpaper/viewer -> when it was first counted
+	pending map[uuid.UUID]*wallpaper.CounterDelta
+}
+
+func NewCounterService(repo WallpaperRepository, dedupeWindow time.Duration) *CounterService {
+	return &CounterService{
+		repo:    repo,
+		window:  dedupeWindow,
+		now:     time.Now,
+		seen:    make(map[string]time.Time),
+		pending: make(map[uuid.UUID]*wallpaper.CounterDelta),
+	}
+}
+
+// RecordView counts a view of a wallpaper unless viewer already viewed it within the window
+func (s *CounterService) RecordView(wallpaperID uuid.UUID, viewer string) {
+	s.record(counterView, wallpaperID, viewer)
+}
+
+// RecordDownload counts a download of a wallpaper unless viewer already downloaded it within the window
+func (s *CounterService) RecordDownload(wallpaperID uuid.UUID, viewer string) {
+	s.record(counterDownload, wallpaperID, viewer)
+}
+
+func (s *CounterService) record(kind string, wallpaperID uuid.UUID, viewer string) {
+	key := kind + "/" + wallpaperID.String() + "/" + viewer
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if first, ok := s.seen[key]; ok && now.Sub(first) < s.window {
+		return
+	}
+	s.seen[key] = now
+
+	delta, ok := s.pending[wallpaperID]
+	if !ok {
+		delta = &wallpaper.CounterDelta{WallpaperID: wallpaperID}
+		s.pending[wallpaperID] = delta
+	}
+	if kind == counterView {
+		delta.Views++
+	} else {
+		delta.Downloads++
+	}
+}
+
+// FlushCounters writes the buffered counts and forgets viewers whose window is over.
+// Counts that fail to write are kept for the next flush.
+func (s *CounterService) FlushCounters(ctx context.Context) error {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = make(map[uuid.UUID]*wallpaper.CounterDelta)
+
+	now := s.now()
+	for key, first := range s.seen {
+		if now.Sub(first) >= s.window {
+			delete(s.seen, key)
+		}
+	}
+	s.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	deltas := make([]wallpaper.CounterDelta, 0, len(pending))
+	for _, d := range pending {
+		deltas = append(deltas, *d)
+	}
+
+	if err := s.repo.IncrementCounters(ctx, deltas); err != nil {
+		s.mu.Lock()
+		for _, d := range deltas {
+			merged, ok := s.pending[d.WallpaperID]
+			if !ok {
+				merged = &wallpaper.CounterDelta{WallpaperID: d.WallpaperID}
+				s.pending[d.WallpaperID] = merged
+			}
+			merged.Views += d.Views
+			merged.Downloads += d.Downloads
+		}
+		s.mu.Unlock()
+		return fmt.Errorf("failed to flush counters for %d wallpapers: %w", len(deltas), err)
+	}
+	return nil
+}
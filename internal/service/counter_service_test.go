@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCounterService(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	repo := newFakeWallpaperRepo()
+	wp := repo.add(uuid.New(), "Counted")
+
+	counters := NewCounterService(repo, 30*time.Minute)
+	counters.now = func() time.Time { return now }
+
+	counters.RecordView(wp.ID, "ip:10.0.0.1")
+	counters.RecordView(wp.ID, "ip:10.0.0.1") // same viewer within the window
+	counters.RecordView(wp.ID, "ip:10.0.0.2")
+	counters.RecordDownload(wp.ID, "ip:10.0.0.1")
+	counters.RecordDownload(wp.ID, "ip:10.0.0.1")
+
+	if repo.wallpapers[wp.ID].ViewCount != 0 {
+		t.Fatalf("counts were written before a flush")
+	}
+
+	// A failed flush keeps the counts for the next one
+	repo.counterErr = errors.New("database is down")
+	if err := counters.FlushCounters(ctx); err == nil {
+		t.Fatalf("FlushCounters succeeded with a failing repository")
+	}
+	repo.counterErr = nil
+	if err := counters.FlushCounters(ctx); err != nil {
+		t.Fatalf("FlushCounters: %v", err)
+	}
+
+	if got := repo.wallpapers[wp.ID]; got.ViewCount != 2 || got.DownloadCount != 1 {
+		t.Errorf("views = %d, downloads = %d, want 2 and 1", got.ViewCount, got.DownloadCount)
+	}
+
+	// Once the window is over the same viewer counts again
+	now = now.Add(31 * time.Minute)
+	counters.RecordView(wp.ID, "ip:10.0.0.1")
+	if err := counters.FlushCounters(ctx); err != nil {
+		t.Fatalf("FlushCounters: %v", err)
+	}
+	if got := repo.wallpapers[wp.ID].ViewCount; got != 3 {
+		t.Errorf("views = %d after the window, want 3", got)
+	}
+}
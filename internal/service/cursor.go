@@ -0,0 +1,40 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursors are handed to clients as base64 encoded JSON of the repository's keyset position,
+// clients only pass them back as next_cursor to get the following page
+
+// encodeCursor returns the opaque form of a cursor, empty for nil (there is no next page)
+func encodeCursor[C any](c *C) string {
+	if c == nil {
+		return ""
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a cursor made by encodeCursor, nil when s is empty
+func decodeCursor[C any](s string) (*C, error) {
+	if s == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c C
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
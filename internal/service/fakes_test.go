@@ -0,0 +1,772 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/collection"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/job"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/like"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/wallpaper"
+)
+
+// In-memory fakes of the postgres repositories. They keep just enough state for
+// the service tests and return sql.ErrNoRows for missing rows like the real ones.
+
+type fakeWallpaperRepo struct {
+	mu         sync.Mutex
+	wallpapers map[uuid.UUID]*wallpaper.Wallpaper
+	order      []uuid.UUID // insertion order, newest last
+	deleted    map[uuid.UUID]time.Time
+	deletedBy  map[uuid.UUID]uuid.UUID
+	metadata   map[uuid.UUID]*wallpaper.Metadata
+	duplicates map[uuid.UUID][]wallpaper.SimilarWallpaper
+	renditions map[uuid.UUID][]wallpaper.Rendition
+	tagCounts  map[string]int // wallpaper_count by tag slug
+	counterErr error          // returned by IncrementCounters when set
+	flagErr    error          // returned by AddDuplicates when set
+}
+
+var _ WallpaperRepository = (*fakeWallpaperRepo)(nil)
+
+func newFakeWallpaperRepo() *fakeWallpaperRepo {
+	return &fakeWallpaperRepo{
+		wallpapers: make(map[uuid.UUID]*wallpaper.Wallpaper),
+		deleted:    make(map[uuid.UUID]time.Time),
+		deletedBy:  make(map[uuid.UUID]uuid.UUID),
+		metadata:   make(map[uuid.UUID]*wallpaper.Metadata),
+		duplicates: make(map[uuid.UUID][]wallpaper.SimilarWallpaper),
+		renditions: make(map[uuid.UUID][]wallpaper.Rendition),
+		tagCounts:  make(map[string]int),
+	}
+}
+
+// add stores an active wallpaper owned by userID and returns it
+func (r *fakeWallpaperRepo) add(userID uuid.UUID, title string) *wallpaper.Wallpaper {
+	w := &wallpaper.Wallpaper{ID: uuid.New(), UserID: userID, Title: title, Status: wallpaper.StatusActive}
+	if err := r.Create(context.Background(), w); err != nil {
+		panic(err)
+	}
+	return w
+}
+
+func (r *fakeWallpaperRepo) Create(ctx context.Context, w *wallpaper.Wallpaper) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	w.CreatedAt, w.UpdatedAt = now, now
+	copied := *w
+	r.wallpapers[w.ID] = &copied
+	r.order = append(r.order, w.ID)
+	return nil
+}
+
+func (r *fakeWallpaperRepo) GetByID(ctx context.Context, id uuid.UUID) (*wallpaper.Wallpaper, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w, ok := r.wallpapers[id]
+	if _, deleted := r.deleted[id]; !ok || deleted {
+		return nil, sql.ErrNoRows
+	}
+	copied := *w
+	return &copied, nil
+}
+
+func (r *fakeWallpaperRepo) GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*wallpaper.Wallpaper, error) {
+	var result []*wallpaper.Wallpaper
+	for _, id := range ids {
+		if w, err := r.GetByID(ctx, id); err == nil {
+			result = append(result, w)
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeWallpaperRepo) AddTags(ctx context.Context, wallpaperID uuid.UUID, tags []wallpaper.TagRef) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w, ok := r.wallpapers[wallpaperID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	// Like the real table the tag is keyed by slug and counted once per wallpaper
+	for _, t := range tags {
+		if slices.ContainsFunc(w.Tags, func(name string) bool { return generateTagSlug(name) == t.Slug }) {
+			continue
+		}
+		w.Tags = append(w.Tags, t.Name)
+		r.tagCounts[t.Slug]++
+	}
+	return nil
+}
+
+func (r *fakeWallpaperRepo) Update(ctx context.Context, id uuid.UUID, title, description *string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w, ok := r.wallpapers[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if title != nil {
+		w.Title = *title
+	}
+	if description != nil {
+		w.Description = description
+	}
+	return nil
+}
+
+func (r *fakeWallpaperRepo) SoftDelete(ctx context.Context, id, deletedBy uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.deleted[id]; !ok {
+		r.deleted[id] = time.Now()
+		r.deletedBy[id] = deletedBy
+	}
+	return nil
+}
+
+// GetDeletedAt returns deleted_at for each of ids that has a row, nil for wallpapers that are not deleted
+func (r *fakeWallpaperRepo) GetDeletedAt(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make(map[uuid.UUID]*time.Time)
+	for _, id := range ids {
+		if _, ok := r.wallpapers[id]; !ok {
+			continue
+		}
+		result[id] = nil
+		if deletedAt, ok := r.deleted[id]; ok {
+			result[id] = &deletedAt
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeWallpaperRepo) ListStoredKeys(ctx context.Context, prefixes []string) ([]*wallpaper.StoredKeys, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	underPrefix := func(key string) bool {
+		first, _, _ := strings.Cut(key, "/")
+		return key != "" && slices.Contains(prefixes, first)
+	}
+
+	var result []*wallpaper.StoredKeys
+	for id, w := range r.wallpapers {
+		k := &wallpaper.StoredKeys{WallpaperID: id, OriginalKey: w.OriginalKey, ThumbnailFormats: w.ThumbnailFormats}
+		if deletedAt, ok := r.deleted[id]; ok {
+			k.DeletedAt = &deletedAt
+		}
+		matched := slices.Contains(prefixes, id.String()) || underPrefix(w.OriginalKey)
+		for _, rd := range r.renditions[id] {
+			k.RenditionKeys = append(k.RenditionKeys, rd.StorageKey)
+			matched = matched || underPrefix(rd.StorageKey)
+		}
+		if matched {
+			result = append(result, k)
+		}
+	}
+	return result, nil
+}
+
+func (r *fakeWallpaperRepo) GetStoredKeys(ctx context.Context, id uuid.UUID) (*wallpaper.StoredKeys, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w, ok := r.wallpapers[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	k := &wallpaper.StoredKeys{WallpaperID: id, OriginalKey: w.OriginalKey, ThumbnailFormats: w.ThumbnailFormats}
+	if deletedAt, ok := r.deleted[id]; ok {
+		k.DeletedAt = &deletedAt
+	}
+	for _, rd := range r.renditions[id] {
+		k.RenditionKeys = append(k.RenditionKeys, rd.StorageKey)
+	}
+	return k, nil
+}
+
+func (r *fakeWallpaperRepo) GetDeleted(ctx context.Context, id uuid.UUID) (*wallpaper.Wallpaper, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deletedAt, ok := r.deleted[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := *r.wallpapers[id]
+	copied.DeletedAt = &deletedAt
+	if deletedBy, ok := r.deletedBy[id]; ok {
+		copied.DeletedBy = &deletedBy
+		copied.ModeratorRemoved = deletedBy != copied.UserID
+	}
+	return &copied, nil
+}
+
+func (r *fakeWallpaperRepo) ListDeletedByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*wallpaper.Wallpaper, int, error) {
+	r.mu.Lock()
+	var ids []uuid.UUID
+	for id := range r.deleted {
+		deletedBy, ok := r.deletedBy[id]
+		if r.wallpapers[id].UserID == userID && (!ok || deletedBy == userID) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return r.deleted[ids[i]].After(r.deleted[ids[j]]) })
+	r.mu.Unlock()
+
+	ids, total, _ := pageIDs(ids, limit, offset)
+	result := make([]*wallpaper.Wallpaper, 0, len(ids))
+	for _, id := range ids {
+		w, err := r.GetDeleted(ctx, id)
+		if err != nil {
+			return nil, 0, err
+		}
+		result = append(result, w)
+	}
+	return result, total, nil
+}
+
+func (r *fakeWallpaperRepo) Restore(ctx context.Context, id uuid.UUID, deletedSince time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deletedAt, ok := r.deleted[id]
+	if !ok || deletedAt.Before(deletedSince) {
+		return false, nil
+	}
+	delete(r.deleted, id)
+	delete(r.deletedBy, id)
+	return true, nil
+}
+
+func (r *fakeWallpaperRepo) ListPurgeable(ctx context.Context, cutoff time.Time, limit int) ([]uuid.UUID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var ids []uuid.UUID
+	for id, deletedAt := range r.deleted {
+		if deletedAt.Before(cutoff) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return r.deleted[ids[i]].Before(r.deleted[ids[j]]) })
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids, nil
+}
+
+func (r *fakeWallpaperRepo) Purge(ctx context.Context, id uuid.UUID, cutoff time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deletedAt, ok := r.deleted[id]
+	if !ok || !deletedAt.Before(cutoff) {
+		return false, nil
+	}
+	delete(r.wallpapers, id)
+	delete(r.deleted, id)
+	delete(r.metadata, id)
+	delete(r.duplicates, id)
+	delete(r.renditions, id)
+	for i, oid := range r.order {
+		if oid == id {
+			r.order = append(r.order[:i], r.order[i+1:]...)
+			break
+		}
+	}
+	return true, nil
+}
+
+func (r *fakeWallpaperRepo) IncrementCounters(ctx context.Context, deltas []wallpaper.CounterDelta) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.counterErr != nil {
+		return r.counterErr
+	}
+	for _, d := range deltas {
+		if w, ok := r.wallpapers[d.WallpaperID]; ok {
+			w.ViewCount += d.Views
+			w.DownloadCount += d.Downloads
+		}
+	}
+	return nil
+}
+
+func (r *fakeWallpaperRepo) SetFeaturedStatus(ctx context.Context, id uuid.UUID, isFeatured bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w, ok := r.wallpapers[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	w.IsFeatured = isFeatured
+	return nil
+}
+
+func (r *fakeWallpaperRepo) SetStatus(ctx context.Context, id uuid.UUID, status string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w, ok := r.wallpapers[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	w.Status = status
+	return nil
+}
+
+func (r *fakeWallpaperRepo) CompleteProcessing(ctx context.Context, id uuid.UUID, result wallpaper.ProcessingResult) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w, ok := r.wallpapers[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	w.ThumbnailURL = result.ThumbnailURL
+	w.ThumbnailFormats = result.ThumbnailFormats
+	w.Blurhash = &result.Blurhash
+	w.Palette = result.Palette
+	w.Status = wallpaper.StatusActive
+	return nil
+}
+
+// page returns the active wallpapers matching keep, newest first, like the listing queries
+func (r *fakeWallpaperRepo) page(keep func(*wallpaper.Wallpaper) bool, limit, offset int) ([]*wallpaper.Wallpaper, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var matched []*wallpaper.Wallpaper
+	for i := len(r.order) - 1; i >= 0; i-- {
+		w := r.wallpapers[r.order[i]]
+		if _, deleted := r.deleted[w.ID]; w.Status == wallpaper.StatusActive && !deleted && (keep == nil || keep(w)) {
+			copied := *w
+			matched = append(matched, &copied)
+		}
+	}
+
+	total := len(matched)
+	if offset >= total {
+		return []*wallpaper.Wallpaper{}, total, nil
+	}
+	return matched[offset:min(offset+limit, total)], total, nil
+}
+
+// List supports the filters the service tests use: search (title substring), device type,
+// minimum size, screen, orientation, tags, user and featured. Relevance is newest first.
+// Cursors are only keyed on the wallpaper ID.
+func (r *fakeWallpaperRepo) List(ctx context.Context, f wallpaper.Filter, o wallpaper.Order, after *wallpaper.Cursor, limit, offset int) ([]*wallpaper.Wallpaper, int, *wallpaper.Cursor, error) {
+	matched, total, _ := r.page(func(w *wallpaper.Wallpaper) bool { return matchesFilter(w, f) }, math.MaxInt32, 0)
+
+	// Stable sorts of the newest first list by a key, highest first
+	sortBy := func(key func(w *wallpaper.Wallpaper) float64) {
+		sort.SliceStable(matched, func(i, j int) bool { return key(matched[i]) > key(matched[j]) })
+	}
+	switch o.Sort {
+	case wallpaper.SortOldest:
+		slices.Reverse(matched)
+	case wallpaper.SortMostLiked:
+		sortBy(func(w *wallpaper.Wallpaper) float64 { return float64(w.LikeCount) })
+	case wallpaper.SortMostDownloaded:
+		sortBy(func(w *wallpaper.Wallpaper) float64 { return float64(w.DownloadCount) })
+	case wallpaper.SortMostViewed:
+		sortBy(func(w *wallpaper.Wallpaper) float64 { return float64(w.ViewCount) })
+	case wallpaper.SortRandom:
+		sortBy(func(w *wallpaper.Wallpaper) float64 {
+			h := fnv.New64a()
+			fmt.Fprintf(h, "%s/%d", w.ID, o.Seed)
+			return float64(h.Sum64())
+		})
+	case wallpaper.SortFit:
+		screen := float64(f.ScreenWidth) / float64(f.ScreenHeight)
+		sortBy(func(w *wallpaper.Wallpaper) float64 {
+			aspect := float64(w.Width) / float64(w.Height)
+			return min(aspect, screen) / max(aspect, screen)
+		})
+	}
+
+	// Keyset pages continue after the cursor's wallpaper and aren't counted
+	if after != nil {
+		total = 0
+		offset = len(matched)
+		for i, w := range matched {
+			if w.ID == after.ID {
+				offset = i + 1
+				break
+			}
+		}
+	}
+	if offset >= len(matched) {
+		return []*wallpaper.Wallpaper{}, total, nil, nil
+	}
+
+	end := min(offset+limit, len(matched))
+	var next *wallpaper.Cursor
+	if end < len(matched) {
+		last := matched[end-1]
+		next = &wallpaper.Cursor{Sort: o.Sort, CreatedAt: last.CreatedAt, ID: last.ID, Seed: o.Seed, Since: f.CreatedAfter}
+	}
+	return matched[offset:end], total, next, nil
+}
+
+func (r *fakeWallpaperRepo) Facets(ctx context.Context, f wallpaper.Filter) (*wallpaper.Facets, error) {
+	matched, _, _ := r.page(func(w *wallpaper.Wallpaper) bool { return matchesFilter(w, f) }, math.MaxInt32, 0)
+
+	facets := &wallpaper.Facets{DeviceType: map[string]int{}, Orientation: map[string]int{}}
+	for _, w := range matched {
+		facets.DeviceType[w.DeviceType]++
+		facets.Orientation[orientation(w)]++
+	}
+	return facets, nil
+}
+
+func matchesFilter(w *wallpaper.Wallpaper, f wallpaper.Filter) bool {
+	if f.Search != "" && !strings.Contains(strings.ToLower(w.Title), strings.ToLower(f.Search)) {
+		return false
+	}
+	if (f.DeviceType != "" && w.DeviceType != f.DeviceType) || w.Width < f.MinWidth || w.Height < f.MinHeight {
+		return false
+	}
+	if w.Width < f.ScreenWidth || w.Height < f.ScreenHeight {
+		return false
+	}
+	if f.Orientation != "" && orientation(w) != f.Orientation {
+		return false
+	}
+	if (f.UserID != nil && w.UserID != *f.UserID) || (f.Featured && !w.IsFeatured) {
+		return false
+	}
+	if (f.CreatedAfter != nil && w.CreatedAt.Before(*f.CreatedAfter)) || (f.CreatedBefore != nil && !w.CreatedAt.Before(*f.CreatedBefore)) {
+		return false
+	}
+
+	matchedTags := 0
+	for _, t := range f.Tags {
+		if slices.Contains(w.Tags, t) {
+			matchedTags++
+		}
+	}
+	if len(f.Tags) > 0 && (matchedTags == 0 || (f.MatchAllTags && matchedTags < len(f.Tags))) {
+		return false
+	}
+	return true
+}
+
+func orientation(w *wallpaper.Wallpaper) string {
+	switch {
+	case w.Width > w.Height:
+		return wallpaper.OrientationLandscape
+	case w.Width < w.Height:
+		return wallpaper.OrientationPortrait
+	}
+	return wallpaper.OrientationSquare
+}
+
+func (r *fakeWallpaperRepo) FindSimilar(ctx context.Context, hash int64, maxDistance, limit int) ([]wallpaper.SimilarWallpaper, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var similar []wallpaper.SimilarWallpaper
+	for _, id := range r.order {
+		w := r.wallpapers[id]
+		if _, deleted := r.deleted[id]; w.PHash == nil || deleted {
+			continue
+		}
+		if d := bits.OnesCount64(uint64(*w.PHash ^ hash)); d <= maxDistance {
+			similar = append(similar, wallpaper.SimilarWallpaper{ID: w.ID, Distance: d})
+		}
+	}
+	sort.SliceStable(similar, func(i, j int) bool { return similar[i].Distance < similar[j].Distance })
+	if len(similar) > limit {
+		similar = similar[:limit]
+	}
+	return similar, nil
+}
+
+func (r *fakeWallpaperRepo) AddDuplicates(ctx context.Context, wallpaperID uuid.UUID, matches []wallpaper.SimilarWallpaper) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.flagErr != nil {
+		return r.flagErr
+	}
+	r.duplicates[wallpaperID] = append(r.duplicates[wallpaperID], matches...)
+	return nil
+}
+
+func (r *fakeWallpaperRepo) ListDuplicateClusters(ctx context.Context, limit, offset int) ([]*wallpaper.DuplicateCluster, int, error) {
+	return []*wallpaper.DuplicateCluster{}, 0, nil
+}
+
+func (r *fakeWallpaperRepo) SaveMetadata(ctx context.Context, wallpaperID uuid.UUID, m *wallpaper.Metadata) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.metadata[wallpaperID] = m
+	return nil
+}
+
+func (r *fakeWallpaperRepo) GetMetadata(ctx context.Context, wallpaperID uuid.UUID) (*wallpaper.Metadata, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.metadata[wallpaperID], nil
+}
+
+func (r *fakeWallpaperRepo) AddRenditions(ctx context.Context, wallpaperID uuid.UUID, renditions []wallpaper.Rendition) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.renditions[wallpaperID] = renditions
+	return nil
+}
+
+type fakeJobRepo struct {
+	mu   sync.Mutex
+	jobs []*job.Job
+	err  error // returned by Enqueue when set
+}
+
+var _ JobRepository = (*fakeJobRepo)(nil)
+
+func (r *fakeJobRepo) Enqueue(ctx context.Context, wallpaperID uuid.UUID, maxAttempts int) (*job.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.err != nil {
+		return nil, r.err
+	}
+	j := &job.Job{ID: uuid.New(), WallpaperID: wallpaperID, Status: job.StatusPending, MaxAttempts: maxAttempts, RunAt: time.Now()}
+	r.jobs = append(r.jobs, j)
+	return j, nil
+}
+
+func (r *fakeJobRepo) GetLatestByWallpaper(ctx context.Context, wallpaperID uuid.UUID) (*job.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := len(r.jobs) - 1; i >= 0; i-- {
+		if r.jobs[i].WallpaperID == wallpaperID {
+			return r.jobs[i], nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+type fakeLikeRepo struct {
+	mu    sync.Mutex
+	likes map[uuid.UUID][]uuid.UUID // user -> liked wallpapers, oldest first
+}
+
+var _ LikeRepository = (*fakeLikeRepo)(nil)
+
+func newFakeLikeRepo() *fakeLikeRepo {
+	return &fakeLikeRepo{likes: make(map[uuid.UUID][]uuid.UUID)}
+}
+
+func (r *fakeLikeRepo) ToggleLikeWithTx(ctx context.Context, userID, wallpaperID uuid.UUID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, id := range r.likes[userID] {
+		if id == wallpaperID {
+			r.likes[userID] = append(r.likes[userID][:i], r.likes[userID][i+1:]...)
+			return false, nil
+		}
+	}
+	r.likes[userID] = append(r.likes[userID], wallpaperID)
+	return true, nil
+}
+
+func (r *fakeLikeRepo) IsLiked(ctx context.Context, userID, wallpaperID uuid.UUID) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, id := range r.likes[userID] {
+		if id == wallpaperID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *fakeLikeRepo) GetUserLikes(ctx context.Context, userID uuid.UUID, after *like.Cursor, limit, offset int) ([]uuid.UUID, int, *like.Cursor, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var afterID *uuid.UUID
+	if after != nil {
+		afterID = &after.WallpaperID
+	}
+	ids, total, last := keysetIDs(r.likes[userID], afterID, limit, offset)
+	if last == nil {
+		return ids, total, nil, nil
+	}
+	return ids, total, &like.Cursor{WallpaperID: *last}, nil
+}
+
+type fakeCollectionRepo struct {
+	mu          sync.Mutex
+	collections map[uuid.UUID]*collection.Collection
+	wallpapers  map[uuid.UUID][]uuid.UUID // collection -> wallpapers, newest first
+}
+
+var _ CollectionRepository = (*fakeCollectionRepo)(nil)
+
+func newFakeCollectionRepo() *fakeCollectionRepo {
+	return &fakeCollectionRepo{
+		collections: make(map[uuid.UUID]*collection.Collection),
+		wallpapers:  make(map[uuid.UUID][]uuid.UUID),
+	}
+}
+
+// add stores a collection owned by userID and returns it
+func (r *fakeCollectionRepo) add(userID uuid.UUID, isPublic bool) *collection.Collection {
+	c := &collection.Collection{UserID: userID, Name: "Favourites", IsPublic: isPublic}
+	if err := r.Create(context.Background(), c); err != nil {
+		panic(err)
+	}
+	return c
+}
+
+func (r *fakeCollectionRepo) Create(ctx context.Context, c *collection.Collection) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c.ID = uuid.New()
+	c.CreatedAt, c.UpdatedAt = time.Now(), time.Now()
+	copied := *c
+	r.collections[c.ID] = &copied
+	return nil
+}
+
+func (r *fakeCollectionRepo) GetByID(ctx context.Context, id uuid.UUID) (*collection.Collection, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.collections[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := *c
+	copied.WallpaperCount = len(r.wallpapers[id])
+	return &copied, nil
+}
+
+func (r *fakeCollectionRepo) GetUserCollections(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*collection.Collection, int, error) {
+	r.mu.Lock()
+	var ids []uuid.UUID
+	for id, c := range r.collections {
+		if c.UserID == userID {
+			ids = append(ids, id)
+		}
+	}
+	r.mu.Unlock()
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+	ids, total, _ := pageIDs(ids, limit, offset)
+
+	collections := make([]*collection.Collection, 0, len(ids))
+	for _, id := range ids {
+		c, _ := r.GetByID(ctx, id)
+		collections = append(collections, c)
+	}
+	return collections, total, nil
+}
+
+func (r *fakeCollectionRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.collections, id)
+	delete(r.wallpapers, id)
+	return nil
+}
+
+func (r *fakeCollectionRepo) AddWallpaper(ctx context.Context, collectionID, wallpaperID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.wallpapers[collectionID] = append([]uuid.UUID{wallpaperID}, r.wallpapers[collectionID]...)
+	return nil
+}
+
+func (r *fakeCollectionRepo) RemoveWallpaper(ctx context.Context, collectionID, wallpaperID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := r.wallpapers[collectionID]
+	for i, id := range ids {
+		if id == wallpaperID {
+			r.wallpapers[collectionID] = append(ids[:i], ids[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (r *fakeCollectionRepo) GetCollectionWallpapers(ctx context.Context, collectionID uuid.UUID, after *collection.Cursor, limit, offset int) ([]uuid.UUID, int, *collection.Cursor, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var afterID *uuid.UUID
+	if after != nil {
+		afterID = &after.WallpaperID
+	}
+	ids, total, last := keysetIDs(r.wallpapers[collectionID], afterID, limit, offset)
+	if last == nil {
+		return ids, total, nil, nil
+	}
+	return ids, total, &collection.Cursor{WallpaperID: *last}, nil
+}
+
+// keysetIDs pages ids after the given one, or at offset without it, and returns the last ID
+// of the page when there is a next one. Keyset pages aren't counted.
+func keysetIDs(ids []uuid.UUID, after *uuid.UUID, limit, offset int) ([]uuid.UUID, int, *uuid.UUID) {
+	total := len(ids)
+	if after != nil {
+		total = 0
+		offset = len(ids)
+		if i := slices.Index(ids, *after); i >= 0 {
+			offset = i + 1
+		}
+	}
+
+	page, _, _ := pageIDs(ids, limit, offset)
+	if offset+len(page) < len(ids) && len(page) > 0 {
+		return page, total, &page[len(page)-1]
+	}
+	return page, total, nil
+}
+
+func pageIDs(ids []uuid.UUID, limit, offset int) ([]uuid.UUID, int, error) {
+	total := len(ids)
+	if offset >= total {
+		return []uuid.UUID{}, total, nil
+	}
+	page := make([]uuid.UUID, min(offset+limit, total)-offset)
+	copy(page, ids[offset:])
+	return page, total, nil
+}
@@ -0,0 +1,182 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pavelc4/pixtify/internal/processor"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/wallpaper"
+	"github.com/pavelc4/pixtify/internal/storage"
+)
+
+var (
+	ErrInvalidImageSignature = errors.New("invalid image signature")
+	ErrInvalidImageParams    = errors.New("invalid image parameters")
+	ErrImageBusy             = errors.New("too many images being resized, try again later")
+)
+
+// How long a request waits for a free resize slot
+const imageSlotTimeout = 30 * time.Second
+
+// RenderedImage is an encoded on-demand transform of a wallpaper
+type RenderedImage struct {
+	Data        []byte
+	ContentType string
+}
+
+// ImageService resizes, crops and converts wallpapers on demand for /img/:id/:params.
+// The params must be signed with the image secret (see Sign) so renders are only reached through
+// URLs handed out by SignURL, which is rate limited, or a frontend sharing the secret.
+// Results are cached in the thumbnails bucket under {id}/img/.
+type ImageService struct {
+	repo         WallpaperRepository
+	storage      storage.Service
+	processor    *processor.ImageProcessor
+	bucketOrigin string
+	bucketThumb  string
+	secret       []byte
+	slots        chan struct{}
+}
+
+func NewImageService(repo WallpaperRepository, storage storage.Service, processor *processor.ImageProcessor, bucketOrigin, bucketThumb, secret string, maxConcurrent int) *ImageService {
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	return &ImageService{
+		repo:         repo,
+		storage:      storage,
+		processor:    processor,
+		bucketOrigin: bucketOrigin,
+		bucketThumb:  bucketThumb,
+		secret:       []byte(secret),
+		slots:        make(chan struct{}, maxConcurrent),
+	}
+}
+
+// renderCachePrefix is where the renders of a wallpaper are cached in the thumbnails bucket
+func renderCachePrefix(id uuid.UUID) string {
+	return id.String() + "/img/"
+}
+
+// Sign returns the signature of params for a wallpaper, base64url of HMAC-SHA256(secret, "{id}/{params}")
+func (s *ImageService) Sign(id uuid.UUID, params string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "%s/%s", id, params)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SignedPath returns the signed /img path of a transform
+func (s *ImageService) SignedPath(id uuid.UUID, opts processor.TransformOptions) string {
+	params := opts.String()
+	return fmt.Sprintf("/img/%s/%s?sig=%s", id, params, s.Sign(id, params))
+}
+
+// SignURL validates transform params of an active wallpaper and returns the signed /img path rendering them
+func (s *ImageService) SignURL(ctx context.Context, idStr, params string) (string, error) {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return "", ErrWallpaperNotFound
+	}
+
+	opts, err := processor.ParseTransformOptions(params)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidImageParams, err)
+	}
+
+	wp, err := s.repo.GetByID(ctx, id)
+	if err != nil || wp.Status != wallpaper.StatusActive || wp.OriginalKey == "" {
+		return "", ErrWallpaperNotFound
+	}
+	return s.SignedPath(wp.ID, opts), nil
+}
+
+// Render returns the transformed image, from the cache when it was rendered before
+func (s *ImageService) Render(ctx context.Context, idStr, params, signature string) (*RenderedImage, error) {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, ErrWallpaperNotFound
+	}
+	if !hmac.Equal([]byte(s.Sign(id, params)), []byte(signature)) {
+		return nil, ErrInvalidImageSignature
+	}
+
+	opts, err := processor.ParseTransformOptions(params)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImageParams, err)
+	}
+
+	wp, err := s.repo.GetByID(ctx, id)
+	if err != nil || wp.Status != wallpaper.StatusActive || wp.OriginalKey == "" {
+		return nil, ErrWallpaperNotFound
+	}
+
+	// Equal options share a cache entry however the params were written,
+	// cover crops also depend on the focal point
+	variant := opts.String()
+	if opts.Fit == processor.FitCover && wp.FocalPoint != nil {
+		variant += fmt.Sprintf(",focal=%.3fx%.3f", wp.FocalPoint.X, wp.FocalPoint.Y)
+	}
+	cacheKey := renderCachePrefix(wp.ID) + variant + opts.Format.Extension()
+	image := &RenderedImage{ContentType: opts.Format.ContentType()}
+
+	if image.Data, err = s.readObject(ctx, s.bucketThumb, cacheKey); err == nil {
+		return image, nil
+	}
+
+	release, err := s.acquireSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	original, err := s.readObject(ctx, s.bucketOrigin, wp.OriginalKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download original: %w", err)
+	}
+
+	if image.Data, err = s.processor.Transform(original, opts, toFocalPoint(wp.FocalPoint)); err != nil {
+		return nil, fmt.Errorf("failed to transform image: %w", err)
+	}
+
+	// A failed cache write only costs a re-render next time
+	if _, err := s.storage.Upload(ctx, s.bucketThumb, cacheKey, bytes.NewReader(image.Data), int64(len(image.Data)), image.ContentType); err != nil {
+		log.Printf("Failed to cache image %s: %v", cacheKey, err)
+	}
+	return image, nil
+}
+
+func (s *ImageService) readObject(ctx context.Context, bucket, key string) ([]byte, error) {
+	if _, err := s.storage.Stat(ctx, bucket, key); err != nil {
+		return nil, err
+	}
+	obj, err := s.storage.Download(ctx, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	return io.ReadAll(obj)
+}
+
+// acquireSlot bounds how many images are decoded at once, the returned func releases the slot
+func (s *ImageService) acquireSlot(ctx context.Context) (func(), error) {
+	timer := time.NewTimer(imageSlotTimeout)
+	defer timer.Stop()
+
+	select {
+	case s.slots <- struct{}{}:
+		return func() { <-s.slots }, nil
+	case <-timer.C:
+		return nil, ErrImageBusy
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	_ "image/png"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/pavelc4/pixtify/internal/config"
+	"github.com/pavelc4/pixtify/internal/processor"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/wallpaper"
+	"github.com/pavelc4/pixtify/internal/storage"
+)
+
+func TestRenderImage(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeWallpaperRepo()
+	store := storage.NewMemoryStorage()
+
+	wp := repo.add(uuid.New(), "Desktop")
+	repo.wallpapers[wp.ID].OriginalKey = wp.ID.String() + "/desktop.jpg"
+	original := testJPEG(t, 1600, 900, 7)
+	if _, err := store.Upload(ctx, testBucketOrigin, wp.ID.String()+"/desktop.jpg", bytes.NewReader(original), int64(len(original)), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+
+	images := NewImageService(repo, store, processor.NewImageProcessor(config.ImageConfig{}),
+		testBucketOrigin, testBucketThumb, "image-secret", 1)
+
+	tests := []struct {
+		name       string
+		id         uuid.UUID
+		params     string
+		sig        string // empty to sign params
+		wantErr    error
+		wantWidth  int
+		wantHeight int
+	}{
+		{name: "contain", id: wp.ID, params: "w=800", wantWidth: 800, wantHeight: 450},
+		{name: "cover", id: wp.ID, params: "w=400,h=800,fit=cover,fmt=png", wantWidth: 400, wantHeight: 800},
+		{name: "cover larger than original", id: wp.ID, params: "w=1800,h=3600,fit=cover", wantWidth: 450, wantHeight: 900},
+		{name: "no upscaling", id: wp.ID, params: "w=3200,h=1800", wantWidth: 1600, wantHeight: 900},
+		{name: "bad signature", id: wp.ID, params: "w=800", sig: "forged", wantErr: ErrInvalidImageSignature},
+		{name: "bad params", id: wp.ID, params: "w=99999", wantErr: ErrInvalidImageParams},
+		{name: "cover needs both sides", id: wp.ID, params: "w=800,fit=cover", wantErr: ErrInvalidImageParams},
+		{name: "missing wallpaper", id: uuid.New(), params: "w=800", wantErr: ErrWallpaperNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sig := tt.sig
+			if sig == "" {
+				sig = images.Sign(tt.id, tt.params)
+			}
+
+			img, err := images.Render(ctx, tt.id.String(), tt.params, sig)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Render error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(img.Data))
+			if err != nil {
+				t.Fatalf("decode rendered image: %v", err)
+			}
+			if cfg.Width != tt.wantWidth || cfg.Height != tt.wantHeight {
+				t.Errorf("size = %dx%d, want %dx%d", cfg.Width, cfg.Height, tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+
+	t.Run("served from cache", func(t *testing.T) {
+		cached := len(store.Keys(testBucketThumb))
+		if cached != 4 {
+			t.Fatalf("%d cached renders, want 4", cached)
+		}
+
+		// Same options written differently hit the same cache entry, without the original
+		if err := store.Delete(ctx, testBucketOrigin, wp.ID.String()+"/desktop.jpg"); err != nil {
+			t.Fatal(err)
+		}
+		params := "fmt=jpeg,w=800"
+		if _, err := images.Render(ctx, wp.ID.String(), params, images.Sign(wp.ID, params)); err != nil {
+			t.Fatalf("Render from cache: %v", err)
+		}
+	})
+}
+
+func TestSignImageURL(t *testing.T) {
+	ctx := context.Background()
+	repo := newFakeWallpaperRepo()
+	store := storage.NewMemoryStorage()
+	images := NewImageService(repo, store, processor.NewImageProcessor(config.ImageConfig{}),
+		testBucketOrigin, testBucketThumb, "image-secret", 1)
+
+	active := repo.add(uuid.New(), "Active")
+	repo.wallpapers[active.ID].OriginalKey = active.ID.String() + "/active.jpg"
+	original := testJPEG(t, 1600, 900, 7)
+	if _, err := store.Upload(ctx, testBucketOrigin, active.ID.String()+"/active.jpg", bytes.NewReader(original), int64(len(original)), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	processing := repo.add(uuid.New(), "Processing")
+	repo.wallpapers[processing.ID].Status = wallpaper.StatusProcessing
+
+	tests := []struct {
+		name    string
+		id      string
+		params  string
+		wantErr error
+	}{
+		{name: "valid", id: active.ID.String(), params: "w=800,fmt=png"},
+		{name: "invalid params", id: active.ID.String(), params: "w=800,fit=stretch", wantErr: ErrInvalidImageParams},
+		{name: "no size", id: active.ID.String(), params: "", wantErr: ErrInvalidImageParams},
+		{name: "processing", id: processing.ID.String(), params: "w=800", wantErr: ErrWallpaperNotFound},
+		{name: "bad id", id: "nope", params: "w=800", wantErr: ErrWallpaperNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := images.SignURL(ctx, tt.id, tt.params)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SignURL error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			// The signed path renders as is
+			u, err := url.Parse(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			parts := strings.Split(strings.TrimPrefix(u.Path, "/img/"), "/")
+			if len(parts) != 2 || parts[0] != tt.id {
+				t.Fatalf("path = %q, want /img/%s/...", path, tt.id)
+			}
+			img, err := images.Render(ctx, parts[0], parts[1], u.Query().Get("sig"))
+			if err != nil {
+				t.Fatalf("Render(%s): %v", path, err)
+			}
+			if img.ContentType != "image/png" {
+				t.Errorf("content type = %q, want image/png", img.ContentType)
+			}
+		})
+	}
+}
@@ -10,14 +10,16 @@ import (
 )
 
 type LikeService struct {
-	likeRepo      *like.Repository
-	wallpaperRepo *wallpaper.Repository
+	likeRepo      LikeRepository
+	wallpaperRepo WallpaperRepository
+	originals     *OriginalLinks
 }
 
-func NewLikeService(likeRepo *like.Repository, wallpaperRepo *wallpaper.Repository) *LikeService {
+func NewLikeService(likeRepo LikeRepository, wallpaperRepo WallpaperRepository, originals *OriginalLinks) *LikeService {
 	return &LikeService{
 		likeRepo:      likeRepo,
 		wallpaperRepo: wallpaperRepo,
+		originals:     originals,
 	}
 }
 
@@ -58,11 +60,17 @@ func (s *LikeService) CheckLikeStatus(ctx context.Context, userIDStr, wallpaperI
 	return s.likeRepo.IsLiked(ctx, userID, wallpaperID)
 }
 
-// GetUserLikedWallpapers returns wallpapers liked by user
-func (s *LikeService) GetUserLikedWallpapers(ctx context.Context, userIDStr string, page, limit int) ([]*wallpaper.Wallpaper, int, error) {
+// GetUserLikedWallpapers returns wallpapers liked by user, most recent like first.
+// Pages continue after cursor when one is given, page is only used without it.
+func (s *LikeService) GetUserLikedWallpapers(ctx context.Context, userIDStr, cursor string, page, limit int) (*WallpaperPage, error) {
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		return nil, 0, fmt.Errorf("invalid user ID")
+		return nil, fmt.Errorf("invalid user ID")
+	}
+
+	after, err := decodeCursor[like.Cursor](cursor)
+	if err != nil {
+		return nil, err
 	}
 
 	if page < 1 {
@@ -74,20 +82,24 @@ func (s *LikeService) GetUserLikedWallpapers(ctx context.Context, userIDStr stri
 	offset := (page - 1) * limit
 
 	// Get wallpaper IDs
-	wallpaperIDs, total, err := s.likeRepo.GetUserLikes(ctx, userID, limit, offset)
+	wallpaperIDs, total, next, err := s.likeRepo.GetUserLikes(ctx, userID, after, limit, offset)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 
+	result := &WallpaperPage{Wallpapers: []*wallpaper.Wallpaper{}, Total: total, NextCursor: encodeCursor(next)}
 	if len(wallpaperIDs) == 0 {
-		return []*wallpaper.Wallpaper{}, total, nil
+		return result, nil
 	}
 
 	// Bulk fetch wallpapers in a single query (avoids N+1 problem)
-	wallpapers, err := s.wallpaperRepo.GetByIDs(ctx, wallpaperIDs)
+	result.Wallpapers, err = s.wallpaperRepo.GetByIDs(ctx, wallpaperIDs)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
+	}
+	if err := s.originals.Sign(ctx, result.Wallpapers...); err != nil {
+		return nil, err
 	}
 
-	return wallpapers, total, nil
+	return result, nil
 }
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestToggleLike(t *testing.T) {
+	tests := []struct {
+		name        string
+		userID      string
+		wallpaperID func(existing uuid.UUID) string
+		wantErr     string
+	}{
+		{name: "existing wallpaper", userID: uuid.New().String(), wallpaperID: uuid.UUID.String},
+		{name: "missing wallpaper", userID: uuid.New().String(),
+			wallpaperID: func(uuid.UUID) string { return uuid.New().String() }, wantErr: "wallpaper not found"},
+		{name: "invalid wallpaper", userID: uuid.New().String(),
+			wallpaperID: func(uuid.UUID) string { return "nope" }, wantErr: "invalid wallpaper ID"},
+		{name: "invalid user", userID: "nope", wallpaperID: uuid.UUID.String, wantErr: "invalid user ID"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wallpapers := newFakeWallpaperRepo()
+			likes := newFakeLikeRepo()
+			s := NewLikeService(likes, wallpapers, nil)
+			ctx := context.Background()
+			wallpaperID := tt.wallpaperID(wallpapers.add(uuid.New(), "Likeable").ID)
+
+			liked, err := s.ToggleLike(ctx, tt.userID, wallpaperID)
+			checkErr(t, "ToggleLike", err, tt.wantErr)
+			if err != nil {
+				return
+			}
+			if !liked {
+				t.Fatal("first toggle should like")
+			}
+
+			if status, _ := s.CheckLikeStatus(ctx, tt.userID, wallpaperID); !status {
+				t.Error("CheckLikeStatus = false after liking")
+			}
+
+			liked, err = s.ToggleLike(ctx, tt.userID, wallpaperID)
+			if err != nil || liked {
+				t.Errorf("second toggle = %v, %v, want unliked", liked, err)
+			}
+		})
+	}
+}
+
+func TestLikedWallpapersPagination(t *testing.T) {
+	wallpapers := newFakeWallpaperRepo()
+	likes := newFakeLikeRepo()
+	s := NewLikeService(likes, wallpapers, nil)
+	ctx := context.Background()
+
+	userID := uuid.New().String()
+	for i := 0; i < 24; i++ {
+		wp := wallpapers.add(uuid.New(), "Liked")
+		if _, err := s.ToggleLike(ctx, userID, wp.ID.String()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		userID    string
+		page      int
+		limit     int
+		wantCount int
+		wantTotal int
+	}{
+		{name: "defaults", userID: userID, page: -1, limit: -1, wantCount: 20, wantTotal: 24},
+		{name: "second page", userID: userID, page: 2, limit: 20, wantCount: 4, wantTotal: 24},
+		{name: "custom limit", userID: userID, page: 3, limit: 7, wantCount: 7, wantTotal: 24},
+		{name: "limit above max falls back to default", userID: userID, page: 1, limit: 100, wantCount: 20, wantTotal: 24},
+		{name: "no likes", userID: uuid.New().String(), page: 1, limit: 20, wantCount: 0, wantTotal: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := s.GetUserLikedWallpapers(ctx, tt.userID, "", tt.page, tt.limit)
+			if err != nil {
+				t.Fatalf("GetUserLikedWallpapers: %v", err)
+			}
+			if result.Wallpapers == nil || len(result.Wallpapers) != tt.wantCount || result.Total != tt.wantTotal {
+				t.Errorf("got %d of %d, want %d of %d", len(result.Wallpapers), result.Total, tt.wantCount, tt.wantTotal)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pavelc4/pixtify/internal/repository/postgres/wallpaper"
+)
+
+var (
+	ErrInvalidFilter = errors.New("invalid filter")
+	ErrInvalidSort   = errors.New("invalid sort")
+)
+
+// Tags a listing can be filtered by at once
+const maxFilterTags = 10
+
+// Limits of the screen filter, a screen's physical pixels and its device pixel ratio
+const (
+	maxScreenSize = 16384
+	minScreenDPR  = 0.5
+	maxScreenDPR  = 5
+)
+
+// ListQuery holds the raw filter parameters of list and search requests, see buildFilter for the formats
+type ListQuery struct {
+	Color       string
+	DeviceType  string
+	MinWidth    string
+	MinHeight   string
+	Resolution  string   // exact "WIDTHxHEIGHT"
+	Screen      string   // "WIDTHxHEIGHT" in device independent pixels, wallpapers have to cover it
+	DPR         string   // device pixel ratio of Screen, 1 by default
+	Aspects     []string // any of wallpaper.AspectRatios, e.g. "16:9"
+	Orientation string
+	MimeTypes   []string // "image/png" or just "png"
+	Tags        []string
+	TagMode     string // "any" (default) or "all"
+	Uploader    string
+	From        string // date or RFC 3339 time, inclusive
+	To          string // date (inclusive) or RFC 3339 time (exclusive)
+}
+
+// SortQuery holds the raw sort parameters of a listing
+type SortQuery struct {
+	Sort string // one of the wallpaper.Sort constants, empty for the listing's default
+	Seed string // shuffles a random sort, one is picked when empty
+}
+
+// WallpaperPage is one page of a listing. Total is only counted for pages requested by number and
+// facets for the first page only, they don't change while paging through the same filter.
+// NextCursor is empty on the last page.
+type WallpaperPage struct {
+	Wallpapers []*wallpaper.Wallpaper
+	Total      int
+	Facets     *wallpaper.Facets
+	NextCursor string
+	Sort       string
+	Seed       *int64 // random sorts only, pass it again to get the same order on other pages
+}
+
+// buildFilter validates list parameters into a repository filter, errors wrap ErrInvalidFilter or ErrInvalidColor
+func buildFilter(q ListQuery) (wallpaper.Filter, error) {
+	var f wallpaper.Filter
+	var err error
+
+	if f.Color, err = colorFilter(q.Color); err != nil {
+		return f, err
+	}
+
+	switch q.DeviceType {
+	case "", "mobile", "desktop":
+		f.DeviceType = q.DeviceType
+	default:
+		return f, fmt.Errorf("%w: device_type must be mobile or desktop", ErrInvalidFilter)
+	}
+
+	if f.MinWidth, err = parseFilterInt("min_width", q.MinWidth); err != nil {
+		return f, err
+	}
+	if f.MinHeight, err = parseFilterInt("min_height", q.MinHeight); err != nil {
+		return f, err
+	}
+
+	if q.Resolution != "" {
+		if f.Width, f.Height, err = parseResolution("resolution", q.Resolution); err != nil {
+			return f, err
+		}
+	}
+
+	if q.Screen != "" {
+		if f.ScreenWidth, f.ScreenHeight, err = parseScreen(q.Screen, q.DPR); err != nil {
+			return f, err
+		}
+	} else if q.DPR != "" {
+		return f, fmt.Errorf("%w: dpr needs a screen", ErrInvalidFilter)
+	}
+
+	for _, aspect := range q.Aspects {
+		aspect = strings.TrimSpace(aspect)
+		known := slices.ContainsFunc(wallpaper.AspectRatios, func(a wallpaper.AspectRatio) bool { return a.Name == aspect })
+		if !known && aspect != wallpaper.AspectOther {
+			return f, fmt.Errorf("%w: unknown aspect ratio %q", ErrInvalidFilter, aspect)
+		}
+		f.AspectRatios = append(f.AspectRatios, aspect)
+	}
+
+	switch q.Orientation {
+	case "", wallpaper.OrientationLandscape, wallpaper.OrientationPortrait, wallpaper.OrientationSquare:
+		f.Orientation = q.Orientation
+	default:
+		return f, fmt.Errorf("%w: orientation must be landscape, portrait or square", ErrInvalidFilter)
+	}
+
+	for _, mimeType := range q.MimeTypes {
+		mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+		if !strings.Contains(mimeType, "/") {
+			mimeType = "image/" + mimeType
+		}
+		if mimeType == "image/jpg" {
+			mimeType = "image/jpeg"
+		}
+		if mimeType != "image/jpeg" && mimeType != "image/png" && mimeType != "image/webp" {
+			return f, fmt.Errorf("%w: mime_type must be jpeg, png or webp", ErrInvalidFilter)
+		}
+		f.MimeTypes = append(f.MimeTypes, mimeType)
+	}
+
+	for _, t := range q.Tags {
+		if slug := generateTagSlug(t); slug != "" {
+			f.Tags = append(f.Tags, slug)
+		}
+	}
+	if len(f.Tags) > maxFilterTags {
+		return f, fmt.Errorf("%w: at most %d tags", ErrInvalidFilter, maxFilterTags)
+	}
+	switch q.TagMode {
+	case "", "any":
+	case "all":
+		f.MatchAllTags = true
+	default:
+		return f, fmt.Errorf("%w: tag_mode must be any or all", ErrInvalidFilter)
+	}
+
+	f.Uploader = strings.TrimSpace(q.Uploader)
+
+	if q.From != "" {
+		from, _, err := parseFilterTime(q.From)
+		if err != nil {
+			return f, fmt.Errorf("%w: from: %v", ErrInvalidFilter, err)
+		}
+		f.CreatedAfter = &from
+	}
+	if q.To != "" {
+		to, dateOnly, err := parseFilterTime(q.To)
+		if err != nil {
+			return f, fmt.Errorf("%w: to: %v", ErrInvalidFilter, err)
+		}
+		// A date includes the whole day
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		}
+		f.CreatedBefore = &to
+	}
+	if f.CreatedAfter != nil && f.CreatedBefore != nil && !f.CreatedAfter.Before(*f.CreatedBefore) {
+		return f, fmt.Errorf("%w: from must be before to", ErrInvalidFilter)
+	}
+
+	return f, nil
+}
+
+// buildOrder validates sort parameters into a repository order, def is the listing's default sort.
+// Relevance needs a search and fit a screen filter. Errors wrap ErrInvalidSort.
+func buildOrder(q SortQuery, def string, f wallpaper.Filter) (wallpaper.Order, error) {
+	o := wallpaper.Order{Sort: strings.ToLower(strings.TrimSpace(q.Sort))}
+	if o.Sort == "" {
+		o.Sort = def
+	}
+
+	switch o.Sort {
+	case wallpaper.SortNewest, wallpaper.SortOldest, wallpaper.SortMostLiked,
+		wallpaper.SortMostDownloaded, wallpaper.SortMostViewed, wallpaper.SortRandom:
+	case wallpaper.SortRelevance:
+		if f.Search == "" {
+			return o, fmt.Errorf("%w: relevance only applies to searches", ErrInvalidSort)
+		}
+	case wallpaper.SortFit:
+		if f.ScreenWidth == 0 {
+			return o, fmt.Errorf("%w: fit needs a screen", ErrInvalidSort)
+		}
+	default:
+		return o, fmt.Errorf("%w: sort must be newest, oldest, most_liked, most_downloaded, most_viewed or random", ErrInvalidSort)
+	}
+
+	switch {
+	case o.Sort != wallpaper.SortRandom && q.Seed != "":
+		return o, fmt.Errorf("%w: seed only applies to random", ErrInvalidSort)
+	case o.Sort == wallpaper.SortRandom && q.Seed == "":
+		o.Seed = rand.Int64N(math.MaxInt32)
+	case o.Sort == wallpaper.SortRandom:
+		seed, err := strconv.ParseInt(q.Seed, 10, 64)
+		if err != nil || seed < 0 {
+			return o, fmt.Errorf("%w: seed must be a positive number", ErrInvalidSort)
+		}
+		o.Seed = seed
+	}
+
+	return o, nil
+}
+
+func parseFilterInt(name, value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%w: %s must be a positive number", ErrInvalidFilter, name)
+	}
+	return n, nil
+}
+
+// parseResolution parses WIDTHxHEIGHT
+func parseResolution(name, value string) (width, height int, err error) {
+	w, h, ok := strings.Cut(strings.ToLower(value), "x")
+	width, err = strconv.Atoi(w)
+	if err == nil {
+		height, err = strconv.Atoi(h)
+	}
+	if !ok || err != nil || width < 1 || height < 1 {
+		return 0, 0, fmt.Errorf("%w: %s must be WIDTHxHEIGHT", ErrInvalidFilter, name)
+	}
+	return width, height, nil
+}
+
+// parseScreen returns the physical pixels of a screen given in device independent pixels
+func parseScreen(screen, dpr string) (width, height int, err error) {
+	if width, height, err = parseResolution("screen", screen); err != nil {
+		return 0, 0, err
+	}
+
+	ratio := 1.0
+	if dpr != "" {
+		ratio, err = strconv.ParseFloat(dpr, 64)
+		if err != nil || ratio < minScreenDPR || ratio > maxScreenDPR {
+			return 0, 0, fmt.Errorf("%w: dpr must be a number between %g and %g", ErrInvalidFilter, minScreenDPR, float64(maxScreenDPR))
+		}
+	}
+
+	width = int(math.Round(float64(width) * ratio))
+	height = int(math.Round(float64(height) * ratio))
+	if width > maxScreenSize || height > maxScreenSize {
+		return 0, 0, fmt.Errorf("%w: screen must be at most %dpx wide and high", ErrInvalidFilter, maxScreenSize)
+	}
+	return width, height, nil
+}
+
+// parseFilterTime accepts a date (2006-01-02, reported as dateOnly) or an RFC 3339 time
+func parseFilterTime(value string) (t time.Time, dateOnly bool, err error) {
+	if t, err = time.Parse(time.DateOnly, value); err == nil {
+		return t, true, nil
+	}
+	if t, err = time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	return t, false, fmt.Errorf("expected YYYY-MM-DD or an RFC 3339 time")
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pavelc4/pixtify/internal/repository/postgres/wallpaper"
+	"github.com/pavelc4/pixtify/internal/storage"
+)
+
+// OriginalLinks replaces the stored original_url and image_url of wallpapers with expiring
+// signed URLs when the originals bucket is private. Thumbnails and renditions stay public.
+// A nil *OriginalLinks leaves the stored URLs as they are.
+type OriginalLinks struct {
+	signer storage.URLSigner
+	bucket string
+	expiry time.Duration
+}
+
+func NewOriginalLinks(signer storage.URLSigner, bucket string, expiry time.Duration) *OriginalLinks {
+	if expiry <= 0 {
+		expiry = time.Hour
+	}
+	return &OriginalLinks{signer: signer, bucket: bucket, expiry: expiry}
+}
+
+// Sign sets fresh signed URLs on wallpapers, which are generated per request and never stored
+func (l *OriginalLinks) Sign(ctx context.Context, wallpapers ...*wallpaper.Wallpaper) error {
+	if l == nil {
+		return nil
+	}
+
+	for _, w := range wallpapers {
+		if w.OriginalKey == "" {
+			// Not processed yet, there is nothing to link to
+			w.OriginalURL, w.ImageURL = "", ""
+			continue
+		}
+		url, err := l.signer.SignURL(ctx, l.bucket, w.OriginalKey, l.expiry)
+		if err != nil {
+			return fmt.Errorf("failed to sign original URL: %w", err)
+		}
+		w.OriginalURL, w.ImageURL = url, url
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/wallpaper"
+	"github.com/pavelc4/pixtify/internal/storage"
+)
+
+func TestOriginalLinks(t *testing.T) {
+	ctx := context.Background()
+	const publicURL = "http://cdn.example.com/original.jpg"
+	key := uuid.New().String() + "/original.jpg"
+
+	cdn, err := storage.NewCDNSigner("https://originals.example.com/", "cdn-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		links      *OriginalLinks
+		key        string
+		wantPrefix string
+	}{
+		{name: "public", links: nil, key: key, wantPrefix: publicURL},
+		{name: "presigned", links: NewOriginalLinks(storage.NewPresignSigner(storage.NewMemoryStorage()), testBucketOrigin, time.Hour),
+			key: key, wantPrefix: "memory://" + testBucketOrigin + "/" + key + "?method=GET&expires=3600"},
+		{name: "cdn", links: NewOriginalLinks(cdn, testBucketOrigin, time.Hour), key: key, wantPrefix: "https://originals.example.com/" + key + "?"},
+		{name: "not processed", links: NewOriginalLinks(cdn, testBucketOrigin, time.Hour), wantPrefix: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &wallpaper.Wallpaper{OriginalKey: tt.key, OriginalURL: publicURL, ImageURL: publicURL}
+			if err := tt.links.Sign(ctx, w); err != nil {
+				t.Fatalf("Sign: %v", err)
+			}
+			if !strings.HasPrefix(w.OriginalURL, tt.wantPrefix) || (tt.wantPrefix == "" && w.OriginalURL != "") {
+				t.Errorf("original_url = %q, want prefix %q", w.OriginalURL, tt.wantPrefix)
+			}
+			if w.ImageURL != w.OriginalURL {
+				t.Errorf("image_url = %q, want the same URL as original_url", w.ImageURL)
+			}
+		})
+	}
+
+	t.Run("cdn signature verifies", func(t *testing.T) {
+		signed, _ := cdn.SignURL(ctx, testBucketOrigin, key, time.Minute)
+		u, err := url.Parse(signed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := u.Query()
+		if !cdn.Verify(key, q.Get("expires"), q.Get("signature")) {
+			t.Errorf("signature of %s does not verify", signed)
+		}
+		if cdn.Verify("other/key.jpg", q.Get("expires"), q.Get("signature")) {
+			t.Errorf("signature verifies for another key")
+		}
+	})
+}
@@ -0,0 +1,322 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pavelc4/pixtify/internal/processor"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/upload"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/wallpaper"
+	"github.com/pavelc4/pixtify/internal/storage"
+)
+
+// Why an object was judged orphaned
+const (
+	OrphanNoWallpaper      = "no_wallpaper"      // no wallpaper row refers to the key
+	OrphanWallpaperDeleted = "wallpaper_deleted" // soft-deleted longer ago than the retention
+	OrphanUploadFinished   = "upload_finished"   // resumable upload chunk whose upload is gone or done
+	OrphanStaleDirect      = "stale_direct"      // direct upload that was never finalized
+	OrphanUnrecognized     = "unrecognized"      // key not written by the API, reported but never deleted
+)
+
+const (
+	orphanBatchSize    = 500
+	maxReportedOrphans = 1000
+)
+
+// OrphanGCOptions configures the orphaned object collector (see config.GCConfig).
+// Objects younger than MinAge are never touched, they may belong to an upload in flight.
+// Objects of soft-deleted wallpapers are kept for DeletedRetention so they can still be restored.
+type OrphanGCOptions struct {
+	MinAge           time.Duration
+	DeletedRetention time.Duration
+}
+
+// Orphan is an object no wallpaper or pending upload refers to
+type Orphan struct {
+	Bucket       string    `json:"bucket"`
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+	Reason       string    `json:"reason"`
+	Deleted      bool      `json:"deleted"`
+}
+
+// BucketReport counts what a collection run found in one bucket
+type BucketReport struct {
+	Bucket       string `json:"bucket"`
+	Scanned      int    `json:"scanned"`
+	TooNew       int    `json:"too_new"`
+	Orphaned     int    `json:"orphaned"`
+	OrphanBytes  int64  `json:"orphan_bytes"`
+	Deleted      int    `json:"deleted"`
+	Failed       int    `json:"failed"`
+	Unrecognized int    `json:"unrecognized"`
+}
+
+// OrphanReport summarizes a collection run. Orphans lists at most the first 1000 found.
+type OrphanReport struct {
+	DryRun     bool            `json:"dry_run"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+	Buckets    []*BucketReport `json:"buckets"`
+	Orphans    []Orphan        `json:"orphans"`
+	Truncated  bool            `json:"truncated"`
+}
+
+// Summary is a one line description of the run for logs
+func (r *OrphanReport) Summary() string {
+	var parts []string
+	for _, b := range r.Buckets {
+		parts = append(parts, fmt.Sprintf("%s: %d scanned, %d orphaned (%d bytes), %d deleted, %d failed, %d unrecognized",
+			b.Bucket, b.Scanned, b.Orphaned, b.OrphanBytes, b.Deleted, b.Failed, b.Unrecognized))
+	}
+	return fmt.Sprintf("dry run: %t, %s", r.DryRun, strings.Join(parts, "; "))
+}
+
+// OrphanGCService finds objects left behind by failed uploads and deleted wallpapers and removes them.
+// Wallpaper objects live under a UUID prefix and are kept while a row refers to them: its original_key,
+// the thumbnails next to it, a rendition's storage_key or the /img render cache under {wallpaperID}/img/
+// (see renderCachePrefix). The prefix isn't necessarily the wallpaper ID,
+// originals uploaded before the API assigned IDs are under another one. Other keys are matched against
+// uploads/{uploadID}/... for resumable upload chunks and incoming/... for direct uploads.
+type OrphanGCService struct {
+	wallpaperRepo WallpaperRepository
+	uploadRepo    UploadRepository
+	storage       storage.Service
+	buckets       []string
+	opts          OrphanGCOptions
+	now           func() time.Time
+}
+
+func NewOrphanGCService(wallpaperRepo WallpaperRepository, uploadRepo UploadRepository, storage storage.Service, buckets []string, opts OrphanGCOptions) *OrphanGCService {
+	if opts.MinAge <= 0 {
+		opts.MinAge = 24 * time.Hour
+	}
+	if opts.DeletedRetention < 0 {
+		opts.DeletedRetention = 0
+	}
+	return &OrphanGCService{
+		wallpaperRepo: wallpaperRepo,
+		uploadRepo:    uploadRepo,
+		storage:       storage,
+		buckets:       buckets,
+		opts:          opts,
+		now:           time.Now,
+	}
+}
+
+// CollectOrphans scans every bucket and deletes orphaned objects, or only reports them when dryRun is set
+func (s *OrphanGCService) CollectOrphans(ctx context.Context, dryRun bool) (*OrphanReport, error) {
+	report := &OrphanReport{DryRun: dryRun, StartedAt: s.now(), Orphans: []Orphan{}}
+
+	for _, bucket := range s.buckets {
+		run := &orphanRun{
+			service: s,
+			report:  report,
+			bucket:  &BucketReport{Bucket: bucket},
+			dryRun:  dryRun,
+			uploads: make(map[uuid.UUID]bool),
+		}
+		report.Buckets = append(report.Buckets, run.bucket)
+
+		err := s.storage.List(ctx, bucket, "", func(obj storage.ObjectInfo) error {
+			run.bucket.Scanned++
+			if s.now().Sub(obj.LastModified) < s.opts.MinAge {
+				run.bucket.TooNew++
+				return nil
+			}
+			run.pending = append(run.pending, obj)
+			if len(run.pending) >= orphanBatchSize {
+				return run.flush(ctx)
+			}
+			return nil
+		})
+		if err == nil {
+			err = run.flush(ctx)
+		}
+		if err != nil {
+			report.FinishedAt = s.now()
+			return report, fmt.Errorf("failed to collect orphans in %s: %w", bucket, err)
+		}
+	}
+
+	report.FinishedAt = s.now()
+	return report, nil
+}
+
+// orphanRun holds the state of one bucket scan, objects are checked in batches
+type orphanRun struct {
+	service *OrphanGCService
+	report  *OrphanReport
+	bucket  *BucketReport
+	dryRun  bool
+	pending []storage.ObjectInfo
+	uploads map[uuid.UUID]bool // resumable upload ID -> still receiving chunks
+}
+
+func (r *orphanRun) flush(ctx context.Context) error {
+	if len(r.pending) == 0 {
+		return nil
+	}
+	batch := r.pending
+	r.pending = nil
+
+	// One query for all wallpapers with objects under the prefixes in the batch
+	var prefixes []string
+	seen := make(map[string]bool)
+	for _, obj := range batch {
+		if prefix, ok := wallpaperKeyPrefix(obj.Key); ok && !seen[prefix] {
+			seen[prefix] = true
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	stored, err := r.service.wallpaperRepo.ListStoredKeys(ctx, prefixes)
+	if err != nil {
+		return fmt.Errorf("failed to look up wallpapers: %w", err)
+	}
+	owners := keyOwners(stored)
+
+	for _, obj := range batch {
+		reason, err := r.classify(ctx, obj, owners)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			r.collect(ctx, obj, reason)
+		}
+	}
+	return nil
+}
+
+// classify returns why obj is orphaned, or "" when it is still in use.
+// owners maps the keys of the batch's wallpapers to their deleted_at, nil while not deleted.
+func (r *orphanRun) classify(ctx context.Context, obj storage.ObjectInfo, owners map[string]*time.Time) (string, error) {
+	if prefix, ok := wallpaperKeyPrefix(obj.Key); ok {
+		key := obj.Key
+		if strings.HasPrefix(key, prefix+"/img/") {
+			// Cached renders belong to the wallpaper of the prefix as a whole
+			key = prefix + "/img/"
+		}
+		at, referenced := owners[key]
+		switch {
+		case !referenced:
+			return OrphanNoWallpaper, nil
+		case at != nil && r.service.now().Sub(*at) > r.service.opts.DeletedRetention:
+			return OrphanWallpaperDeleted, nil
+		}
+		return "", nil
+	}
+
+	first, rest, _ := strings.Cut(obj.Key, "/")
+	switch first {
+	case "uploads":
+		uploadID, err := uuid.Parse(strings.SplitN(rest, "/", 2)[0])
+		if err != nil {
+			return OrphanUnrecognized, nil
+		}
+		active, err := r.uploadActive(ctx, uploadID)
+		if err != nil {
+			return "", err
+		}
+		if !active {
+			return OrphanUploadFinished, nil
+		}
+		return "", nil
+	case "incoming":
+		// Presigned URLs expire long before MinAge, anything this old was abandoned
+		return OrphanStaleDirect, nil
+	}
+	return OrphanUnrecognized, nil
+}
+
+// uploadActive reports whether a resumable upload can still use its chunks
+func (r *orphanRun) uploadActive(ctx context.Context, id uuid.UUID) (bool, error) {
+	if active, ok := r.uploads[id]; ok {
+		return active, nil
+	}
+
+	u, err := r.service.uploadRepo.GetByID(ctx, id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("failed to look up upload: %w", err)
+	}
+	active := u != nil && (u.Status == upload.StatusPending || u.Status == upload.StatusFinalizing)
+	r.uploads[id] = active
+	return active, nil
+}
+
+// collect records an orphan and deletes it unless this is a dry run or the key is unrecognized
+func (r *orphanRun) collect(ctx context.Context, obj storage.ObjectInfo, reason string) {
+	orphan := Orphan{Bucket: r.bucket.Bucket, Key: obj.Key, Size: obj.Size, LastModified: obj.LastModified, Reason: reason}
+
+	if reason == OrphanUnrecognized {
+		r.bucket.Unrecognized++
+	} else {
+		r.bucket.Orphaned++
+		r.bucket.OrphanBytes += obj.Size
+
+		if !r.dryRun {
+			if err := r.service.storage.Delete(ctx, r.bucket.Bucket, obj.Key); err != nil {
+				log.Printf("Orphan GC: failed to delete %s/%s: %v", r.bucket.Bucket, obj.Key, err)
+				r.bucket.Failed++
+			} else {
+				orphan.Deleted = true
+				r.bucket.Deleted++
+			}
+		}
+	}
+
+	if len(r.report.Orphans) < maxReportedOrphans {
+		r.report.Orphans = append(r.report.Orphans, orphan)
+	} else {
+		r.report.Truncated = true
+	}
+}
+
+// wallpaperKeyPrefix returns the UUID prefix of keys laid out as {uuid}/..., the layout of wallpaper objects
+func wallpaperKeyPrefix(key string) (string, bool) {
+	first, _, found := strings.Cut(key, "/")
+	if !found {
+		return "", false
+	}
+	_, err := uuid.Parse(first)
+	return first, err == nil
+}
+
+// keyOwners maps every key the wallpapers refer to, and the prefix of their render cache, to the
+// deleted_at of its wallpaper. A key shared by several wallpapers is only as deleted as the least deleted of them.
+func keyOwners(stored []*wallpaper.StoredKeys) map[string]*time.Time {
+	owners := make(map[string]*time.Time)
+	for _, k := range stored {
+		for _, key := range append(storedObjectKeys(k), renderCachePrefix(k.WallpaperID)) {
+			at, seen := owners[key]
+			switch {
+			case !seen:
+				owners[key] = k.DeletedAt
+			case at != nil && (k.DeletedAt == nil || k.DeletedAt.After(*at)):
+				owners[key] = k.DeletedAt
+			}
+		}
+	}
+	return owners
+}
+
+// storedObjectKeys lists the original, thumbnail and rendition keys of a wallpaper
+func storedObjectKeys(k *wallpaper.StoredKeys) []string {
+	var keys []string
+	if k.OriginalKey != "" {
+		keys = append(keys, k.OriginalKey)
+		for _, name := range k.ThumbnailFormats {
+			if format, err := processor.ParseOutputFormat(name); err == nil {
+				keys = append(keys, thumbnailKey(k.OriginalKey, format))
+			}
+		}
+	}
+	return append(keys, k.RenditionKeys...)
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/upload"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/wallpaper"
+	"github.com/pavelc4/pixtify/internal/storage"
+)
+
+type fakeUploadRepo struct {
+	UploadRepository // only GetByID is used by the collector
+	uploads          map[uuid.UUID]*upload.Upload
+}
+
+func (r *fakeUploadRepo) GetByID(ctx context.Context, id uuid.UUID) (*upload.Upload, error) {
+	if u, ok := r.uploads[id]; ok {
+		return u, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func TestCollectOrphans(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	later := now.Add(48 * time.Hour) // every object is past the 24h minimum age
+
+	wallpapers := newFakeWallpaperRepo()
+	live := wallpapers.add(uuid.New(), "Live")
+	legacy := wallpapers.add(uuid.New(), "Legacy")
+	recentlyDeleted := wallpapers.add(uuid.New(), "Recently deleted")
+	longDeleted := wallpapers.add(uuid.New(), "Long deleted")
+	_ = wallpapers.SoftDelete(ctx, recentlyDeleted.ID, recentlyDeleted.UserID)
+	wallpapers.deleted[longDeleted.ID] = now.Add(-60 * 24 * time.Hour)
+
+	// Legacy originals were stored under a different UUID than the one the row got
+	legacyPrefix := uuid.New()
+	stored := func(w *wallpaper.Wallpaper, originalKey string, renditionKeys ...string) {
+		wallpapers.wallpapers[w.ID].OriginalKey = originalKey
+		wallpapers.wallpapers[w.ID].ThumbnailFormats = []string{"jpeg"}
+		for _, key := range renditionKeys {
+			wallpapers.renditions[w.ID] = append(wallpapers.renditions[w.ID], wallpaper.Rendition{StorageKey: key})
+		}
+	}
+	stored(live, fmt.Sprintf("%s/live.jpg", live.ID), fmt.Sprintf("%s/renditions/1080p.jpg", live.ID))
+	stored(legacy, fmt.Sprintf("%s/legacy.jpg", legacyPrefix), fmt.Sprintf("%s/renditions/720p.jpg", legacy.ID))
+	stored(recentlyDeleted, fmt.Sprintf("%s/recent.jpg", recentlyDeleted.ID))
+	stored(longDeleted, fmt.Sprintf("%s/old.jpg", longDeleted.ID))
+
+	pending, completed := uuid.New(), uuid.New()
+	uploads := &fakeUploadRepo{uploads: map[uuid.UUID]*upload.Upload{
+		pending:   {ID: pending, Status: upload.StatusPending},
+		completed: {ID: completed, Status: upload.StatusCompleted},
+	}}
+
+	tests := []struct {
+		bucket string
+		key    string
+		reason string // empty when the object is in use
+	}{
+		{bucket: testBucketOrigin, key: fmt.Sprintf("%s/live.jpg", live.ID)},
+		{bucket: testBucketThumb, key: fmt.Sprintf("%s/live_thumb.jpg", live.ID)},
+		{bucket: testBucketThumb, key: fmt.Sprintf("%s/renditions/1080p.jpg", live.ID)},
+		{bucket: testBucketThumb, key: fmt.Sprintf("%s/live_thumb.webp", live.ID), reason: OrphanNoWallpaper},
+		{bucket: testBucketThumb, key: fmt.Sprintf("%s/img/w800-h600-fcover.webp", live.ID)},
+		{bucket: testBucketThumb, key: fmt.Sprintf("%s/img/w400-fcover-cx0.50-cy0.50.jpg", longDeleted.ID), reason: OrphanWallpaperDeleted},
+		{bucket: testBucketThumb, key: fmt.Sprintf("%s/img/w800.jpg", uuid.New()), reason: OrphanNoWallpaper},
+		{bucket: testBucketOrigin, key: fmt.Sprintf("%s/legacy.jpg", legacyPrefix)},
+		{bucket: testBucketThumb, key: fmt.Sprintf("%s/legacy_thumb.jpg", legacyPrefix)},
+		{bucket: testBucketThumb, key: fmt.Sprintf("%s/renditions/720p.jpg", legacy.ID)},
+		{bucket: testBucketOrigin, key: fmt.Sprintf("%s/recent.jpg", recentlyDeleted.ID)},
+		{bucket: testBucketOrigin, key: fmt.Sprintf("%s/old.jpg", longDeleted.ID), reason: OrphanWallpaperDeleted},
+		{bucket: testBucketThumb, key: fmt.Sprintf("%s/old_thumb.jpg", longDeleted.ID), reason: OrphanWallpaperDeleted},
+		{bucket: testBucketOrigin, key: fmt.Sprintf("%s/failed-upload.jpg", uuid.New()), reason: OrphanNoWallpaper},
+		{bucket: testBucketOrigin, key: fmt.Sprintf("uploads/%s/000000000000-abcd1234", pending)},
+		{bucket: testBucketOrigin, key: fmt.Sprintf("uploads/%s/000000000000-abcd1234", completed), reason: OrphanUploadFinished},
+		{bucket: testBucketOrigin, key: fmt.Sprintf("uploads/%s/000000000000-abcd1234", uuid.New()), reason: OrphanUploadFinished},
+		{bucket: testBucketOrigin, key: fmt.Sprintf("incoming/%s/%s", uuid.New(), uuid.New()), reason: OrphanStaleDirect},
+		{bucket: testBucketOrigin, key: "backup.tar", reason: OrphanUnrecognized},
+	}
+
+	for _, dryRun := range []bool{true, false} {
+		t.Run(fmt.Sprintf("dry run %t", dryRun), func(t *testing.T) {
+			store := storage.NewMemoryStorage()
+			for _, tt := range tests {
+				if _, err := store.Upload(ctx, tt.bucket, tt.key, bytes.NewReader([]byte("data")), 4, "image/jpeg"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			gc := NewOrphanGCService(wallpapers, uploads, store, []string{testBucketOrigin, testBucketThumb},
+				OrphanGCOptions{MinAge: 24 * time.Hour, DeletedRetention: 30 * 24 * time.Hour})
+			gc.now = func() time.Time { return later }
+
+			report, err := gc.CollectOrphans(ctx, dryRun)
+			if err != nil {
+				t.Fatalf("CollectOrphans: %v", err)
+			}
+
+			reasons := make(map[string]string)
+			for _, o := range report.Orphans {
+				reasons[o.Bucket+"/"+o.Key] = o.Reason
+			}
+
+			for _, tt := range tests {
+				if got := reasons[tt.bucket+"/"+tt.key]; got != tt.reason {
+					t.Errorf("%s/%s: reason = %q, want %q", tt.bucket, tt.key, got, tt.reason)
+				}
+
+				_, statErr := store.Stat(ctx, tt.bucket, tt.key)
+				wantDeleted := !dryRun && tt.reason != "" && tt.reason != OrphanUnrecognized
+				if deleted := errors.Is(statErr, storage.ErrObjectNotFound); deleted != wantDeleted {
+					t.Errorf("%s/%s: deleted = %v, want %v", tt.bucket, tt.key, deleted, wantDeleted)
+				}
+			}
+
+			origin := report.Buckets[0]
+			wantDeleted := 5
+			if dryRun {
+				wantDeleted = 0
+			}
+			if origin.Scanned != 10 || origin.Orphaned != 5 || origin.Unrecognized != 1 || origin.Deleted != wantDeleted {
+				t.Errorf("originals report = %+v", origin)
+			}
+			if thumbs := report.Buckets[1]; thumbs.Scanned != 9 || thumbs.Orphaned != 4 || thumbs.OrphanBytes != 16 {
+				t.Errorf("thumbnails report = %+v", thumbs)
+			}
+		})
+	}
+
+	t.Run("objects younger than min age are kept", func(t *testing.T) {
+		store := storage.NewMemoryStorage()
+		key := fmt.Sprintf("%s/in-flight.jpg", uuid.New())
+		if _, err := store.Upload(ctx, testBucketOrigin, key, bytes.NewReader([]byte("data")), 4, "image/jpeg"); err != nil {
+			t.Fatal(err)
+		}
+
+		gc := NewOrphanGCService(wallpapers, uploads, store, []string{testBucketOrigin}, OrphanGCOptions{MinAge: time.Hour})
+		report, err := gc.CollectOrphans(ctx, false)
+		if err != nil {
+			t.Fatalf("CollectOrphans: %v", err)
+		}
+		if b := report.Buckets[0]; b.TooNew != 1 || b.Orphaned != 0 {
+			t.Errorf("report = %+v, want the object skipped as too new", b)
+		}
+		if _, err := store.Stat(ctx, testBucketOrigin, key); err != nil {
+			t.Errorf("in-flight object was deleted")
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/pavelc4/pixtify/internal/repository/postgres/wallpaper"
+	"github.com/pavelc4/pixtify/internal/storage"
+)
+
+const purgeBatchSize = 100
+
+// PurgeService permanently removes wallpapers that were soft-deleted longer ago than the retention.
+// The row goes first, in a transaction that also fixes the tag and collection counters, then the objects
+// it referred to (original, thumbnails, renditions) and anything else under its key prefixes in the buckets.
+// Objects that fail to delete are left to the orphan GC.
+type PurgeService struct {
+	repo      WallpaperRepository
+	storage   storage.Service
+	buckets   []string
+	retention time.Duration
+	now       func() time.Time
+}
+
+func NewPurgeService(repo WallpaperRepository, storage storage.Service, buckets []string, retention time.Duration) *PurgeService {
+	if retention < 0 {
+		retention = 0
+	}
+	return &PurgeService{
+		repo:      repo,
+		storage:   storage,
+		buckets:   buckets,
+		retention: retention,
+		now:       time.Now,
+	}
+}
+
+// PurgeDeleted purges one batch of expired wallpapers, returning how many were purged
+func (s *PurgeService) PurgeDeleted(ctx context.Context) (int, error) {
+	cutoff := s.now().Add(-s.retention)
+
+	ids, err := s.repo.ListPurgeable(ctx, cutoff, purgeBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list purgeable wallpapers: %w", err)
+	}
+
+	purged := 0
+	for _, id := range ids {
+		// The keys have to be read first, the renditions go with the row
+		keys, err := s.repo.GetStoredKeys(ctx, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return purged, fmt.Errorf("failed to look up objects of wallpaper %s: %w", id, err)
+		}
+
+		// Purge re-checks deleted_at under a row lock, a wallpaper restored since the listing is skipped
+		ok, err := s.repo.Purge(ctx, id, cutoff)
+		if err != nil {
+			return purged, fmt.Errorf("failed to purge wallpaper %s: %w", id, err)
+		}
+		if !ok {
+			continue
+		}
+		purged++
+		s.deleteObjects(ctx, keys)
+	}
+	return purged, nil
+}
+
+// deleteObjects deletes the stored keys of a purged wallpaper, then sweeps its ID prefix and the prefix
+// of its original, which differ for originals uploaded before the API assigned wallpaper IDs
+func (s *PurgeService) deleteObjects(ctx context.Context, stored *wallpaper.StoredKeys) {
+	if s.storage == nil {
+		return
+	}
+
+	prefixes := []string{stored.WallpaperID.String() + "/"}
+	if prefix, ok := wallpaperKeyPrefix(stored.OriginalKey); ok && prefix != stored.WallpaperID.String() {
+		prefixes = append(prefixes, prefix+"/")
+	}
+
+	for _, bucket := range s.buckets {
+		keys := storedObjectKeys(stored)
+		for _, prefix := range prefixes {
+			err := s.storage.List(ctx, bucket, prefix, func(obj storage.ObjectInfo) error {
+				keys = append(keys, obj.Key)
+				return nil
+			})
+			if err != nil {
+				log.Printf("Failed to list objects of purged wallpaper %s in %s: %v", stored.WallpaperID, bucket, err)
+			}
+		}
+
+		seen := make(map[string]bool, len(keys))
+		for _, key := range keys {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			// Stored keys are tried in every bucket, deleting a missing object is not an error
+			if err := s.storage.Delete(ctx, bucket, key); err != nil {
+				log.Printf("Failed to delete %s/%s of purged wallpaper: %v", bucket, key, err)
+			}
+		}
+	}
+}
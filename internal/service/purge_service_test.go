@@ -0,0 +1,92 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/wallpaper"
+	"github.com/pavelc4/pixtify/internal/storage"
+)
+
+func TestPurgeDeleted(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	retention := 30 * 24 * time.Hour
+
+	wallpapers := newFakeWallpaperRepo()
+	live := wallpapers.add(uuid.New(), "Live")
+	recent := wallpapers.add(uuid.New(), "Recently deleted")
+	expired := wallpapers.add(uuid.New(), "Expired")
+	wallpapers.deleted[recent.ID] = now.Add(-24 * time.Hour)
+	wallpapers.deleted[expired.ID] = now.Add(-31 * 24 * time.Hour)
+
+	// Legacy originals live under a different UUID than the wallpaper ID
+	legacy := wallpapers.add(uuid.New(), "Expired legacy")
+	legacyPrefix := uuid.New()
+	wallpapers.deleted[legacy.ID] = now.Add(-40 * 24 * time.Hour)
+	wallpapers.wallpapers[legacy.ID].OriginalKey = fmt.Sprintf("%s/legacy.jpg", legacyPrefix)
+	wallpapers.wallpapers[legacy.ID].ThumbnailFormats = []string{"jpeg", "webp"}
+	wallpapers.renditions[legacy.ID] = []wallpaper.Rendition{{StorageKey: fmt.Sprintf("%s/renditions/1080p.jpg", legacy.ID)}}
+
+	store := storage.NewMemoryStorage()
+	objects := []struct {
+		bucket string
+		key    string
+		purged bool
+	}{
+		{bucket: testBucketOrigin, key: fmt.Sprintf("%s/original.jpg", live.ID)},
+		{bucket: testBucketOrigin, key: fmt.Sprintf("%s/original.jpg", recent.ID)},
+		{bucket: testBucketOrigin, key: fmt.Sprintf("%s/original.jpg", expired.ID), purged: true},
+		{bucket: testBucketThumb, key: fmt.Sprintf("%s/thumb.jpg", expired.ID), purged: true},
+		{bucket: testBucketThumb, key: fmt.Sprintf("%s/renditions/1080p.jpg", expired.ID), purged: true},
+		{bucket: testBucketOrigin, key: fmt.Sprintf("%s/legacy.jpg", legacyPrefix), purged: true},
+		{bucket: testBucketThumb, key: fmt.Sprintf("%s/legacy_thumb.jpg", legacyPrefix), purged: true},
+		{bucket: testBucketThumb, key: fmt.Sprintf("%s/legacy_thumb.webp", legacyPrefix), purged: true},
+		{bucket: testBucketThumb, key: fmt.Sprintf("%s/legacy_thumb.png", legacyPrefix), purged: true},
+		{bucket: testBucketThumb, key: fmt.Sprintf("%s/renditions/1080p.jpg", legacy.ID), purged: true},
+	}
+	for _, o := range objects {
+		if _, err := store.Upload(ctx, o.bucket, o.key, bytes.NewReader([]byte("data")), 4, "image/jpeg"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	purger := NewPurgeService(wallpapers, store, []string{testBucketOrigin, testBucketThumb}, retention)
+	purger.now = func() time.Time { return now }
+
+	n, err := purger.PurgeDeleted(ctx)
+	if err != nil {
+		t.Fatalf("PurgeDeleted: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("purged %d wallpapers, want 2", n)
+	}
+
+	for _, o := range objects {
+		_, statErr := store.Stat(ctx, o.bucket, o.key)
+		if gone := errors.Is(statErr, storage.ErrObjectNotFound); gone != o.purged {
+			t.Errorf("%s/%s: deleted = %v, want %v", o.bucket, o.key, gone, o.purged)
+		}
+	}
+
+	deletedAt, _ := wallpapers.GetDeletedAt(ctx, []uuid.UUID{live.ID, recent.ID, expired.ID})
+	if _, ok := deletedAt[expired.ID]; ok {
+		t.Errorf("expired wallpaper row still exists")
+	}
+	if _, ok := deletedAt[recent.ID]; !ok {
+		t.Errorf("wallpaper inside the retention was purged")
+	}
+	if _, err := wallpapers.GetByID(ctx, live.ID); errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("live wallpaper was purged")
+	}
+
+	if n, err := purger.PurgeDeleted(ctx); err != nil || n != 0 {
+		t.Errorf("second run purged %d (err %v), want 0", n, err)
+	}
+}
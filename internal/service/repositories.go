@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/collection"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/job"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/like"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/tag"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/upload"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/user"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/wallpaper"
+)
+
+// Services depend on these interfaces rather than the postgres repositories,
+// so they can be tested against in-memory fakes. Each lists only the methods the services use.
+
+// WallpaperRepository is implemented by *wallpaper.Repository
+type WallpaperRepository interface {
+	Create(ctx context.Context, w *wallpaper.Wallpaper) error
+	GetByID(ctx context.Context, id uuid.UUID) (*wallpaper.Wallpaper, error)
+	GetByIDs(ctx context.Context, ids []uuid.UUID) ([]*wallpaper.Wallpaper, error)
+	AddTags(ctx context.Context, wallpaperID uuid.UUID, tags []wallpaper.TagRef) error
+	Update(ctx context.Context, id uuid.UUID, title, description *string) error
+	SoftDelete(ctx context.Context, id, deletedBy uuid.UUID) error
+	ListStoredKeys(ctx context.Context, prefixes []string) ([]*wallpaper.StoredKeys, error)
+	GetStoredKeys(ctx context.Context, id uuid.UUID) (*wallpaper.StoredKeys, error)
+	GetDeleted(ctx context.Context, id uuid.UUID) (*wallpaper.Wallpaper, error)
+	ListDeletedByUser(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*wallpaper.Wallpaper, int, error)
+	Restore(ctx context.Context, id uuid.UUID, deletedSince time.Time) (bool, error)
+	ListPurgeable(ctx context.Context, cutoff time.Time, limit int) ([]uuid.UUID, error)
+	IncrementCounters(ctx context.Context, deltas []wallpaper.CounterDelta) error
+	Purge(ctx context.Context, id uuid.UUID, cutoff time.Time) (bool, error)
+	SetFeaturedStatus(ctx context.Context, id uuid.UUID, isFeatured bool) error
+	SetStatus(ctx context.Context, id uuid.UUID, status string) error
+	CompleteProcessing(ctx context.Context, id uuid.UUID, result wallpaper.ProcessingResult) error
+
+	List(ctx context.Context, f wallpaper.Filter, o wallpaper.Order, after *wallpaper.Cursor, limit, offset int) ([]*wallpaper.Wallpaper, int, *wallpaper.Cursor, error)
+	Facets(ctx context.Context, f wallpaper.Filter) (*wallpaper.Facets, error)
+
+	FindSimilar(ctx context.Context, hash int64, maxDistance, limit int) ([]wallpaper.SimilarWallpaper, error)
+	AddDuplicates(ctx context.Context, wallpaperID uuid.UUID, matches []wallpaper.SimilarWallpaper) error
+	ListDuplicateClusters(ctx context.Context, limit, offset int) ([]*wallpaper.DuplicateCluster, int, error)
+
+	SaveMetadata(ctx context.Context, wallpaperID uuid.UUID, m *wallpaper.Metadata) error
+	GetMetadata(ctx context.Context, wallpaperID uuid.UUID) (*wallpaper.Metadata, error)
+	AddRenditions(ctx context.Context, wallpaperID uuid.UUID, renditions []wallpaper.Rendition) error
+}
+
+// JobRepository is implemented by *job.Repository
+type JobRepository interface {
+	Enqueue(ctx context.Context, wallpaperID uuid.UUID, maxAttempts int) (*job.Job, error)
+	GetLatestByWallpaper(ctx context.Context, wallpaperID uuid.UUID) (*job.Job, error)
+}
+
+// LikeRepository is implemented by *like.Repository
+type LikeRepository interface {
+	ToggleLikeWithTx(ctx context.Context, userID, wallpaperID uuid.UUID) (bool, error)
+	IsLiked(ctx context.Context, userID, wallpaperID uuid.UUID) (bool, error)
+	GetUserLikes(ctx context.Context, userID uuid.UUID, after *like.Cursor, limit, offset int) ([]uuid.UUID, int, *like.Cursor, error)
+}
+
+// CollectionRepository is implemented by *collection.Repository
+type CollectionRepository interface {
+	Create(ctx context.Context, c *collection.Collection) error
+	GetByID(ctx context.Context, id uuid.UUID) (*collection.Collection, error)
+	GetUserCollections(ctx context.Context, userID uuid.UUID, limit, offset int) ([]*collection.Collection, int, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	AddWallpaper(ctx context.Context, collectionID, wallpaperID uuid.UUID) error
+	RemoveWallpaper(ctx context.Context, collectionID, wallpaperID uuid.UUID) error
+	GetCollectionWallpapers(ctx context.Context, collectionID uuid.UUID, after *collection.Cursor, limit, offset int) ([]uuid.UUID, int, *collection.Cursor, error)
+}
+
+// TagRepository is implemented by *tag.Repository
+type TagRepository interface {
+	Create(ctx context.Context, name, slug string) (*tag.Tag, error)
+	List(ctx context.Context, limit, offset int) ([]*tag.Tag, int, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*tag.Tag, error)
+	GetBySlug(ctx context.Context, slug string) (*tag.Tag, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// UserRepository is implemented by *user.Repository
+type UserRepository interface {
+	Create(ctx context.Context, u *user.User) error
+	GetByID(ctx context.Context, id uuid.UUID) (*user.User, error)
+	GetByEmail(ctx context.Context, email string) (*user.User, error)
+	Update(ctx context.Context, u *user.User) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	ListWithPagination(ctx context.Context, offset, limit int) ([]*user.User, int, error)
+	BanUser(ctx context.Context, userID, bannedBy uuid.UUID) error
+	UnbanUser(ctx context.Context, userID uuid.UUID) error
+	GetUserStats(ctx context.Context, userID uuid.UUID) (*user.UserStats, error)
+}
+
+// UploadRepository is implemented by *upload.Repository
+type UploadRepository interface {
+	Create(ctx context.Context, u *upload.Upload) error
+	GetByID(ctx context.Context, id uuid.UUID) (*upload.Upload, error)
+	AppendChunk(ctx context.Context, id uuid.UUID, offset, size int64, key string, expiresAt time.Time) (bool, error)
+	BeginFinalize(ctx context.Context, id uuid.UUID) (bool, error)
+	CancelFinalize(ctx context.Context, id uuid.UUID) error
+	Complete(ctx context.Context, id, wallpaperID uuid.UUID) error
+	Fail(ctx context.Context, id uuid.UUID, lastError string) error
+	Expire(ctx context.Context, id uuid.UUID) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	ListExpired(ctx context.Context, limit int) ([]*upload.Upload, error)
+}
+
+var (
+	_ WallpaperRepository  = (*wallpaper.Repository)(nil)
+	_ JobRepository        = (*job.Repository)(nil)
+	_ LikeRepository       = (*like.Repository)(nil)
+	_ CollectionRepository = (*collection.Repository)(nil)
+	_ TagRepository        = (*tag.Repository)(nil)
+	_ UserRepository       = (*user.Repository)(nil)
+	_ UploadRepository     = (*upload.Repository)(nil)
+)
@@ -9,6 +9,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/pavelc4/pixtify/internal/repository/postgres/tag"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/wallpaper"
 )
 
 var (
@@ -20,11 +21,11 @@ var (
 
 // TagService handles tag business logic
 type TagService struct {
-	tagRepo *tag.Repository
+	tagRepo TagRepository
 }
 
 // NewTagService creates a new tag service
-func NewTagService(tagRepo *tag.Repository) *TagService {
+func NewTagService(tagRepo TagRepository) *TagService {
 	return &TagService{
 		tagRepo: tagRepo,
 	}
@@ -42,7 +43,7 @@ func (s *TagService) CreateTag(ctx context.Context, name string) (*tag.Tag, erro
 	}
 
 	// Generate slug
-	slug := s.generateSlug(name)
+	slug := generateTagSlug(name)
 
 	// Check if tag with this slug already exists
 	existing, err := s.tagRepo.GetBySlug(ctx, slug)
@@ -116,7 +117,8 @@ func (s *TagService) GetTagBySlug(ctx context.Context, slug string) (*tag.Tag, e
 	return tagObj, nil
 }
 
-func (s *TagService) generateSlug(name string) string {
+// generateTagSlug is shared with tags attached on upload so both map a name to the same tag
+func generateTagSlug(name string) string {
 	// Convert to lowercase
 	slug := strings.ToLower(name)
 
@@ -136,3 +138,21 @@ func (s *TagService) generateSlug(name string) string {
 
 	return slug
 }
+
+// uploadTagRefs cleans the free-form tags sent with an upload. Names are trimmed, names
+// without a usable slug or over 50 characters are dropped, and of names sharing a slug
+// only the first is kept since they are the same tag.
+func uploadTagRefs(names []string) []wallpaper.TagRef {
+	var refs []wallpaper.TagRef
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		slug := generateTagSlug(name)
+		if slug == "" || len(name) > 50 || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		refs = append(refs, wallpaper.TagRef{Name: name, Slug: slug})
+	}
+	return refs
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/pavelc4/pixtify/internal/repository/postgres/wallpaper"
+)
+
+func TestGenerateTagSlug(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "Nature", want: "nature"},
+		{name: "Mountain Lake", want: "mountain-lake"},
+		{name: "  Sci-Fi -- Art  ", want: "sci-fi-art"},
+		{name: "Café & Bar!", want: "caf-bar"},
+		{name: "4K", want: "4k"},
+		{name: "!!!", want: ""},
+		{name: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := generateTagSlug(tt.name); got != tt.want {
+				t.Errorf("generateTagSlug(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUploadTagRefs(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []string
+		want  []wallpaper.TagRef
+	}{
+		{name: "none", input: nil, want: nil},
+		{
+			name:  "trimmed",
+			input: []string{" Nature ", "Mountain Lake"},
+			want:  []wallpaper.TagRef{{Name: "Nature", Slug: "nature"}, {Name: "Mountain Lake", Slug: "mountain-lake"}},
+		},
+		{
+			name:  "empty and symbol-only names dropped",
+			input: []string{"", "   ", "#!?", "Dawn"},
+			want:  []wallpaper.TagRef{{Name: "Dawn", Slug: "dawn"}},
+		},
+		{
+			name:  "over 50 characters dropped",
+			input: []string{strings.Repeat("a", 51), "Dawn"},
+			want:  []wallpaper.TagRef{{Name: "Dawn", Slug: "dawn"}},
+		},
+		{
+			name:  "first name of a slug kept",
+			input: []string{"Sci-Fi", "sci fi", "SCI-FI"},
+			want:  []wallpaper.TagRef{{Name: "Sci-Fi", Slug: "sci-fi"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := uploadTagRefs(tt.input); !slices.Equal(got, tt.want) {
+				t.Errorf("uploadTagRefs(%q) = %+v, want %+v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/wallpaper"
+)
+
+var (
+	ErrNotInTrash         = errors.New("wallpaper is not in the trash")
+	ErrRestoreForbidden   = errors.New("you don't have permission to restore this wallpaper")
+	ErrRestoreWindowOver  = errors.New("the restore window for this wallpaper is over")
+	ErrRemovedByModerator = errors.New("this wallpaper was removed by a moderator")
+)
+
+// TrashedWallpaper is a soft-deleted wallpaper with the time it will be purged for good
+type TrashedWallpaper struct {
+	*wallpaper.Wallpaper
+	PurgeAt time.Time `json:"purge_at"`
+}
+
+// TrashService lists and restores soft-deleted wallpapers. Owners can restore their own
+// within the retention (PURGE_RETENTION), moderators any wallpaper that was not purged yet.
+// Wallpapers a moderator removed are left out of the owner's trash and only moderators restore them.
+type TrashService struct {
+	repo      WallpaperRepository
+	retention time.Duration
+	now       func() time.Time
+}
+
+func NewTrashService(repo WallpaperRepository, retention time.Duration) *TrashService {
+	return &TrashService{repo: repo, retention: retention, now: time.Now}
+}
+
+// ListTrash returns the user's soft-deleted wallpapers, most recently deleted first
+func (s *TrashService) ListTrash(ctx context.Context, userIDStr string, page, limit int) ([]*TrashedWallpaper, int, error) {
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid user ID")
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 50 {
+		limit = 20
+	}
+	offset := (page - 1) * limit
+
+	wallpapers, total, err := s.repo.ListDeletedByUser(ctx, userID, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list trash: %w", err)
+	}
+
+	trash := make([]*TrashedWallpaper, 0, len(wallpapers))
+	for _, w := range wallpapers {
+		trash = append(trash, &TrashedWallpaper{Wallpaper: w, PurgeAt: w.DeletedAt.Add(s.retention)})
+	}
+	return trash, total, nil
+}
+
+// RestoreWallpaper moves a soft-deleted wallpaper back out of the trash
+func (s *TrashService) RestoreWallpaper(ctx context.Context, wallpaperIDStr, userIDStr, userRole string) error {
+	wallpaperID, err := uuid.Parse(wallpaperIDStr)
+	if err != nil {
+		return fmt.Errorf("invalid wallpaper ID")
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return fmt.Errorf("invalid user ID")
+	}
+
+	wp, err := s.repo.GetDeleted(ctx, wallpaperID)
+	if err == sql.ErrNoRows {
+		return ErrNotInTrash
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get wallpaper: %w", err)
+	}
+
+	isOwner := wp.UserID == userID
+	isModerator := userRole == "moderator" || userRole == "owner"
+	if !isOwner && !isModerator {
+		return ErrRestoreForbidden
+	}
+	if wp.ModeratorRemoved && !isModerator {
+		return ErrRemovedByModerator
+	}
+
+	// Moderators are not bound by the window, only by the purge having run
+	var deletedSince time.Time
+	if !isModerator {
+		deletedSince = s.now().Add(-s.retention)
+		if wp.DeletedAt.Before(deletedSince) {
+			return ErrRestoreWindowOver
+		}
+	}
+
+	restored, err := s.repo.Restore(ctx, wallpaperID, deletedSince)
+	if err != nil {
+		return fmt.Errorf("failed to restore wallpaper: %w", err)
+	}
+	if !restored {
+		// Purged or restored by someone else since GetDeleted
+		return ErrNotInTrash
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRestoreWallpaper(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	retention := 30 * 24 * time.Hour
+	owner, stranger, mod := uuid.New(), uuid.New(), uuid.New()
+
+	tests := []struct {
+		name      string
+		deletedAt time.Duration // ago, 0 for a wallpaper that is not deleted
+		deletedBy uuid.UUID     // the owner when zero
+		userID    uuid.UUID
+		role      string
+		want      error
+	}{
+		{name: "owner within retention", deletedAt: 24 * time.Hour, userID: owner, role: "user"},
+		{name: "owner after retention", deletedAt: 31 * 24 * time.Hour, userID: owner, role: "user", want: ErrRestoreWindowOver},
+		{name: "other user", deletedAt: 24 * time.Hour, userID: stranger, role: "user", want: ErrRestoreForbidden},
+		{name: "moderator after retention", deletedAt: 31 * 24 * time.Hour, userID: mod, role: "moderator"},
+		{name: "not deleted", userID: owner, role: "user", want: ErrNotInTrash},
+		{name: "owner after moderator removal", deletedAt: time.Hour, deletedBy: mod, userID: owner, role: "user", want: ErrRemovedByModerator},
+		{name: "moderator after moderator removal", deletedAt: time.Hour, deletedBy: mod, userID: mod, role: "moderator"},
+		{name: "moderator owner after own deletion", deletedAt: time.Hour, userID: owner, role: "moderator"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakeWallpaperRepo()
+			w := repo.add(owner, "Wallpaper")
+			if tt.deletedAt > 0 {
+				repo.deleted[w.ID] = now.Add(-tt.deletedAt)
+				repo.deletedBy[w.ID] = owner
+				if tt.deletedBy != uuid.Nil {
+					repo.deletedBy[w.ID] = tt.deletedBy
+				}
+			}
+
+			trash := NewTrashService(repo, retention)
+			trash.now = func() time.Time { return now }
+
+			err := trash.RestoreWallpaper(ctx, w.ID.String(), tt.userID.String(), tt.role)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("RestoreWallpaper error = %v, want %v", err, tt.want)
+			}
+
+			_, getErr := repo.GetByID(ctx, w.ID)
+			if visible := getErr == nil; visible != (tt.want == nil || tt.deletedAt == 0) {
+				t.Errorf("wallpaper visible = %v after restore error %v", visible, err)
+			}
+		})
+	}
+}
+
+func TestListTrash(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	owner := uuid.New()
+
+	repo := newFakeWallpaperRepo()
+	older := repo.add(owner, "Older")
+	newer := repo.add(owner, "Newer")
+	repo.add(owner, "Live")
+	other := repo.add(uuid.New(), "Someone else's")
+	repo.deleted[older.ID] = now.Add(-48 * time.Hour)
+	repo.deleted[newer.ID] = now.Add(-time.Hour)
+	repo.deleted[other.ID] = now.Add(-time.Hour)
+
+	trash := NewTrashService(repo, 24*time.Hour)
+	items, total, err := trash.ListTrash(ctx, owner.String(), 1, 20)
+	if err != nil {
+		t.Fatalf("ListTrash: %v", err)
+	}
+	if total != 2 || len(items) != 2 {
+		t.Fatalf("got %d items (total %d), want 2", len(items), total)
+	}
+	if items[0].ID != newer.ID || items[1].ID != older.ID {
+		t.Errorf("trash not ordered by most recently deleted")
+	}
+	if want := now.Add(-time.Hour).Add(24 * time.Hour); !items[0].PurgeAt.Equal(want) {
+		t.Errorf("purge_at = %v, want %v", items[0].PurgeAt, want)
+	}
+}
+
+func TestModeratorRemoval(t *testing.T) {
+	ctx := context.Background()
+	owner, mod := uuid.New(), uuid.New()
+
+	f := newWallpaperFixture(t, DuplicateModeOff)
+	repo, wallpapers := f.repo, f.service
+	trash := NewTrashService(repo, 24*time.Hour)
+
+	removed := repo.add(owner, "Removed")
+	deleted := repo.add(owner, "Deleted")
+	if err := wallpapers.DeleteWallpaper(ctx, removed.ID.String(), mod.String(), "moderator"); err != nil {
+		t.Fatalf("moderator DeleteWallpaper: %v", err)
+	}
+	if err := wallpapers.DeleteWallpaper(ctx, deleted.ID.String(), owner.String(), "user"); err != nil {
+		t.Fatalf("owner DeleteWallpaper: %v", err)
+	}
+
+	// The deleter is recorded, and only a removal by someone else counts as a moderator's
+	if w, err := repo.GetDeleted(ctx, removed.ID); err != nil || w.DeletedBy == nil || *w.DeletedBy != mod || !w.ModeratorRemoved {
+		t.Errorf("removed wallpaper = %+v, %v, want removed by the moderator", w, err)
+	}
+	if w, err := repo.GetDeleted(ctx, deleted.ID); err != nil || w.ModeratorRemoved {
+		t.Errorf("deleted wallpaper = %+v, %v, want deleted by the owner", w, err)
+	}
+
+	// The owner's trash only holds what they deleted themselves
+	items, total, err := trash.ListTrash(ctx, owner.String(), 1, 20)
+	if err != nil {
+		t.Fatalf("ListTrash: %v", err)
+	}
+	if total != 1 || len(items) != 1 || items[0].ID != deleted.ID {
+		t.Errorf("trash = %d items (total %d), want only the owner's own deletion", len(items), total)
+	}
+
+	if err := trash.RestoreWallpaper(ctx, removed.ID.String(), owner.String(), "user"); !errors.Is(err, ErrRemovedByModerator) {
+		t.Errorf("owner restore of a removal: err = %v, want %v", err, ErrRemovedByModerator)
+	}
+	if err := trash.RestoreWallpaper(ctx, removed.ID.String(), mod.String(), "moderator"); err != nil {
+		t.Fatalf("moderator restore: %v", err)
+	}
+
+	// Once restored, a later deletion by the owner is theirs again
+	if err := wallpapers.DeleteWallpaper(ctx, removed.ID.String(), owner.String(), "user"); err != nil {
+		t.Fatalf("owner DeleteWallpaper: %v", err)
+	}
+	if err := trash.RestoreWallpaper(ctx, removed.ID.String(), owner.String(), "user"); err != nil {
+		t.Errorf("owner restore of their own deletion: %v", err)
+	}
+}
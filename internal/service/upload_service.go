@@ -0,0 +1,326 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/upload"
+	"github.com/pavelc4/pixtify/internal/storage"
+)
+
+var (
+	ErrUploadNotFound       = errors.New("upload not found")
+	ErrUploadExpired        = errors.New("upload has expired")
+	ErrUploadOffsetMismatch = errors.New("upload offset does not match the stored offset")
+	ErrUploadTooLarge       = errors.New("upload length exceeds the maximum size")
+	ErrUploadLengthExceeded = errors.New("chunk goes past the declared upload length")
+	ErrUploadNotPending     = errors.New("upload is already finished")
+	ErrUploadMissingTitle   = errors.New("upload metadata must include a title")
+)
+
+// expiredUploadBatch is how many expired uploads are cleaned up per pass
+const expiredUploadBatch = 100
+
+// UploadService implements resumable (tus) uploads on top of CreateWallpaper
+type UploadService struct {
+	repo       UploadRepository
+	wallpapers *WallpaperService
+	storage    storage.Service
+	bucket     string
+	maxSize    int64
+	expiry     time.Duration
+	tempDir    string
+}
+
+func NewUploadService(repo UploadRepository, wallpapers *WallpaperService, storage storage.Service, bucket string, maxSize int64, expiry time.Duration, tempDir string) *UploadService {
+	if expiry <= 0 {
+		expiry = 24 * time.Hour
+	}
+	return &UploadService{
+		repo:       repo,
+		wallpapers: wallpapers,
+		storage:    storage,
+		bucket:     bucket,
+		maxSize:    maxSize,
+		expiry:     expiry,
+		tempDir:    tempDir,
+	}
+}
+
+// MaxSize is the largest upload length accepted
+func (s *UploadService) MaxSize() int64 {
+	return s.maxSize
+}
+
+// CreateUpload starts a resumable upload. metadata carries the wallpaper fields
+// (title, description, tags, device_type, focal_x, focal_y) and optionally filetype.
+func (s *UploadService) CreateUpload(ctx context.Context, userIDStr string, length int64, metadata map[string]string) (*upload.Upload, error) {
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+	if length > s.maxSize {
+		return nil, ErrUploadTooLarge
+	}
+	if length < 1 {
+		return nil, fmt.Errorf("upload length must be positive")
+	}
+	if strings.TrimSpace(metadata["title"]) == "" {
+		return nil, ErrUploadMissingTitle
+	}
+	if _, err := ParseFocalPoint(metadata["focal_x"], metadata["focal_y"]); err != nil {
+		return nil, err
+	}
+
+	u := &upload.Upload{
+		UserID:    userID,
+		Length:    length,
+		Metadata:  metadata,
+		ExpiresAt: time.Now().Add(s.expiry),
+	}
+	if err := s.repo.Create(ctx, u); err != nil {
+		return nil, fmt.Errorf("failed to create upload: %w", err)
+	}
+	return u, nil
+}
+
+// GetUpload returns an upload owned by the user
+func (s *UploadService) GetUpload(ctx context.Context, idStr, userIDStr string) (*upload.Upload, error) {
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		return nil, ErrUploadNotFound
+	}
+
+	u, err := s.repo.GetByID(ctx, id)
+	if err == sql.ErrNoRows {
+		return nil, ErrUploadNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upload: %w", err)
+	}
+
+	// Other users' uploads are reported as missing rather than forbidden
+	if u.UserID.String() != userIDStr {
+		return nil, ErrUploadNotFound
+	}
+	if u.Status == upload.StatusExpired || (u.Status == upload.StatusPending && time.Now().After(u.ExpiresAt)) {
+		return nil, ErrUploadExpired
+	}
+	return u, nil
+}
+
+// WriteChunk stores the bytes of one PATCH request starting at offset.
+// If the client disconnects halfway, whatever arrived is kept so it can resume from there.
+// The chunk that completes the upload also creates the wallpaper, errors from
+// CreateWallpaper (validation, duplicates) are returned as is.
+func (s *UploadService) WriteChunk(ctx context.Context, idStr, userIDStr string, offset int64, body io.Reader) (*upload.Upload, error) {
+	u, err := s.GetUpload(ctx, idStr, userIDStr)
+	if err != nil {
+		return nil, err
+	}
+	if u.Status != upload.StatusPending {
+		return nil, ErrUploadNotPending
+	}
+	if offset != u.Offset {
+		return nil, ErrUploadOffsetMismatch
+	}
+
+	remaining := u.Length - u.Offset
+	if remaining > 0 {
+		chunk, err := storage.NewSpooledFile(s.tempDir)
+		if err != nil {
+			return nil, err
+		}
+		defer chunk.Close()
+
+		// Read one byte past the remaining length to detect oversized chunks
+		_, readErr := io.Copy(chunk, io.LimitReader(body, remaining+1))
+		if chunk.Size() > remaining {
+			return nil, ErrUploadLengthExceeded
+		}
+		if readErr != nil && chunk.Size() == 0 {
+			return nil, fmt.Errorf("failed to read chunk: %w", readErr)
+		}
+
+		if chunk.Size() > 0 {
+			// The request may be gone by now, the received bytes are still worth keeping
+			storeCtx := context.WithoutCancel(ctx)
+			if err := s.storeChunk(storeCtx, u, chunk); err != nil {
+				return nil, err
+			}
+		}
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read chunk: %w", readErr)
+		}
+	}
+
+	if u.Offset == u.Length {
+		return s.finalize(context.WithoutCancel(ctx), u)
+	}
+	return u, nil
+}
+
+func (s *UploadService) storeChunk(ctx context.Context, u *upload.Upload, chunk *storage.SpooledFile) error {
+	// Unique per attempt so a request losing the offset race cannot overwrite the winner's chunk
+	key := fmt.Sprintf("uploads/%s/%012d-%s", u.ID, u.Offset, uuid.NewString()[:8])
+	if _, err := s.storage.Upload(ctx, s.bucket, key, chunk.Reader(), chunk.Size(), "application/octet-stream"); err != nil {
+		return fmt.Errorf("failed to store chunk: %w", err)
+	}
+
+	expiresAt := time.Now().Add(s.expiry)
+	ok, err := s.repo.AppendChunk(ctx, u.ID, u.Offset, chunk.Size(), key, expiresAt)
+	if err != nil || !ok {
+		_ = s.storage.Delete(ctx, s.bucket, key)
+		if err != nil {
+			return fmt.Errorf("failed to record chunk: %w", err)
+		}
+		return ErrUploadOffsetMismatch
+	}
+
+	u.Offset += chunk.Size()
+	u.ChunkKeys = append(u.ChunkKeys, key)
+	u.ExpiresAt = expiresAt
+	return nil
+}
+
+// finalize joins the chunks into a wallpaper and removes them
+func (s *UploadService) finalize(ctx context.Context, u *upload.Upload) (*upload.Upload, error) {
+	ok, err := s.repo.BeginFinalize(ctx, u.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to finalize upload: %w", err)
+	}
+	if !ok {
+		return nil, ErrUploadNotPending
+	}
+
+	// Checked when the upload was created
+	focal, _ := ParseFocalPoint(u.Metadata["focal_x"], u.Metadata["focal_y"])
+
+	chunks := &chunkReader{ctx: ctx, storage: s.storage, bucket: s.bucket, keys: u.ChunkKeys}
+	wp, err := s.wallpapers.CreateWallpaper(ctx, CreateWallpaperInput{
+		UserID:      u.UserID.String(),
+		Title:       strings.TrimSpace(u.Metadata["title"]),
+		Description: u.Metadata["description"],
+		DeviceType:  u.Metadata["device_type"],
+		Image:       chunks,
+		ContentType: u.Metadata["filetype"],
+		Tags:        SplitTags(u.Metadata["tags"]),
+		FocalPoint:  focal,
+	})
+	chunks.Close()
+
+	// Nothing was stored, the client can retry with an empty PATCH at the final offset
+	if errors.Is(err, ErrUploadBusy) {
+		if resetErr := s.repo.CancelFinalize(ctx, u.ID); resetErr != nil {
+			log.Printf("Failed to reset upload %s: %v", u.ID, resetErr)
+		}
+		return nil, err
+	}
+
+	s.deleteChunks(ctx, u.ChunkKeys)
+	u.ChunkKeys = nil
+
+	if err != nil {
+		if failErr := s.repo.Fail(ctx, u.ID, err.Error()); failErr != nil {
+			log.Printf("Failed to mark upload %s failed: %v", u.ID, failErr)
+		}
+		return nil, err
+	}
+
+	if err := s.repo.Complete(ctx, u.ID, wp.ID); err != nil {
+		return nil, fmt.Errorf("failed to complete upload: %w", err)
+	}
+	u.Status = upload.StatusCompleted
+	u.WallpaperID = &wp.ID
+	return u, nil
+}
+
+// Terminate cancels an upload and deletes what was received (tus termination extension)
+func (s *UploadService) Terminate(ctx context.Context, idStr, userIDStr string) error {
+	u, err := s.GetUpload(ctx, idStr, userIDStr)
+	if err != nil {
+		return err
+	}
+	if u.Status == upload.StatusFinalizing {
+		return ErrUploadNotPending
+	}
+
+	s.deleteChunks(ctx, u.ChunkKeys)
+	if err := s.repo.Delete(ctx, u.ID); err != nil {
+		return fmt.Errorf("failed to delete upload: %w", err)
+	}
+	return nil
+}
+
+// ExpireUploads deletes the chunks of abandoned uploads, returning how many were expired
+func (s *UploadService) ExpireUploads(ctx context.Context) (int, error) {
+	expired, err := s.repo.ListExpired(ctx, expiredUploadBatch)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list expired uploads: %w", err)
+	}
+
+	for _, u := range expired {
+		s.deleteChunks(ctx, u.ChunkKeys)
+		if err := s.repo.Expire(ctx, u.ID); err != nil {
+			return 0, fmt.Errorf("failed to expire upload %s: %w", u.ID, err)
+		}
+	}
+	return len(expired), nil
+}
+
+func (s *UploadService) deleteChunks(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := s.storage.Delete(ctx, s.bucket, key); err != nil {
+			log.Printf("Failed to delete upload chunk %s: %v", key, err)
+		}
+	}
+}
+
+// chunkReader reads stored chunks back to back, downloading each one only when the previous is used up
+type chunkReader struct {
+	ctx     context.Context
+	storage storage.Service
+	bucket  string
+	keys    []string
+	current io.ReadCloser
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.keys) == 0 {
+				return 0, io.EOF
+			}
+			obj, err := r.storage.Download(r.ctx, r.bucket, r.keys[0])
+			if err != nil {
+				return 0, err
+			}
+			r.current, r.keys = obj, r.keys[1:]
+		}
+
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (r *chunkReader) Close() error {
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
+}
@@ -17,10 +17,10 @@ var (
 )
 
 type UserService struct {
-	repo *userRepo.Repository
+	repo UserRepository
 }
 
-func NewUserService(repo *userRepo.Repository) *UserService {
+func NewUserService(repo UserRepository) *UserService {
 	return &UserService{repo: repo}
 }
 
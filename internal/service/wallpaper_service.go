@@ -3,21 +3,97 @@ package service
 import (
 	"bytes"
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"path"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/pavelc4/pixtify/internal/config"
 	"github.com/pavelc4/pixtify/internal/processor"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/job"
 	"github.com/pavelc4/pixtify/internal/repository/postgres/wallpaper"
 	"github.com/pavelc4/pixtify/internal/storage"
 )
 
+// Palette colours within this delta E of the requested color count as a match,
+// and must cover at least this share of the image to be considered dominant
+const (
+	colorMatchDistance  = 20.0
+	colorMatchMinWeight = 0.1
+)
+
+var ErrInvalidColor = errors.New("invalid color, expected hex like #1e3a8a")
+
+var ErrInvalidFocalPoint = errors.New("invalid focal point, focal_x and focal_y must both be between 0 and 1")
+
+var ErrInvalidSearchQuery = errors.New("invalid search query")
+
+var (
+	ErrUploadBusy           = errors.New("too many uploads in progress, try again later")
+	ErrDirectUploadNotFound = errors.New("no uploaded image found, PUT it to the upload URL first")
+)
+
+var (
+	ErrWallpaperNotFound = errors.New("wallpaper not found")
+	ErrRenditionNotFound = errors.New("rendition not found")
+	ErrUnknownSize       = errors.New("unknown size, use original, thumbnail or a rendition name")
+)
+
+// Download links are presigned for a short time so they can't be hotlinked for long
+const downloadURLExpiry = 5 * time.Minute
+
+// Download sizes besides rendition names (e.g. "1080p")
+const (
+	DownloadOriginal  = "original"
+	DownloadThumbnail = "thumbnail"
+)
+
+// UploadOptions bounds how many uploads are spooled and processed at once and how long
+// presigned direct upload URLs stay valid (see config.UploadConfig)
+type UploadOptions struct {
+	MaxConcurrent int
+	QueueTimeout  time.Duration
+	TempDir       string
+	PresignExpiry time.Duration
+}
+
+// Duplicate detection modes (config.DuplicateConfig.Mode)
+const (
+	DuplicateModeReject = "reject"
+	DuplicateModeFlag   = "flag"
+	DuplicateModeOff    = "off"
+)
+
+// DuplicateError is returned when an upload is rejected as a near-duplicate of an existing wallpaper
+type DuplicateError struct {
+	DuplicateOf uuid.UUID
+	Distance    int
+}
+
+func (e *DuplicateError) Error() string {
+	return "this image looks like a duplicate of an existing wallpaper"
+}
+
 type WallpaperService struct {
-	repo         *wallpaper.Repository
-	storage      storage.Service
-	processor    *processor.ImageProcessor
-	bucketOrigin string
-	bucketThumb  string
+	repo           WallpaperRepository
+	jobRepo        JobRepository
+	storage        storage.Service
+	processor      *processor.ImageProcessor
+	bucketOrigin   string
+	bucketThumb    string
+	maxJobAttempts int
+	duplicates     config.DuplicateConfig
+	uploadSlots    chan struct{}
+	uploadTimeout  time.Duration
+	uploadTempDir  string
+	presignExpiry  time.Duration
+	originals      *OriginalLinks
 }
 
 // SplitTags
@@ -28,13 +104,33 @@ func SplitTags(tags string) []string {
 	return strings.Split(tags, ",")
 }
 
-func NewWallpaperService(repo *wallpaper.Repository, storage storage.Service, processor *processor.ImageProcessor, bucketOrigin, bucketThumb string) *WallpaperService {
+func NewWallpaperService(repo WallpaperRepository, jobRepo JobRepository, storage storage.Service, processor *processor.ImageProcessor, bucketOrigin, bucketThumb string, maxJobAttempts int, duplicates config.DuplicateConfig, uploads UploadOptions, originals *OriginalLinks) *WallpaperService {
+	if maxJobAttempts < 1 {
+		maxJobAttempts = 1
+	}
+	if uploads.MaxConcurrent < 1 {
+		uploads.MaxConcurrent = 1
+	}
+	if uploads.PresignExpiry <= 0 {
+		uploads.PresignExpiry = 15 * time.Minute
+	}
+	if duplicates.Mode != DuplicateModeReject && duplicates.Mode != DuplicateModeOff {
+		duplicates.Mode = DuplicateModeFlag
+	}
 	return &WallpaperService{
-		repo:         repo,
-		storage:      storage,
-		processor:    processor,
-		bucketOrigin: bucketOrigin,
-		bucketThumb:  bucketThumb,
+		repo:           repo,
+		jobRepo:        jobRepo,
+		storage:        storage,
+		processor:      processor,
+		bucketOrigin:   bucketOrigin,
+		bucketThumb:    bucketThumb,
+		maxJobAttempts: maxJobAttempts,
+		duplicates:     duplicates,
+		uploadSlots:    make(chan struct{}, uploads.MaxConcurrent),
+		uploadTimeout:  uploads.QueueTimeout,
+		uploadTempDir:  uploads.TempDir,
+		presignExpiry:  uploads.PresignExpiry,
+		originals:      originals,
 	}
 }
 
@@ -43,22 +139,22 @@ type CreateWallpaperInput struct {
 	Title       string
 	Description string
 	DeviceType  string // "mobile" or "desktop"
-	ImageData   []byte
+	Image       io.Reader
 	ContentType string
-	Tags        []string // keywords for search
+	Tags        []string              // keywords for search
+	FocalPoint  *wallpaper.FocalPoint // optional, kept in frame by device crops
 }
 
+// CreateWallpaper stores the original and queues derivative generation.
+// The wallpaper stays in "processing" (hidden from listings) until a worker finishes it.
 func (s *WallpaperService) CreateWallpaper(ctx context.Context, input CreateWallpaperInput) (*wallpaper.Wallpaper, error) {
-	// Validate Image
-	info, err := s.processor.ValidateImage(input.ImageData, input.ContentType)
-	if err != nil {
-		return nil, fmt.Errorf("invalid image: %w", err)
-	}
-
 	userUUID, err := uuid.Parse(input.UserID)
 	if err != nil {
 		return nil, fmt.Errorf("invalid user ID")
 	}
+	if input.FocalPoint != nil && !toFocalPoint(input.FocalPoint).Valid() {
+		return nil, ErrInvalidFocalPoint
+	}
 
 	// Validate device type
 	deviceType := input.DeviceType
@@ -66,6 +162,62 @@ func (s *WallpaperService) CreateWallpaper(ctx context.Context, input CreateWall
 		deviceType = "desktop" // default
 	}
 
+	// Decoding a large image takes hundreds of MB, only a few uploads are handled at once
+	release, err := s.acquireUploadSlot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// Spool to disk so the upload is never held in memory
+	upload, err := storage.Spool(input.Image, s.uploadTempDir, s.processor.MaxSizeBytes())
+	if err != nil {
+		if errors.Is(err, storage.ErrSpoolLimit) {
+			return nil, fmt.Errorf("invalid image: %w", &processor.ValidationError{
+				Kind:   processor.ErrImageTooLarge,
+				Detail: fmt.Sprintf("max %dMB", s.processor.MaxSizeBytes()/(1024*1024)),
+			})
+		}
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+	defer upload.Close()
+
+	// Validate Image (content sniffing, pixel limits, minimum resolution for the device type)
+	info, err := s.processor.ValidateImage(upload, upload.Size(), input.ContentType, deviceType)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image: %w", err)
+	}
+
+	// Remove location and device data before the original is stored anywhere
+	metadata := s.processor.ExtractMetadata(upload, upload.Size())
+	original, err := storage.NewSpooledFile(s.uploadTempDir)
+	if err != nil {
+		return nil, err
+	}
+	defer original.Close()
+	if err := s.processor.StripMetadata(original, upload, upload.Size()); err != nil {
+		return nil, fmt.Errorf("invalid image: %w", err)
+	}
+
+	// Perceptual hash, stored even when detection is off so it can be enabled later
+	hash, err := s.processor.PerceptualHash(original, original.Size())
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash image: %w", err)
+	}
+	phash := int64(hash)
+
+	// Catch reposts of existing wallpapers before anything is stored
+	var duplicates []wallpaper.SimilarWallpaper
+	if s.duplicates.Mode != DuplicateModeOff {
+		duplicates, err = s.repo.FindSimilar(ctx, phash, s.duplicates.MaxDistance, 10)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check for duplicates: %w", err)
+		}
+		if len(duplicates) > 0 && s.duplicates.Mode == DuplicateModeReject {
+			return nil, &DuplicateError{DuplicateOf: duplicates[0].ID, Distance: duplicates[0].Distance}
+		}
+	}
+
 	wallpaperID := uuid.New()
 	slug := slugify(input.Title)
 	if slug == "" {
@@ -79,41 +231,43 @@ func (s *WallpaperService) CreateWallpaper(ctx context.Context, input CreateWall
 		ext = ".webp"
 	}
 
+	// Store the sniffed type, the client supplied header is not trusted
+	contentType := info.ContentType
+
 	// Upload Original Image (no compression, full quality)
 	imageKey := fmt.Sprintf("%s/%s%s", wallpaperID, slug, ext)
-	imageURL, err := s.storage.Upload(ctx, s.bucketOrigin, imageKey, bytes.NewReader(input.ImageData), int64(len(input.ImageData)), input.ContentType)
+	imageURL, err := s.storage.Upload(ctx, s.bucketOrigin, imageKey, original.Reader(), original.Size(), contentType)
 	if err != nil {
 		return nil, fmt.Errorf("failed to upload image: %w", err)
 	}
-
-	// Generate & Upload Thumbnail (compressed for fast loading)
-	thumbData, err := s.processor.GenerateThumbnail(input.ImageData, 400, 400)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate thumbnail: %w", err)
+	// Private originals are only reachable through URLs signed per request (see OriginalLinks),
+	// the bucket URL is never stored
+	if s.originals != nil {
+		imageURL = ""
 	}
 
-	thumbKey := fmt.Sprintf("%s/%s_thumb.jpg", wallpaperID, slug)
-	thumbURL, err := s.storage.Upload(ctx, s.bucketThumb, thumbKey, bytes.NewReader(thumbData), int64(len(thumbData)), "image/jpeg")
-	if err != nil {
-		return nil, fmt.Errorf("failed to upload thumbnail: %w", err)
-	}
+	checksum := original.SHA256()
 
-	// Save Metadata
+	// Save Metadata, derivatives are filled in by the processing worker
 	wp := &wallpaper.Wallpaper{
-		ID:            wallpaperID,
-		UserID:        userUUID,
-		Title:         input.Title,
-		Description:   &input.Description,
-		OriginalURL:   imageURL,
-		ImageURL:      imageURL,
-		ThumbnailURL:  thumbURL,
-		DeviceType:    deviceType,
-		Width:         info.Width,
-		Height:        info.Height,
-		FileSizeBytes: int64(len(input.ImageData)),
-		MimeType:      input.ContentType,
-		Status:        "active",
-		IsFeatured:    false,
+		ID:               wallpaperID,
+		UserID:           userUUID,
+		Title:            input.Title,
+		Description:      &input.Description,
+		OriginalURL:      imageURL,
+		OriginalKey:      imageKey,
+		ImageURL:         imageURL,
+		ThumbnailFormats: []string{},
+		DeviceType:       deviceType,
+		Width:            info.Width,
+		Height:           info.Height,
+		FileSizeBytes:    original.Size(),
+		MimeType:         contentType,
+		Status:           wallpaper.StatusProcessing,
+		IsFeatured:       false,
+		PHash:            &phash,
+		ChecksumSHA256:   &checksum,
+		FocalPoint:       input.FocalPoint,
 	}
 
 	if err := s.repo.Create(ctx, wp); err != nil {
@@ -121,22 +275,423 @@ func (s *WallpaperService) CreateWallpaper(ctx context.Context, input CreateWall
 	}
 
 	// Save Tags (keywords for search)
-	if len(input.Tags) > 0 {
-		var cleanTags []string
-		for _, t := range input.Tags {
-			if t != "" {
-				cleanTags = append(cleanTags, t)
+	if refs := uploadTagRefs(input.Tags); len(refs) > 0 {
+		if err := s.repo.AddTags(ctx, wallpaperID, refs); err != nil {
+			log.Printf("Failed to save tags for %s: %v", wallpaperID, err)
+		} else {
+			for _, ref := range refs {
+				wp.Tags = append(wp.Tags, ref.Name)
 			}
 		}
-		if len(cleanTags) > 0 {
-			_ = s.repo.AddTags(ctx, wallpaperID, cleanTags)
-			wp.Tags = cleanTags
+	}
+
+	// Queue suspected reposts for moderator review
+	if len(duplicates) > 0 {
+		if err := s.repo.AddDuplicates(ctx, wallpaperID, duplicates); err != nil {
+			_ = s.repo.SetStatus(ctx, wallpaperID, wallpaper.StatusFailed)
+			return nil, fmt.Errorf("failed to flag suspected duplicates: %w", err)
+		}
+	}
+
+	// Save camera details (model, lens, exposure) shown on the wallpaper page
+	if metadata != nil {
+		m := toMetadata(metadata)
+		if err := s.repo.SaveMetadata(ctx, wallpaperID, m); err != nil {
+			log.Printf("Failed to save metadata for %s: %v", wallpaperID, err)
+		} else {
+			wp.Metadata = m
 		}
 	}
 
+	if _, err := s.jobRepo.Enqueue(ctx, wallpaperID, s.maxJobAttempts); err != nil {
+		_ = s.repo.SetStatus(ctx, wallpaperID, wallpaper.StatusFailed)
+		return nil, fmt.Errorf("failed to queue image processing: %w", err)
+	}
+
+	// The upload already succeeded, a link that fails to sign is left out rather than failing it
+	if err := s.originals.Sign(ctx, wp); err != nil {
+		log.Printf("Failed to sign original URL of %s: %v", wallpaperID, err)
+		wp.OriginalURL, wp.ImageURL = "", ""
+	}
+
 	return wp, nil
 }
 
+// DirectUpload is a presigned URL a client PUTs an image to before calling FinalizeDirectUpload
+type DirectUpload struct {
+	UploadID     uuid.UUID         `json:"upload_id"`
+	UploadURL    string            `json:"upload_url"`
+	Method       string            `json:"method"`
+	Headers      map[string]string `json:"headers"`
+	MaxSizeBytes int64             `json:"max_size_bytes"`
+	ExpiresAt    time.Time         `json:"expires_at"`
+}
+
+// directUploadKey is where a user's direct upload is staged, scoped by user so
+// nobody can finalize someone else's object
+func directUploadKey(userID string, uploadID uuid.UUID) string {
+	return fmt.Sprintf("incoming/%s/%s", userID, uploadID)
+}
+
+// CreateDirectUpload returns a presigned PUT URL so the image goes straight to the bucket
+func (s *WallpaperService) CreateDirectUpload(ctx context.Context, userIDStr, contentType string) (*DirectUpload, error) {
+	if _, err := uuid.Parse(userIDStr); err != nil {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+
+	uploadID := uuid.New()
+	url, err := s.storage.GetPresignedPutURL(ctx, s.bucketOrigin, directUploadKey(userIDStr, uploadID), int(s.presignExpiry.Seconds()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload URL: %w", err)
+	}
+
+	headers := map[string]string{}
+	if contentType != "" {
+		headers["Content-Type"] = contentType
+	}
+
+	return &DirectUpload{
+		UploadID:     uploadID,
+		UploadURL:    url,
+		Method:       "PUT",
+		Headers:      headers,
+		MaxSizeBytes: s.processor.MaxSizeBytes(),
+		ExpiresAt:    time.Now().Add(s.presignExpiry),
+	}, nil
+}
+
+type FinalizeDirectUploadInput struct {
+	UserID      string
+	UploadID    string
+	Title       string
+	Description string
+	DeviceType  string
+	Tags        []string
+	FocalPoint  *wallpaper.FocalPoint
+}
+
+// FinalizeDirectUpload validates an image PUT to a presigned URL and creates the wallpaper from it.
+// The staged object is removed afterwards unless the server was too busy, so the client can retry.
+func (s *WallpaperService) FinalizeDirectUpload(ctx context.Context, input FinalizeDirectUploadInput) (*wallpaper.Wallpaper, error) {
+	uploadID, err := uuid.Parse(input.UploadID)
+	if err != nil {
+		return nil, ErrDirectUploadNotFound
+	}
+	key := directUploadKey(input.UserID, uploadID)
+
+	// Presigned PUTs cannot limit the size, check it before downloading anything
+	info, err := s.storage.Stat(ctx, s.bucketOrigin, key)
+	if errors.Is(err, storage.ErrObjectNotFound) {
+		return nil, ErrDirectUploadNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var wp *wallpaper.Wallpaper
+	if info.Size > s.processor.MaxSizeBytes() {
+		err = fmt.Errorf("invalid image: %w", &processor.ValidationError{
+			Kind:   processor.ErrImageTooLarge,
+			Detail: fmt.Sprintf("max %dMB", s.processor.MaxSizeBytes()/(1024*1024)),
+		})
+	} else {
+		var obj io.ReadCloser
+		obj, err = s.storage.Download(ctx, s.bucketOrigin, key)
+		if err != nil {
+			return nil, err
+		}
+		wp, err = s.CreateWallpaper(ctx, CreateWallpaperInput{
+			UserID:      input.UserID,
+			Title:       input.Title,
+			Description: input.Description,
+			DeviceType:  input.DeviceType,
+			Image:       obj,
+			ContentType: info.ContentType,
+			Tags:        input.Tags,
+			FocalPoint:  input.FocalPoint,
+		})
+		obj.Close()
+	}
+
+	if !errors.Is(err, ErrUploadBusy) {
+		if delErr := s.storage.Delete(context.WithoutCancel(ctx), s.bucketOrigin, key); delErr != nil {
+			log.Printf("Failed to delete staged upload %s: %v", key, delErr)
+		}
+	}
+	return wp, err
+}
+
+// acquireUploadSlot waits up to the queue timeout for a free upload slot, the returned func releases it
+func (s *WallpaperService) acquireUploadSlot(ctx context.Context) (func(), error) {
+	timer := time.NewTimer(s.uploadTimeout)
+	defer timer.Stop()
+
+	select {
+	case s.uploadSlots <- struct{}{}:
+		return func() { <-s.uploadSlots }, nil
+	case <-timer.C:
+		return nil, ErrUploadBusy
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// ProcessWallpaper generates thumbnails, blurhash and renditions from the stored original
+// and activates the wallpaper. It is safe to run again after a partial failure,
+// every object key and rendition row is overwritten.
+func (s *WallpaperService) ProcessWallpaper(ctx context.Context, wallpaperID uuid.UUID) error {
+	wp, err := s.repo.GetByID(ctx, wallpaperID)
+	if err != nil {
+		return fmt.Errorf("wallpaper not found: %w", err)
+	}
+	if wp.OriginalKey == "" {
+		return fmt.Errorf("wallpaper has no stored original")
+	}
+
+	// Originals can be as large as uploads, they are spooled to disk and decoded once for every derivative
+	obj, err := s.storage.Download(ctx, s.bucketOrigin, wp.OriginalKey)
+	if err != nil {
+		return fmt.Errorf("failed to download original: %w", err)
+	}
+	original, err := storage.Spool(obj, s.uploadTempDir, s.processor.MaxSizeBytes())
+	obj.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read original: %w", err)
+	}
+	defer original.Close()
+
+	img, err := s.processor.Decode(original, original.Size())
+	if err != nil {
+		return fmt.Errorf("failed to decode original: %w", err)
+	}
+
+	// Generate & Upload Thumbnails (compressed for fast loading), one per derivative format
+	// unless a lossless one comes out heavier than the JPEG, as it does for photos
+	thumbnails, err := s.processor.GenerateThumbnails(img, 400, 400)
+	if err != nil {
+		return fmt.Errorf("failed to generate thumbnails: %w", err)
+	}
+
+	var result wallpaper.ProcessingResult
+	for _, thumb := range thumbnails {
+		url, err := s.storage.Upload(ctx, s.bucketThumb, thumbnailKey(wp.OriginalKey, thumb.Format), bytes.NewReader(thumb.Data), int64(len(thumb.Data)), thumb.Format.ContentType())
+		if err != nil {
+			return fmt.Errorf("failed to upload thumbnail: %w", err)
+		}
+
+		// JPEG comes first and is the canonical thumbnail every client can display
+		if thumb.Format == processor.FormatJPEG {
+			result.ThumbnailURL = url
+		}
+		result.ThumbnailFormats = append(result.ThumbnailFormats, string(thumb.Format))
+	}
+
+	// Placeholder shown by clients while the thumbnail loads
+	result.Blurhash, err = s.processor.GenerateBlurhash(img)
+	if err != nil {
+		return fmt.Errorf("failed to generate blurhash: %w", err)
+	}
+
+	// Dominant colours for search by color
+	palette, err := s.processor.ExtractPalette(img)
+	if err != nil {
+		return fmt.Errorf("failed to extract palette: %w", err)
+	}
+	result.Palette = toPalette(palette)
+
+	// Generate & Upload Renditions so clients can pick the best size for their screen
+	generated, err := s.processor.GenerateRenditions(img)
+	if err != nil {
+		return fmt.Errorf("failed to generate renditions: %w", err)
+	}
+
+	renditions := make([]wallpaper.Rendition, 0, len(generated))
+	for _, rd := range generated {
+		key := fmt.Sprintf("%s/renditions/%s%s", wp.ID, rd.Name, rd.Format.Extension())
+		url, err := s.storage.Upload(ctx, s.bucketThumb, key, bytes.NewReader(rd.Data), int64(len(rd.Data)), rd.Format.ContentType())
+		if err != nil {
+			return fmt.Errorf("failed to upload rendition: %w", err)
+		}
+
+		renditions = append(renditions, wallpaper.Rendition{
+			Name:          rd.Name,
+			Format:        string(rd.Format),
+			Width:         rd.Width,
+			Height:        rd.Height,
+			FileSizeBytes: int64(len(rd.Data)),
+			URL:           url,
+			StorageKey:    key,
+		})
+	}
+
+	// Phone, tablet and ultrawide crops, stored with the renditions so they're served the same way
+	crops, err := s.processor.GenerateCrops(img, toFocalPoint(wp.FocalPoint))
+	if err != nil {
+		return fmt.Errorf("failed to generate crops: %w", err)
+	}
+
+	for _, rd := range crops {
+		key := fmt.Sprintf("%s/renditions/%s%s", wp.ID, rd.Name, rd.Format.Extension())
+		url, err := s.storage.Upload(ctx, s.bucketThumb, key, bytes.NewReader(rd.Data), int64(len(rd.Data)), rd.Format.ContentType())
+		if err != nil {
+			return fmt.Errorf("failed to upload crop: %w", err)
+		}
+
+		renditions = append(renditions, wallpaper.Rendition{
+			Name:          rd.Name,
+			Format:        string(rd.Format),
+			Width:         rd.Width,
+			Height:        rd.Height,
+			FileSizeBytes: int64(len(rd.Data)),
+			URL:           url,
+			StorageKey:    key,
+			Cropped:       true,
+		})
+	}
+
+	if err := s.repo.AddRenditions(ctx, wp.ID, renditions); err != nil {
+		return fmt.Errorf("failed to save renditions: %w", err)
+	}
+
+	if err := s.repo.CompleteProcessing(ctx, wp.ID, result); err != nil {
+		return fmt.Errorf("failed to activate wallpaper: %w", err)
+	}
+
+	return nil
+}
+
+// thumbnailKey is where the thumbnail of an original is stored in a format, next to the original.
+// Originals uploaded before the API assigned wallpaper IDs aren't under the wallpaper ID, neither are their thumbnails.
+func thumbnailKey(originalKey string, format processor.OutputFormat) string {
+	return strings.TrimSuffix(originalKey, path.Ext(originalKey)) + "_thumb" + format.Extension()
+}
+
+// MarkProcessingFailed flags a wallpaper whose processing job ran out of attempts
+func (s *WallpaperService) MarkProcessingFailed(ctx context.Context, wallpaperID uuid.UUID) error {
+	return s.repo.SetStatus(ctx, wallpaperID, wallpaper.StatusFailed)
+}
+
+// ProcessingStatus describes where a wallpaper is in the processing pipeline
+type ProcessingStatus struct {
+	WallpaperID uuid.UUID `json:"wallpaper_id"`
+	Status      string    `json:"status"`
+	Job         *job.Job  `json:"job,omitempty"`
+}
+
+// GetProcessingStatus returns the wallpaper status and its latest processing job (owner or moderator)
+func (s *WallpaperService) GetProcessingStatus(ctx context.Context, wallpaperIDStr, userIDStr, userRole string) (*ProcessingStatus, error) {
+	wallpaperID, err := uuid.Parse(wallpaperIDStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wallpaper ID")
+	}
+
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user ID")
+	}
+
+	wp, err := s.repo.GetByID(ctx, wallpaperID)
+	if err != nil {
+		return nil, fmt.Errorf("wallpaper not found")
+	}
+
+	isOwner := wp.UserID == userID
+	isModerator := userRole == "moderator" || userRole == "owner"
+	if !isOwner && !isModerator {
+		return nil, fmt.Errorf("you don't have permission to view this wallpaper")
+	}
+
+	status := &ProcessingStatus{WallpaperID: wp.ID, Status: wp.Status}
+
+	j, err := s.jobRepo.GetLatestByWallpaper(ctx, wallpaperID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get processing job: %w", err)
+	}
+	status.Job = j
+
+	return status, nil
+}
+
+// toMetadata converts extracted EXIF fields, leaving the ones the camera didn't write empty
+func toMetadata(m *processor.ImageMetadata) *wallpaper.Metadata {
+	result := &wallpaper.Metadata{}
+	if m.CameraMake != "" {
+		result.CameraMake = &m.CameraMake
+	}
+	if m.CameraModel != "" {
+		result.CameraModel = &m.CameraModel
+	}
+	if m.LensModel != "" {
+		result.LensModel = &m.LensModel
+	}
+	if m.FocalLength > 0 {
+		result.FocalLength = &m.FocalLength
+	}
+	if m.Aperture > 0 {
+		result.Aperture = &m.Aperture
+	}
+	if m.ISO > 0 {
+		result.ISO = &m.ISO
+	}
+	if m.ExposureTime != "" {
+		result.ExposureTime = &m.ExposureTime
+	}
+	return result
+}
+
+func toPalette(colors []processor.PaletteColor) []wallpaper.PaletteColor {
+	palette := make([]wallpaper.PaletteColor, 0, len(colors))
+	for _, c := range colors {
+		palette = append(palette, wallpaper.PaletteColor{
+			Hex:    c.Hex,
+			Weight: c.Weight,
+			L:      c.Lab.L,
+			A:      c.Lab.A,
+			B:      c.Lab.B,
+		})
+	}
+	return palette
+}
+
+// ParseFocalPoint parses the optional focal_x and focal_y upload fields, nil when neither is set
+func ParseFocalPoint(x, y string) (*wallpaper.FocalPoint, error) {
+	if x == "" && y == "" {
+		return nil, nil
+	}
+	fx, errX := strconv.ParseFloat(x, 64)
+	fy, errY := strconv.ParseFloat(y, 64)
+	focal := &wallpaper.FocalPoint{X: fx, Y: fy}
+	if errX != nil || errY != nil || !toFocalPoint(focal).Valid() {
+		return nil, ErrInvalidFocalPoint
+	}
+	return focal, nil
+}
+
+func toFocalPoint(f *wallpaper.FocalPoint) *processor.FocalPoint {
+	if f == nil {
+		return nil
+	}
+	return &processor.FocalPoint{X: f.X, Y: f.Y}
+}
+
+// colorFilter builds a palette filter for a hex color, nil when no color was requested
+func colorFilter(hex string) (*wallpaper.ColorFilter, error) {
+	if hex == "" {
+		return nil, nil
+	}
+	lab, err := processor.ParseHexColor(hex)
+	if err != nil {
+		return nil, ErrInvalidColor
+	}
+	return &wallpaper.ColorFilter{
+		L:           lab.L,
+		A:           lab.A,
+		B:           lab.B,
+		MaxDistance: colorMatchDistance,
+		MinWeight:   colorMatchMinWeight,
+	}, nil
+}
+
 // slugify converts title to URL-safe slug
 func slugify(title string) string {
 	// Simple slugify: lowercase, replace spaces with hyphens, remove special chars
@@ -166,7 +721,15 @@ func slugify(title string) string {
 	return slug
 }
 
-func (s *WallpaperService) ListWallpapers(ctx context.Context, page, limit int) ([]*wallpaper.Wallpaper, int, error) {
+// ListWallpapers lists wallpapers matching the filters, newest first by default.
+// With a screen filter the wallpapers needing the least cropping to fit the screen come first.
+// Pages continue after cursor when one is given, page is only used without it.
+func (s *WallpaperService) ListWallpapers(ctx context.Context, query ListQuery, sort SortQuery, cursor string, page, limit int) (*WallpaperPage, error) {
+	filter, err := buildFilter(query)
+	if err != nil {
+		return nil, err
+	}
+
 	if page < 1 {
 		page = 1
 	}
@@ -174,10 +737,81 @@ func (s *WallpaperService) ListWallpapers(ctx context.Context, page, limit int)
 		limit = 20
 	}
 	offset := (page - 1) * limit
-	return s.repo.List(ctx, limit, offset)
+
+	def := wallpaper.SortNewest
+	if filter.ScreenWidth > 0 {
+		def = wallpaper.SortFit
+	}
+	return s.facetedPage(ctx, filter, sort, def, cursor, page, limit, offset)
 }
 
-func (s *WallpaperService) GetWallpaper(ctx context.Context, idStr string) (*wallpaper.Wallpaper, error) {
+// facetedPage fetches a page of a listing, with facets on the first page
+func (s *WallpaperService) facetedPage(ctx context.Context, filter wallpaper.Filter, sort SortQuery, def, cursor string, page, limit, offset int) (*WallpaperPage, error) {
+	result, err := s.listPage(ctx, filter, sort, def, cursor, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	if cursor == "" && page == 1 {
+		if result.Facets, err = s.repo.Facets(ctx, filter); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// listPage fetches a page of a listing in the requested order, def when none is requested,
+// after the cursor when one is given or else at offset
+func (s *WallpaperService) listPage(ctx context.Context, filter wallpaper.Filter, sort SortQuery, def, cursor string, limit, offset int) (*WallpaperPage, error) {
+	order, err := buildOrder(sort, def, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := decodeCursor[wallpaper.Cursor](cursor)
+	if err != nil {
+		return nil, err
+	}
+	// Keyset positions only make sense in the order they were taken from,
+	// a random order goes on with the seed of the cursor
+	if after != nil {
+		if after.Sort != order.Sort || after.ID == uuid.Nil {
+			return nil, ErrInvalidCursor
+		}
+		if order.Sort == wallpaper.SortRandom {
+			order.Seed = after.Seed
+		}
+	}
+
+	wallpapers, total, next, err := s.repo.List(ctx, filter, order, after, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &WallpaperPage{Wallpapers: wallpapers, Total: total, NextCursor: encodeCursor(next), Sort: order.Sort}
+	if order.Sort == wallpaper.SortRandom {
+		result.Seed = &order.Seed
+	}
+	return result, nil
+}
+
+// GetWallpaper returns a wallpaper visible to the user (see canView), userIDStr and userRole are empty for anonymous requests
+func (s *WallpaperService) GetWallpaper(ctx context.Context, idStr, userIDStr, userRole string) (*wallpaper.Wallpaper, error) {
+	wp, err := s.getVisibleWallpaper(ctx, idStr, userIDStr, userRole)
+	if err != nil {
+		return nil, err
+	}
+
+	wp.Metadata, err = s.repo.GetMetadata(ctx, wp.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.originals.Sign(ctx, wp); err != nil {
+		return nil, err
+	}
+	return wp, nil
+}
+
+func (s *WallpaperService) getWallpaper(ctx context.Context, idStr string) (*wallpaper.Wallpaper, error) {
 	id, err := uuid.Parse(idStr)
 	if err != nil {
 		return nil, fmt.Errorf("invalid wallpaper ID")
@@ -185,6 +819,126 @@ func (s *WallpaperService) GetWallpaper(ctx context.Context, idStr string) (*wal
 	return s.repo.GetByID(ctx, id)
 }
 
+// getVisibleWallpaper returns ErrWallpaperNotFound for missing wallpapers and ones the user can't view
+func (s *WallpaperService) getVisibleWallpaper(ctx context.Context, idStr, userIDStr, userRole string) (*wallpaper.Wallpaper, error) {
+	wp, err := s.getWallpaper(ctx, idStr)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWallpaperNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !canView(wp, userIDStr, userRole) {
+		return nil, ErrWallpaperNotFound
+	}
+	return wp, nil
+}
+
+// canView reports whether a user may see a wallpaper: active ones are public, wallpapers still
+// processing or that failed to process only show to their owner and moderators
+func canView(wp *wallpaper.Wallpaper, userIDStr, userRole string) bool {
+	if wp.Status == wallpaper.StatusActive {
+		return true
+	}
+	return wp.UserID.String() == userIDStr || userRole == "moderator" || userRole == "owner"
+}
+
+// GetThumbnailURL returns the thumbnail URL in the best format the client accepts
+func (s *WallpaperService) GetThumbnailURL(ctx context.Context, idStr, userIDStr, userRole, accept string) (string, error) {
+	wp, err := s.getVisibleWallpaper(ctx, idStr, userIDStr, userRole)
+	if err != nil {
+		return "", err
+	}
+	if wp.ThumbnailURL == "" {
+		return "", ErrWallpaperNotFound
+	}
+
+	var available []processor.OutputFormat
+	for _, name := range wp.ThumbnailFormats {
+		if format, err := processor.ParseOutputFormat(name); err == nil {
+			available = append(available, format)
+		}
+	}
+
+	format := processor.NegotiateFormat(accept, available)
+	if format == processor.FormatJPEG {
+		return wp.ThumbnailURL, nil
+	}
+
+	// Variants share the JPEG thumbnail's key and only differ by extension
+	return strings.TrimSuffix(wp.ThumbnailURL, processor.FormatJPEG.Extension()) + format.Extension(), nil
+}
+
+// GetRenditionURL returns the URL of a named rendition in the best format the client accepts
+func (s *WallpaperService) GetRenditionURL(ctx context.Context, idStr, userIDStr, userRole, name, accept string) (string, error) {
+	wp, err := s.getVisibleWallpaper(ctx, idStr, userIDStr, userRole)
+	if err != nil {
+		return "", err
+	}
+
+	urls := make(map[processor.OutputFormat]string)
+	var available []processor.OutputFormat
+	for _, rd := range wp.Renditions {
+		if rd.Name != name {
+			continue
+		}
+		if format, err := processor.ParseOutputFormat(rd.Format); err == nil {
+			urls[format] = rd.URL
+			available = append(available, format)
+		}
+	}
+
+	if len(available) == 0 {
+		return "", ErrRenditionNotFound
+	}
+
+	if url, ok := urls[processor.NegotiateFormat(accept, available)]; ok {
+		return url, nil
+	}
+	return urls[available[0]], nil
+}
+
+// GetDownloadURL returns a short-lived presigned URL of the original, the JPEG thumbnail
+// or a rendition (preferring JPEG) of an active wallpaper
+func (s *WallpaperService) GetDownloadURL(ctx context.Context, idStr, size string) (string, error) {
+	wp, err := s.getWallpaper(ctx, idStr)
+	if err != nil || wp.Status != wallpaper.StatusActive {
+		return "", ErrWallpaperNotFound
+	}
+
+	var bucket, key string
+	switch size {
+	case "", DownloadOriginal:
+		bucket, key = s.bucketOrigin, wp.OriginalKey
+	case DownloadThumbnail:
+		if wp.OriginalKey != "" {
+			bucket, key = s.bucketThumb, thumbnailKey(wp.OriginalKey, processor.FormatJPEG)
+		}
+	default:
+		for _, rd := range wp.Renditions {
+			if rd.Name != size {
+				continue
+			}
+			if key == "" || rd.Format == string(processor.FormatJPEG) {
+				bucket, key = s.bucketThumb, rd.StorageKey
+			}
+		}
+		if key == "" {
+			return "", ErrUnknownSize
+		}
+	}
+
+	if key == "" {
+		return "", ErrWallpaperNotFound
+	}
+
+	url, err := s.storage.GetPresignedURL(ctx, bucket, key, int(downloadURLExpiry.Seconds()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create download URL: %w", err)
+	}
+	return url, nil
+}
+
 // UpdateWallpaper updates wallpaper metadata (owner only)
 func (s *WallpaperService) UpdateWallpaper(ctx context.Context, wallpaperIDStr, userIDStr string, title, description *string) error {
 	wallpaperID, err := uuid.Parse(wallpaperIDStr)
@@ -237,7 +991,7 @@ func (s *WallpaperService) DeleteWallpaper(ctx context.Context, wallpaperIDStr,
 		return fmt.Errorf("you don't have permission to delete this wallpaper")
 	}
 
-	return s.repo.SoftDelete(ctx, wallpaperID)
+	return s.repo.SoftDelete(ctx, wallpaperID, userID)
 }
 
 // SetFeaturedStatus toggles featured status (moderator only)
@@ -256,8 +1010,20 @@ func (s *WallpaperService) SetFeaturedStatus(ctx context.Context, wallpaperIDStr
 	return s.repo.SetFeaturedStatus(ctx, wallpaperID, isFeatured)
 }
 
+// ListDuplicateClusters lists uploads flagged as near-duplicates, grouped by original (moderator only)
+func (s *WallpaperService) ListDuplicateClusters(ctx context.Context, page, limit int) ([]*wallpaper.DuplicateCluster, int, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 50 {
+		limit = 20
+	}
+	offset := (page - 1) * limit
+	return s.repo.ListDuplicateClusters(ctx, limit, offset)
+}
+
 // ListFeaturedWallpapers retrieves all featured wallpapers with pagination
-func (s *WallpaperService) ListFeaturedWallpapers(ctx context.Context, page, limit int) ([]*wallpaper.Wallpaper, int, error) {
+func (s *WallpaperService) ListFeaturedWallpapers(ctx context.Context, sort SortQuery, cursor string, page, limit int) (*WallpaperPage, error) {
 	if page < 1 {
 		page = 1
 	}
@@ -265,19 +1031,25 @@ func (s *WallpaperService) ListFeaturedWallpapers(ctx context.Context, page, lim
 		limit = 20
 	}
 	offset := (page - 1) * limit
-	return s.repo.ListFeatured(ctx, limit, offset)
+	return s.listPage(ctx, wallpaper.Filter{Featured: true}, sort, wallpaper.SortNewest, cursor, limit, offset)
 }
 
-// SearchWallpapers performs full-text search on wallpapers
-func (s *WallpaperService) SearchWallpapers(ctx context.Context, query string, page, limit int) ([]*wallpaper.Wallpaper, int, error) {
+// SearchWallpapers performs full-text search on wallpapers matching the filters, best match first by default
+func (s *WallpaperService) SearchWallpapers(ctx context.Context, query string, filters ListQuery, sort SortQuery, cursor string, page, limit int) (*WallpaperPage, error) {
 	// Validate query
 	query = strings.TrimSpace(query)
 	if len(query) < 2 {
-		return nil, 0, fmt.Errorf("search query must be at least 2 characters")
+		return nil, fmt.Errorf("%w: must be at least 2 characters", ErrInvalidSearchQuery)
 	}
 	if len(query) > 100 {
-		return nil, 0, fmt.Errorf("search query must be at most 100 characters")
+		return nil, fmt.Errorf("%w: must be at most 100 characters", ErrInvalidSearchQuery)
+	}
+
+	filter, err := buildFilter(filters)
+	if err != nil {
+		return nil, err
 	}
+	filter.Search = query
 
 	// Validate pagination
 	if page < 1 {
@@ -288,15 +1060,15 @@ func (s *WallpaperService) SearchWallpapers(ctx context.Context, query string, p
 	}
 	offset := (page - 1) * limit
 
-	return s.repo.Search(ctx, query, limit, offset)
+	return s.facetedPage(ctx, filter, sort, wallpaper.SortRelevance, cursor, page, limit, offset)
 }
 
 // GetWallpapersByTag retrieves wallpapers filtered by tag slug
-func (s *WallpaperService) GetWallpapersByTag(ctx context.Context, tagSlug string, page, limit int) ([]*wallpaper.Wallpaper, int, error) {
+func (s *WallpaperService) GetWallpapersByTag(ctx context.Context, tagSlug string, sort SortQuery, cursor string, page, limit int) (*WallpaperPage, error) {
 	// Validate tag slug (alphanumeric + hyphens only)
 	tagSlug = strings.TrimSpace(strings.ToLower(tagSlug))
 	if tagSlug == "" {
-		return nil, 0, fmt.Errorf("tag slug cannot be empty")
+		return nil, fmt.Errorf("%w: tag slug cannot be empty", ErrInvalidFilter)
 	}
 
 	// Validate pagination
@@ -308,14 +1080,14 @@ func (s *WallpaperService) GetWallpapersByTag(ctx context.Context, tagSlug strin
 	}
 	offset := (page - 1) * limit
 
-	return s.repo.ListByTag(ctx, tagSlug, limit, offset)
+	return s.listPage(ctx, wallpaper.Filter{Tags: []string{tagSlug}}, sort, wallpaper.SortNewest, cursor, limit, offset)
 }
 
 // GetUserWallpapers retrieves all wallpapers uploaded by a specific user
-func (s *WallpaperService) GetUserWallpapers(ctx context.Context, userIDStr string, page, limit int) ([]*wallpaper.Wallpaper, int, error) {
+func (s *WallpaperService) GetUserWallpapers(ctx context.Context, userIDStr string, sort SortQuery, cursor string, page, limit int) (*WallpaperPage, error) {
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		return nil, 0, fmt.Errorf("invalid user ID")
+		return nil, fmt.Errorf("invalid user ID")
 	}
 
 	// Validate pagination
@@ -327,11 +1099,11 @@ func (s *WallpaperService) GetUserWallpapers(ctx context.Context, userIDStr stri
 	}
 	offset := (page - 1) * limit
 
-	return s.repo.ListByUser(ctx, userID, limit, offset)
+	return s.listPage(ctx, wallpaper.Filter{UserID: &userID}, sort, wallpaper.SortNewest, cursor, limit, offset)
 }
 
-// GetTrendingWallpapers retrieves trending wallpapers (by like count and recency)
-func (s *WallpaperService) GetTrendingWallpapers(ctx context.Context, page, limit int) ([]*wallpaper.Wallpaper, int, error) {
+// GetTrendingWallpapers retrieves trending wallpapers (recent ones, most liked first by default)
+func (s *WallpaperService) GetTrendingWallpapers(ctx context.Context, sort SortQuery, cursor string, page, limit int) (*WallpaperPage, error) {
 	// Validate pagination
 	if page < 1 {
 		page = 1
@@ -341,5 +1113,15 @@ func (s *WallpaperService) GetTrendingWallpapers(ctx context.Context, page, limi
 	}
 	offset := (page - 1) * limit
 
-	return s.repo.ListTrending(ctx, limit, offset)
+	// Most liked of the last 30 days, later pages keep the window of the first one
+	// so wallpapers don't age out of it (and pages don't shift) while scrolling
+	since := time.Now().AddDate(0, 0, -30)
+	after, err := decodeCursor[wallpaper.Cursor](cursor)
+	if err != nil {
+		return nil, err
+	}
+	if after != nil && after.Since != nil {
+		since = *after.Since
+	}
+	return s.listPage(ctx, wallpaper.Filter{CreatedAfter: &since}, sort, wallpaper.SortMostLiked, cursor, limit, offset)
 }
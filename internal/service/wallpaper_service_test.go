@@ -0,0 +1,1069 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pavelc4/pixtify/internal/config"
+	"github.com/pavelc4/pixtify/internal/processor"
+	"github.com/pavelc4/pixtify/internal/repository/postgres/wallpaper"
+	"github.com/pavelc4/pixtify/internal/storage"
+)
+
+const (
+	testBucketOrigin = "originals"
+	testBucketThumb  = "thumbnails"
+)
+
+type wallpaperFixture struct {
+	service *WallpaperService
+	repo    *fakeWallpaperRepo
+	jobs    *fakeJobRepo
+	storage *storage.MemoryStorage
+}
+
+func newWallpaperFixture(t *testing.T, duplicateMode string) *wallpaperFixture {
+	t.Helper()
+
+	f := &wallpaperFixture{
+		repo:    newFakeWallpaperRepo(),
+		jobs:    &fakeJobRepo{},
+		storage: storage.NewMemoryStorage(),
+	}
+	f.service = NewWallpaperService(
+		f.repo, f.jobs, f.storage,
+		processor.NewImageProcessor(config.ImageConfig{}),
+		testBucketOrigin, testBucketThumb, 3,
+		config.DuplicateConfig{Mode: duplicateMode, MaxDistance: 6},
+		UploadOptions{MaxConcurrent: 2, QueueTimeout: time.Second, TempDir: t.TempDir()},
+		nil,
+	)
+	return f
+}
+
+// testJPEG encodes a gradient so perceptual hashes of different seeds differ
+func testJPEG(t *testing.T, width, height int, seed uint8) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x*255/width) ^ seed, G: uint8(y * 255 / height), B: seed, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatalf("encode jpeg: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestCreateWallpaper(t *testing.T) {
+	userID := uuid.New().String()
+	valid := testJPEG(t, 320, 200, 0)
+
+	tests := []struct {
+		name    string
+		userID  string
+		image   []byte
+		wantErr string
+		isValid bool // error wraps a processor.ValidationError
+	}{
+		{name: "valid jpeg", userID: userID, image: valid},
+		{name: "invalid user", userID: "not-a-uuid", image: valid, wantErr: "invalid user ID"},
+		{name: "not an image", userID: userID, image: []byte("definitely not an image"), wantErr: "invalid image", isValid: true},
+		{name: "empty upload", userID: userID, image: nil, wantErr: "invalid image", isValid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newWallpaperFixture(t, DuplicateModeFlag)
+
+			wp, err := f.service.CreateWallpaper(context.Background(), CreateWallpaperInput{
+				UserID:      tt.userID,
+				Title:       "Mountain Lake",
+				Description: "At dawn",
+				DeviceType:  "desktop",
+				Image:       bytes.NewReader(tt.image),
+				ContentType: "image/jpeg",
+				Tags:        []string{"nature", "", "lake"},
+			})
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				var validation *processor.ValidationError
+				if got := errors.As(err, &validation); got != tt.isValid {
+					t.Errorf("errors.As(ValidationError) = %v, want %v", got, tt.isValid)
+				}
+				if keys := f.storage.Keys(testBucketOrigin); len(keys) != 0 {
+					t.Errorf("stored %v for a rejected upload", keys)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateWallpaper: %v", err)
+			}
+
+			if wp.Status != wallpaper.StatusProcessing {
+				t.Errorf("status = %q, want %q", wp.Status, wallpaper.StatusProcessing)
+			}
+			if wp.Width != 320 || wp.Height != 200 {
+				t.Errorf("size = %dx%d, want 320x200", wp.Width, wp.Height)
+			}
+			if wp.ChecksumSHA256 == nil || len(*wp.ChecksumSHA256) != 64 {
+				t.Errorf("checksum = %v, want a sha256 hex digest", wp.ChecksumSHA256)
+			}
+			if got := strings.Join(wp.Tags, ","); got != "nature,lake" {
+				t.Errorf("tags = %q, want empty tags dropped", got)
+			}
+
+			info, err := f.storage.Stat(context.Background(), testBucketOrigin, wp.OriginalKey)
+			if err != nil {
+				t.Fatalf("original not stored: %v", err)
+			}
+			if info.Size != wp.FileSizeBytes || info.ContentType != "image/jpeg" {
+				t.Errorf("stored %d bytes as %q, want %d bytes as image/jpeg", info.Size, info.ContentType, wp.FileSizeBytes)
+			}
+
+			if len(f.jobs.jobs) != 1 || f.jobs.jobs[0].WallpaperID != wp.ID || f.jobs.jobs[0].MaxAttempts != 3 {
+				t.Errorf("jobs = %+v, want one processing job for the wallpaper", f.jobs.jobs)
+			}
+		})
+	}
+}
+
+func TestCreateWallpaperPrivateOriginals(t *testing.T) {
+	f := newWallpaperFixture(t, DuplicateModeOff)
+	f.service.originals = NewOriginalLinks(storage.NewPresignSigner(f.storage), testBucketOrigin, time.Hour)
+
+	wp, err := f.service.CreateWallpaper(context.Background(), CreateWallpaperInput{
+		UserID:      uuid.New().String(),
+		Title:       "Mountain Lake",
+		DeviceType:  "desktop",
+		Image:       bytes.NewReader(testJPEG(t, 320, 200, 0)),
+		ContentType: "image/jpeg",
+	})
+	if err != nil {
+		t.Fatalf("CreateWallpaper: %v", err)
+	}
+
+	// The response links the original with a signed URL, the row never holds a bucket URL
+	if !strings.Contains(wp.OriginalURL, "expires=") || wp.ImageURL != wp.OriginalURL {
+		t.Errorf("response urls = %q, %q, want signed URLs", wp.OriginalURL, wp.ImageURL)
+	}
+	stored := f.repo.wallpapers[wp.ID]
+	if stored.OriginalURL != "" || stored.ImageURL != "" {
+		t.Errorf("stored urls = %q, %q, want none", stored.OriginalURL, stored.ImageURL)
+	}
+	if stored.OriginalKey != wp.OriginalKey || stored.OriginalKey == "" {
+		t.Errorf("stored original_key = %q, want %q", stored.OriginalKey, wp.OriginalKey)
+	}
+}
+
+func TestCreateWallpaperTags(t *testing.T) {
+	f := newWallpaperFixture(t, DuplicateModeOff)
+	ctx := context.Background()
+
+	upload := func(tags ...string) *wallpaper.Wallpaper {
+		t.Helper()
+		wp, err := f.service.CreateWallpaper(ctx, CreateWallpaperInput{
+			UserID:      uuid.New().String(),
+			Title:       "Tagged",
+			Image:       bytes.NewReader(testJPEG(t, 320, 200, uint8(len(tags)))),
+			ContentType: "image/jpeg",
+			Tags:        tags,
+		})
+		if err != nil {
+			t.Fatalf("CreateWallpaper: %v", err)
+		}
+		return wp
+	}
+
+	first := upload("  Mountain Lake ", "mountain-lake", "", "!!!", strings.Repeat("x", 51), "Sci-Fi")
+	second := upload("mountain lake", "Dawn")
+
+	// Names are trimmed and names mapping to an existing slug are the same tag
+	if want := []string{"Mountain Lake", "Sci-Fi"}; !slices.Equal(first.Tags, want) {
+		t.Errorf("tags = %q, want %q", first.Tags, want)
+	}
+	if want := []string{"mountain lake", "Dawn"}; !slices.Equal(second.Tags, want) {
+		t.Errorf("tags = %q, want %q", second.Tags, want)
+	}
+
+	want := map[string]int{"mountain-lake": 2, "sci-fi": 1, "dawn": 1}
+	if !maps.Equal(f.repo.tagCounts, want) {
+		t.Errorf("wallpaper counts = %v, want %v", f.repo.tagCounts, want)
+	}
+}
+
+func TestCreateWallpaperDuplicates(t *testing.T) {
+	tests := []struct {
+		mode           string
+		wantErr        bool
+		wantDuplicates int
+	}{
+		{mode: DuplicateModeReject, wantErr: true},
+		{mode: DuplicateModeFlag, wantDuplicates: 1},
+		{mode: DuplicateModeOff},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			f := newWallpaperFixture(t, tt.mode)
+			data := testJPEG(t, 320, 200, 0)
+			input := CreateWallpaperInput{UserID: uuid.New().String(), Title: "Repost", ContentType: "image/jpeg"}
+
+			input.Image = bytes.NewReader(data)
+			first, err := f.service.CreateWallpaper(context.Background(), input)
+			if err != nil {
+				t.Fatalf("first upload: %v", err)
+			}
+
+			input.Image = bytes.NewReader(data)
+			second, err := f.service.CreateWallpaper(context.Background(), input)
+
+			var dup *DuplicateError
+			if tt.wantErr {
+				if !errors.As(err, &dup) || dup.DuplicateOf != first.ID {
+					t.Fatalf("err = %v, want DuplicateError of %s", err, first.ID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("second upload: %v", err)
+			}
+			if got := len(f.repo.duplicates[second.ID]); got != tt.wantDuplicates {
+				t.Errorf("flagged %d duplicates, want %d", got, tt.wantDuplicates)
+			}
+		})
+	}
+}
+
+func TestCreateWallpaperQueueFailure(t *testing.T) {
+	f := newWallpaperFixture(t, DuplicateModeOff)
+	f.jobs.err = errors.New("queue down")
+
+	_, err := f.service.CreateWallpaper(context.Background(), CreateWallpaperInput{
+		UserID:      uuid.New().String(),
+		Title:       "Orphan",
+		Image:       bytes.NewReader(testJPEG(t, 320, 200, 0)),
+		ContentType: "image/jpeg",
+	})
+	if err == nil {
+		t.Fatal("expected an error when the job cannot be queued")
+	}
+
+	// The record must not be left in processing forever
+	for _, w := range f.repo.wallpapers {
+		if w.Status != wallpaper.StatusFailed {
+			t.Errorf("status = %q, want %q", w.Status, wallpaper.StatusFailed)
+		}
+	}
+}
+
+func TestCreateWallpaperFlagFailure(t *testing.T) {
+	f := newWallpaperFixture(t, DuplicateModeFlag)
+	data := testJPEG(t, 320, 200, 0)
+	input := CreateWallpaperInput{UserID: uuid.New().String(), Title: "Repost", ContentType: "image/jpeg"}
+
+	input.Image = bytes.NewReader(data)
+	first, err := f.service.CreateWallpaper(context.Background(), input)
+	if err != nil {
+		t.Fatalf("first upload: %v", err)
+	}
+
+	// A repost that can't be queued for review must not go live unflagged
+	f.repo.flagErr = errors.New("db down")
+	input.Image = bytes.NewReader(data)
+	if _, err := f.service.CreateWallpaper(context.Background(), input); !errors.Is(err, f.repo.flagErr) {
+		t.Fatalf("err = %v, want the flagging error", err)
+	}
+
+	for id, w := range f.repo.wallpapers {
+		if id != first.ID && w.Status != wallpaper.StatusFailed {
+			t.Errorf("status = %q, want %q", w.Status, wallpaper.StatusFailed)
+		}
+	}
+	if len(f.jobs.jobs) != 1 {
+		t.Errorf("queued %d jobs, want only the first upload's", len(f.jobs.jobs))
+	}
+}
+
+func TestFinalizeDirectUpload(t *testing.T) {
+	userID := uuid.New().String()
+	otherID := uuid.New().String()
+
+	tests := []struct {
+		name      string
+		stagedBy  string // user whose prefix the object is PUT under, empty for none
+		finalizer string
+		data      []byte
+		wantErr   error
+	}{
+		{name: "valid upload", stagedBy: userID, finalizer: userID, data: testJPEG(t, 320, 200, 0)},
+		{name: "nothing uploaded", finalizer: userID, wantErr: ErrDirectUploadNotFound},
+		{name: "someone else's upload", stagedBy: otherID, finalizer: userID, data: testJPEG(t, 320, 200, 0), wantErr: ErrDirectUploadNotFound},
+		{name: "not an image", stagedBy: userID, finalizer: userID, data: []byte("nope"), wantErr: processor.ErrUnsupportedType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newWallpaperFixture(t, DuplicateModeOff)
+			ctx := context.Background()
+
+			upload, err := f.service.CreateDirectUpload(ctx, userID, "image/jpeg")
+			if err != nil {
+				t.Fatalf("CreateDirectUpload: %v", err)
+			}
+			key := directUploadKey(tt.stagedBy, upload.UploadID)
+			if tt.stagedBy != "" {
+				if _, err := f.storage.Upload(ctx, testBucketOrigin, key, bytes.NewReader(tt.data), int64(len(tt.data)), "image/jpeg"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			wp, err := f.service.FinalizeDirectUpload(ctx, FinalizeDirectUploadInput{
+				UserID:   tt.finalizer,
+				UploadID: upload.UploadID.String(),
+				Title:    "Direct",
+			})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+			} else if err != nil || wp == nil {
+				t.Fatalf("FinalizeDirectUpload: %v", err)
+			}
+
+			// The staged object is consumed whenever the finalizer owned it
+			_, statErr := f.storage.Stat(ctx, testBucketOrigin, key)
+			if tt.stagedBy == tt.finalizer && !errors.Is(statErr, storage.ErrObjectNotFound) {
+				t.Errorf("staged object %s was not deleted", key)
+			}
+		})
+	}
+}
+
+func TestWallpaperPermissions(t *testing.T) {
+	ownerID := uuid.New()
+	otherID := uuid.New()
+
+	tests := []struct {
+		name       string
+		userID     string
+		role       string
+		wantUpdate string // expected error, empty for success
+		wantDelete string
+	}{
+		{name: "owner", userID: ownerID.String(), role: "user"},
+		{name: "other user", userID: otherID.String(), role: "user",
+			wantUpdate: "you don't own this wallpaper", wantDelete: "you don't have permission to delete this wallpaper"},
+		{name: "moderator", userID: otherID.String(), role: "moderator", wantUpdate: "you don't own this wallpaper"},
+		{name: "site owner", userID: otherID.String(), role: "owner", wantUpdate: "you don't own this wallpaper"},
+		{name: "invalid user", userID: "nope", role: "moderator", wantUpdate: "invalid user ID", wantDelete: "invalid user ID"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newWallpaperFixture(t, DuplicateModeOff)
+			ctx := context.Background()
+			wp := f.repo.add(ownerID, "Original title")
+			title := "New title"
+
+			err := f.service.UpdateWallpaper(ctx, wp.ID.String(), tt.userID, &title, nil)
+			checkErr(t, "UpdateWallpaper", err, tt.wantUpdate)
+
+			err = f.service.DeleteWallpaper(ctx, wp.ID.String(), tt.userID, tt.role)
+			checkErr(t, "DeleteWallpaper", err, tt.wantDelete)
+
+			_, err = f.repo.GetByID(ctx, wp.ID)
+			if deleted := err != nil; deleted != (tt.wantDelete == "") {
+				t.Errorf("deleted = %v, want %v", deleted, tt.wantDelete == "")
+			}
+		})
+	}
+
+	t.Run("missing wallpaper", func(t *testing.T) {
+		f := newWallpaperFixture(t, DuplicateModeOff)
+		err := f.service.DeleteWallpaper(context.Background(), uuid.New().String(), ownerID.String(), "owner")
+		checkErr(t, "DeleteWallpaper", err, "wallpaper not found")
+	})
+}
+
+func TestGetProcessingStatus(t *testing.T) {
+	ownerID := uuid.New()
+
+	tests := []struct {
+		name    string
+		userID  string
+		role    string
+		wantErr string
+	}{
+		{name: "owner", userID: ownerID.String(), role: "user"},
+		{name: "moderator", userID: uuid.New().String(), role: "moderator"},
+		{name: "other user", userID: uuid.New().String(), role: "user", wantErr: "you don't have permission to view this wallpaper"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newWallpaperFixture(t, DuplicateModeOff)
+			ctx := context.Background()
+			wp := f.repo.add(ownerID, "Queued")
+			if _, err := f.jobs.Enqueue(ctx, wp.ID, 3); err != nil {
+				t.Fatal(err)
+			}
+
+			status, err := f.service.GetProcessingStatus(ctx, wp.ID.String(), tt.userID, tt.role)
+			checkErr(t, "GetProcessingStatus", err, tt.wantErr)
+			if err == nil && (status.Job == nil || status.Job.WallpaperID != wp.ID) {
+				t.Errorf("job = %+v, want the wallpaper's job", status.Job)
+			}
+		})
+	}
+}
+
+func TestProcessWallpaperCrops(t *testing.T) {
+	// Flat desktop wallpaper with all of its detail in a checkered patch on the right
+	img := image.NewRGBA(image.Rect(0, 0, 1600, 900))
+	for y := 0; y < 900; y++ {
+		for x := 0; x < 1600; x++ {
+			c := color.RGBA{R: 90, G: 110, B: 140, A: 255}
+			if x >= 1200 && x < 1500 && y >= 300 && y < 600 && (x/10+y/10)%2 == 0 {
+				c = color.RGBA{R: 250, G: 200, B: 30, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		focal      *wallpaper.FocalPoint
+		wantDetail bool // the phone crop shows the checkered patch
+	}{
+		{name: "salient region", wantDetail: true},
+		{name: "focal point", focal: &wallpaper.FocalPoint{X: 0.1, Y: 0.5}, wantDetail: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newWallpaperFixture(t, DuplicateModeOff)
+			f.service.processor = processor.NewImageProcessor(config.ImageConfig{
+				Crops: []string{"phone:1080x2340", "tablet:2048x2732", "ultrawide:3440x1440", "wide:1920x1080"},
+			})
+
+			wp, err := f.service.CreateWallpaper(ctx, CreateWallpaperInput{
+				UserID:      uuid.New().String(),
+				Title:       "Desk",
+				Image:       bytes.NewReader(buf.Bytes()),
+				ContentType: "image/jpeg",
+				FocalPoint:  tt.focal,
+			})
+			if err != nil {
+				t.Fatalf("CreateWallpaper: %v", err)
+			}
+			if err := f.service.ProcessWallpaper(ctx, wp.ID); err != nil {
+				t.Fatalf("ProcessWallpaper: %v", err)
+			}
+
+			// The original is already 16:9, so no "wide" crop
+			sizes := map[string]string{}
+			for _, rd := range f.repo.renditions[wp.ID] {
+				if !rd.Cropped {
+					t.Errorf("rendition %s not marked as cropped", rd.Name)
+				}
+				sizes[rd.Name] = fmt.Sprintf("%dx%d", rd.Width, rd.Height)
+			}
+			want := map[string]string{"phone": "415x900", "tablet": "675x900", "ultrawide": "1600x670"}
+			if fmt.Sprint(sizes) != fmt.Sprint(want) {
+				t.Errorf("crops = %v, want %v", sizes, want)
+			}
+
+			obj, err := f.storage.Download(ctx, testBucketThumb, wp.ID.String()+"/renditions/phone.jpg")
+			if err != nil {
+				t.Fatalf("phone crop not stored: %v", err)
+			}
+			defer obj.Close()
+			phone, err := jpeg.Decode(obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			detail := false
+			for x := 0; x < phone.Bounds().Dx(); x++ {
+				if r, _, _, _ := phone.At(x, 450).RGBA(); r>>8 > 200 {
+					detail = true
+					break
+				}
+			}
+			if detail != tt.wantDetail {
+				t.Errorf("phone crop shows the detailed patch = %v, want %v", detail, tt.wantDetail)
+			}
+		})
+	}
+}
+
+// countingSigner signs nothing, it counts the originals a service tried to link
+type countingSigner struct{ signed int }
+
+func (s *countingSigner) SignURL(ctx context.Context, bucket, key string, expiry time.Duration) (string, error) {
+	s.signed++
+	return "signed://" + key, nil
+}
+
+func TestWallpaperVisibility(t *testing.T) {
+	f := newWallpaperFixture(t, DuplicateModeOff)
+	signer := &countingSigner{}
+	f.service.originals = NewOriginalLinks(signer, testBucketOrigin, time.Hour)
+	ctx := context.Background()
+
+	ownerID := uuid.New()
+	add := func(title, status string) uuid.UUID {
+		w := f.repo.add(ownerID, title)
+		stored := f.repo.wallpapers[w.ID]
+		stored.Status = status
+		stored.OriginalKey = w.ID.String() + "/original.jpg"
+		stored.ThumbnailURL = "https://cdn.example.com/" + w.ID.String() + "/original_thumb.jpg"
+		stored.ThumbnailFormats = []string{"jpeg"}
+		stored.Renditions = []wallpaper.Rendition{{Name: "720p", Format: "jpeg", URL: "https://cdn.example.com/720p.jpg"}}
+		return w.ID
+	}
+	active := add("Active", wallpaper.StatusActive)
+	processing := add("Processing", wallpaper.StatusProcessing)
+	failed := add("Failed", wallpaper.StatusFailed)
+
+	tests := []struct {
+		name    string
+		id      uuid.UUID
+		userID  string
+		role    string
+		visible bool
+	}{
+		{name: "active to anyone", id: active, visible: true},
+		{name: "processing to anyone", id: processing},
+		{name: "processing to another user", id: processing, userID: uuid.New().String(), role: "user"},
+		{name: "processing to the owner", id: processing, userID: ownerID.String(), role: "user", visible: true},
+		{name: "failed to a moderator", id: failed, userID: uuid.New().String(), role: "moderator", visible: true},
+		{name: "missing", id: uuid.New()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantErr := ErrWallpaperNotFound
+			if tt.visible {
+				wantErr = nil
+			}
+			signed := signer.signed
+
+			_, err := f.service.GetWallpaper(ctx, tt.id.String(), tt.userID, tt.role)
+			if !errors.Is(err, wantErr) {
+				t.Errorf("GetWallpaper error = %v, want %v", err, wantErr)
+			}
+			if !tt.visible && signer.signed != signed {
+				t.Errorf("signed the original of a hidden wallpaper")
+			}
+			if _, err := f.service.GetThumbnailURL(ctx, tt.id.String(), tt.userID, tt.role, "image/jpeg"); !errors.Is(err, wantErr) {
+				t.Errorf("GetThumbnailURL error = %v, want %v", err, wantErr)
+			}
+			if _, err := f.service.GetRenditionURL(ctx, tt.id.String(), tt.userID, tt.role, "720p", "image/jpeg"); !errors.Is(err, wantErr) {
+				t.Errorf("GetRenditionURL error = %v, want %v", err, wantErr)
+			}
+		})
+	}
+}
+
+func TestGetDownloadURL(t *testing.T) {
+	f := newWallpaperFixture(t, DuplicateModeOff)
+	ctx := context.Background()
+
+	wp := f.repo.add(uuid.New(), "Mountains")
+	stored := f.repo.wallpapers[wp.ID]
+	stored.OriginalKey = wp.ID.String() + "/mountains.jpg"
+	stored.Renditions = []wallpaper.Rendition{
+		{Name: "1080p", Format: "webp", StorageKey: wp.ID.String() + "/renditions/1080p.webp"},
+		{Name: "1080p", Format: "jpeg", StorageKey: wp.ID.String() + "/renditions/1080p.jpg"},
+	}
+	processing := f.repo.add(uuid.New(), "Still processing")
+	f.repo.wallpapers[processing.ID].Status = wallpaper.StatusProcessing
+
+	tests := []struct {
+		id      uuid.UUID
+		size    string
+		wantKey string
+		wantErr error
+	}{
+		{id: wp.ID, wantKey: testBucketOrigin + "/" + stored.OriginalKey},
+		{id: wp.ID, size: "original", wantKey: testBucketOrigin + "/" + stored.OriginalKey},
+		{id: wp.ID, size: "thumbnail", wantKey: testBucketThumb + "/" + wp.ID.String() + "/mountains_thumb.jpg"},
+		{id: wp.ID, size: "1080p", wantKey: testBucketThumb + "/" + wp.ID.String() + "/renditions/1080p.jpg"},
+		{id: wp.ID, size: "8k", wantErr: ErrUnknownSize},
+		{id: processing.ID, wantErr: ErrWallpaperNotFound},
+		{id: uuid.New(), wantErr: ErrWallpaperNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.size, func(t *testing.T) {
+			url, err := f.service.GetDownloadURL(ctx, tt.id.String(), tt.size)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetDownloadURL error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !strings.HasPrefix(url, "memory://"+tt.wantKey+"?") {
+				t.Errorf("url = %q, want a presigned URL of %s", url, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestWallpaperPagination(t *testing.T) {
+	f := newWallpaperFixture(t, DuplicateModeOff)
+	userID := uuid.New()
+	for i := 0; i < 25; i++ {
+		f.repo.add(userID, "Wallpaper")
+	}
+	f.repo.add(uuid.New(), "Someone else's")
+
+	tests := []struct {
+		name      string
+		page      int
+		limit     int
+		wantCount int
+	}{
+		{name: "defaults", page: 0, limit: 0, wantCount: 20},
+		{name: "second page", page: 2, limit: 20, wantCount: 6},
+		{name: "custom limit", page: 3, limit: 10, wantCount: 6},
+		{name: "limit above max falls back to default", page: 1, limit: 500, wantCount: 20},
+		{name: "past the end", page: 9, limit: 10, wantCount: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := f.service.ListWallpapers(context.Background(), ListQuery{}, SortQuery{}, "", tt.page, tt.limit)
+			if err != nil {
+				t.Fatalf("ListWallpapers: %v", err)
+			}
+			if result.Total != 26 || len(result.Wallpapers) != tt.wantCount {
+				t.Errorf("got %d of %d, want %d of 26", len(result.Wallpapers), result.Total, tt.wantCount)
+			}
+		})
+	}
+
+	t.Run("by user", func(t *testing.T) {
+		result, err := f.service.GetUserWallpapers(context.Background(), userID.String(), SortQuery{}, "", 2, 20)
+		if err != nil {
+			t.Fatalf("GetUserWallpapers: %v", err)
+		}
+		if result.Total != 25 || len(result.Wallpapers) != 5 {
+			t.Errorf("got %d of %d, want 5 of 25", len(result.Wallpapers), result.Total)
+		}
+	})
+
+	t.Run("cursor", func(t *testing.T) {
+		first, err := f.service.ListWallpapers(context.Background(), ListQuery{}, SortQuery{}, "", 1, 20)
+		if err != nil {
+			t.Fatalf("ListWallpapers: %v", err)
+		}
+
+		// An upload while scrolling doesn't shift the next page
+		f.repo.add(userID, "New upload")
+
+		next, err := f.service.ListWallpapers(context.Background(), ListQuery{}, SortQuery{}, first.NextCursor, 1, 20)
+		if err != nil {
+			t.Fatalf("ListWallpapers: %v", err)
+		}
+		if len(next.Wallpapers) != 6 || next.NextCursor != "" || next.Facets != nil {
+			t.Errorf("got %d wallpapers, next cursor %q, facets %v, want the last 6 only", len(next.Wallpapers), next.NextCursor, next.Facets)
+		}
+		if next.Wallpapers[0].ID == first.Wallpapers[19].ID {
+			t.Errorf("next page repeats the last wallpaper")
+		}
+	})
+
+	t.Run("cursor of another order", func(t *testing.T) {
+		trending, err := f.service.GetTrendingWallpapers(context.Background(), SortQuery{}, "", 1, 5)
+		if err != nil {
+			t.Fatalf("GetTrendingWallpapers: %v", err)
+		}
+		if _, err := f.service.ListFeaturedWallpapers(context.Background(), SortQuery{}, trending.NextCursor, 1, 5); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("err = %v, want ErrInvalidCursor", err)
+		}
+	})
+
+	t.Run("trending keeps the window of the first page", func(t *testing.T) {
+		f := newWallpaperFixture(t, DuplicateModeOff)
+		for i, title := range []string{"Old", "Third", "Second", "First"} {
+			w := f.repo.add(uuid.New(), title)
+			f.repo.wallpapers[w.ID].LikeCount = i
+		}
+		old := f.repo.wallpapers[f.repo.order[0]]
+		old.CreatedAt = time.Now().AddDate(0, 0, -35)
+
+		first, err := f.service.GetTrendingWallpapers(context.Background(), SortQuery{}, "", 1, 2)
+		if err != nil {
+			t.Fatalf("GetTrendingWallpapers: %v", err)
+		}
+		cursor, err := decodeCursor[wallpaper.Cursor](first.NextCursor)
+		if err != nil || cursor == nil || cursor.Since == nil {
+			t.Fatalf("cursor %q has no window (err %v)", first.NextCursor, err)
+		}
+
+		// Later pages use the window of the cursor instead of moving it to now
+		windowTitles := func(since time.Time) string {
+			cursor.Since = &since
+			next, err := f.service.GetTrendingWallpapers(context.Background(), SortQuery{}, encodeCursor(cursor), 1, 2)
+			if err != nil {
+				t.Fatalf("GetTrendingWallpapers: %v", err)
+			}
+			var titles []string
+			for _, w := range next.Wallpapers {
+				titles = append(titles, w.Title)
+			}
+			return strings.Join(titles, ",")
+		}
+		if got := windowTitles(*cursor.Since); got != "Third" {
+			t.Errorf("next page = %q, want Third", got)
+		}
+		if got := windowTitles(time.Now().AddDate(0, 0, -40)); got != "Third,Old" {
+			t.Errorf("next page of an older window = %q, want Third,Old", got)
+		}
+	})
+
+	t.Run("invalid color", func(t *testing.T) {
+		if _, err := f.service.ListWallpapers(context.Background(), ListQuery{Color: "blue"}, SortQuery{}, "", 1, 20); !errors.Is(err, ErrInvalidColor) {
+			t.Errorf("err = %v, want ErrInvalidColor", err)
+		}
+	})
+}
+
+func TestListFilters(t *testing.T) {
+	f := newWallpaperFixture(t, DuplicateModeOff)
+	ctx := context.Background()
+
+	add := func(title, deviceType string, width, height int, tags ...string) {
+		w := f.repo.add(uuid.New(), title)
+		stored := f.repo.wallpapers[w.ID]
+		stored.DeviceType, stored.Width, stored.Height, stored.Tags = deviceType, width, height, tags
+	}
+	add("Mountain lake", "desktop", 3840, 2160, "nature", "lake")
+	add("Mountain road", "desktop", 1920, 1080, "nature", "road")
+	add("City lights", "mobile", 1080, 2400, "city")
+	add("Forest", "mobile", 1440, 3200, "nature")
+	add("Tall forest", "mobile", 1600, 4000, "forest")
+
+	tests := []struct {
+		name       string
+		query      ListQuery
+		wantTitles string
+		wantErr    error
+	}{
+		{name: "device type", query: ListQuery{DeviceType: "mobile"}, wantTitles: "Tall forest,Forest,City lights"},
+		{name: "minimum size", query: ListQuery{MinWidth: "1440", MinHeight: "2160"}, wantTitles: "Tall forest,Forest,Mountain lake"},
+		{name: "orientation", query: ListQuery{Orientation: "landscape"}, wantTitles: "Mountain road,Mountain lake"},
+		{name: "any tag", query: ListQuery{Tags: []string{"City", "lake"}}, wantTitles: "City lights,Mountain lake"},
+		{name: "all tags", query: ListQuery{Tags: []string{"nature", "lake"}, TagMode: "all"}, wantTitles: "Mountain lake"},
+		{name: "combined", query: ListQuery{Tags: []string{"nature"}, DeviceType: "desktop", MinWidth: "2000"}, wantTitles: "Mountain lake"},
+		// 1440x3200 physical pixels, Forest fits exactly and City lights is too small
+		{name: "screen", query: ListQuery{Screen: "360x800", DPR: "4"}, wantTitles: "Forest,Tall forest"},
+		{name: "unknown device type", query: ListQuery{DeviceType: "watch"}, wantErr: ErrInvalidFilter},
+		{name: "bad resolution", query: ListQuery{Resolution: "1920"}, wantErr: ErrInvalidFilter},
+		{name: "unknown aspect ratio", query: ListQuery{Aspects: []string{"7:3"}}, wantErr: ErrInvalidFilter},
+		{name: "unknown mime type", query: ListQuery{MimeTypes: []string{"gif"}}, wantErr: ErrInvalidFilter},
+		{name: "bad screen", query: ListQuery{Screen: "360"}, wantErr: ErrInvalidFilter},
+		{name: "bad dpr", query: ListQuery{Screen: "360x800", DPR: "10"}, wantErr: ErrInvalidFilter},
+		{name: "dpr without screen", query: ListQuery{DPR: "2"}, wantErr: ErrInvalidFilter},
+		{name: "bad tag mode", query: ListQuery{TagMode: "some"}, wantErr: ErrInvalidFilter},
+		{name: "bad date", query: ListQuery{From: "yesterday"}, wantErr: ErrInvalidFilter},
+		{name: "empty date range", query: ListQuery{From: "2025-03-02", To: "2025-03-01"}, wantErr: ErrInvalidFilter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := f.service.ListWallpapers(ctx, tt.query, SortQuery{}, "", 1, 20)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ListWallpapers error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			var titles []string
+			for _, w := range result.Wallpapers {
+				titles = append(titles, w.Title)
+			}
+			if got := strings.Join(titles, ","); got != tt.wantTitles {
+				t.Errorf("titles = %q, want %q", got, tt.wantTitles)
+			}
+		})
+	}
+
+	t.Run("valid filters", func(t *testing.T) {
+		filter, err := buildFilter(ListQuery{
+			Resolution: "3840x2160",
+			Aspects:    []string{"16:9", "21:9"},
+			MimeTypes:  []string{"JPG", "image/webp"},
+			From:       "2025-03-01",
+			To:         "2025-03-01",
+		})
+		if err != nil {
+			t.Fatalf("buildFilter: %v", err)
+		}
+		if filter.Width != 3840 || filter.Height != 2160 {
+			t.Errorf("resolution = %dx%d, want 3840x2160", filter.Width, filter.Height)
+		}
+		if got := strings.Join(filter.MimeTypes, ","); got != "image/jpeg,image/webp" {
+			t.Errorf("mime types = %q, want image/jpeg,image/webp", got)
+		}
+		if got := filter.CreatedBefore.Sub(*filter.CreatedAfter); got != 24*time.Hour {
+			t.Errorf("a single date covers %v, want the whole day", got)
+		}
+	})
+
+	t.Run("facets on the first page", func(t *testing.T) {
+		first, err := f.service.ListWallpapers(ctx, ListQuery{Tags: []string{"nature"}}, SortQuery{}, "", 1, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if first.Facets == nil || first.Facets.DeviceType["desktop"] != 2 || first.Facets.DeviceType["mobile"] != 1 {
+			t.Errorf("facets = %+v, want 2 desktop and 1 mobile", first.Facets)
+		}
+
+		second, err := f.service.ListWallpapers(ctx, ListQuery{Tags: []string{"nature"}}, SortQuery{}, "", 2, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if second.Facets != nil {
+			t.Errorf("facets counted again on page 2")
+		}
+	})
+}
+
+func TestListSorts(t *testing.T) {
+	f := newWallpaperFixture(t, DuplicateModeOff)
+	ctx := context.Background()
+
+	add := func(title string, likes, downloads, views int) {
+		w := f.repo.add(uuid.New(), title)
+		stored := f.repo.wallpapers[w.ID]
+		stored.LikeCount, stored.DownloadCount, stored.ViewCount = likes, downloads, views
+	}
+	add("First", 5, 1, 30)
+	add("Second", 1, 9, 10)
+	add("Third", 3, 4, 20)
+
+	tests := []struct {
+		name       string
+		sort       SortQuery
+		wantTitles string
+		wantErr    error
+	}{
+		{name: "default", wantTitles: "Third,Second,First"},
+		{name: "oldest", sort: SortQuery{Sort: "oldest"}, wantTitles: "First,Second,Third"},
+		{name: "most liked", sort: SortQuery{Sort: "most_liked"}, wantTitles: "First,Third,Second"},
+		{name: "most downloaded", sort: SortQuery{Sort: "most_downloaded"}, wantTitles: "Second,Third,First"},
+		{name: "most viewed", sort: SortQuery{Sort: "most_viewed"}, wantTitles: "First,Third,Second"},
+		{name: "unknown sort", sort: SortQuery{Sort: "best"}, wantErr: ErrInvalidSort},
+		{name: "relevance without search", sort: SortQuery{Sort: "relevance"}, wantErr: ErrInvalidSort},
+		{name: "fit without screen", sort: SortQuery{Sort: "fit"}, wantErr: ErrInvalidSort},
+		{name: "seed without random", sort: SortQuery{Sort: "newest", Seed: "7"}, wantErr: ErrInvalidSort},
+		{name: "bad seed", sort: SortQuery{Sort: "random", Seed: "abc"}, wantErr: ErrInvalidSort},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := f.service.ListWallpapers(ctx, ListQuery{}, tt.sort, "", 1, 20)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ListWallpapers error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			var titles []string
+			for _, w := range result.Wallpapers {
+				titles = append(titles, w.Title)
+			}
+			if got := strings.Join(titles, ","); got != tt.wantTitles {
+				t.Errorf("titles = %q, want %q", got, tt.wantTitles)
+			}
+		})
+	}
+
+	t.Run("random is stable across pages", func(t *testing.T) {
+		for i := 0; i < 20; i++ {
+			add("More", 0, 0, 0)
+		}
+
+		first, err := f.service.ListWallpapers(ctx, ListQuery{}, SortQuery{Sort: "random"}, "", 1, 10)
+		if err != nil {
+			t.Fatalf("ListWallpapers: %v", err)
+		}
+		if first.Seed == nil {
+			t.Fatal("random sort without a seed")
+		}
+		seed := strconv.FormatInt(*first.Seed, 10)
+
+		// The cursor and the seed both lead to the same next page
+		byCursor, err := f.service.ListWallpapers(ctx, ListQuery{}, SortQuery{Sort: "random"}, first.NextCursor, 1, 10)
+		if err != nil {
+			t.Fatalf("ListWallpapers: %v", err)
+		}
+		byPage, err := f.service.ListWallpapers(ctx, ListQuery{}, SortQuery{Sort: "random", Seed: seed}, "", 2, 10)
+		if err != nil {
+			t.Fatalf("ListWallpapers: %v", err)
+		}
+
+		seen := map[uuid.UUID]bool{}
+		for _, w := range first.Wallpapers {
+			seen[w.ID] = true
+		}
+		for i, w := range byCursor.Wallpapers {
+			if seen[w.ID] {
+				t.Errorf("wallpaper %s on both pages", w.ID)
+			}
+			if w.ID != byPage.Wallpapers[i].ID {
+				t.Errorf("wallpaper %d of page 2 differs between cursor and seed", i)
+			}
+		}
+
+		// A cursor carries its seed, a different one in the request doesn't reshuffle the rest
+		other := strconv.FormatInt(*first.Seed+1, 10)
+		next, err := f.service.ListWallpapers(ctx, ListQuery{}, SortQuery{Sort: "random", Seed: other}, first.NextCursor, 1, 10)
+		if err != nil {
+			t.Fatalf("ListWallpapers: %v", err)
+		}
+		if next.Seed == nil || *next.Seed != *first.Seed {
+			t.Errorf("seed = %v, want the cursor's %d", next.Seed, *first.Seed)
+		}
+		for i, w := range next.Wallpapers {
+			if w.ID != byCursor.Wallpapers[i].ID {
+				t.Errorf("wallpaper %d of page 2 differs with another seed in the request", i)
+			}
+		}
+	})
+
+	t.Run("cursor of another sort", func(t *testing.T) {
+		liked, err := f.service.ListWallpapers(ctx, ListQuery{}, SortQuery{Sort: "most_liked"}, "", 1, 1)
+		if err != nil {
+			t.Fatalf("ListWallpapers: %v", err)
+		}
+		random, err := f.service.ListWallpapers(ctx, ListQuery{}, SortQuery{Sort: "random"}, "", 1, 1)
+		if err != nil {
+			t.Fatalf("ListWallpapers: %v", err)
+		}
+
+		tests := []struct {
+			cursor string
+			sort   SortQuery
+		}{
+			{cursor: liked.NextCursor, sort: SortQuery{}},
+			{cursor: liked.NextCursor, sort: SortQuery{Sort: "most_viewed"}},
+			{cursor: random.NextCursor, sort: SortQuery{Sort: "oldest"}},
+			{cursor: random.NextCursor, sort: SortQuery{}},
+		}
+		for _, tt := range tests {
+			if _, err := f.service.ListWallpapers(ctx, ListQuery{}, tt.sort, tt.cursor, 1, 1); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("sort %q with another sort's cursor: err = %v, want %v", tt.sort.Sort, err, ErrInvalidCursor)
+			}
+		}
+	})
+}
+
+func TestColorFilter(t *testing.T) {
+	tests := []struct {
+		hex     string
+		want    processor.Lab
+		wantNil bool
+		wantErr error
+	}{
+		{hex: "", wantNil: true},
+		{hex: "#1e3a8a", want: processor.Lab{L: 27.12, A: 20.01, B: -47.60}},
+		{hex: "f59e0b", want: processor.Lab{L: 72.16, A: 23.49, B: 75.22}},
+		{hex: "blue", wantErr: ErrInvalidColor},
+		{hex: "#12345", wantErr: ErrInvalidColor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.hex, func(t *testing.T) {
+			f, err := colorFilter(tt.hex)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil || tt.wantNil {
+				if f != nil {
+					t.Errorf("filter = %+v, want none", f)
+				}
+				return
+			}
+
+			got := processor.Lab{L: f.L, A: f.A, B: f.B}
+			if got.Distance(tt.want) > 0.01 {
+				t.Errorf("target = %+v, want %+v", got, tt.want)
+			}
+			if f.MaxDistance != colorMatchDistance || f.MinWeight != colorMatchMinWeight {
+				t.Errorf("thresholds = %v, %v, want %v, %v", f.MaxDistance, f.MinWeight, colorMatchDistance, colorMatchMinWeight)
+			}
+		})
+	}
+}
+
+func TestSearchValidation(t *testing.T) {
+	f := newWallpaperFixture(t, DuplicateModeOff)
+
+	tests := []struct {
+		name    string
+		query   string
+		filters ListQuery
+		sort    SortQuery
+		cursor  string
+		wantErr error
+	}{
+		{name: "too short", query: " a ", wantErr: ErrInvalidSearchQuery},
+		{name: "too long", query: strings.Repeat("a", 101), wantErr: ErrInvalidSearchQuery},
+		{name: "bad filter", query: "sunset", filters: ListQuery{DeviceType: "watch"}, wantErr: ErrInvalidFilter},
+		{name: "bad color", query: "sunset", filters: ListQuery{Color: "blue"}, wantErr: ErrInvalidColor},
+		{name: "bad sort", query: "sunset", sort: SortQuery{Sort: "best"}, wantErr: ErrInvalidSort},
+		{name: "bad cursor", query: "sunset", cursor: "garbage", wantErr: ErrInvalidCursor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := f.service.SearchWallpapers(context.Background(), tt.query, tt.filters, tt.sort, tt.cursor, 1, 20)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("SearchWallpapers error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// checkErr fails the test unless err matches want, an empty want means no error
+func checkErr(t *testing.T, op string, err error, want string) {
+	t.Helper()
+
+	if want == "" {
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", op, err)
+		}
+		return
+	}
+	if err == nil || err.Error() != want {
+		t.Errorf("%s: err = %v, want %q", op, err, want)
+	}
+}
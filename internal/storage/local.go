@@ -0,0 +1,270 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidKey is returned for object keys that would escape the bucket directory
+var ErrInvalidKey = errors.New("invalid object key")
+
+// LocalStorage keeps objects on the local filesystem as <root>/<bucket>/<key>,
+// for single-box deployments and development without MinIO/R2.
+// Objects are served by the /files route, presigned URLs are HMAC signed.
+// Objects of private buckets are only served with a valid signature.
+type LocalStorage struct {
+	root    string
+	baseURL string
+	secret  []byte
+	private map[string]bool
+}
+
+func NewLocalStorage(root, baseURL, secret string) (*LocalStorage, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("local storage needs a signing secret")
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	return &LocalStorage{
+		root:    root,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		secret:  []byte(secret),
+		private: make(map[string]bool),
+	}, nil
+}
+
+// InitializeBuckets creates a directory per bucket and marks the private ones
+func (s *LocalStorage) InitializeBuckets(ctx context.Context, public, private []string) error {
+	for _, bucket := range private {
+		s.private[bucket] = true
+	}
+	for _, bucket := range append(append([]string{}, public...), private...) {
+		dir, err := s.Path(bucket, "")
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create bucket %s: %w", bucket, err)
+		}
+	}
+	return nil
+}
+
+// IsPrivate reports whether objects of bucket need a signed URL to be read
+func (s *LocalStorage) IsPrivate(bucket string) bool {
+	return s.private[bucket]
+}
+
+// Path returns the file path of an object, rejecting keys with "..", absolute paths and
+// percent-encoded separators or dots that a later decoding step could turn into a traversal
+func (s *LocalStorage) Path(bucket, key string) (string, error) {
+	if bucket == "" || strings.ContainsAny(bucket, `/\%`) || bucket == "." || bucket == ".." {
+		return "", ErrInvalidKey
+	}
+	if key != "" {
+		if strings.HasPrefix(key, "/") || strings.ContainsAny(key, "\\\x00") || path.Clean(key) != key {
+			return "", ErrInvalidKey
+		}
+		lower := strings.ToLower(key)
+		if strings.Contains(lower, "%2f") || strings.Contains(lower, "%5c") || strings.Contains(lower, "%2e") {
+			return "", ErrInvalidKey
+		}
+		for _, part := range strings.Split(key, "/") {
+			if part == ".." || part == "." {
+				return "", ErrInvalidKey
+			}
+		}
+	}
+	return filepath.Join(s.root, bucket, filepath.FromSlash(key)), nil
+}
+
+func (s *LocalStorage) Upload(ctx context.Context, bucket, key string, data io.Reader, size int64, contentType string) (string, error) {
+	p, err := s.Path(bucket, key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return "", fmt.Errorf("failed to upload: %w", err)
+	}
+
+	// Write next to the target and rename so readers never see a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to upload: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to upload: %w", err)
+	}
+	if size >= 0 && n != size {
+		return "", fmt.Errorf("failed to upload: wrote %d of %d bytes", n, size)
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return "", fmt.Errorf("failed to upload: %w", err)
+	}
+
+	return s.objectURL(bucket, key), nil
+}
+
+func (s *LocalStorage) Download(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	p, err := s.Path(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download: %w", err)
+	}
+	return f, nil
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, bucket, key string) error {
+	p, err := s.Path(bucket, key)
+	if err != nil {
+		return err
+	}
+	// Like S3, deleting a missing object is not an error
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStorage) Stat(ctx context.Context, bucket, key string) (*ObjectInfo, error) {
+	p, err := s.Path(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(p)
+	if os.IsNotExist(err) || (err == nil && info.IsDir()) {
+		return nil, ErrObjectNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat object: %w", err)
+	}
+	return &ObjectInfo{
+		Key:          key,
+		Size:         info.Size(),
+		ContentType:  ContentTypeByKey(key),
+		LastModified: info.ModTime(),
+	}, nil
+}
+
+func (s *LocalStorage) List(ctx context.Context, bucket, prefix string, fn func(ObjectInfo) error) error {
+	dir, err := s.Path(bucket, "")
+	if err != nil {
+		return err
+	}
+
+	// WalkDir visits entries in lexical order, which matches key order
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && p == dir {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// Skip directories and in-progress uploads (see Upload)
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if os.IsNotExist(err) {
+			return nil // removed while listing
+		}
+		if err != nil {
+			return err
+		}
+		return fn(ObjectInfo{
+			Key:          key,
+			Size:         info.Size(),
+			ContentType:  ContentTypeByKey(key),
+			LastModified: info.ModTime(),
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list objects: %w", err)
+	}
+	return nil
+}
+
+func (s *LocalStorage) GetPresignedURL(ctx context.Context, bucket, key string, expirySeconds int) (string, error) {
+	return s.signedURL("GET", bucket, key, expirySeconds)
+}
+
+func (s *LocalStorage) GetPresignedPutURL(ctx context.Context, bucket, key string, expirySeconds int) (string, error) {
+	return s.signedURL("PUT", bucket, key, expirySeconds)
+}
+
+// VerifySignature checks a presigned URL's expires and signature query parameters
+func (s *LocalStorage) VerifySignature(method, bucket, key, expires, signature string) bool {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return false
+	}
+	expected := s.sign(method, bucket, key, exp)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+func (s *LocalStorage) signedURL(method, bucket, key string, expirySeconds int) (string, error) {
+	if _, err := s.Path(bucket, key); err != nil {
+		return "", err
+	}
+	exp := time.Now().Add(time.Duration(expirySeconds) * time.Second).Unix()
+
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(exp, 10))
+	q.Set("signature", s.sign(method, bucket, key, exp))
+	return s.objectURL(bucket, key) + "?" + q.Encode(), nil
+}
+
+func (s *LocalStorage) sign(method, bucket, key string, expires int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%d", method, bucket, key, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *LocalStorage) objectURL(bucket, key string) string {
+	return fmt.Sprintf("%s/%s/%s", s.baseURL, bucket, key)
+}
+
+// ContentTypeByKey guesses a content type from the key's extension
+func ContentTypeByKey(key string) string {
+	if ct := mime.TypeByExtension(path.Ext(key)); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
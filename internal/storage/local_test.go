@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newTestLocalStorage(t *testing.T) *LocalStorage {
+	t.Helper()
+	s, err := NewLocalStorage(t.TempDir(), "/files", "test-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestLocalStoragePath(t *testing.T) {
+	s := newTestLocalStorage(t)
+
+	tests := []struct {
+		name    string
+		bucket  string
+		key     string
+		want    string // relative to the root
+		wantErr bool
+	}{
+		{name: "nested key", bucket: "originals", key: "a1/original.jpg", want: "originals/a1/original.jpg"},
+		{name: "bucket directory", bucket: "originals", key: "", want: "originals"},
+		{name: "render cache key", bucket: "thumbnails", key: "a1/img/w=800,fit=cover,fmt=webp,q=80.webp", want: "thumbnails/a1/img/w=800,fit=cover,fmt=webp,q=80.webp"},
+		{name: "parent directory", bucket: "originals", key: "../secret", wantErr: true},
+		{name: "parent in the middle", bucket: "originals", key: "a1/../../secret", wantErr: true},
+		{name: "bare parent", bucket: "originals", key: "..", wantErr: true},
+		{name: "current directory", bucket: "originals", key: "./a1", wantErr: true},
+		{name: "absolute key", bucket: "originals", key: "/etc/passwd", wantErr: true},
+		{name: "empty segment", bucket: "originals", key: "a1//b", wantErr: true},
+		{name: "trailing slash", bucket: "originals", key: "a1/", wantErr: true},
+		{name: "backslash", bucket: "originals", key: `a1\..\..\secret`, wantErr: true},
+		{name: "nul byte", bucket: "originals", key: "a1\x00.jpg", wantErr: true},
+		{name: "encoded slash", bucket: "originals", key: "..%2fsecret", wantErr: true},
+		{name: "encoded slash upper case", bucket: "originals", key: "a1%2F..%2F..%2Fsecret", wantErr: true},
+		{name: "encoded backslash", bucket: "originals", key: "..%5csecret", wantErr: true},
+		{name: "encoded dots", bucket: "originals", key: "%2e%2e/secret", wantErr: true},
+		{name: "no bucket", bucket: "", key: "a1/original.jpg", wantErr: true},
+		{name: "parent bucket", bucket: "..", key: "secret", wantErr: true},
+		{name: "bucket with a separator", bucket: "originals/..", key: "secret", wantErr: true},
+		{name: "encoded bucket", bucket: "%2e%2e", key: "secret", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Path(tt.bucket, tt.key)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidKey) {
+					t.Errorf("Path(%q, %q) = %q, %v, want ErrInvalidKey", tt.bucket, tt.key, got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Path(%q, %q): %v", tt.bucket, tt.key, err)
+			}
+			if want := filepath.Join(s.root, filepath.FromSlash(tt.want)); got != want {
+				t.Errorf("Path(%q, %q) = %q, want %q", tt.bucket, tt.key, got, want)
+			}
+		})
+	}
+}
+
+func TestLocalStorageSignature(t *testing.T) {
+	s := newTestLocalStorage(t)
+	ctx := context.Background()
+
+	signed, err := s.GetPresignedURL(ctx, "originals", "a1/original.jpg", 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Path != "/files/originals/a1/original.jpg" {
+		t.Errorf("path = %q, want the object URL", u.Path)
+	}
+	expires, signature := u.Query().Get("expires"), u.Query().Get("signature")
+
+	later := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	expiredAt := time.Now().Add(-time.Second).Unix()
+	expired := strconv.FormatInt(expiredAt, 10)
+	tampered := []byte(signature)
+	tampered[0] ^= 1
+
+	tests := []struct {
+		name      string
+		method    string
+		bucket    string
+		key       string
+		expires   string
+		signature string
+		want      bool
+	}{
+		{name: "valid", method: "GET", bucket: "originals", key: "a1/original.jpg", expires: expires, signature: signature, want: true},
+		{name: "tampered signature", method: "GET", bucket: "originals", key: "a1/original.jpg", expires: expires, signature: string(tampered)},
+		{name: "extended expiry", method: "GET", bucket: "originals", key: "a1/original.jpg", expires: later, signature: signature},
+		{name: "other key", method: "GET", bucket: "originals", key: "a2/original.jpg", expires: expires, signature: signature},
+		{name: "other bucket", method: "GET", bucket: "thumbnails", key: "a1/original.jpg", expires: expires, signature: signature},
+		{name: "other method", method: "PUT", bucket: "originals", key: "a1/original.jpg", expires: expires, signature: signature},
+		{name: "no signature", method: "GET", bucket: "originals", key: "a1/original.jpg", expires: expires},
+		{name: "malformed expiry", method: "GET", bucket: "originals", key: "a1/original.jpg", expires: "soon", signature: signature},
+		{name: "expired", method: "GET", bucket: "originals", key: "a1/original.jpg", expires: expired, signature: s.sign("GET", "originals", "a1/original.jpg", expiredAt)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.VerifySignature(tt.method, tt.bucket, tt.key, tt.expires, tt.signature); got != tt.want {
+				t.Errorf("VerifySignature = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := s.GetPresignedURL(ctx, "originals", "../secret", 60); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("signing a traversal key: err = %v, want ErrInvalidKey", err)
+	}
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type memoryObject struct {
+	data        []byte
+	contentType string
+	modified    time.Time
+}
+
+// MemoryStorage keeps objects in memory, for tests
+type MemoryStorage struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{objects: make(map[string]memoryObject)}
+}
+
+func memoryKey(bucket, key string) string {
+	return bucket + "/" + key
+}
+
+func (s *MemoryStorage) Upload(ctx context.Context, bucket, key string, data io.Reader, size int64, contentType string) (string, error) {
+	buf, err := io.ReadAll(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload: %w", err)
+	}
+	if size >= 0 && int64(len(buf)) != size {
+		return "", fmt.Errorf("failed to upload: wrote %d of %d bytes", len(buf), size)
+	}
+
+	s.mu.Lock()
+	s.objects[memoryKey(bucket, key)] = memoryObject{data: buf, contentType: contentType, modified: time.Now()}
+	s.mu.Unlock()
+
+	return "memory://" + memoryKey(bucket, key), nil
+}
+
+func (s *MemoryStorage) Download(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	s.mu.RLock()
+	obj, ok := s.objects[memoryKey(bucket, key)]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("failed to download: %w", ErrObjectNotFound)
+	}
+	return io.NopCloser(bytes.NewReader(obj.data)), nil
+}
+
+func (s *MemoryStorage) Delete(ctx context.Context, bucket, key string) error {
+	s.mu.Lock()
+	delete(s.objects, memoryKey(bucket, key))
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStorage) Stat(ctx context.Context, bucket, key string) (*ObjectInfo, error) {
+	s.mu.RLock()
+	obj, ok := s.objects[memoryKey(bucket, key)]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrObjectNotFound
+	}
+	return &ObjectInfo{
+		Key:          key,
+		Size:         int64(len(obj.data)),
+		ContentType:  obj.contentType,
+		LastModified: obj.modified,
+	}, nil
+}
+
+func (s *MemoryStorage) List(ctx context.Context, bucket, prefix string, fn func(ObjectInfo) error) error {
+	keys := s.Keys(bucket)
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		info, err := s.Stat(ctx, bucket, key)
+		if err != nil {
+			continue // deleted while listing
+		}
+		if err := fn(*info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStorage) GetPresignedURL(ctx context.Context, bucket, key string, expirySeconds int) (string, error) {
+	return fmt.Sprintf("memory://%s?method=GET&expires=%d", memoryKey(bucket, key), expirySeconds), nil
+}
+
+func (s *MemoryStorage) GetPresignedPutURL(ctx context.Context, bucket, key string, expirySeconds int) (string, error) {
+	return fmt.Sprintf("memory://%s?method=PUT&expires=%d", memoryKey(bucket, key), expirySeconds), nil
+}
+
+// Keys returns the keys stored in a bucket
+func (s *MemoryStorage) Keys(bucket string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []string
+	for k := range s.objects {
+		if key, ok := strings.CutPrefix(k, bucket+"/"); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
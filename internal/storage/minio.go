@@ -27,8 +27,10 @@ func NewMinIOStorage(endpoint, accessKey, secretKey, cdnURL string, useSSL bool)
 	return &MinIOStorage{client: client, cdnURL: cdnURL}, nil
 }
 
-func (s *MinIOStorage) InitializeBuckets(ctx context.Context, buckets []string) error {
-	for _, bucketName := range buckets {
+// InitializeBuckets creates the buckets, public ones get an anonymous read policy and
+// private ones have any policy removed so objects are only reachable through presigned URLs
+func (s *MinIOStorage) InitializeBuckets(ctx context.Context, public, private []string) error {
+	for _, bucketName := range append(append([]string{}, public...), private...) {
 		err := s.client.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{})
 		if err != nil {
 			exists, errBucketExists := s.client.BucketExists(ctx, bucketName)
@@ -38,14 +40,22 @@ func (s *MinIOStorage) InitializeBuckets(ctx context.Context, buckets []string)
 				return fmt.Errorf("failed to create bucket %s: %w", bucketName, err)
 			}
 		}
+	}
 
+	for _, bucketName := range public {
 		// Set public policy (readonly)
 		policy := fmt.Sprintf(`{"Version": "2012-10-17","Statement": [{"Action": ["s3:GetObject"],"Effect": "Allow","Principal": {"AWS": ["*"]},"Resource": ["arn:aws:s3:::%s/*"]}]}`, bucketName)
-		err = s.client.SetBucketPolicy(ctx, bucketName, policy)
-		if err != nil {
+		if err := s.client.SetBucketPolicy(ctx, bucketName, policy); err != nil {
 			return fmt.Errorf("failed to set policy for %s: %w", bucketName, err)
 		}
 	}
+
+	for _, bucketName := range private {
+		// An empty policy deletes the bucket policy
+		if err := s.client.SetBucketPolicy(ctx, bucketName, ""); err != nil {
+			return fmt.Errorf("failed to remove policy of %s: %w", bucketName, err)
+		}
+	}
 	return nil
 }
 
@@ -63,6 +73,20 @@ func (s *MinIOStorage) Upload(ctx context.Context, bucket, key string, data io.R
 	return fmt.Sprintf("http://%s/%s/%s", s.client.EndpointURL().Host, bucket, key), nil
 }
 
+func (s *MinIOStorage) Download(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	obj, err := s.client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download: %w", err)
+	}
+
+	// GetObject is lazy, stat it so a missing object fails here instead of on first read
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		return nil, fmt.Errorf("failed to download: %w", err)
+	}
+	return obj, nil
+}
+
 func (s *MinIOStorage) Delete(ctx context.Context, bucket, key string) error {
 	return s.client.RemoveObject(ctx, bucket, key, minio.RemoveObjectOptions{})
 }
@@ -74,3 +98,49 @@ func (s *MinIOStorage) GetPresignedURL(ctx context.Context, bucket, key string,
 	}
 	return u.String(), nil
 }
+
+func (s *MinIOStorage) GetPresignedPutURL(ctx context.Context, bucket, key string, expirySeconds int) (string, error) {
+	u, err := s.client.PresignedPutObject(ctx, bucket, key, time.Duration(expirySeconds)*time.Second)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func (s *MinIOStorage) Stat(ctx context.Context, bucket, key string) (*ObjectInfo, error) {
+	info, err := s.client.StatObject(ctx, bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("failed to stat object: %w", err)
+	}
+	return &ObjectInfo{
+		Key:          info.Key,
+		Size:         info.Size,
+		ContentType:  info.ContentType,
+		LastModified: info.LastModified,
+	}, nil
+}
+
+func (s *MinIOStorage) List(ctx context.Context, bucket, prefix string, fn func(ObjectInfo) error) error {
+	// Cancelling stops the listing goroutine when fn returns early
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for obj := range s.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return fmt.Errorf("failed to list objects: %w", obj.Err)
+		}
+		err := fn(ObjectInfo{
+			Key:          obj.Key,
+			Size:         obj.Size,
+			ContentType:  obj.ContentType,
+			LastModified: obj.LastModified,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
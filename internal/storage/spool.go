@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+// ErrSpoolLimit is returned by Spool when the source is larger than the allowed size
+var ErrSpoolLimit = errors.New("spooled data exceeds size limit")
+
+// SpooledFile is a temporary file that hashes everything written to it.
+// It lets large uploads be validated, rewritten and stored without holding them in memory.
+type SpooledFile struct {
+	file *os.File
+	hash hash.Hash
+	size int64
+}
+
+// NewSpooledFile creates an empty temporary file in dir (os.TempDir when empty)
+func NewSpooledFile(dir string) (*SpooledFile, error) {
+	file, err := os.CreateTemp(dir, "pixtify-upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	return &SpooledFile{file: file, hash: sha256.New()}, nil
+}
+
+// Spool copies r into a new temporary file, failing with ErrSpoolLimit after maxBytes
+func Spool(r io.Reader, dir string, maxBytes int64) (*SpooledFile, error) {
+	f, err := NewSpooledFile(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read one byte past the limit to tell "exactly maxBytes" from "too large"
+	n, err := io.Copy(f, io.LimitReader(r, maxBytes+1))
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to spool data: %w", err)
+	}
+	if n > maxBytes {
+		f.Close()
+		return nil, ErrSpoolLimit
+	}
+	return f, nil
+}
+
+func (f *SpooledFile) Write(p []byte) (int, error) {
+	n, err := f.file.Write(p)
+	f.hash.Write(p[:n])
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *SpooledFile) ReadAt(p []byte, off int64) (int, error) {
+	return f.file.ReadAt(p, off)
+}
+
+// Reader returns a reader over the whole file, independent of other readers
+func (f *SpooledFile) Reader() io.Reader {
+	return io.NewSectionReader(f.file, 0, f.size)
+}
+
+// Size returns the number of bytes written so far
+func (f *SpooledFile) Size() int64 {
+	return f.size
+}
+
+// SHA256 returns the hex encoded checksum of the bytes written so far
+func (f *SpooledFile) SHA256() string {
+	return hex.EncodeToString(f.hash.Sum(nil))
+}
+
+// Close closes and removes the temporary file
+func (f *SpooledFile) Close() error {
+	err := f.file.Close()
+	if removeErr := os.Remove(f.file.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
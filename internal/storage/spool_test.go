@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// failingReader returns data, then err
+type failingReader struct {
+	data io.Reader
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	n, err := r.data.Read(p)
+	if err == io.EOF {
+		return n, r.err
+	}
+	return n, err
+}
+
+// tempFiles lists what is left in dir
+func tempFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestSpool(t *testing.T) {
+	data := bytes.Repeat([]byte("pixtify "), 10000)
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name     string
+		reader   func() io.Reader
+		maxBytes int64
+		wantErr  error
+	}{
+		{name: "under the limit", reader: func() io.Reader { return bytes.NewReader(data) }, maxBytes: int64(len(data)) + 1},
+		{name: "exactly the limit", reader: func() io.Reader { return bytes.NewReader(data) }, maxBytes: int64(len(data))},
+		{name: "one byte over", reader: func() io.Reader { return bytes.NewReader(data) }, maxBytes: int64(len(data)) - 1, wantErr: ErrSpoolLimit},
+		{name: "far over", reader: func() io.Reader { return bytes.NewReader(data) }, maxBytes: 100, wantErr: ErrSpoolLimit},
+		{
+			name: "read error",
+			reader: func() io.Reader {
+				return &failingReader{data: bytes.NewReader(data[:500]), err: errors.New("connection reset")}
+			},
+			maxBytes: int64(len(data)),
+			wantErr:  errors.New("connection reset"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			f, err := Spool(tt.reader(), dir, tt.maxBytes)
+			if tt.wantErr != nil {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr.Error()) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				// Nothing is left behind when spooling fails
+				if left := tempFiles(t, dir); len(left) > 0 {
+					t.Errorf("temp files left after a failed spool: %v", left)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Spool: %v", err)
+			}
+
+			if f.Size() != int64(len(data)) {
+				t.Errorf("size = %d, want %d", f.Size(), len(data))
+			}
+			if f.SHA256() != checksum {
+				t.Errorf("sha256 = %s, want %s", f.SHA256(), checksum)
+			}
+
+			// Readers are independent and see the whole file
+			for i := 0; i < 2; i++ {
+				got, err := io.ReadAll(f.Reader())
+				if err != nil || !bytes.Equal(got, data) {
+					t.Fatalf("read %d bytes (%v), want the spooled data", len(got), err)
+				}
+			}
+			tail := make([]byte, 8)
+			if _, err := f.ReadAt(tail, int64(len(data)-8)); err != nil || string(tail) != "pixtify " {
+				t.Errorf("ReadAt = %q, %v", tail, err)
+			}
+
+			if left := tempFiles(t, dir); len(left) != 1 || !strings.HasPrefix(left[0], "pixtify-upload-") {
+				t.Errorf("temp files = %v, want one upload file", left)
+			}
+			if err := f.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+			if left := tempFiles(t, dir); len(left) > 0 {
+				t.Errorf("temp files left after Close: %v", left)
+			}
+		})
+	}
+}
+
+func TestSpooledFileWrite(t *testing.T) {
+	f, err := NewSpooledFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// The checksum covers everything written, however it arrives
+	for _, part := range []string{"wall", "paper", ""} {
+		if _, err := io.WriteString(f, part); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sum := sha256.Sum256([]byte("wallpaper"))
+	if f.SHA256() != hex.EncodeToString(sum[:]) || f.Size() != 9 {
+		t.Errorf("sha256 = %s, size = %d, want the checksum of %q", f.SHA256(), f.Size(), "wallpaper")
+	}
+
+	empty, err := NewSpooledFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer empty.Close()
+	if want := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"; empty.SHA256() != want {
+		t.Errorf("sha256 of an empty file = %s, want %s", empty.SHA256(), want)
+	}
+}
@@ -2,11 +2,33 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"io"
+	"time"
 )
 
+// ErrObjectNotFound is returned by Stat when the key does not exist
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	LastModified time.Time
+}
+
 type Service interface {
+	// Upload streams data to the bucket. size must be the exact length of data,
+	// callers with data of unknown length should spool it first (see Spool).
 	Upload(ctx context.Context, bucket, key string, data io.Reader, size int64, contentType string) (string, error)
+	Download(ctx context.Context, bucket, key string) (io.ReadCloser, error)
 	Delete(ctx context.Context, bucket, key string) error
+	Stat(ctx context.Context, bucket, key string) (*ObjectInfo, error)
+	// List calls fn for every object whose key starts with prefix, in key order.
+	// Listing stops at the first error fn returns, which List returns.
+	List(ctx context.Context, bucket, prefix string, fn func(ObjectInfo) error) error
 	GetPresignedURL(ctx context.Context, bucket, key string, expirySeconds int) (string, error)
+	// GetPresignedPutURL returns a URL clients can PUT an object to directly, bypassing the API
+	GetPresignedPutURL(ctx context.Context, bucket, key string, expirySeconds int) (string, error)
 }
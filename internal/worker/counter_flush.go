@@ -0,0 +1,34 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// CounterFlusher writes buffered view and download counts
+type CounterFlusher interface {
+	FlushCounters(ctx context.Context) error
+}
+
+// CounterFlushWorker periodically writes buffered view and download counts,
+// and once more on stop so what is still buffered isn't lost
+type CounterFlushWorker struct {
+	periodic
+	counters CounterFlusher
+}
+
+func NewCounterFlushWorker(counters CounterFlusher, interval time.Duration) *CounterFlushWorker {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	w := &CounterFlushWorker{counters: counters}
+	w.periodic = periodic{interval: interval, finalRun: 5 * time.Second, run: w.run}
+	return w
+}
+
+func (w *CounterFlushWorker) run(ctx context.Context) {
+	if err := w.counters.FlushCounters(ctx); err != nil {
+		log.Printf("Counter flush worker: %v", err)
+	}
+}
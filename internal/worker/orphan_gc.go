@@ -0,0 +1,40 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/pavelc4/pixtify/internal/service"
+)
+
+// OrphanCollector removes objects no wallpaper or upload refers to
+type OrphanCollector interface {
+	CollectOrphans(ctx context.Context, dryRun bool) (*service.OrphanReport, error)
+}
+
+// OrphanGCWorker periodically removes orphaned objects from the buckets.
+// The first run waits a full interval, a restart loop should not rescan the buckets every time.
+type OrphanGCWorker struct {
+	periodic
+	collector OrphanCollector
+	dryRun    bool
+}
+
+func NewOrphanGCWorker(collector OrphanCollector, interval time.Duration, dryRun bool) *OrphanGCWorker {
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+	w := &OrphanGCWorker{collector: collector, dryRun: dryRun}
+	w.periodic = periodic{interval: interval, run: w.run}
+	return w
+}
+
+func (w *OrphanGCWorker) run(ctx context.Context) {
+	report, err := w.collector.CollectOrphans(ctx, w.dryRun)
+	if err != nil {
+		log.Printf("Orphan GC worker: %v", err)
+		return
+	}
+	log.Printf("Orphan GC worker: %s", report.Summary())
+}
@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// periodic runs a task every interval until its context is cancelled. Workers embed it
+// and only supply run, Start and Wait come from here.
+type periodic struct {
+	interval  time.Duration
+	workers   int           // goroutines running the task side by side, 1 when unset
+	immediate bool          // run once at start instead of waiting a full interval
+	finalRun  time.Duration // when set, run once more on stop with this long to finish
+	run       func(ctx context.Context)
+	wg        sync.WaitGroup
+}
+
+// Start launches the worker goroutines, they stop when ctx is cancelled
+func (p *periodic) Start(ctx context.Context) {
+	for i := 0; i < max(p.workers, 1); i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.loop(ctx)
+		}()
+	}
+}
+
+// Wait blocks until every worker goroutine has finished its current run and exited
+func (p *periodic) Wait() {
+	p.wg.Wait()
+}
+
+func (p *periodic) loop(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	if p.immediate {
+		p.run(ctx)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			if p.finalRun > 0 {
+				finalCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), p.finalRun)
+				p.run(finalCtx)
+				cancel()
+			}
+			return
+		case <-ticker.C:
+		}
+		p.run(ctx)
+	}
+}
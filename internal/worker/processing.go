@@ -0,0 +1,126 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/pavelc4/pixtify/internal/repository/postgres/job"
+)
+
+// Processor turns a stored original into its derivatives
+type Processor interface {
+	ProcessWallpaper(ctx context.Context, wallpaperID uuid.UUID) error
+	MarkProcessingFailed(ctx context.Context, wallpaperID uuid.UUID) error
+}
+
+type ProcessingConfig struct {
+	Concurrency  int
+	PollInterval time.Duration
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	LockTimeout  time.Duration
+}
+
+// ProcessingWorker runs image processing jobs from the Postgres queue.
+// Several instances (goroutines or API replicas) can poll concurrently, SKIP LOCKED keeps them apart.
+type ProcessingWorker struct {
+	periodic
+	jobs      *job.Repository
+	processor Processor
+	cfg       ProcessingConfig
+}
+
+func NewProcessingWorker(jobs *job.Repository, processor Processor, cfg ProcessingConfig) *ProcessingWorker {
+	if cfg.Concurrency < 1 {
+		cfg.Concurrency = 1
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 2 * time.Second
+	}
+	if cfg.BaseBackoff <= 0 {
+		cfg.BaseBackoff = 10 * time.Second
+	}
+	if cfg.MaxBackoff < cfg.BaseBackoff {
+		cfg.MaxBackoff = cfg.BaseBackoff
+	}
+	if cfg.LockTimeout <= 0 {
+		cfg.LockTimeout = 15 * time.Minute
+	}
+
+	w := &ProcessingWorker{
+		jobs:      jobs,
+		processor: processor,
+		cfg:       cfg,
+	}
+	w.periodic = periodic{interval: cfg.PollInterval, workers: cfg.Concurrency, immediate: true, run: w.run}
+	return w
+}
+
+// run drains the queue before going back to sleep
+func (w *ProcessingWorker) run(ctx context.Context) {
+	for w.runNext(ctx) {
+	}
+}
+
+// runNext claims and runs a single job, reporting whether one was found
+func (w *ProcessingWorker) runNext(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	j, err := w.jobs.ClaimNext(ctx, w.cfg.LockTimeout)
+	if err != nil {
+		log.Printf("Processing worker: failed to claim job: %v", err)
+		return false
+	}
+	if j == nil {
+		return false
+	}
+
+	// Finish the job even if shutdown starts halfway through
+	jobCtx := context.WithoutCancel(ctx)
+
+	if err := w.processor.ProcessWallpaper(jobCtx, j.WallpaperID); err != nil {
+		w.handleFailure(jobCtx, j, err)
+		return true
+	}
+
+	if err := w.jobs.Complete(jobCtx, j.ID); err != nil {
+		log.Printf("Processing worker: failed to complete job %s: %v", j.ID, err)
+	}
+	return true
+}
+
+func (w *ProcessingWorker) handleFailure(ctx context.Context, j *job.Job, cause error) {
+	if j.Attempts >= j.MaxAttempts {
+		log.Printf("Processing worker: wallpaper %s failed after %d attempts: %v", j.WallpaperID, j.Attempts, cause)
+		if err := w.jobs.Fail(ctx, j.ID, cause.Error()); err != nil {
+			log.Printf("Processing worker: failed to mark job %s failed: %v", j.ID, err)
+		}
+		if err := w.processor.MarkProcessingFailed(ctx, j.WallpaperID); err != nil {
+			log.Printf("Processing worker: failed to mark wallpaper %s failed: %v", j.WallpaperID, err)
+		}
+		return
+	}
+
+	delay := w.backoff(j.Attempts)
+	log.Printf("Processing worker: wallpaper %s attempt %d failed, retrying in %s: %v", j.WallpaperID, j.Attempts, delay, cause)
+	if err := w.jobs.Retry(ctx, j.ID, cause.Error(), delay); err != nil {
+		log.Printf("Processing worker: failed to reschedule job %s: %v", j.ID, err)
+	}
+}
+
+// backoff doubles the delay after every attempt, capped at MaxBackoff
+func (w *ProcessingWorker) backoff(attempt int) time.Duration {
+	delay := w.cfg.BaseBackoff
+	for i := 1; i < attempt && delay < w.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > w.cfg.MaxBackoff {
+		delay = w.cfg.MaxBackoff
+	}
+	return delay
+}
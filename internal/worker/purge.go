@@ -0,0 +1,42 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// WallpaperPurger permanently removes wallpapers past their soft-delete retention
+type WallpaperPurger interface {
+	PurgeDeleted(ctx context.Context) (int, error)
+}
+
+// PurgeWorker periodically purges soft-deleted wallpapers
+type PurgeWorker struct {
+	periodic
+	purger WallpaperPurger
+}
+
+func NewPurgeWorker(purger WallpaperPurger, interval time.Duration) *PurgeWorker {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	w := &PurgeWorker{purger: purger}
+	w.periodic = periodic{interval: interval, immediate: true, run: w.run}
+	return w
+}
+
+func (w *PurgeWorker) run(ctx context.Context) {
+	// Wallpapers are purged in batches, keep going until none are left
+	for ctx.Err() == nil {
+		n, err := w.purger.PurgeDeleted(ctx)
+		if err != nil {
+			log.Printf("Purge worker: %v", err)
+			return
+		}
+		if n == 0 {
+			return
+		}
+		log.Printf("Purge worker: purged %d wallpapers", n)
+	}
+}
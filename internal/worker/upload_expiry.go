@@ -0,0 +1,42 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// UploadExpirer cleans up resumable uploads that were abandoned
+type UploadExpirer interface {
+	ExpireUploads(ctx context.Context) (int, error)
+}
+
+// UploadExpiryWorker periodically removes the chunks of expired resumable uploads
+type UploadExpiryWorker struct {
+	periodic
+	uploads UploadExpirer
+}
+
+func NewUploadExpiryWorker(uploads UploadExpirer, interval time.Duration) *UploadExpiryWorker {
+	if interval <= 0 {
+		interval = 15 * time.Minute
+	}
+	w := &UploadExpiryWorker{uploads: uploads}
+	w.periodic = periodic{interval: interval, immediate: true, run: w.run}
+	return w
+}
+
+func (w *UploadExpiryWorker) run(ctx context.Context) {
+	// Expired uploads are handled in batches, keep going until none are left
+	for ctx.Err() == nil {
+		n, err := w.uploads.ExpireUploads(ctx)
+		if err != nil {
+			log.Printf("Upload expiry worker: %v", err)
+			return
+		}
+		if n == 0 {
+			return
+		}
+		log.Printf("Upload expiry worker: expired %d uploads", n)
+	}
+}